@@ -37,4 +37,13 @@ wget -q -O geoip_IR.dat https://github.com/chocolate4u/Iran-v2ray-rules/releases
 wget -q -O geosite_IR.dat https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geosite.dat
 wget -q -O geoip_RU.dat https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geoip.dat
 wget -q -O geosite_RU.dat https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geosite.dat
+# 从源码构建带 V2Ray API 的 sing-box，官方发布的二进制无法统计 Hysteria2/TUIC 入站的流量
+SINGBOX_VERSION=$(git ls-remote --sort=-v:refname --tags --refs https://github.com/SagerNet/sing-box.git 'v*' | sed 's#.*refs/tags/##' | grep -v -- '-' | head -n 1)
+if [ -n "$SINGBOX_VERSION" ] && git clone -q --depth 1 --branch "$SINGBOX_VERSION" https://github.com/SagerNet/sing-box.git /tmp/sing-box; then
+  (cd /tmp/sing-box && CGO_ENABLED=0 go build -trimpath \
+    -tags "with_quic,with_utls,with_v2ray_api" \
+    -ldflags "-s -w -X github.com/sagernet/sing-box/constant.Version=${SINGBOX_VERSION#v}" \
+    -o "$OLDPWD/sing-box-linux-$(go env GOARCH)" ./cmd/sing-box)
+  rm -rf /tmp/sing-box
+fi
 cd ../../
@@ -24,6 +24,8 @@ type App struct {
 	LastStatus      *service.Status
 	XrayAPI         *xray.XrayAPI
 	TgBot           *service.Tgbot
+	EventHub        *service.EventHub
+	HealthService   *service.HealthService
 
 	// Repositories
 	InboundRepo  repository.InboundRepository
@@ -43,6 +45,8 @@ func NewApp(
 	tgBotService *service.Tgbot,
 	status *service.Status,
 	xrayAPI *xray.XrayAPI,
+	eventHub *service.EventHub,
+	healthService *service.HealthService,
 	inboundRepo repository.InboundRepository,
 	outboundRepo repository.OutboundRepository,
 	settingRepo repository.SettingRepository,
@@ -52,6 +56,7 @@ func NewApp(
 	inboundService.SetXrayService(xrayService)
 	inboundService.SetTelegramService(tgBotService)
 	xrayService.SetInboundService(inboundService)
+	xrayService.SetTelegramService(tgBotService)
 	serverService.SetInboundService(inboundService)
 	serverService.SetXrayService(xrayService)
 	serverService.SetTelegramService(tgBotService)
@@ -68,6 +73,8 @@ func NewApp(
 		LastStatus:      status,
 		XrayAPI:         xrayAPI,
 		TgBot:           tgBotService,
+		EventHub:        eventHub,
+		HealthService:   healthService,
 
 		InboundRepo:  inboundRepo,
 		OutboundRepo: outboundRepo,
@@ -18,6 +18,24 @@ const (
 	StateError
 )
 
+// String 返回状态的可读名称
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
 type Component interface {
 	Name() string
 	Start(ctx context.Context) error
@@ -43,6 +61,18 @@ func (m *LifecycleManager) Register(c Component) {
 	logger.Infof("[Lifecycle] Registered component: %s", c.Name())
 }
 
+// States 返回所有已注册组件的状态快照，供健康检查使用
+func (m *LifecycleManager) States() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make(map[string]string, len(m.components))
+	for _, c := range m.components {
+		states[c.Name()] = c.State().String()
+	}
+	return states
+}
+
 func (m *LifecycleManager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -3,6 +3,7 @@ package bootstrap
 import (
 	"context"
 	"log"
+	"os"
 	"sync"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"x-ui/web"
 	"x-ui/web/global"
 	"x-ui/web/job"
+	"x-ui/web/network"
 	"x-ui/web/service"
 )
 
@@ -29,11 +31,15 @@ type Runtime struct {
 
 // NewRuntime 创建运行时实例
 func NewRuntime(app *App) *Runtime {
-	return &Runtime{
+	r := &Runtime{
 		App:              app,
 		JobManager:       job.NewManager(),
 		LifecycleManager: NewLifecycleManager(),
 	}
+	if app != nil && app.HealthService != nil {
+		app.HealthService.SetComponentStateProvider(r.LifecycleManager)
+	}
+	return r
 }
 
 // JobComponent 适配器：将 job.Manager 包装为 Component
@@ -51,7 +57,12 @@ func (j *JobComponent) Stop(ctx context.Context) error {
 	j.manager.StopAll()
 	return nil
 }
-func (j *JobComponent) State() State { return StateRunning }
+func (j *JobComponent) State() State {
+	if j.manager.IsRunning() {
+		return StateRunning
+	}
+	return StateStopped
+}
 
 // InitTelegramBot 初始化 Telegram Bot 服务
 func (r *Runtime) InitTelegramBot() error {
@@ -99,6 +110,8 @@ func (r *Runtime) StartWebServer() error {
 	if r.TgBotService != nil {
 		r.WebServer.SetTelegramService(r.TgBotService)
 	}
+	r.WebServer.SetEventHub(r.App.EventHub)
+	r.WebServer.SetHealthService(r.App.HealthService)
 
 	global.SetWebServer(r.WebServer)
 	return r.WebServer.Start()
@@ -151,9 +164,13 @@ func (r *Runtime) StopAll() {
 	if r.SubServer != nil {
 		_ = r.SubServer.Stop()
 	}
+
+	network.CloseAllListeners()
 }
 
-// Restart 重启所有服务（用于 SIGHUP 信号处理）
+// Restart 平滑重启所有服务（用于 SIGHUP 信号处理）
+// 在途请求在超时时间内排空，监听套接字由新的服务器实例直接接管，
+// Xray 进程保持运行，仅在生成的配置发生变化时才重启。
 func (r *Runtime) Restart() error {
 	// 停止所有服务
 	r.JobManager.StopAll()
@@ -163,7 +180,7 @@ func (r *Runtime) Restart() error {
 	}
 
 	if r.WebServer != nil {
-		_ = r.WebServer.Stop()
+		_ = r.WebServer.StopForRestart()
 	}
 
 	if r.SubServer != nil {
@@ -193,9 +210,53 @@ func (r *Runtime) Restart() error {
 	// 重启后台任务
 	r.JobManager.StartAll()
 
+	// 关闭监听地址已变更、未被新实例接管的旧套接字
+	network.CloseIdleListeners()
+
 	return nil
 }
 
+// Upgrade 停止所有服务后用磁盘上的新二进制替换当前进程，监听套接字通过文件描述符传递给新进程，
+// 外部进程模式的 Xray 保持运行并由新进程接管。exec 成功时不会返回；失败时恢复交接前的核心状态并回退为普通的平滑重启。
+func (r *Runtime) Upgrade() error {
+	r.JobManager.StopAll()
+
+	if r.LogForwarder != nil {
+		_ = r.LogForwarder.Stop()
+	}
+
+	if r.WebServer != nil {
+		_ = r.WebServer.StopForRestart()
+	}
+
+	var env []string
+	var files []*os.File
+	if xrayEnv, xrayOutput := r.App.XrayService.HandoffXray(); xrayOutput != nil {
+		env = append(env, xrayEnv)
+		files = append(files, xrayOutput)
+	}
+	defer func() {
+		// 只有 exec 失败时才会执行到这里
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	if r.SubServer != nil {
+		_ = r.SubServer.Stop()
+	}
+
+	// 数据库连接保持打开：wire 注入的仓库持有同一个句柄，SQLite 的文件描述符带有 CLOEXEC，不会泄漏给新进程
+	err := network.ExecWithListeners(env, files...)
+	log.Printf("监听套接字交接失败，回退为普通重启: %v", err)
+
+	// 撤销交接：清除手动停止标记并重新拉起交接时停止的核心
+	if xrayErr := r.App.XrayService.CancelHandoff(); xrayErr != nil {
+		log.Printf("恢复 Xray 运行失败: %v", xrayErr)
+	}
+	return r.Restart()
+}
+
 // refreshTelegramBot 刷新 Telegram Bot 服务状态
 func (r *Runtime) refreshTelegramBot() error {
 	tgEnable, err := r.App.SettingService.GetTgbotEnabled()
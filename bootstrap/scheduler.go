@@ -18,6 +18,7 @@ func RegisterJobs(
 		tgBotService,
 		*app.SettingService,
 	)
+	checkJob.SetEventHub(app.EventHub)
 	jobManager.Register(checkJob)
 
 	// 证书监控任务
@@ -30,6 +31,7 @@ func RegisterJobs(
 		app.InboundService,
 		app.OutboundService,
 	)
+	trafficJob.SetEventHub(app.EventHub)
 	jobManager.Register(trafficJob)
 
 	// Xray 运行状态检查任务
@@ -44,5 +46,32 @@ func RegisterJobs(
 	restartJob := job.NewXrayRestartJob(app.XrayService)
 	jobManager.Register(restartJob)
 
+	// 出站健康观测任务
+	outboundHealthJob := job.NewOutboundHealthJob(app.XrayService)
+	jobManager.Register(outboundHealthJob)
+
+	// 出站订阅刷新任务
+	outboundSubJob := job.NewOutboundSubscriptionJob(
+		service.NewXraySettingService(app.SettingService),
+		app.XrayService,
+	)
+	jobManager.Register(outboundSubJob)
+
+	// 客户端访问日志采集任务
+	accessLogJob := job.NewAccessLogJob(&service.AccessLogService{SettingService: *app.SettingService})
+	jobManager.Register(accessLogJob)
+
+	// 地理数据定期更新任务
+	geoUpdateJob := job.NewGeoUpdateJob(&service.GeoDataService{SettingService: *app.SettingService}, app.XrayService)
+	jobManager.Register(geoUpdateJob)
+
+	// WARP 账户检查与轮换任务
+	warpCheckJob := job.NewWarpCheckJob(service.NewWarpService(app.SettingService), app.XrayService)
+	jobManager.Register(warpCheckJob)
+
+	// 流量配额周期重置任务
+	quotaResetJob := job.NewQuotaResetJob(app.InboundService, app.XrayService, *app.SettingService, tgBotService)
+	jobManager.Register(quotaResetJob)
+
 	return monitorJob
 }
@@ -33,8 +33,11 @@ func InitializeApp() (*App, error) {
 	inboundService := service.NewInboundService(inboundRepository, clientTrafficRepository, clientIPRepository, xrayAPI)
 	xrayService := service.NewXrayService(settingService, xrayAPI)
 	serverService := service.NewServerService()
+	clientPlanService := service.NewClientPlanService(inboundService)
 	status := service.NewStatus()
-	tgbot := service.NewTgBot(inboundService, settingService, serverService, xrayService, status)
-	app := NewApp(settingService, userService, outboundService, inboundService, xrayService, serverService, tgbot, status, xrayAPI, inboundRepository, outboundRepository, settingRepository, userRepository)
+	tgbot := service.NewTgBot(inboundService, settingService, serverService, xrayService, clientPlanService, status)
+	eventHub := service.NewEventHub()
+	healthService := service.NewHealthService(settingService, xrayService)
+	app := NewApp(settingService, userService, outboundService, inboundService, xrayService, serverService, tgbot, status, xrayAPI, eventHub, healthService, inboundRepository, outboundRepository, settingRepository, userRepository)
 	return app, nil
 }
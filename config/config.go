@@ -110,6 +110,11 @@ func GetSNIFolderPath() string {
 	return filepath.Join(getBaseDir(), "sni")
 }
 
+// GetHealthListen 返回健康检查独立监听地址，为空时不启动独立监听
+func GetHealthListen() string {
+	return viper.GetString("health.listen")
+}
+
 func copyFile(src, dst string) error {
 	//nolint:gosec
 	in, err := os.Open(src)
@@ -48,6 +48,9 @@ const (
 const (
 	// HTTPSRedirectDelay HTTPS 重定向后关闭连接的延时
 	HTTPSRedirectDelay = 500 * time.Millisecond
+
+	// PanelDrainTimeout 面板/订阅服务器停止时排空在途请求的最长等待时间
+	PanelDrainTimeout = 10 * time.Second
 )
 
 // =================================================================
@@ -61,3 +64,69 @@ const (
 	// LogFlushInterval 日志刷新间隔
 	LogFlushInterval = 5 * time.Second
 )
+
+// =================================================================
+// Xray 相关常量
+// =================================================================
+
+const (
+	// XrayConfigTestTimeout 使用 Xray 二进制测试模式校验配置的超时时间
+	XrayConfigTestTimeout = 20 * time.Second
+
+	// XrayStartupCheckPeriod 启动后观察 Xray 进程是否立即退出的时间窗口
+	XrayStartupCheckPeriod = 1500 * time.Millisecond
+
+	// OutboundHealthHistorySize 每个出站保留的延迟历史采样数
+	OutboundHealthHistorySize = 120
+
+	// XrayRestartBackoffBase Xray 崩溃后首次自动重启前的等待时间，之后每次失败翻倍
+	XrayRestartBackoffBase = 2 * time.Second
+
+	// XrayRestartBackoffMax 自动重启等待时间的上限
+	XrayRestartBackoffMax = 5 * time.Minute
+
+	// XrayStablePeriod Xray 连续运行超过该时间后重置退避计数
+	XrayStablePeriod = time.Minute
+
+	// XrayCrashLoopThreshold 在 XrayCrashLoopWindow 内崩溃达到该次数即判定为崩溃循环，停止自动重启
+	XrayCrashLoopThreshold = 5
+
+	// XrayCrashLoopWindow 统计崩溃次数的时间窗口
+	XrayCrashLoopWindow = 10 * time.Minute
+
+	// XrayCrashReportRetention 最多保留的崩溃报告数量
+	XrayCrashReportRetention = 20
+
+	// XrayCrashLogTailLines 崩溃报告中保留的 Xray 最后输出行数
+	XrayCrashLogTailLines = 50
+)
+
+// =================================================================
+// sing-box 相关常量
+// =================================================================
+
+const (
+	// SingBoxAPIPort sing-box V2Ray API（流量统计）监听的本地端口
+	SingBoxAPIPort = 62790
+
+	// SingBoxConfigTestTimeout 使用 sing-box check 校验配置的超时时间
+	SingBoxConfigTestTimeout = 20 * time.Second
+)
+
+// =================================================================
+// 访问日志相关常量
+// =================================================================
+
+const (
+	// AccessLogBatchSize 访问记录批量写入数据库的条数
+	AccessLogBatchSize = 500
+
+	// AccessLogFlushInterval 未满一批时写入数据库的最长间隔
+	AccessLogFlushInterval = 5 * time.Second
+
+	// AccessLogPruneInterval 清理过期访问记录的间隔
+	AccessLogPruneInterval = time.Hour
+
+	// AccessLogMaxEntries 最多保留的访问记录条数，超出时删除最旧的记录
+	AccessLogMaxEntries = 1000000
+)
@@ -40,6 +40,7 @@ func RefreshEnvConfig() {
 	viper.Set("paths.db_folder", os.Getenv("XUI_DB_FOLDER"))
 	viper.Set("paths.log_folder", os.Getenv("XUI_LOG_FOLDER"))
 	viper.Set("paths.sni_folder", os.Getenv("XUI_SNI_FOLDER"))
+	viper.Set("health.listen", os.Getenv("XUI_HEALTH_LISTEN"))
 }
 
 // setStaticDefaults 设置静态配置的默认值
@@ -53,6 +54,9 @@ func setStaticDefaults() {
 	viper.SetDefault("paths.bin_folder", "bin")
 	viper.SetDefault("paths.sni_folder", "sni")
 
+	// 健康检查默认值（为空表示仅在面板监听地址上提供）
+	viper.SetDefault("health.listen", "")
+
 	// 平台特定默认值
 	if runtime.GOOS == "windows" {
 		viper.SetDefault("paths.db_folder", getBaseDir())
@@ -38,10 +38,19 @@ func initModels() error {
 		&model.User{},
 		&model.Inbound{},
 		&model.OutboundTraffics{},
+		&model.OutboundSubscription{},
+		&model.GeoSource{},
+		&model.WarpAccount{},
 		&model.Setting{},
 		&model.InboundClientIps{},
 		&xray.ClientTraffic{},
 		&model.HistoryOfSeeders{},
+		&model.XrayConfigRevision{},
+		&model.AccessLogEntry{},
+		&model.DeviceLimitBan{},
+		&model.DeviceLimitWhitelist{},
+		&model.QuotaUsageArchive{},
+		&model.ClientPlan{},
 		&LinkHistory{}, // 把 LinkHistory 表也迁移
 	}
 	for _, model := range models {
@@ -0,0 +1,21 @@
+package model
+
+// AccessLogEntry 一条客户端访问记录，由 Xray 访问日志解析而来
+type AccessLogEntry struct {
+	Id       int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Time     int64  `json:"time" gorm:"index;index:idx_access_email_time,priority:2"`
+	Email    string `json:"email" gorm:"index:idx_access_email_time,priority:1"`
+	SourceIp string `json:"sourceIp"`
+	Network  string `json:"network"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Inbound  string `json:"inbound"`
+	Outbound string `json:"outbound"`
+}
+
+// AccessDestinationStat 按目标域名聚合的访问统计
+type AccessDestinationStat struct {
+	Host     string `json:"host"`
+	Count    int64  `json:"count"`
+	LastSeen int64  `json:"lastSeen"`
+}
@@ -20,6 +20,19 @@ type Client struct {
 	Reset      int    `json:"reset" form:"reset"`
 	CreatedAt  int64  `json:"created_at,omitempty"`
 	UpdatedAt  int64  `json:"updated_at,omitempty"`
+
+	// ResetSchedule 按日历周期重置流量配额，与 Reset（到期后续期的天数）无关，格式见 service.ParseQuotaResetSchedule
+	ResetSchedule string `json:"resetSchedule,omitempty" form:"resetSchedule"`
+
+	// PlanId 客户端订阅的套餐，0 表示未使用套餐，限制字段由套餐统一管理
+	PlanId int `json:"planId,omitempty" form:"planId"`
+
+	// WireGuard peer 字段，仅 wireguard 入站使用
+	PrivateKey   string   `json:"privateKey,omitempty"`
+	PublicKey    string   `json:"publicKey,omitempty"`
+	PreSharedKey string   `json:"preSharedKey,omitempty"`
+	AllowedIPs   []string `json:"allowedIPs,omitempty"`
+	KeepAlive    int      `json:"keepAlive,omitempty"`
 }
 
 type VLESSSettings struct {
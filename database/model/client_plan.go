@@ -0,0 +1,17 @@
+package model
+
+// ClientPlan 客户端套餐，打包客户端的流量、有效期和限速等限制。
+// 客户端通过 Client.PlanId 关联套餐，套餐修改后可重新应用到所有订阅客户端
+type ClientPlan struct {
+	Id            int    `json:"id" form:"id" gorm:"primaryKey;autoIncrement"`
+	Name          string `json:"name" form:"name" gorm:"unique;not null"`
+	TotalGB       int64  `json:"totalGB" form:"totalGB"`       // 流量配额，单位字节，0 表示不限
+	ExpiryDays    int    `json:"expiryDays" form:"expiryDays"` // 有效天数，从套餐应用时开始计算，0 表示永不过期
+	LimitIP       int    `json:"limitIp" form:"limitIp"`
+	SpeedLimit    int    `json:"speedLimit" form:"speedLimit"` // 单位 KB/s，0 表示不限速
+	Reset         int    `json:"reset" form:"reset"`
+	ResetSchedule string `json:"resetSchedule" form:"resetSchedule"`
+	Flow          string `json:"flow" form:"flow"` // 仅 VLESS 客户端使用
+	CreatedAt     int64  `json:"createdAt"`
+	UpdatedAt     int64  `json:"updatedAt"`
+}
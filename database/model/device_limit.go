@@ -0,0 +1,24 @@
+package model
+
+// DeviceLimitBan 一次设备超限处理记录。UnbannedAt 为 0 表示处理仍在生效，
+// 同一客户端的全部记录构成其封禁历史
+type DeviceLimitBan struct {
+	Id          int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email       string `json:"email" gorm:"index;not null"`
+	InboundTag  string `json:"inboundTag"`
+	Policy      string `json:"policy"`
+	Reason      string `json:"reason"`
+	Limit       int    `json:"limit"`
+	ActiveIPs   int    `json:"activeIps"`
+	IPs         string `json:"ips"` // JSON 数组：处理时的在线 IP，rejectNewest 策略下为被拒绝的 IP
+	BannedAt    int64  `json:"bannedAt"`
+	UnbannedAt  int64  `json:"unbannedAt" gorm:"index;default:0"`
+	UnbanReason string `json:"unbanReason"`
+}
+
+// DeviceLimitWhitelist 不受设备数限制的客户端
+type DeviceLimitWhitelist struct {
+	Id        int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email     string `json:"email" gorm:"unique;not null"`
+	CreatedAt int64  `json:"createdAt"`
+}
@@ -0,0 +1,24 @@
+package model
+
+// GeoSource 地理数据文件来源。Url 为空时只能手动上传；
+// Format 为 list 时内容是纯文本的域名或 CIDR 列表，编译为只包含 Code 一个条目的 .dat 文件，
+// 路由规则中以 ext:FileName:Code 引用
+type GeoSource struct {
+	Id          int    `json:"id" form:"id" gorm:"primaryKey;autoIncrement"`
+	FileName    string `json:"fileName" form:"fileName" gorm:"unique;not null"`
+	Type        string `json:"type" form:"type" gorm:"not null"`
+	Format      string `json:"format" form:"format" gorm:"default:dat"`
+	Code        string `json:"code" form:"code"`
+	Url         string `json:"url" form:"url"`
+	ChecksumUrl string `json:"checksumUrl" form:"checksumUrl"`            // 发布方提供的 sha256 校验文件地址
+	Sha256      string `json:"sha256" form:"sha256"`                      // 固定的期望校验和，优先于 ChecksumUrl
+	Interval    int    `json:"interval" form:"interval" gorm:"default:0"` // 自动更新间隔（小时），0 表示只手动更新
+	Enable      bool   `json:"enable" form:"enable"`
+	Builtin     bool   `json:"builtin" form:"builtin"`
+	FileSha256  string `json:"fileSha256" form:"fileSha256"` // 当前安装文件的校验和
+	Size        int64  `json:"size" form:"size" gorm:"default:0"`
+	LastUpdate  int64  `json:"lastUpdate" form:"lastUpdate" gorm:"default:0"` // 文件最近一次变化的时间
+	LastCheck   int64  `json:"lastCheck" form:"lastCheck" gorm:"default:0"`
+	LastError   string `json:"lastError" form:"lastError"`
+	HasBackup   bool   `json:"hasBackup" form:"hasBackup" gorm:"-"`
+}
@@ -22,6 +22,10 @@ type Inbound struct {
 	// gorm:"column:device_limit;default:0" 定义了数据库中的字段名和默认值。
 	DeviceLimit int `json:"deviceLimit" form:"deviceLimit" gorm:"column:device_limit;default:0"`
 
+	// ResetSchedule 按日历周期重置入站流量配额，LastReset 为上次重置（或开始计算周期）的毫秒时间戳
+	ResetSchedule string `json:"resetSchedule" form:"resetSchedule"`
+	LastReset     int64  `json:"lastReset" form:"lastReset" gorm:"default:0"`
+
 	ClientStats []xray.ClientTraffic `gorm:"foreignKey:InboundId;references:Id" json:"clientStats" form:"clientStats"`
 
 	// config part
@@ -7,3 +7,18 @@ type OutboundTraffics struct {
 	Down  int64  `json:"down" form:"down" gorm:"default:0"`
 	Total int64  `json:"total" form:"total" gorm:"default:0"`
 }
+
+// OutboundSubscription 出站订阅，定期拉取远程订阅并将其中的节点同步为带 TagPrefix 前缀的出站。
+// 出站的归属以 Tags 中记录的标签为准，不按前缀推断，避免误删手动添加或其他订阅的出站
+type OutboundSubscription struct {
+	Id         int    `json:"id" form:"id" gorm:"primaryKey;autoIncrement"`
+	Remark     string `json:"remark" form:"remark"`
+	Url        string `json:"url" form:"url" gorm:"not null"`
+	TagPrefix  string `json:"tagPrefix" form:"tagPrefix" gorm:"unique;not null"`
+	Interval   int    `json:"interval" form:"interval" gorm:"default:0"` // 刷新间隔（分钟），0 表示只手动刷新
+	Enable     bool   `json:"enable" form:"enable"`
+	LastUpdate int64  `json:"lastUpdate" form:"lastUpdate" gorm:"default:0"`
+	LastError  string `json:"lastError" form:"lastError"`
+	Count      int    `json:"count" form:"count" gorm:"default:0"`
+	Tags       string `json:"tags" form:"-"` // 订阅生成的出站标签（JSON 数组）
+}
@@ -12,4 +12,8 @@ const (
 	Socks       Protocol = "socks"
 	WireGuard   Protocol = "wireguard"
 	TUN         Protocol = "tun"
+
+	// 以下协议仅 sing-box 后端支持
+	Hysteria2 Protocol = "hysteria2"
+	TUIC      Protocol = "tuic"
 )
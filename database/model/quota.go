@@ -0,0 +1,13 @@
+package model
+
+// QuotaUsageArchive 按周期重置配额时归档的上一周期用量。Email 为空表示入站配额，否则为客户端配额
+type QuotaUsageArchive struct {
+	Id          int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	InboundId   int    `json:"inboundId" gorm:"index"`
+	Email       string `json:"email" gorm:"index"`
+	Up          int64  `json:"up"`
+	Down        int64  `json:"down"`
+	Total       int64  `json:"total"`
+	PeriodStart int64  `json:"periodStart"` // 毫秒时间戳
+	PeriodEnd   int64  `json:"periodEnd"`
+}
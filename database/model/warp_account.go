@@ -0,0 +1,26 @@
+package model
+
+// WarpAccount Cloudflare WARP 注册账户。OutboundTag 为当前使用该账户的 WireGuard 出站，
+// 为空表示账户空闲，可在出站的账户失效时用于轮换
+type WarpAccount struct {
+	Id            int    `json:"id" form:"id" gorm:"primaryKey;autoIncrement"`
+	Name          string `json:"name" form:"name" gorm:"unique;not null"`
+	DeviceId      string `json:"deviceId" form:"deviceId"`
+	AccessToken   string `json:"accessToken" form:"accessToken"`
+	PrivateKey    string `json:"privateKey" form:"privateKey"`
+	ClientId      string `json:"clientId" form:"clientId"` // base64 编码，解码后作为 WireGuard reserved 字段
+	AddressV4     string `json:"addressV4" form:"addressV4"`
+	AddressV6     string `json:"addressV6" form:"addressV6"`
+	PeerPublicKey string `json:"peerPublicKey" form:"peerPublicKey"`
+	LicenseKey    string `json:"licenseKey" form:"licenseKey"`
+	AccountType   string `json:"accountType" form:"accountType"` // free、limited（WARP+）等
+	Quota         int64  `json:"quota" form:"quota" gorm:"default:0"`
+	Enable        bool   `json:"enable" form:"enable"`
+	OutboundTag   string `json:"outboundTag" form:"outboundTag"`
+	Healthy       bool   `json:"healthy" form:"healthy"`
+	Failures      int    `json:"failures" form:"failures" gorm:"default:0"` // 连续检查失败次数
+	// TunnelFailures 观测服务连续判定出站不可用的次数，与账户 API 检查结果无关
+	TunnelFailures int    `json:"tunnelFailures" form:"tunnelFailures" gorm:"default:0"`
+	LastCheck      int64  `json:"lastCheck" form:"lastCheck" gorm:"default:0"`
+	LastError      string `json:"lastError" form:"lastError"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// XrayConfigRevision Xray 配置模板的历史版本，每次保存模板都会记录一条
+type XrayConfigRevision struct {
+	Id        int    `json:"id" form:"id" gorm:"primaryKey;autoIncrement"`
+	Config    string `json:"config,omitempty" form:"config" gorm:"type:text;not null"`
+	Author    string `json:"author" form:"author"`
+	Comment   string `json:"comment" form:"comment"`
+	RevertOf  int    `json:"revertOf" form:"revertOf" gorm:"default:0"`
+	Size      int    `json:"size" form:"size"`
+	CreatedAt int64  `json:"createdAt" form:"createdAt" gorm:"index"`
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// AccessLogQuery 访问记录查询条件，零值字段不参与过滤
+type AccessLogQuery struct {
+	Email string
+	Host  string
+	Since int64
+	Until int64
+	Limit int
+}
+
+// AccessLogRepository 定义客户端访问记录的数据访问接口
+type AccessLogRepository interface {
+	CreateBatch(entries []*model.AccessLogEntry) error
+	Find(query *AccessLogQuery) ([]*model.AccessLogEntry, error)
+	TopDestinations(email string, since int64, limit int) ([]*model.AccessDestinationStat, error)
+	DeleteBefore(time int64) (int64, error)
+	Prune(keep int64) (int64, error)
+	DeleteByEmail(email string) error
+
+	GetDB() *gorm.DB
+}
+
+// accessLogRepository 实现 AccessLogRepository 接口
+type accessLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAccessLogRepository 创建新的 AccessLogRepository 实例
+func NewAccessLogRepository(db *gorm.DB) AccessLogRepository {
+	return &accessLogRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *accessLogRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// CreateBatch 批量写入访问记录
+func (r *accessLogRepository) CreateBatch(entries []*model.AccessLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(entries, 100).Error
+}
+
+// Find 按条件查询访问记录，按时间倒序
+func (r *accessLogRepository) Find(query *AccessLogQuery) ([]*model.AccessLogEntry, error) {
+	var entries []*model.AccessLogEntry
+	db := r.db.Model(model.AccessLogEntry{})
+	if query.Email != "" {
+		db = db.Where("email = ?", query.Email)
+	}
+	if query.Host != "" {
+		db = db.Where("host = ?", query.Host)
+	}
+	if query.Since > 0 {
+		db = db.Where("time >= ?", query.Since)
+	}
+	if query.Until > 0 {
+		db = db.Where("time < ?", query.Until)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if err := db.Order("time desc, id desc").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// TopDestinations 统计客户端自 since 起访问次数最多的目标
+func (r *accessLogRepository) TopDestinations(email string, since int64, limit int) ([]*model.AccessDestinationStat, error) {
+	var stats []*model.AccessDestinationStat
+	db := r.db.Model(model.AccessLogEntry{}).
+		Select("host, COUNT(*) AS count, MAX(time) AS last_seen").
+		Where("email = ? AND time >= ?", email, since).
+		Group("host").
+		Order("count desc, host")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// DeleteBefore 删除早于指定时间的访问记录
+func (r *accessLogRepository) DeleteBefore(time int64) (int64, error) {
+	result := r.db.Where("time < ?", time).Delete(model.AccessLogEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// Prune 按自增 ID 仅保留最新的约 keep 条访问记录，避免对大表计数
+func (r *accessLogRepository) Prune(keep int64) (int64, error) {
+	var maxId int64
+	if err := r.db.Model(model.AccessLogEntry{}).Select("COALESCE(MAX(id), 0)").Scan(&maxId).Error; err != nil {
+		return 0, err
+	}
+	if maxId <= keep {
+		return 0, nil
+	}
+	result := r.db.Where("id <= ?", maxId-keep).Delete(model.AccessLogEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByEmail 删除指定客户端的全部访问记录
+func (r *accessLogRepository) DeleteByEmail(email string) error {
+	return r.db.Where("email = ?", email).Delete(model.AccessLogEntry{}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewAccessLogRepository(database.GetDB())
+
+	entries := []*model.AccessLogEntry{
+		{Time: 100, Email: "a@x", Host: "example.com", Port: 443},
+		{Time: 200, Email: "a@x", Host: "example.com", Port: 443},
+		{Time: 300, Email: "a@x", Host: "google.com", Port: 443},
+		{Time: 400, Email: "b@x", Host: "example.com", Port: 80},
+		{Time: 50, Email: "a@x", Host: "old.com", Port: 80},
+	}
+	assert.NoError(t, repo.CreateBatch(entries))
+
+	top, err := repo.TopDestinations("a@x", 100, 10)
+	assert.NoError(t, err)
+	assert.Len(t, top, 2)
+	assert.Equal(t, "example.com", top[0].Host)
+	assert.Equal(t, int64(2), top[0].Count)
+	assert.Equal(t, int64(200), top[0].LastSeen)
+
+	found, err := repo.Find(&AccessLogQuery{Email: "a@x", Since: 100, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Equal(t, int64(300), found[0].Time)
+
+	deleted, err := repo.DeleteBefore(100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = repo.Prune(2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	found, err = repo.Find(&AccessLogQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	assert.NoError(t, repo.DeleteByEmail("b@x"))
+	found, err = repo.Find(&AccessLogQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+}
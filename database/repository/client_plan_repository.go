@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// ClientPlanRepository 定义客户端套餐的数据访问接口
+type ClientPlanRepository interface {
+	FindAll() ([]*model.ClientPlan, error)
+	FindById(id int) (*model.ClientPlan, error)
+	CheckNameExist(name string, ignoreId int) (bool, error)
+	Create(plan *model.ClientPlan) error
+	Update(plan *model.ClientPlan) error
+	Delete(id int) error
+
+	GetDB() *gorm.DB
+}
+
+// clientPlanRepository 实现 ClientPlanRepository 接口
+type clientPlanRepository struct {
+	db *gorm.DB
+}
+
+// NewClientPlanRepository 创建新的 ClientPlanRepository 实例
+func NewClientPlanRepository(db *gorm.DB) ClientPlanRepository {
+	return &clientPlanRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *clientPlanRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// FindAll 按 ID 顺序查找所有套餐
+func (r *clientPlanRepository) FindAll() ([]*model.ClientPlan, error) {
+	var plans []*model.ClientPlan
+	err := r.db.Model(model.ClientPlan{}).Order("id asc").Find(&plans).Error
+	if err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// FindById 根据 ID 查找套餐
+func (r *clientPlanRepository) FindById(id int) (*model.ClientPlan, error) {
+	plan := &model.ClientPlan{}
+	err := r.db.Model(model.ClientPlan{}).First(plan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// CheckNameExist 检查套餐名称是否已被其他套餐使用
+func (r *clientPlanRepository) CheckNameExist(name string, ignoreId int) (bool, error) {
+	var count int64
+	query := r.db.Model(model.ClientPlan{}).Where("name = ?", name)
+	if ignoreId > 0 {
+		query = query.Where("id != ?", ignoreId)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Create 创建套餐
+func (r *clientPlanRepository) Create(plan *model.ClientPlan) error {
+	return r.db.Create(plan).Error
+}
+
+// Update 更新套餐
+func (r *clientPlanRepository) Update(plan *model.ClientPlan) error {
+	return r.db.Save(plan).Error
+}
+
+// Delete 删除套餐
+func (r *clientPlanRepository) Delete(id int) error {
+	return r.db.Delete(model.ClientPlan{}, id).Error
+}
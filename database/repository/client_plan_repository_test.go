@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPlanRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewClientPlanRepository(database.GetDB())
+
+	basic := &model.ClientPlan{Name: "basic", TotalGB: 10 << 30, ExpiryDays: 30}
+	assert.NoError(t, repo.Create(basic))
+	assert.NoError(t, repo.Create(&model.ClientPlan{Name: "pro", LimitIP: 3}))
+	assert.Error(t, repo.Create(&model.ClientPlan{Name: "basic"}))
+
+	exist, err := repo.CheckNameExist("basic", 0)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = repo.CheckNameExist("basic", basic.Id)
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	basic.LimitIP = 2
+	assert.NoError(t, repo.Update(basic))
+	plan, err := repo.FindById(basic.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, plan.LimitIP)
+	assert.Equal(t, 30, plan.ExpiryDays)
+
+	assert.NoError(t, repo.Delete(basic.Id))
+	plans, err := repo.FindAll()
+	assert.NoError(t, err)
+	assert.Len(t, plans, 1)
+	assert.Equal(t, "pro", plans[0].Name)
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// DeviceLimitRepository 定义设备超限处理记录和白名单的数据访问接口
+type DeviceLimitRepository interface {
+	FindActiveBans() ([]*model.DeviceLimitBan, error)
+	FindActiveBan(email string) (*model.DeviceLimitBan, error)
+	FindBanHistory(email string, limit int) ([]*model.DeviceLimitBan, error)
+	CreateBan(ban *model.DeviceLimitBan) error
+	UpdateBan(ban *model.DeviceLimitBan) error
+	CloseActiveBans(email string, reason string, at int64) (int64, error)
+
+	FindWhitelist() ([]*model.DeviceLimitWhitelist, error)
+	AddWhitelist(entry *model.DeviceLimitWhitelist) error
+	RemoveWhitelist(email string) (int64, error)
+
+	GetDB() *gorm.DB
+}
+
+// deviceLimitRepository 实现 DeviceLimitRepository 接口
+type deviceLimitRepository struct {
+	db *gorm.DB
+}
+
+// NewDeviceLimitRepository 创建新的 DeviceLimitRepository 实例
+func NewDeviceLimitRepository(db *gorm.DB) DeviceLimitRepository {
+	return &deviceLimitRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *deviceLimitRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// FindActiveBans 查找所有仍在生效的处理记录
+func (r *deviceLimitRepository) FindActiveBans() ([]*model.DeviceLimitBan, error) {
+	var bans []*model.DeviceLimitBan
+	err := r.db.Model(model.DeviceLimitBan{}).Where("unbanned_at = 0").Order("banned_at asc").Find(&bans).Error
+	if err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// FindActiveBan 查找客户端仍在生效的处理记录
+func (r *deviceLimitRepository) FindActiveBan(email string) (*model.DeviceLimitBan, error) {
+	ban := &model.DeviceLimitBan{}
+	err := r.db.Model(model.DeviceLimitBan{}).Where("email = ? AND unbanned_at = 0", email).Order("id desc").First(ban).Error
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// FindBanHistory 按时间倒序查找客户端的处理记录，limit 为 0 时不限制条数
+func (r *deviceLimitRepository) FindBanHistory(email string, limit int) ([]*model.DeviceLimitBan, error) {
+	var bans []*model.DeviceLimitBan
+	query := r.db.Model(model.DeviceLimitBan{}).Where("email = ?", email).Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&bans).Error; err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// CreateBan 创建处理记录
+func (r *deviceLimitRepository) CreateBan(ban *model.DeviceLimitBan) error {
+	return r.db.Create(ban).Error
+}
+
+// UpdateBan 更新处理记录
+func (r *deviceLimitRepository) UpdateBan(ban *model.DeviceLimitBan) error {
+	return r.db.Save(ban).Error
+}
+
+// CloseActiveBans 结束客户端仍在生效的处理记录，返回受影响的记录数
+func (r *deviceLimitRepository) CloseActiveBans(email string, reason string, at int64) (int64, error) {
+	result := r.db.Model(model.DeviceLimitBan{}).
+		Where("email = ? AND unbanned_at = 0", email).
+		Updates(map[string]any{"unbanned_at": at, "unban_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// FindWhitelist 查找所有白名单客户端
+func (r *deviceLimitRepository) FindWhitelist() ([]*model.DeviceLimitWhitelist, error) {
+	var entries []*model.DeviceLimitWhitelist
+	err := r.db.Model(model.DeviceLimitWhitelist{}).Order("id asc").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// AddWhitelist 添加白名单客户端
+func (r *deviceLimitRepository) AddWhitelist(entry *model.DeviceLimitWhitelist) error {
+	return r.db.Create(entry).Error
+}
+
+// RemoveWhitelist 移除白名单客户端，返回受影响的记录数
+func (r *deviceLimitRepository) RemoveWhitelist(email string) (int64, error) {
+	result := r.db.Where("email = ?", email).Delete(model.DeviceLimitWhitelist{})
+	return result.RowsAffected, result.Error
+}
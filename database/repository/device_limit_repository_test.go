@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceLimitRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewDeviceLimitRepository(database.GetDB())
+
+	assert.NoError(t, repo.CreateBan(&model.DeviceLimitBan{Email: "a@x", Policy: "kickAll", BannedAt: 100, UnbannedAt: 150}))
+	assert.NoError(t, repo.CreateBan(&model.DeviceLimitBan{Email: "a@x", Policy: "kickAll", BannedAt: 200}))
+	assert.NoError(t, repo.CreateBan(&model.DeviceLimitBan{Email: "b@x", Policy: "throttle", BannedAt: 300}))
+
+	active, err := repo.FindActiveBans()
+	assert.NoError(t, err)
+	assert.Len(t, active, 2)
+	assert.Equal(t, "a@x", active[0].Email)
+
+	ban, err := repo.FindActiveBan("a@x")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), ban.BannedAt)
+
+	closed, err := repo.CloseActiveBans("a@x", "manual", 250)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), closed)
+	_, err = repo.FindActiveBan("a@x")
+	assert.Error(t, err)
+
+	history, err := repo.FindBanHistory("a@x", 0)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "manual", history[0].UnbanReason)
+	history, err = repo.FindBanHistory("a@x", 1)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	assert.NoError(t, repo.AddWhitelist(&model.DeviceLimitWhitelist{Email: "c@x", CreatedAt: 1}))
+	// email 唯一
+	assert.Error(t, repo.AddWhitelist(&model.DeviceLimitWhitelist{Email: "c@x"}))
+	entries, err := repo.FindWhitelist()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	removed, err := repo.RemoveWhitelist("c@x")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// GeoSourceRepository 定义地理数据来源的数据访问接口
+type GeoSourceRepository interface {
+	FindAll() ([]*model.GeoSource, error)
+	FindByID(id int) (*model.GeoSource, error)
+	FindByFileName(fileName string) (*model.GeoSource, error)
+	Count() (int64, error)
+	Create(source *model.GeoSource) error
+	Update(source *model.GeoSource) error
+	Delete(id int) error
+
+	GetDB() *gorm.DB
+}
+
+// geoSourceRepository 实现 GeoSourceRepository 接口
+type geoSourceRepository struct {
+	db *gorm.DB
+}
+
+// NewGeoSourceRepository 创建新的 GeoSourceRepository 实例
+func NewGeoSourceRepository(db *gorm.DB) GeoSourceRepository {
+	return &geoSourceRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *geoSourceRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// FindAll 查找所有地理数据来源
+func (r *geoSourceRepository) FindAll() ([]*model.GeoSource, error) {
+	var sources []*model.GeoSource
+	err := r.db.Model(model.GeoSource{}).Order("id asc").Find(&sources).Error
+	if err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// FindByID 根据 ID 查找地理数据来源
+func (r *geoSourceRepository) FindByID(id int) (*model.GeoSource, error) {
+	source := &model.GeoSource{}
+	err := r.db.Model(model.GeoSource{}).Where("id = ?", id).First(source).Error
+	if err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// FindByFileName 根据文件名查找地理数据来源
+func (r *geoSourceRepository) FindByFileName(fileName string) (*model.GeoSource, error) {
+	source := &model.GeoSource{}
+	err := r.db.Model(model.GeoSource{}).Where("file_name = ?", fileName).First(source).Error
+	if err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// Count 返回地理数据来源数量
+func (r *geoSourceRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(model.GeoSource{}).Count(&count).Error
+	return count, err
+}
+
+// Create 创建新的地理数据来源
+func (r *geoSourceRepository) Create(source *model.GeoSource) error {
+	return r.db.Create(source).Error
+}
+
+// Update 更新地理数据来源
+func (r *geoSourceRepository) Update(source *model.GeoSource) error {
+	return r.db.Save(source).Error
+}
+
+// Delete 删除地理数据来源
+func (r *geoSourceRepository) Delete(id int) error {
+	return r.db.Where("id = ?", id).Delete(model.GeoSource{}).Error
+}
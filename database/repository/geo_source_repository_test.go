@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoSourceRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewGeoSourceRepository(database.GetDB())
+
+	source := &model.GeoSource{FileName: "geosite_custom.dat", Type: "geosite", Format: "list", Code: "CUSTOM", Interval: 24}
+	assert.NoError(t, repo.Create(source))
+	assert.NotZero(t, source.Id)
+
+	// 文件名唯一
+	assert.Error(t, repo.Create(&model.GeoSource{FileName: "geosite_custom.dat", Type: "geosite"}))
+
+	found, err := repo.FindByFileName("geosite_custom.dat")
+	assert.NoError(t, err)
+	assert.Equal(t, source.Id, found.Id)
+	assert.Equal(t, "CUSTOM", found.Code)
+
+	found.FileSha256 = "abc"
+	found.Size = 42
+	assert.NoError(t, repo.Update(found))
+	found, err = repo.FindByID(source.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", found.FileSha256)
+	assert.Equal(t, int64(42), found.Size)
+
+	count, err := repo.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, repo.Delete(source.Id))
+	all, err := repo.FindAll()
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
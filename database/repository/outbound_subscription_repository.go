@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// OutboundSubscriptionRepository 定义出站订阅的数据访问接口
+type OutboundSubscriptionRepository interface {
+	FindAll() ([]*model.OutboundSubscription, error)
+	FindByID(id int) (*model.OutboundSubscription, error)
+	FindByTagPrefix(prefix string) (*model.OutboundSubscription, error)
+	Create(sub *model.OutboundSubscription) error
+	Update(sub *model.OutboundSubscription) error
+	Delete(id int) error
+
+	GetDB() *gorm.DB
+}
+
+// outboundSubscriptionRepository 实现 OutboundSubscriptionRepository 接口
+type outboundSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboundSubscriptionRepository 创建新的 OutboundSubscriptionRepository 实例
+func NewOutboundSubscriptionRepository(db *gorm.DB) OutboundSubscriptionRepository {
+	return &outboundSubscriptionRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *outboundSubscriptionRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// FindAll 查找所有出站订阅
+func (r *outboundSubscriptionRepository) FindAll() ([]*model.OutboundSubscription, error) {
+	var subs []*model.OutboundSubscription
+	err := r.db.Model(model.OutboundSubscription{}).Order("id asc").Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// FindByID 根据 ID 查找出站订阅
+func (r *outboundSubscriptionRepository) FindByID(id int) (*model.OutboundSubscription, error) {
+	sub := &model.OutboundSubscription{}
+	err := r.db.Model(model.OutboundSubscription{}).Where("id = ?", id).First(sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// FindByTagPrefix 根据标签前缀查找出站订阅
+func (r *outboundSubscriptionRepository) FindByTagPrefix(prefix string) (*model.OutboundSubscription, error) {
+	sub := &model.OutboundSubscription{}
+	err := r.db.Model(model.OutboundSubscription{}).Where("tag_prefix = ?", prefix).First(sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Create 创建新的出站订阅
+func (r *outboundSubscriptionRepository) Create(sub *model.OutboundSubscription) error {
+	return r.db.Create(sub).Error
+}
+
+// Update 更新出站订阅
+func (r *outboundSubscriptionRepository) Update(sub *model.OutboundSubscription) error {
+	return r.db.Save(sub).Error
+}
+
+// Delete 删除出站订阅
+func (r *outboundSubscriptionRepository) Delete(id int) error {
+	return r.db.Where("id = ?", id).Delete(model.OutboundSubscription{}).Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboundSubscriptionRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewOutboundSubscriptionRepository(database.GetDB())
+
+	sub := &model.OutboundSubscription{Remark: "airport", Url: "https://example.com/sub", TagPrefix: "air", Interval: 60}
+	assert.NoError(t, repo.Create(sub))
+	assert.NotZero(t, sub.Id)
+	assert.False(t, sub.Enable)
+
+	// 标签前缀唯一
+	assert.Error(t, repo.Create(&model.OutboundSubscription{Url: "https://example.com/other", TagPrefix: "air"}))
+
+	found, err := repo.FindByTagPrefix("air")
+	assert.NoError(t, err)
+	assert.Equal(t, sub.Id, found.Id)
+
+	found.Enable = true
+	found.Count = 3
+	assert.NoError(t, repo.Update(found))
+	found, err = repo.FindByID(sub.Id)
+	assert.NoError(t, err)
+	assert.True(t, found.Enable)
+	assert.Equal(t, 3, found.Count)
+
+	all, err := repo.FindAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	assert.NoError(t, repo.Delete(sub.Id))
+	_, err = repo.FindByID(sub.Id)
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// WarpAccountRepository 定义 WARP 账户的数据访问接口
+type WarpAccountRepository interface {
+	FindAll() ([]*model.WarpAccount, error)
+	FindByID(id int) (*model.WarpAccount, error)
+	FindByName(name string) (*model.WarpAccount, error)
+	FindByOutboundTag(tag string) (*model.WarpAccount, error)
+	Count() (int64, error)
+	Create(account *model.WarpAccount) error
+	Update(account *model.WarpAccount) error
+	Delete(id int) error
+
+	GetDB() *gorm.DB
+}
+
+// warpAccountRepository 实现 WarpAccountRepository 接口
+type warpAccountRepository struct {
+	db *gorm.DB
+}
+
+// NewWarpAccountRepository 创建新的 WarpAccountRepository 实例
+func NewWarpAccountRepository(db *gorm.DB) WarpAccountRepository {
+	return &warpAccountRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *warpAccountRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// FindAll 查找所有 WARP 账户
+func (r *warpAccountRepository) FindAll() ([]*model.WarpAccount, error) {
+	var accounts []*model.WarpAccount
+	err := r.db.Model(model.WarpAccount{}).Order("id asc").Find(&accounts).Error
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// FindByID 根据 ID 查找 WARP 账户
+func (r *warpAccountRepository) FindByID(id int) (*model.WarpAccount, error) {
+	account := &model.WarpAccount{}
+	err := r.db.Model(model.WarpAccount{}).Where("id = ?", id).First(account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// FindByName 根据名称查找 WARP 账户
+func (r *warpAccountRepository) FindByName(name string) (*model.WarpAccount, error) {
+	account := &model.WarpAccount{}
+	err := r.db.Model(model.WarpAccount{}).Where("name = ?", name).First(account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// FindByOutboundTag 查找当前被指定出站使用的 WARP 账户
+func (r *warpAccountRepository) FindByOutboundTag(tag string) (*model.WarpAccount, error) {
+	account := &model.WarpAccount{}
+	err := r.db.Model(model.WarpAccount{}).Where("outbound_tag = ?", tag).First(account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Count 返回 WARP 账户数量
+func (r *warpAccountRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(model.WarpAccount{}).Count(&count).Error
+	return count, err
+}
+
+// Create 创建新的 WARP 账户
+func (r *warpAccountRepository) Create(account *model.WarpAccount) error {
+	return r.db.Create(account).Error
+}
+
+// Update 更新 WARP 账户
+func (r *warpAccountRepository) Update(account *model.WarpAccount) error {
+	return r.db.Save(account).Error
+}
+
+// Delete 删除 WARP 账户
+func (r *warpAccountRepository) Delete(id int) error {
+	return r.db.Where("id = ?", id).Delete(model.WarpAccount{}).Error
+}
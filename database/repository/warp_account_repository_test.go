@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarpAccountRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewWarpAccountRepository(database.GetDB())
+
+	account := &model.WarpAccount{Name: "primary", DeviceId: "dev-1", Enable: true}
+	assert.NoError(t, repo.Create(account))
+	assert.NotZero(t, account.Id)
+
+	// 名称唯一
+	assert.Error(t, repo.Create(&model.WarpAccount{Name: "primary"}))
+
+	found, err := repo.FindByName("primary")
+	assert.NoError(t, err)
+	assert.Equal(t, account.Id, found.Id)
+
+	_, err = repo.FindByOutboundTag("warp")
+	assert.Error(t, err)
+	found.OutboundTag = "warp"
+	found.Failures = 2
+	assert.NoError(t, repo.Update(found))
+	found, err = repo.FindByOutboundTag("warp")
+	assert.NoError(t, err)
+	assert.Equal(t, account.Id, found.Id)
+	assert.Equal(t, 2, found.Failures)
+
+	count, err := repo.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, repo.Delete(account.Id))
+	_, err = repo.FindByID(account.Id)
+	assert.Error(t, err)
+}
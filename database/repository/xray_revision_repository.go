@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"x-ui/database/model"
+
+	"gorm.io/gorm"
+)
+
+// XrayRevisionRepository 定义 Xray 配置模板历史版本的数据访问接口
+type XrayRevisionRepository interface {
+	Create(revision *model.XrayConfigRevision) error
+	FindByID(id int) (*model.XrayConfigRevision, error)
+	FindLatest() (*model.XrayConfigRevision, error)
+	List(limit int) ([]*model.XrayConfigRevision, error)
+	Count() (int64, error)
+	Prune(keep int) error
+
+	GetDB() *gorm.DB
+}
+
+// xrayRevisionRepository 实现 XrayRevisionRepository 接口
+type xrayRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewXrayRevisionRepository 创建新的 XrayRevisionRepository 实例
+func NewXrayRevisionRepository(db *gorm.DB) XrayRevisionRepository {
+	return &xrayRevisionRepository{
+		db: db,
+	}
+}
+
+// GetDB 返回当前数据库连接
+func (r *xrayRevisionRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// Create 创建新的历史版本
+func (r *xrayRevisionRepository) Create(revision *model.XrayConfigRevision) error {
+	return r.db.Create(revision).Error
+}
+
+// FindByID 根据 ID 查找历史版本
+func (r *xrayRevisionRepository) FindByID(id int) (*model.XrayConfigRevision, error) {
+	revision := &model.XrayConfigRevision{}
+	err := r.db.Model(model.XrayConfigRevision{}).Where("id = ?", id).First(revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// FindLatest 查找最新的历史版本
+func (r *xrayRevisionRepository) FindLatest() (*model.XrayConfigRevision, error) {
+	revision := &model.XrayConfigRevision{}
+	err := r.db.Model(model.XrayConfigRevision{}).Order("id desc").First(revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// List 按时间倒序列出历史版本，不包含配置内容
+func (r *xrayRevisionRepository) List(limit int) ([]*model.XrayConfigRevision, error) {
+	var revisions []*model.XrayConfigRevision
+	query := r.db.Model(model.XrayConfigRevision{}).
+		Select("id", "author", "comment", "revert_of", "size", "created_at").
+		Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Count 统计历史版本数量
+func (r *xrayRevisionRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(model.XrayConfigRevision{}).Count(&count).Error
+	return count, err
+}
+
+// Prune 仅保留最新的 keep 个历史版本
+func (r *xrayRevisionRepository) Prune(keep int) error {
+	var ids []int
+	err := r.db.Model(model.XrayConfigRevision{}).Order("id desc").Offset(keep).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return r.db.Where("id IN ?", ids).Delete(model.XrayConfigRevision{}).Error
+}
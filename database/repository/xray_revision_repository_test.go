@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXrayRevisionRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewXrayRevisionRepository(database.GetDB())
+
+	for i := 1; i <= 5; i++ {
+		err := repo.Create(&model.XrayConfigRevision{Config: `{"n":` + string(rune('0'+i)) + `}`, Author: "admin", CreatedAt: int64(i)})
+		assert.NoError(t, err)
+	}
+
+	latest, err := repo.FindLatest()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"n":5}`, latest.Config)
+
+	list, err := repo.List(10)
+	assert.NoError(t, err)
+	assert.Len(t, list, 5)
+	assert.Equal(t, latest.Id, list[0].Id)
+	// 列表不返回配置内容
+	assert.Empty(t, list[0].Config)
+
+	assert.NoError(t, repo.Prune(3))
+	count, err := repo.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	_, err = repo.FindByID(list[4].Id)
+	assert.Error(t, err)
+	found, err := repo.FindByID(list[2].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"n":3}`, found.Config)
+}
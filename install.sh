@@ -620,6 +620,10 @@ install_x-ui() {
   else
     chmod +x "bin/xray-linux-$(arch)"
   fi
+  # 发布包自带编译了 with_v2ray_api 的 sing-box，用于 Hysteria2/TUIC 入站
+  for sing_box in bin/sing-box-linux-*; do
+    [[ -f "${sing_box}" ]] && chmod +x "${sing_box}"
+  done
   chmod +x x-ui
 
   # Update x-ui cli and se set permission
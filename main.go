@@ -11,6 +11,7 @@ import (
 	"x-ui/bootstrap"
 	"x-ui/config"
 	"x-ui/logger"
+	"x-ui/web/network"
 )
 
 // runWebServer 是主执行函数，使用 bootstrap 模块简化启动流程
@@ -45,6 +46,9 @@ func runWebServer() {
 	// 注册并启动后台任务
 	runtime.StartJobs()
 
+	// 关闭从上一个进程继承但本次未使用的监听套接字
+	network.CloseIdleListeners()
+
 	// 信号处理循环
 	sigCh := make(chan os.Signal, 1)
 	setupSignalHandler(sigCh)
@@ -64,6 +68,12 @@ func runWebServer() {
 				log.Fatalf("Error restarting: %v", err)
 			}
 
+		case upgradeSignal:
+			logger.Info("Received upgrade signal. Handing listeners over to the new binary...")
+			if err := runtime.Upgrade(); err != nil {
+				log.Fatalf("Error upgrading: %v", err)
+			}
+
 		default:
 			runtime.StopAll()
 			log.Println("Shutting down servers.")
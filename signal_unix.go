@@ -11,9 +11,12 @@ import (
 	"x-ui/web/job"
 )
 
-// setupSignalHandler 注册信号监听（Unix版包含 SIGUSR2）
+// upgradeSignal 触发二进制升级并交接监听套接字的信号
+var upgradeSignal os.Signal = syscall.SIGUSR1
+
+// setupSignalHandler 注册信号监听（Unix版包含 SIGUSR1/SIGUSR2）
 func setupSignalHandler(sigCh chan os.Signal) {
-	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGUSR2)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
 }
 
 // handleCustomSignal 处理平台特定的信号（如 SIGUSR2）
@@ -10,6 +10,9 @@ import (
 	"x-ui/web/job"
 )
 
+// upgradeSignal Windows 不支持二进制升级交接，永远不会匹配
+var upgradeSignal os.Signal
+
 // setupSignalHandler 注册信号监听（Windows版仅包含基础信号）
 func setupSignalHandler(sigCh chan os.Signal) {
 	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGTERM)
@@ -0,0 +1,95 @@
+package singbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/xray"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// queryStatsMethod sing-box 的 V2Ray API 沿用 V2Ray 的服务名，消息结构与 Xray 的 StatsService 一致
+const queryStatsMethod = "/v2ray.core.app.stats.command.StatsService/QueryStats"
+
+// ErrV2RayAPIUnavailable sing-box 二进制编译时未启用 with_v2ray_api，无法读取流量统计
+var ErrV2RayAPIUnavailable = errors.New("sing-box is built without with_v2ray_api, traffic statistics are unavailable")
+
+var buildTags struct {
+	mu      sync.Mutex
+	modTime time.Time
+	tags    []string
+}
+
+// HasV2RayAPI 检查 bin 目录下的 sing-box 是否编译了 V2Ray API。官方发布的二进制不包含该功能，
+// 结果按二进制的修改时间缓存，更新二进制后重新检查
+func HasV2RayAPI() bool {
+	info, err := os.Stat(GetBinaryPath())
+	if err != nil {
+		return false
+	}
+	buildTags.mu.Lock()
+	defer buildTags.mu.Unlock()
+	if !info.ModTime().Equal(buildTags.modTime) {
+		//nolint:gosec
+		data, err := exec.Command(GetBinaryPath(), "version").Output()
+		if err != nil {
+			return false
+		}
+		buildTags.tags = ParseBuildTags(string(data))
+		buildTags.modTime = info.ModTime()
+	}
+	for _, tag := range buildTags.tags {
+		if tag == "with_v2ray_api" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBuildTags 从 sing-box version 的输出中解析编译标签，输出中形如 "Tags: with_gvisor,with_quic"
+func ParseBuildTags(output string) []string {
+	for line := range strings.SplitSeq(output, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "Tags:")
+		if !ok {
+			continue
+		}
+		var tags []string
+		for tag := range strings.SplitSeq(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		return tags
+	}
+	return nil
+}
+
+// GetTraffic 通过 sing-box 的 V2Ray API 读取入站、出站和用户流量
+func GetTraffic(apiPort int, reset bool) ([]*xray.Traffic, []*xray.ClientTraffic, error) {
+	if apiPort <= 0 {
+		return nil, nil, fmt.Errorf("invalid sing-box API port: %d", apiPort)
+	}
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", apiPort), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to sing-box API: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp := &statsService.QueryStatsResponse{}
+	if err := conn.Invoke(ctx, queryStatsMethod, &statsService.QueryStatsRequest{Reset_: reset}, resp); err != nil {
+		return nil, nil, err
+	}
+	traffic, clientTraffic := xray.ParseTrafficStats(resp.GetStat())
+	return traffic, clientTraffic, nil
+}
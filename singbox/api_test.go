@@ -0,0 +1,64 @@
+package singbox
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"google.golang.org/grpc"
+)
+
+// v2rayStatsServer 以 V2Ray 的服务名提供 QueryStats，模拟 sing-box 的 V2Ray API
+type v2rayStatsServer struct {
+	reset bool
+}
+
+func (s *v2rayStatsServer) queryStats(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+	req := &statsService.QueryStatsRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	s.reset = req.GetReset_()
+	return &statsService.QueryStatsResponse{Stat: []*statsService.Stat{
+		{Name: "inbound>>>inbound-443>>>traffic>>>uplink", Value: 10},
+		{Name: "inbound>>>inbound-443>>>traffic>>>downlink", Value: 20},
+		{Name: "user>>>a@example.com>>>traffic>>>downlink", Value: 5},
+	}}, nil
+}
+
+func TestGetTraffic(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fake := &v2rayStatsServer{}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "v2ray.core.app.stats.command.StatsService",
+		HandlerType: (*any)(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "QueryStats", Handler: fake.queryStats}},
+	}, fake)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	traffic, clientTraffic, err := GetTraffic(lis.Addr().(*net.TCPAddr).Port, true)
+	require.NoError(t, err)
+	assert.True(t, fake.reset)
+	require.Len(t, traffic, 1)
+	assert.Equal(t, "inbound-443", traffic[0].Tag)
+	assert.Equal(t, int64(10), traffic[0].Up)
+	assert.Equal(t, int64(20), traffic[0].Down)
+	require.Len(t, clientTraffic, 1)
+	assert.Equal(t, "a@example.com", clientTraffic[0].Email)
+	assert.Equal(t, int64(5), clientTraffic[0].Down)
+
+	_, _, err = GetTraffic(0, true)
+	assert.Error(t, err)
+}
+
+func TestParseBuildTags(t *testing.T) {
+	output := "sing-box version 1.12.0\n\nEnvironment: go1.24.4 linux/amd64\nTags: with_gvisor,with_quic,with_v2ray_api\nRevision: abc\nCGO: disabled\n"
+	assert.Equal(t, []string{"with_gvisor", "with_quic", "with_v2ray_api"}, ParseBuildTags(output))
+	assert.Nil(t, ParseBuildTags("sing-box version 1.12.0\n"))
+}
@@ -0,0 +1,377 @@
+package singbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"x-ui/database/model"
+	"x-ui/logger"
+)
+
+// Config sing-box 配置，只包含面板生成的部分
+type Config struct {
+	Log          map[string]any   `json:"log,omitempty"`
+	Inbounds     []map[string]any `json:"inbounds"`
+	Outbounds    []map[string]any `json:"outbounds"`
+	Route        map[string]any   `json:"route,omitempty"`
+	Experimental map[string]any   `json:"experimental,omitempty"`
+}
+
+// supportedProtocols sing-box 后端可以承载的入站协议
+var supportedProtocols = map[model.Protocol]string{
+	model.VLESS:       "vless",
+	model.VMESS:       "vmess",
+	model.Trojan:      "trojan",
+	model.Shadowsocks: "shadowsocks",
+	model.Socks:       "socks",
+	model.HTTP:        "http",
+	model.Hysteria2:   "hysteria2",
+	model.TUIC:        "tuic",
+}
+
+// SupportsProtocol 判断 sing-box 后端是否支持该入站协议
+func SupportsProtocol(protocol model.Protocol) bool {
+	_, ok := supportedProtocols[protocol]
+	return ok
+}
+
+// inboundSettings 面板中入站 settings 字段里 sing-box 需要的部分，沿用 Xray 的字段命名
+type inboundSettings struct {
+	Clients  []map[string]any `json:"clients"`
+	Accounts []struct {
+		User string `json:"user"`
+		Pass string `json:"pass"`
+	} `json:"accounts"`
+	Method   string `json:"method"`
+	Password string `json:"password"`
+
+	// Hysteria2
+	UpMbps                int  `json:"upMbps"`
+	DownMbps              int  `json:"downMbps"`
+	IgnoreClientBandwidth bool `json:"ignoreClientBandwidth"`
+	Obfs                  *struct {
+		Type     string `json:"type"`
+		Password string `json:"password"`
+	} `json:"obfs"`
+	Masquerade string `json:"masquerade"`
+
+	// TUIC
+	CongestionControl string `json:"congestionControl"`
+	ZeroRTTHandshake  bool   `json:"zeroRttHandshake"`
+}
+
+// BuildConfig 根据面板入站生成 sing-box 配置，apiPort 为 V2Ray API 的监听端口，用于流量统计。
+// 已禁用的入站和客户端不会写入配置；sing-box 不支持的协议、传输方式或安全设置的入站记录警告后跳过，
+// 不影响其他入站。流量由 direct 出站直接发出，不经过 Xray 模板中的路由和出站
+func BuildConfig(inbounds []*model.Inbound, apiPort int) (*Config, error) {
+	c := &Config{
+		Log:       map[string]any{"level": "warn", "timestamp": true},
+		Inbounds:  []map[string]any{},
+		Outbounds: []map[string]any{{"type": "direct", "tag": "direct"}},
+		Route:     map[string]any{"final": "direct"},
+	}
+
+	var inboundTags, users []string
+	for _, inbound := range inbounds {
+		if !inbound.Enable {
+			continue
+		}
+		if !SupportsProtocol(inbound.Protocol) {
+			logger.Warningf("Inbound %s uses protocol %s which sing-box does not support, skipped", inbound.Tag, inbound.Protocol)
+			continue
+		}
+		built, emails, err := BuildInbound(inbound)
+		if err != nil {
+			logger.Warningf("Inbound %s cannot be served by sing-box, skipped: %v", inbound.Tag, err)
+			continue
+		}
+		c.Inbounds = append(c.Inbounds, built)
+		inboundTags = append(inboundTags, inbound.Tag)
+		users = append(users, emails...)
+	}
+
+	if apiPort > 0 {
+		c.Experimental = map[string]any{
+			"v2ray_api": map[string]any{
+				"listen": net.JoinHostPort("127.0.0.1", strconv.Itoa(apiPort)),
+				"stats": map[string]any{
+					"enabled":   true,
+					"inbounds":  inboundTags,
+					"outbounds": []string{"direct"},
+					"users":     users,
+				},
+			},
+		}
+	}
+	return c, nil
+}
+
+// BuildInbound 将面板入站转换为 sing-box 入站，同时返回其中启用的客户端 email
+func BuildInbound(inbound *model.Inbound) (map[string]any, []string, error) {
+	typ, ok := supportedProtocols[inbound.Protocol]
+	if !ok {
+		return nil, nil, fmt.Errorf("protocol %s is not supported by sing-box", inbound.Protocol)
+	}
+	var settings inboundSettings
+	if inbound.Settings != "" {
+		if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+			return nil, nil, err
+		}
+	}
+	var stream map[string]any
+	if inbound.StreamSettings != "" {
+		if err := json.Unmarshal([]byte(inbound.StreamSettings), &stream); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	listen := inbound.Listen
+	if listen == "" {
+		listen = "::"
+	}
+	out := map[string]any{
+		"type":        typ,
+		"tag":         inbound.Tag,
+		"listen":      listen,
+		"listen_port": inbound.Port,
+	}
+
+	clients := enabledClients(inbound, settings.Clients)
+	var emails []string
+	var users []map[string]any
+	for _, client := range clients {
+		email, _ := client["email"].(string)
+		id, _ := client["id"].(string)
+		password, _ := client["password"].(string)
+		user := map[string]any{"name": email}
+		switch inbound.Protocol {
+		case model.VLESS:
+			user["uuid"] = id
+			if flow, _ := client["flow"].(string); flow != "" {
+				user["flow"] = strings.TrimSuffix(flow, "-udp443")
+			}
+		case model.VMESS:
+			user["uuid"] = id
+			user["alterId"] = 0
+		case model.TUIC:
+			user["uuid"] = id
+			user["password"] = password
+		default:
+			user["password"] = password
+		}
+		users = append(users, user)
+		if email != "" {
+			emails = append(emails, email)
+		}
+	}
+
+	switch inbound.Protocol {
+	case model.VLESS, model.VMESS, model.Trojan, model.TUIC:
+		out["users"] = users
+	case model.Hysteria2:
+		out["users"] = users
+		if settings.UpMbps > 0 {
+			out["up_mbps"] = settings.UpMbps
+		}
+		if settings.DownMbps > 0 {
+			out["down_mbps"] = settings.DownMbps
+		}
+		if settings.IgnoreClientBandwidth {
+			out["ignore_client_bandwidth"] = true
+		}
+		if settings.Obfs != nil && settings.Obfs.Type != "" {
+			out["obfs"] = map[string]any{"type": settings.Obfs.Type, "password": settings.Obfs.Password}
+		}
+		if settings.Masquerade != "" {
+			out["masquerade"] = settings.Masquerade
+		}
+	case model.Shadowsocks:
+		out["method"] = settings.Method
+		switch {
+		case strings.HasPrefix(settings.Method, "2022-"):
+			// 2022 系列加密支持多用户：入站密码为服务端密钥，每个客户端有独立密钥
+			out["password"] = settings.Password
+			if len(users) > 0 {
+				out["users"] = users
+			}
+		case len(clients) == 1:
+			password, _ := clients[0]["password"].(string)
+			if method, _ := clients[0]["method"].(string); method != "" {
+				out["method"] = method
+			}
+			out["password"] = password
+		case len(clients) == 0:
+			out["password"] = settings.Password
+		default:
+			return nil, nil, fmt.Errorf("sing-box only supports multiple shadowsocks users with 2022 methods")
+		}
+	case model.Socks, model.HTTP:
+		var accounts []map[string]any
+		for _, account := range settings.Accounts {
+			accounts = append(accounts, map[string]any{"username": account.User, "password": account.Pass})
+		}
+		if len(accounts) > 0 {
+			out["users"] = accounts
+		}
+	}
+
+	if inbound.Protocol == model.TUIC {
+		if settings.CongestionControl != "" {
+			out["congestion_control"] = settings.CongestionControl
+		}
+		if settings.ZeroRTTHandshake {
+			out["zero_rtt_handshake"] = true
+		}
+	}
+
+	tls, err := buildTLS(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tls != nil {
+		out["tls"] = tls
+	} else if inbound.Protocol == model.Hysteria2 || inbound.Protocol == model.TUIC {
+		return nil, nil, fmt.Errorf("%s requires tls", inbound.Protocol)
+	}
+
+	// Hysteria2 与 TUIC 基于 QUIC，没有可选的传输层
+	if inbound.Protocol != model.Hysteria2 && inbound.Protocol != model.TUIC {
+		transport, err := buildTransport(stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		if transport != nil {
+			out["transport"] = transport
+		}
+	}
+	return out, emails, nil
+}
+
+// enabledClients 过滤 settings 和流量统计中被禁用的客户端
+func enabledClients(inbound *model.Inbound, clients []map[string]any) []map[string]any {
+	disabled := make(map[string]bool)
+	for _, stat := range inbound.ClientStats {
+		if !stat.Enable {
+			disabled[stat.Email] = true
+		}
+	}
+	result := make([]map[string]any, 0, len(clients))
+	for _, client := range clients {
+		if enable, ok := client["enable"].(bool); ok && !enable {
+			continue
+		}
+		if email, _ := client["email"].(string); disabled[email] {
+			continue
+		}
+		result = append(result, client)
+	}
+	return result
+}
+
+// buildTLS 将 Xray 的 tlsSettings / realitySettings 转换为 sing-box 的 tls 配置
+func buildTLS(stream map[string]any) (map[string]any, error) {
+	security, _ := stream["security"].(string)
+	switch security {
+	case "", "none":
+		return nil, nil
+	case "tls":
+		settings, _ := stream["tlsSettings"].(map[string]any)
+		tls := map[string]any{"enabled": true}
+		if serverName, _ := settings["serverName"].(string); serverName != "" {
+			tls["server_name"] = serverName
+		}
+		if alpn, ok := settings["alpn"].([]any); ok && len(alpn) > 0 {
+			tls["alpn"] = alpn
+		}
+		certificates, _ := settings["certificates"].([]any)
+		if len(certificates) == 0 {
+			return nil, fmt.Errorf("tls certificate is required")
+		}
+		cert, _ := certificates[0].(map[string]any)
+		if file, _ := cert["certificateFile"].(string); file != "" {
+			tls["certificate_path"] = file
+			tls["key_path"], _ = cert["keyFile"].(string)
+		} else {
+			tls["certificate"] = cert["certificate"]
+			tls["key"] = cert["key"]
+		}
+		return tls, nil
+	case "reality":
+		settings, _ := stream["realitySettings"].(map[string]any)
+		target, _ := settings["target"].(string)
+		if target == "" {
+			target, _ = settings["dest"].(string)
+		}
+		host, port, err := net.SplitHostPort(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reality target %q", target)
+		}
+		serverPort, _ := strconv.Atoi(port)
+		privateKey, _ := settings["privateKey"].(string)
+		reality := map[string]any{
+			"enabled":     true,
+			"handshake":   map[string]any{"server": host, "server_port": serverPort},
+			"private_key": privateKey,
+		}
+		if shortIds, ok := settings["shortIds"].([]any); ok {
+			reality["short_id"] = shortIds
+		}
+		tls := map[string]any{"enabled": true, "reality": reality}
+		if serverNames, ok := settings["serverNames"].([]any); ok && len(serverNames) > 0 {
+			tls["server_name"] = serverNames[0]
+		}
+		return tls, nil
+	default:
+		return nil, fmt.Errorf("security %s is not supported by sing-box", security)
+	}
+}
+
+// buildTransport 将 Xray 的传输方式转换为 sing-box 的 transport 配置，tcp 不需要 transport
+func buildTransport(stream map[string]any) (map[string]any, error) {
+	network, _ := stream["network"].(string)
+	switch network {
+	case "", "tcp":
+		tcp, _ := stream["tcpSettings"].(map[string]any)
+		header, _ := tcp["header"].(map[string]any)
+		if typ, _ := header["type"].(string); typ != "" && typ != "none" {
+			return nil, fmt.Errorf("tcp header %s is not supported by sing-box", typ)
+		}
+		return nil, nil
+	case "ws":
+		ws, _ := stream["wsSettings"].(map[string]any)
+		transport := map[string]any{"type": "ws", "path": ws["path"]}
+		if host := transportHost(ws); host != "" {
+			transport["headers"] = map[string]any{"Host": host}
+		}
+		return transport, nil
+	case "httpupgrade":
+		settings, _ := stream["httpupgradeSettings"].(map[string]any)
+		transport := map[string]any{"type": "httpupgrade", "path": settings["path"]}
+		if host := transportHost(settings); host != "" {
+			transport["host"] = host
+		}
+		return transport, nil
+	case "grpc":
+		grpc, _ := stream["grpcSettings"].(map[string]any)
+		return map[string]any{"type": "grpc", "service_name": grpc["serviceName"]}, nil
+	default:
+		return nil, fmt.Errorf("network %s is not supported by sing-box", network)
+	}
+}
+
+func transportHost(settings map[string]any) string {
+	if host, _ := settings["host"].(string); host != "" {
+		return host
+	}
+	headers, _ := settings["headers"].(map[string]any)
+	for key, value := range headers {
+		if strings.EqualFold(key, "host") {
+			host, _ := value.(string)
+			return host
+		}
+	}
+	return ""
+}
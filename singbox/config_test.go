@@ -0,0 +1,98 @@
+package singbox
+
+import (
+	"encoding/json"
+	"testing"
+
+	"x-ui/database/model"
+	"x-ui/xray"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTLSStream = `{"network": "tcp", "security": "tls", "tlsSettings": {"serverName": "example.com", "alpn": ["h3"], "certificates": [{"certificateFile": "/etc/cert.pem", "keyFile": "/etc/key.pem"}]}}`
+
+func TestBuildConfig(t *testing.T) {
+	inbounds := []*model.Inbound{
+		{
+			Tag:            "inbound-443",
+			Port:           443,
+			Enable:         true,
+			Protocol:       model.VLESS,
+			Settings:       `{"clients": [{"id": "uuid-1", "email": "a@example.com", "flow": "xtls-rprx-vision", "enable": true}, {"id": "uuid-2", "email": "b@example.com", "enable": false}]}`,
+			StreamSettings: `{"network": "tcp", "security": "reality", "realitySettings": {"target": "www.example.com:443", "privateKey": "pk", "serverNames": ["www.example.com"], "shortIds": ["ab"]}}`,
+		},
+		{
+			Tag:            "inbound-8443",
+			Port:           8443,
+			Enable:         true,
+			Protocol:       model.Hysteria2,
+			Settings:       `{"clients": [{"password": "hy-pass", "email": "c@example.com"}], "upMbps": 100, "obfs": {"type": "salamander", "password": "obfs"}}`,
+			StreamSettings: testTLSStream,
+		},
+		{
+			Tag:            "inbound-9443",
+			Port:           9443,
+			Enable:         true,
+			Protocol:       model.TUIC,
+			Settings:       `{"clients": [{"id": "uuid-3", "password": "tuic-pass", "email": "d@example.com"}], "congestionControl": "bbr"}`,
+			StreamSettings: testTLSStream,
+			ClientStats:    []xray.ClientTraffic{{Email: "d@example.com", Enable: true}},
+		},
+		{Tag: "inbound-disabled", Port: 1000, Enable: false, Protocol: model.VMESS},
+		{Tag: "inbound-tunnel", Port: 1001, Enable: true, Protocol: model.Tunnel},
+		// 不支持的传输方式只跳过该入站
+		{Tag: "inbound-kcp", Port: 1002, Enable: true, Protocol: model.VMESS, StreamSettings: `{"network": "kcp"}`},
+	}
+
+	c, err := BuildConfig(inbounds, 62790)
+	require.NoError(t, err)
+	require.Len(t, c.Inbounds, 3)
+
+	vless := c.Inbounds[0]
+	assert.Equal(t, "vless", vless["type"])
+	assert.Equal(t, "::", vless["listen"])
+	users := vless["users"].([]map[string]any)
+	require.Len(t, users, 1)
+	assert.Equal(t, "a@example.com", users[0]["name"])
+	assert.Equal(t, "xtls-rprx-vision", users[0]["flow"])
+	reality := vless["tls"].(map[string]any)["reality"].(map[string]any)
+	assert.Equal(t, map[string]any{"server": "www.example.com", "server_port": 443}, reality["handshake"])
+
+	hy2 := c.Inbounds[1]
+	assert.Equal(t, "hysteria2", hy2["type"])
+	assert.Equal(t, 100, hy2["up_mbps"])
+	assert.Equal(t, map[string]any{"type": "salamander", "password": "obfs"}, hy2["obfs"])
+	assert.Equal(t, "/etc/cert.pem", hy2["tls"].(map[string]any)["certificate_path"])
+	assert.NotContains(t, hy2, "transport")
+
+	tuic := c.Inbounds[2]
+	assert.Equal(t, "tuic", tuic["type"])
+	assert.Equal(t, "bbr", tuic["congestion_control"])
+	assert.Equal(t, []map[string]any{{"name": "d@example.com", "uuid": "uuid-3", "password": "tuic-pass"}}, tuic["users"])
+
+	stats := c.Experimental["v2ray_api"].(map[string]any)["stats"].(map[string]any)
+	assert.Equal(t, []string{"inbound-443", "inbound-8443", "inbound-9443"}, stats["inbounds"])
+	assert.Equal(t, []string{"a@example.com", "c@example.com", "d@example.com"}, stats["users"])
+
+	_, err = json.Marshal(c)
+	assert.NoError(t, err)
+}
+
+func TestBuildInboundErrors(t *testing.T) {
+	_, _, err := BuildInbound(&model.Inbound{Protocol: model.Hysteria2, Settings: `{}`})
+	assert.ErrorContains(t, err, "requires tls")
+
+	_, _, err = BuildInbound(&model.Inbound{Protocol: model.VLESS, StreamSettings: `{"network": "kcp"}`})
+	assert.ErrorContains(t, err, "network kcp")
+
+	_, _, err = BuildInbound(&model.Inbound{
+		Protocol: model.Shadowsocks,
+		Settings: `{"method": "aes-128-gcm", "clients": [{"password": "a", "email": "a"}, {"password": "b", "email": "b"}]}`,
+	})
+	assert.ErrorContains(t, err, "2022")
+
+	_, _, err = BuildInbound(&model.Inbound{Protocol: model.WireGuard})
+	assert.ErrorContains(t, err, "not supported")
+}
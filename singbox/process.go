@@ -0,0 +1,282 @@
+package singbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/config"
+	"x-ui/logger"
+	"x-ui/util/common"
+)
+
+func GetBinaryName() string {
+	return fmt.Sprintf("sing-box-%s-%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func GetBinaryPath() string {
+	return config.GetBinFolderPath() + "/" + GetBinaryName()
+}
+
+func GetConfigPath() string {
+	return config.GetBinFolderPath() + "/sing-box.json"
+}
+
+// Process 以独立进程运行 sing-box，配置变更通过重新加载生效
+type Process struct {
+	cmd     *exec.Cmd
+	done    chan struct{}
+	mutex   sync.RWMutex
+	config  *Config
+	apiPort int
+	version string
+	output  *outputWriter
+	exitErr error
+
+	startTime time.Time
+}
+
+// NewProcess 创建 sing-box 进程，apiPort 为配置中 V2Ray API 的监听端口
+func NewProcess(c *Config, apiPort int) *Process {
+	return &Process{
+		config:  c,
+		apiPort: apiPort,
+		version: "Unknown",
+		output:  &outputWriter{},
+	}
+}
+
+func (p *Process) IsRunning() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.cmd == nil || p.done == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *Process) GetConfig() *Config {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.config
+}
+
+func (p *Process) GetAPIPort() int {
+	return p.apiPort
+}
+
+func (p *Process) GetVersion() string {
+	return p.version
+}
+
+func (p *Process) GetErr() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.exitErr
+}
+
+// GetResult 返回进程最后一行输出，进程启动失败时返回错误信息
+func (p *Process) GetResult() string {
+	if line := p.output.LastLine(); line != "" {
+		return line
+	}
+	if err := p.GetErr(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// ExitCode 返回进程的退出码，进程尚未退出或无法获取时返回 -1
+func (p *Process) ExitCode() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.cmd == nil || p.done == nil {
+		return -1
+	}
+	select {
+	case <-p.done:
+		// Wait 在关闭 done 之前写入 ProcessState
+		if p.cmd.ProcessState != nil {
+			return p.cmd.ProcessState.ExitCode()
+		}
+	default:
+	}
+	return -1
+}
+
+func (p *Process) GetUptime() uint64 {
+	return uint64(time.Since(p.startTime).Seconds())
+}
+
+func (p *Process) Start() error {
+	if p.IsRunning() {
+		return errors.New("sing-box is already running")
+	}
+	if err := writeConfigFile(GetConfigPath(), p.config); err != nil {
+		return err
+	}
+
+	//nolint:gosec
+	cmd := exec.Command(GetBinaryPath(), "run", "-c", GetConfigPath(), "-D", config.GetBinFolderPath())
+	cmd.Stdout = p.output
+	cmd.Stderr = p.output
+	if err := cmd.Start(); err != nil {
+		p.exitErr = err
+		return common.NewErrorf("failed to start sing-box: %v", err)
+	}
+
+	done := make(chan struct{})
+	p.mutex.Lock()
+	p.cmd = cmd
+	p.done = done
+	p.exitErr = nil
+	p.startTime = time.Now()
+	p.mutex.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logger.Error("Failure in running sing-box:", err)
+			p.mutex.Lock()
+			p.exitErr = err
+			p.mutex.Unlock()
+		}
+		close(done)
+	}()
+
+	p.refreshVersion()
+	return nil
+}
+
+// Reload 写入新配置并通知 sing-box 重新加载，不中断进程；当前平台不支持重新加载时返回错误
+func (p *Process) Reload(c *Config) error {
+	if !p.IsRunning() {
+		return errors.New("sing-box is not running")
+	}
+	if err := writeConfigFile(GetConfigPath(), c); err != nil {
+		return err
+	}
+	if err := reloadProcess(p.cmd.Process); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	p.config = c
+	p.mutex.Unlock()
+	return nil
+}
+
+func (p *Process) Stop() error {
+	if !p.IsRunning() {
+		return errors.New("sing-box is not running")
+	}
+	if err := stopProcess(p.cmd.Process); err != nil {
+		return err
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(2 * time.Second):
+		logger.Warning("sing-box process did not stop in time, killing it...")
+		return p.cmd.Process.Kill()
+	}
+}
+
+func (p *Process) refreshVersion() {
+	//nolint:gosec
+	data, err := exec.Command(GetBinaryPath(), "version").Output()
+	if err != nil {
+		return
+	}
+	// 输出第一行形如 "sing-box version 1.10.0"
+	fields := strings.Fields(strings.SplitN(string(data), "\n", 2)[0])
+	if len(fields) >= 3 {
+		p.version = fields[2]
+	}
+}
+
+// TestConfig 使用 sing-box check 校验配置，二进制不存在时跳过
+func TestConfig(c *Config) error {
+	binary := GetBinaryPath()
+	if _, err := os.Stat(binary); err != nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return common.NewErrorf("failed to marshal sing-box config: %v", err)
+	}
+	file, err := os.CreateTemp("", "sing-box-test-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	_ = file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.SingBoxConfigTestTimeout)
+	defer cancel()
+	//nolint:gosec
+	output, err := exec.CommandContext(ctx, binary, "check", "-c", file.Name()).CombinedOutput()
+	if err != nil {
+		return common.NewErrorf("sing-box config check failed: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func writeConfigFile(path string, c *Config) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return common.NewErrorf("failed to generate sing-box configuration: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return common.NewErrorf("failed to write sing-box configuration: %v", err)
+	}
+	return nil
+}
+
+// outputWriter 记录 sing-box 的输出并转发到面板日志
+type outputWriter struct {
+	mu       sync.Mutex
+	lastLine string
+}
+
+func (w *outputWriter) Write(m []byte) (int, error) {
+	for line := range strings.SplitSeq(strings.TrimSpace(string(m)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		w.mu.Lock()
+		w.lastLine = line
+		w.mu.Unlock()
+		switch {
+		case strings.Contains(line, "ERROR") || strings.Contains(line, "FATAL"):
+			logger.Error("sing-box:", line)
+		case strings.Contains(line, "WARN"):
+			logger.Warning("sing-box:", line)
+		default:
+			logger.Debug("sing-box:", line)
+		}
+	}
+	return len(m), nil
+}
+
+func (w *outputWriter) LastLine() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastLine
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package singbox
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadProcess sing-box 收到 SIGHUP 后重新读取配置文件
+func reloadProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGHUP)
+}
+
+func stopProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
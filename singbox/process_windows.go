@@ -0,0 +1,17 @@
+//go:build windows
+
+package singbox
+
+import (
+	"errors"
+	"os"
+)
+
+// reloadProcess Windows 上无法发送 SIGHUP，由调用方重启进程
+func reloadProcess(*os.Process) error {
+	return errors.New("sing-box reload is not supported on windows")
+}
+
+func stopProcess(process *os.Process) error {
+	return process.Kill()
+}
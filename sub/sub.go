@@ -163,7 +163,7 @@ func (s *Server) Start() (err error) {
 	}
 
 	listenAddr := net.JoinHostPort(listen, strconv.Itoa(port))
-	listener, err := net.Listen("tcp", listenAddr)
+	listener, err := network.Listen("tcp", listenAddr)
 	if err != nil {
 		return err
 	}
@@ -210,7 +210,7 @@ func (s *Server) Stop() error {
 	var err1 error
 	var err2 error
 	if s.httpServer != nil {
-		err1 = s.httpServer.Shutdown(s.ctx)
+		err1 = network.DrainServer(s.httpServer, config.PanelDrainTimeout)
 	}
 	if s.listener != nil {
 		err2 = s.listener.Close()
@@ -2,10 +2,12 @@ package sub
 
 import (
 	"encoding/base64"
+	"fmt"
 	"net"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
 )
 
 type SUBController struct {
@@ -55,26 +57,15 @@ func (a *SUBController) initRouter(g *gin.RouterGroup) {
 	gJson := g.Group(a.subJsonPath)
 
 	gLink.GET(":subid", a.subs)
+	gLink.GET(":subid/wireguard/:email", a.wireguardConf)
+	gLink.GET(":subid/wireguard/:email/qr", a.wireguardQR)
 
 	gJson.GET(":subid", a.subJsons)
 }
 
 func (a *SUBController) subs(c *gin.Context) {
 	subId := c.Param("subid")
-	var host string
-	if h, err := getHostFromXFH(c.GetHeader("X-Forwarded-Host")); err == nil {
-		host = h
-	}
-	if host == "" {
-		host = c.GetHeader("X-Real-IP")
-	}
-	if host == "" {
-		var err error
-		host, _, err = net.SplitHostPort(c.Request.Host)
-		if err != nil {
-			host = c.Request.Host
-		}
-	}
+	host := requestHost(c)
 	subs, header, err := a.subService.GetSubs(subId, host)
 	if err != nil || len(subs) == 0 {
 		c.String(400, "Error!")
@@ -99,6 +90,50 @@ func (a *SUBController) subs(c *gin.Context) {
 
 func (a *SUBController) subJsons(c *gin.Context) {
 	subId := c.Param("subid")
+	host := requestHost(c)
+	jsonSub, header, err := a.subJsonService.GetJson(subId, host)
+	if err != nil || len(jsonSub) == 0 {
+		c.String(400, "Error!")
+	} else {
+
+		// Add headers
+		c.Writer.Header().Set("Subscription-Userinfo", header)
+		c.Writer.Header().Set("Profile-Update-Interval", a.updateInterval)
+		c.Writer.Header().Set("Profile-Title", "base64:"+base64.StdEncoding.EncodeToString([]byte(a.subTitle)))
+
+		c.String(200, jsonSub)
+	}
+}
+
+// wireguardConf 下载 peer 的 wg-quick 配置文件
+func (a *SUBController) wireguardConf(c *gin.Context) {
+	email := c.Param("email")
+	conf, err := a.subService.GetWireguardConfig(c.Param("subid"), email, requestHost(c))
+	if err != nil {
+		c.String(400, "Error!")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.conf"`, wireguardFileName(email)))
+	c.Data(200, "text/plain; charset=utf-8", []byte(conf))
+}
+
+// wireguardQR 以二维码（PNG）返回 peer 的 wg-quick 配置，供移动端扫码导入
+func (a *SUBController) wireguardQR(c *gin.Context) {
+	conf, err := a.subService.GetWireguardConfig(c.Param("subid"), c.Param("email"), requestHost(c))
+	if err != nil {
+		c.String(400, "Error!")
+		return
+	}
+	png, err := qrcode.Encode(conf, qrcode.Medium, 512)
+	if err != nil {
+		c.String(500, "Error!")
+		return
+	}
+	c.Data(200, "image/png", png)
+}
+
+// requestHost 按 X-Forwarded-Host、X-Real-IP、Host 的顺序取订阅链接使用的地址
+func requestHost(c *gin.Context) string {
 	var host string
 	if h, err := getHostFromXFH(c.GetHeader("X-Forwarded-Host")); err == nil {
 		host = h
@@ -113,18 +148,21 @@ func (a *SUBController) subJsons(c *gin.Context) {
 			host = c.Request.Host
 		}
 	}
-	jsonSub, header, err := a.subJsonService.GetJson(subId, host)
-	if err != nil || len(jsonSub) == 0 {
-		c.String(400, "Error!")
-	} else {
-
-		// Add headers
-		c.Writer.Header().Set("Subscription-Userinfo", header)
-		c.Writer.Header().Set("Profile-Update-Interval", a.updateInterval)
-		c.Writer.Header().Set("Profile-Title", "base64:"+base64.StdEncoding.EncodeToString([]byte(a.subTitle)))
+	return host
+}
 
-		c.String(200, jsonSub)
+// wireguardFileName 将 email 转换为安全的文件名（wg-quick 要求接口名仅含字母、数字和 _=+.-）
+func wireguardFileName(email string) string {
+	name := []byte(email)
+	for i, ch := range name {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte("_=+.-", ch) >= 0) {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 15 {
+		name = name[:15]
 	}
+	return string(name)
 }
 
 func getHostFromXFH(s string) (string, error) {
@@ -80,6 +80,10 @@ func (s *SubJsonService) GetJson(subId string, host string) (string, string, err
 
 	// Prepare Inbounds
 	for _, inbound := range inbounds {
+		// JSON 订阅生成的是 Xray 客户端配置，无法表示 sing-box 独有的协议
+		if inbound.Protocol == model.Hysteria2 || inbound.Protocol == model.TUIC {
+			continue
+		}
 		clients, err := s.inboundService.GetClients(inbound)
 		if err != nil {
 			logger.Error("SubJsonService - GetClients: Unable to get clients from inbound")
@@ -4,7 +4,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"x-ui/logger"
 	"x-ui/util/common"
 	"x-ui/util/random"
+	"x-ui/util/wireguard"
 	"x-ui/xray"
 
 	json "github.com/goccy/go-json"
@@ -140,7 +143,7 @@ func (s *SubService) getInboundsBySubId(subId string) ([]*model.Inbound, error)
 		FROM inbounds,
 			JSON_EACH(JSON_EXTRACT(inbounds.settings, '$.clients')) AS client 
 		WHERE
-			protocol in ('vmess','vless','trojan','shadowsocks')
+			protocol in ('vmess','vless','trojan','shadowsocks','wireguard','socks','http')
 			AND JSON_EXTRACT(client.value, '$.subId') = ? AND enable = ?
 	)`, subId, true).Find(&inbounds).Error
 	if err != nil {
@@ -194,6 +197,14 @@ func (s *SubService) getLink(inbound *model.Inbound, email string) string {
 		return s.genTrojanLink(inbound, email)
 	case "shadowsocks":
 		return s.genShadowsocksLink(inbound, email)
+	case "hysteria2":
+		return s.genHysteria2Link(inbound, email)
+	case "tuic":
+		return s.genTuicLink(inbound, email)
+	case "wireguard":
+		return s.genWireguardLink(inbound, email)
+	case "socks", "http":
+		return s.genAccountLink(inbound, email)
 	}
 	return ""
 }
@@ -929,6 +940,219 @@ func (s *SubService) genShadowsocksLink(inbound *model.Inbound, email string) st
 	return url.String()
 }
 
+// genHysteria2Link 生成 hysteria2:// 链接，入站由 sing-box 后端提供
+func (s *SubService) genHysteria2Link(inbound *model.Inbound, email string) string {
+	if inbound.Protocol != model.Hysteria2 {
+		return ""
+	}
+	client := s.findClient(inbound, email)
+	if client == nil {
+		return ""
+	}
+	var settings map[string]any
+	_ = json.Unmarshal([]byte(inbound.Settings), &settings)
+
+	params := quicTLSParams(inbound)
+	if obfs, ok := settings["obfs"].(map[string]any); ok {
+		if obfsType, _ := obfs["type"].(string); obfsType != "" {
+			params["obfs"] = obfsType
+			params["obfs-password"], _ = obfs["password"].(string)
+		}
+	}
+
+	link := fmt.Sprintf("hysteria2://%s@%s:%d", url.PathEscape(client.Password), s.address, inbound.Port)
+	return s.buildQuicLink(link, params, inbound, email)
+}
+
+// genTuicLink 生成 tuic:// 链接，入站由 sing-box 后端提供
+func (s *SubService) genTuicLink(inbound *model.Inbound, email string) string {
+	if inbound.Protocol != model.TUIC {
+		return ""
+	}
+	client := s.findClient(inbound, email)
+	if client == nil {
+		return ""
+	}
+	var settings map[string]any
+	_ = json.Unmarshal([]byte(inbound.Settings), &settings)
+
+	params := quicTLSParams(inbound)
+	if cc, _ := settings["congestionControl"].(string); cc != "" {
+		params["congestion_control"] = cc
+	}
+
+	link := fmt.Sprintf("tuic://%s:%s@%s:%d", client.ID, url.PathEscape(client.Password), s.address, inbound.Port)
+	return s.buildQuicLink(link, params, inbound, email)
+}
+
+// genAccountLink 生成 SOCKS/HTTP 入站的 socks:// 或 http:// 链接，用户名即客户端 email
+func (s *SubService) genAccountLink(inbound *model.Inbound, email string) string {
+	if inbound.Protocol != model.Socks && inbound.Protocol != model.HTTP {
+		return ""
+	}
+	client := s.findClient(inbound, email)
+	if client == nil {
+		return ""
+	}
+	scheme := string(inbound.Protocol)
+	if inbound.Protocol == model.HTTP {
+		var stream map[string]any
+		_ = json.Unmarshal([]byte(inbound.StreamSettings), &stream)
+		if security, _ := stream["security"].(string); security == "tls" {
+			scheme = "https"
+		}
+	}
+	link := url.URL{
+		Scheme:   scheme,
+		User:     url.UserPassword(client.Email, client.Password),
+		Host:     net.JoinHostPort(s.address, strconv.Itoa(inbound.Port)),
+		Fragment: s.genRemark(inbound, email, ""),
+	}
+	return link.String()
+}
+
+// GetWireguardConfig 返回订阅 subId 下指定 peer 的 wg-quick 配置
+func (s *SubService) GetWireguardConfig(subId string, email string, host string) (string, error) {
+	s.address = host
+	inbounds, err := s.getInboundsBySubId(subId)
+	if err != nil {
+		return "", err
+	}
+	for _, inbound := range inbounds {
+		if inbound.Protocol != model.WireGuard {
+			continue
+		}
+		client := s.findClient(inbound, email)
+		if client == nil || client.SubID != subId {
+			continue
+		}
+		if !client.Enable {
+			return "", common.NewError("wireguard peer is disabled: ", email)
+		}
+		conf := s.wireguardClientConfig(inbound, client)
+		if conf == nil {
+			return "", common.NewError("wireguard peer has no exportable private key: ", email)
+		}
+		return conf.String(), nil
+	}
+	return "", common.NewError("No wireguard peer found with ", email)
+}
+
+// genWireguardLink 生成 wireguard:// 链接（v2rayN、Hiddify 等客户端使用的格式）
+func (s *SubService) genWireguardLink(inbound *model.Inbound, email string) string {
+	if inbound.Protocol != model.WireGuard {
+		return ""
+	}
+	client := s.findClient(inbound, email)
+	if client == nil {
+		return ""
+	}
+	conf := s.wireguardClientConfig(inbound, client)
+	if conf == nil {
+		return ""
+	}
+
+	params := url.Values{}
+	params.Set("publickey", conf.ServerPublicKey)
+	if conf.PreSharedKey != "" {
+		params.Set("presharedkey", conf.PreSharedKey)
+	}
+	params.Set("address", strings.Join(conf.Addresses, ","))
+	if conf.MTU > 0 {
+		params.Set("mtu", strconv.Itoa(conf.MTU))
+	}
+	link := url.URL{
+		Scheme:   "wireguard",
+		User:     url.User(conf.PrivateKey),
+		Host:     net.JoinHostPort(s.address, strconv.Itoa(inbound.Port)),
+		Path:     "/",
+		RawQuery: params.Encode(),
+		Fragment: conf.Remark,
+	}
+	return link.String()
+}
+
+// wireguardClientConfig 生成 peer 的客户端配置；私钥未保存在面板（仅有公钥）时返回 nil
+func (s *SubService) wireguardClientConfig(inbound *model.Inbound, client *model.Client) *wireguard.ClientConfig {
+	if client.PrivateKey == "" || len(client.AllowedIPs) == 0 {
+		return nil
+	}
+	var settings struct {
+		SecretKey string `json:"secretKey"`
+		MTU       int    `json:"mtu"`
+		DNS       string `json:"dns"`
+	}
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return nil
+	}
+	serverKey, err := wireguard.PublicKey(settings.SecretKey)
+	if err != nil {
+		return nil
+	}
+	return &wireguard.ClientConfig{
+		PrivateKey:      client.PrivateKey,
+		Addresses:       client.AllowedIPs,
+		DNS:             settings.DNS,
+		MTU:             settings.MTU,
+		ServerPublicKey: serverKey,
+		PreSharedKey:    client.PreSharedKey,
+		Endpoint:        s.address,
+		Port:            inbound.Port,
+		KeepAlive:       client.KeepAlive,
+		Remark:          s.genRemark(inbound, client.Email, ""),
+	}
+}
+
+// findClient 按 email 查找入站中的客户端
+func (s *SubService) findClient(inbound *model.Inbound, email string) *model.Client {
+	clients, err := s.inboundService.GetClients(inbound)
+	if err != nil {
+		return nil
+	}
+	for i := range clients {
+		if clients[i].Email == email {
+			return &clients[i]
+		}
+	}
+	return nil
+}
+
+// quicTLSParams 读取 Hysteria2、TUIC 入站的 sni 和 alpn
+func quicTLSParams(inbound *model.Inbound) map[string]string {
+	var stream map[string]any
+	_ = json.Unmarshal([]byte(inbound.StreamSettings), &stream)
+	params := make(map[string]string)
+	tlsSetting, _ := stream["tlsSettings"].(map[string]any)
+	if sni, _ := tlsSetting["serverName"].(string); sni != "" {
+		params["sni"] = sni
+	}
+	alpns, _ := tlsSetting["alpn"].([]any)
+	var alpn []string
+	for _, a := range alpns {
+		if v, ok := a.(string); ok {
+			alpn = append(alpn, v)
+		}
+	}
+	if len(alpn) > 0 {
+		params["alpn"] = strings.Join(alpn, ",")
+	}
+	return params
+}
+
+func (s *SubService) buildQuicLink(link string, params map[string]string, inbound *model.Inbound, email string) string {
+	url, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	q := url.Query()
+	for k, v := range params {
+		q.Add(k, v)
+	}
+	url.RawQuery = q.Encode()
+	url.Fragment = s.genRemark(inbound, email, "")
+	return url.String()
+}
+
 func (s *SubService) genRemark(inbound *model.Inbound, email string, extra string) string {
 	separationChar := string(s.remarkModel[0])
 	orderChars := s.remarkModel[1:]
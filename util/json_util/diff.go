@@ -0,0 +1,99 @@
+package json_util
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// 差异类型
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// DiffEntry 描述两个 JSON 文档之间的一处差异，Path 形如 routing.rules[2].outboundTag
+type DiffEntry struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// DiffJSON 对两个 JSON 文档做结构化比较，对象按键、数组按下标逐层对比
+func DiffJSON(oldDoc, newDoc []byte) ([]DiffEntry, error) {
+	var a, b any
+	if err := json.Unmarshal(oldDoc, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(newDoc, &b); err != nil {
+		return nil, err
+	}
+	entries := make([]DiffEntry, 0)
+	diffValue("", a, b, &entries)
+	return entries, nil
+}
+
+func diffValue(path string, a, b any, entries *[]DiffEntry) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			diffObject(path, av, bv, entries)
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			diffArray(path, av, bv, entries)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*entries = append(*entries, DiffEntry{Path: path, Op: DiffChanged, Old: a, New: b})
+	}
+}
+
+func diffObject(path string, a, b map[string]any, entries *[]DiffEntry) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inA:
+			*entries = append(*entries, DiffEntry{Path: child, Op: DiffAdded, New: bv})
+		case !inB:
+			*entries = append(*entries, DiffEntry{Path: child, Op: DiffRemoved, Old: av})
+		default:
+			diffValue(child, av, bv, entries)
+		}
+	}
+}
+
+func diffArray(path string, a, b []any, entries *[]DiffEntry) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		child := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= len(a):
+			*entries = append(*entries, DiffEntry{Path: child, Op: DiffAdded, New: b[i]})
+		case i >= len(b):
+			*entries = append(*entries, DiffEntry{Path: child, Op: DiffRemoved, Old: a[i]})
+		default:
+			diffValue(child, a[i], b[i], entries)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package json_util_test
+
+import (
+	"testing"
+
+	"x-ui/util/json_util"
+)
+
+func TestDiffJSON(t *testing.T) {
+	oldDoc := []byte(`{"log":{"loglevel":"warning"},"routing":{"rules":[{"outboundTag":"direct"},{"outboundTag":"blocked"}]},"dns":{}}`)
+	newDoc := []byte(`{"log":{"loglevel":"info"},"routing":{"rules":[{"outboundTag":"warp"}]},"stats":{}}`)
+
+	entries, err := json_util.DiffJSON(oldDoc, newDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"dns":                          json_util.DiffRemoved,
+		"log.loglevel":                 json_util.DiffChanged,
+		"routing.rules[0].outboundTag": json_util.DiffChanged,
+		"routing.rules[1]":             json_util.DiffRemoved,
+		"stats":                        json_util.DiffAdded,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(want), len(entries), entries)
+	}
+	for _, e := range entries {
+		if op, ok := want[e.Path]; !ok || op != e.Op {
+			t.Errorf("unexpected entry %+v", e)
+		}
+	}
+}
+
+func TestDiffJSON_Identical(t *testing.T) {
+	entries, err := json_util.DiffJSON([]byte(`{"a":[1,2],"b":{"c":true}}`), []byte(`{"b":{"c":true},"a":[1,2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no differences, got %+v", entries)
+	}
+}
+
+func TestDiffJSON_Invalid(t *testing.T) {
+	if _, err := json_util.DiffJSON([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
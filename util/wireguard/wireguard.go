@@ -0,0 +1,150 @@
+// Package wireguard 提供 WireGuard 密钥生成、地址池分配和 wg-quick 配置生成
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// DefaultDNS 客户端配置未指定 DNS 时使用的服务器
+const DefaultDNS = "1.1.1.1, 1.0.0.1"
+
+// GenerateKeyPair 生成密钥对（base64 编码），返回私钥和公钥
+func GenerateKeyPair() (string, string, error) {
+	var private [32]byte
+	if _, err := rand.Read(private[:]); err != nil {
+		return "", "", err
+	}
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+	public, err := curve25519.X25519(private[:], curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(private[:]), base64.StdEncoding.EncodeToString(public), nil
+}
+
+// GeneratePreSharedKey 生成 32 字节预共享密钥（base64 编码）
+func GeneratePreSharedKey() (string, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key[:]), nil
+}
+
+// PublicKey 由 base64 编码的私钥计算公钥
+func PublicKey(privateKey string) (string, error) {
+	private, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil || len(private) != 32 {
+		return "", errors.New("invalid wireguard private key")
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(public), nil
+}
+
+// ServerAddress 返回地址池中分配给服务端的地址（第一个可用地址）
+func ServerAddress(pool string) (netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	prefix = prefix.Masked()
+	addr := prefix.Addr().Next()
+	if !addr.IsValid() || !prefix.Contains(addr) {
+		return netip.Addr{}, fmt.Errorf("address pool %s is too small", pool)
+	}
+	return addr, nil
+}
+
+// AllocateAddress 在地址池中为新 peer 分配一个未被 used 占用的单地址前缀（/32 或 /128）。
+// 网络地址和服务端地址不参与分配，IPv4 的广播地址同样跳过
+func AllocateAddress(pool string, used []string) (string, error) {
+	server, err := ServerAddress(pool)
+	if err != nil {
+		return "", err
+	}
+	prefix := netip.MustParsePrefix(pool).Masked()
+
+	taken := make(map[netip.Addr]bool, len(used))
+	for _, u := range used {
+		if addr, ok := parseAddress(u); ok {
+			taken[addr] = true
+		}
+	}
+
+	for addr := server.Next(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		if addr.Is4() && !prefix.Contains(addr.Next()) {
+			break
+		}
+		if !taken[addr] {
+			return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+		}
+	}
+	return "", fmt.Errorf("address pool %s is exhausted", pool)
+}
+
+// parseAddress 解析 "10.0.0.2" 或 "10.0.0.2/32" 形式的地址
+func parseAddress(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Addr(), true
+	}
+	addr, err := netip.ParseAddr(s)
+	return addr, err == nil
+}
+
+// ClientConfig 单个 peer 的 wg-quick 配置
+type ClientConfig struct {
+	PrivateKey      string
+	Addresses       []string
+	DNS             string
+	MTU             int
+	ServerPublicKey string
+	PreSharedKey    string
+	Endpoint        string
+	Port            int
+	KeepAlive       int
+	Remark          string
+}
+
+// String 生成 wg-quick 格式的 .conf 内容
+func (c *ClientConfig) String() string {
+	var b strings.Builder
+	if c.Remark != "" {
+		fmt.Fprintf(&b, "# %s\n", c.Remark)
+	}
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "PrivateKey = %s\n", c.PrivateKey)
+	fmt.Fprintf(&b, "Address = %s\n", strings.Join(c.Addresses, ", "))
+	dns := c.DNS
+	if dns == "" {
+		dns = DefaultDNS
+	}
+	fmt.Fprintf(&b, "DNS = %s\n", dns)
+	if c.MTU > 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", c.MTU)
+	}
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "PublicKey = %s\n", c.ServerPublicKey)
+	if c.PreSharedKey != "" {
+		fmt.Fprintf(&b, "PresharedKey = %s\n", c.PreSharedKey)
+	}
+	b.WriteString("AllowedIPs = 0.0.0.0/0, ::/0\n")
+	fmt.Fprintf(&b, "Endpoint = %s\n", net.JoinHostPort(c.Endpoint, strconv.Itoa(c.Port)))
+	if c.KeepAlive > 0 {
+		fmt.Fprintf(&b, "PersistentKeepalive = %d\n", c.KeepAlive)
+	}
+	return b.String()
+}
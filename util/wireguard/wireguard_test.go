@@ -0,0 +1,78 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	private, public, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	derived, err := PublicKey(private)
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	if derived != public {
+		t.Errorf("derived public key %s, want %s", derived, public)
+	}
+	if _, err := PublicKey("not-a-key"); err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
+
+func TestAllocateAddress(t *testing.T) {
+	addr, err := AllocateAddress("10.0.0.0/24", nil)
+	if err != nil || addr != "10.0.0.2/32" {
+		t.Fatalf("first allocation = %q, %v; want 10.0.0.2/32", addr, err)
+	}
+
+	addr, err = AllocateAddress("10.0.0.0/24", []string{"10.0.0.2/32", "10.0.0.3"})
+	if err != nil || addr != "10.0.0.4/32" {
+		t.Errorf("allocation with used = %q, %v; want 10.0.0.4/32", addr, err)
+	}
+
+	addr, err = AllocateAddress("fd00::/120", nil)
+	if err != nil || addr != "fd00::2/128" {
+		t.Errorf("ipv6 allocation = %q, %v; want fd00::2/128", addr, err)
+	}
+
+	// /30 只有一个可分配地址（.2），.3 为广播地址
+	if _, err := AllocateAddress("10.0.0.0/30", []string{"10.0.0.2/32"}); err == nil {
+		t.Error("expected exhausted pool error")
+	}
+	if _, err := AllocateAddress("bad", nil); err == nil {
+		t.Error("expected error for invalid pool")
+	}
+}
+
+func TestClientConfigString(t *testing.T) {
+	conf := (&ClientConfig{
+		PrivateKey:      "cpriv",
+		Addresses:       []string{"10.0.0.2/32"},
+		MTU:             1420,
+		ServerPublicKey: "spub",
+		PreSharedKey:    "psk",
+		Endpoint:        "2001:db8::1",
+		Port:            51820,
+		KeepAlive:       25,
+		Remark:          "alice",
+	}).String()
+
+	for _, want := range []string{
+		"# alice\n",
+		"PrivateKey = cpriv\n",
+		"Address = 10.0.0.2/32\n",
+		"DNS = " + DefaultDNS + "\n",
+		"MTU = 1420\n",
+		"PublicKey = spub\n",
+		"PresharedKey = psk\n",
+		"Endpoint = [2001:db8::1]:51820\n",
+		"PersistentKeepalive = 25\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q:\n%s", want, conf)
+		}
+	}
+}
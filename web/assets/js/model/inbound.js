@@ -2598,7 +2598,8 @@ Inbound.SocksSettings = class extends Inbound.Settings {
   static fromJson(json = {}) {
     let accounts;
     if (json.auth === "password") {
-      accounts = json.accounts.map((account) =>
+      // accounts are stored as clients (email = user, password = pass)
+      accounts = (json.clients || json.accounts || []).map((account) =>
         Inbound.SocksSettings.SocksAccount.fromJson(account),
       );
     }
@@ -2614,9 +2615,9 @@ Inbound.SocksSettings = class extends Inbound.Settings {
   toJson() {
     return {
       auth: this.auth,
-      accounts:
+      clients:
         this.auth === "password"
-          ? this.accounts.map((account) => account.toJson())
+          ? this.accounts.map((account) => account.toClient())
           : undefined,
       udp: this.udp,
       ip: this.ip,
@@ -2627,14 +2628,25 @@ Inbound.SocksSettings.SocksAccount = class extends XrayCommonClass {
   constructor(
     user = RandomUtil.randomSeq(10),
     pass = RandomUtil.randomSeq(10),
+    client = {},
   ) {
     super();
     this.user = user;
     this.pass = pass;
+    this.client = client;
+  }
+
+  toClient() {
+    return { ...this.client, email: this.user, password: this.pass };
   }
 
   static fromJson(json = {}) {
-    return new Inbound.SocksSettings.SocksAccount(json.user, json.pass);
+    const { user, pass, email, password, ...client } = json;
+    return new Inbound.SocksSettings.SocksAccount(
+      user ?? email,
+      pass ?? password,
+      client,
+    );
   }
 };
 
@@ -2658,9 +2670,10 @@ Inbound.HttpSettings = class extends Inbound.Settings {
   }
 
   static fromJson(json = {}) {
+    // accounts are stored as clients (email = user, password = pass)
     return new Inbound.HttpSettings(
       Protocols.HTTP,
-      json.accounts.map((account) =>
+      (json.clients || json.accounts || []).map((account) =>
         Inbound.HttpSettings.HttpAccount.fromJson(account),
       ),
       json.allowTransparent,
@@ -2669,7 +2682,10 @@ Inbound.HttpSettings = class extends Inbound.Settings {
 
   toJson() {
     return {
-      accounts: Inbound.HttpSettings.toJsonArray(this.accounts),
+      clients:
+        this.accounts.length > 0
+          ? this.accounts.map((account) => account.toClient())
+          : undefined,
       allowTransparent: this.allowTransparent,
     };
   }
@@ -2679,14 +2695,25 @@ Inbound.HttpSettings.HttpAccount = class extends XrayCommonClass {
   constructor(
     user = RandomUtil.randomSeq(10),
     pass = RandomUtil.randomSeq(10),
+    client = {},
   ) {
     super();
     this.user = user;
     this.pass = pass;
+    this.client = client;
+  }
+
+  toClient() {
+    return { ...this.client, email: this.user, password: this.pass };
   }
 
   static fromJson(json = {}) {
-    return new Inbound.HttpSettings.HttpAccount(json.user, json.pass);
+    const { user, pass, email, password, ...client } = json;
+    return new Inbound.HttpSettings.HttpAccount(
+      user ?? email,
+      pass ?? password,
+      client,
+    );
   }
 };
 
@@ -2697,6 +2724,8 @@ Inbound.WireguardSettings = class extends XrayCommonClass {
     secretKey = Wireguard.generateKeypair().privateKey,
     peers = [new Inbound.WireguardSettings.Peer()],
     noKernelTun = false,
+    addressPool = "",
+    dns = "",
   ) {
     super(protocol);
     this.mtu = mtu;
@@ -2707,14 +2736,14 @@ Inbound.WireguardSettings = class extends XrayCommonClass {
         : "";
     this.peers = peers;
     this.noKernelTun = noKernelTun;
+    // peers are stored as clients so that they get traffic stats, expiry and subscriptions
+    this.addressPool = addressPool;
+    this.dns = dns;
   }
 
   addPeer() {
-    this.peers.push(
-      new Inbound.WireguardSettings.Peer(null, null, "", [
-        "10.0.0." + (this.peers.length + 2),
-      ]),
-    );
+    // the panel allocates the address from addressPool when saving
+    this.peers.push(new Inbound.WireguardSettings.Peer(null, null, "", []));
   }
 
   delPeer(index) {
@@ -2726,8 +2755,12 @@ Inbound.WireguardSettings = class extends XrayCommonClass {
       Protocols.WIREGUARD,
       json.mtu,
       json.secretKey,
-      json.peers.map((peer) => Inbound.WireguardSettings.Peer.fromJson(peer)),
+      (json.clients || json.peers || []).map((peer) =>
+        Inbound.WireguardSettings.Peer.fromJson(peer),
+      ),
       json.noKernelTun,
+      json.addressPool,
+      json.dns,
     );
   }
 
@@ -2735,7 +2768,9 @@ Inbound.WireguardSettings = class extends XrayCommonClass {
     return {
       mtu: this.mtu ?? undefined,
       secretKey: this.secretKey,
-      peers: Inbound.WireguardSettings.Peer.toJsonArray(this.peers),
+      addressPool: this.addressPool || undefined,
+      dns: this.dns || undefined,
+      clients: Inbound.WireguardSettings.Peer.toJsonArray(this.peers),
       noKernelTun: this.noKernelTun,
     };
   }
@@ -2748,8 +2783,11 @@ Inbound.WireguardSettings.Peer = class extends XrayCommonClass {
     psk = "",
     allowedIPs = ["10.0.0.2/32"],
     keepAlive = 0,
+    client = {},
   ) {
     super();
+    // client fields (email, subId, enable, totalGB, expiryTime...) are kept as-is
+    this.client = { email: "", ...client };
     this.privateKey = privateKey;
     this.publicKey = publicKey;
     if (!this.publicKey) {
@@ -2766,12 +2804,21 @@ Inbound.WireguardSettings.Peer = class extends XrayCommonClass {
   }
 
   static fromJson(json = {}) {
+    const {
+      privateKey,
+      publicKey,
+      preSharedKey,
+      allowedIPs,
+      keepAlive,
+      ...client
+    } = json;
     return new Inbound.WireguardSettings.Peer(
-      json.privateKey,
-      json.publicKey,
-      json.preSharedKey,
-      json.allowedIPs,
-      json.keepAlive,
+      privateKey,
+      publicKey,
+      preSharedKey,
+      allowedIPs,
+      keepAlive,
+      client,
     );
   }
 
@@ -2780,6 +2827,7 @@ Inbound.WireguardSettings.Peer = class extends XrayCommonClass {
       if (a.length > 0 && !a.includes("/")) this.allowedIPs[index] += "/32";
     });
     return {
+      ...this.client,
       privateKey: this.privateKey,
       publicKey: this.publicKey,
       preSharedKey: this.psk.length > 0 ? this.psk : undefined,
@@ -13,13 +13,16 @@ type APIController struct {
 	BaseController
 	inboundController *InboundController
 	serverController  *ServerController
+	eventController   *EventController
 	Tgbot             service.Tgbot
 	serverService     *service.ServerService
+	eventHub          *service.EventHub
 }
 
-func NewAPIController(g *gin.RouterGroup, serverService *service.ServerService) *APIController {
+func NewAPIController(g *gin.RouterGroup, serverService *service.ServerService, eventHub *service.EventHub) *APIController {
 	a := &APIController{
 		serverService: serverService,
+		eventHub:      eventHub,
 	}
 	a.initRouter(g)
 	return a
@@ -48,6 +51,11 @@ func (a *APIController) initRouter(g *gin.RouterGroup) {
 	server := api.Group("/server")
 	a.serverController = NewServerController(server, a.serverService)
 
+	// Real-time push (SSE)
+	if a.eventHub != nil {
+		a.eventController = NewEventController(api, a.serverService, a.eventHub)
+	}
+
 	// Extra routes
 	api.GET("/backuptotgbot", a.BackuptoTgbot)
 }
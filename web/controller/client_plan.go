@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"strconv"
+
+	"x-ui/database/model"
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientPlanController 提供客户端套餐管理，以及批量迁移客户端和重新应用套餐的接口
+type ClientPlanController struct {
+	clientPlanService *service.ClientPlanService
+	xrayService       *service.XrayService
+}
+
+// NewClientPlanController 创建 ClientPlanController 实例
+func NewClientPlanController(g *gin.RouterGroup, inboundService *service.InboundService, xrayService *service.XrayService) *ClientPlanController {
+	a := &ClientPlanController{
+		clientPlanService: service.NewClientPlanService(inboundService),
+		xrayService:       xrayService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *ClientPlanController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/plans")
+
+	g.GET("/list", a.getPlans)
+	g.GET("/get/:id", a.getPlan)
+	g.GET("/clients/:id", a.getPlanClients)
+	g.POST("/add", a.addPlan)
+	g.POST("/update/:id", a.updatePlan)
+	g.POST("/del/:id", a.delPlan)
+	g.POST("/assign/:id", a.assignPlan)
+	g.POST("/sync/:id", a.syncPlan)
+}
+
+func (a *ClientPlanController) planId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "get"), err)
+		return 0, false
+	}
+	return id, true
+}
+
+func (a *ClientPlanController) getPlans(c *gin.Context) {
+	plans, err := a.clientPlanService.GetPlans()
+	jsonObj(c, plans, err)
+}
+
+func (a *ClientPlanController) getPlan(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	plan, err := a.clientPlanService.GetPlan(id)
+	jsonObj(c, plan, err)
+}
+
+// getPlanClients 返回订阅了套餐的客户端 email
+func (a *ClientPlanController) getPlanClients(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	emails, err := a.clientPlanService.GetPlanClients(id)
+	jsonObj(c, emails, err)
+}
+
+func (a *ClientPlanController) addPlan(c *gin.Context) {
+	plan := &model.ClientPlan{}
+	if err := c.ShouldBind(plan); err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	err := a.clientPlanService.AddPlan(plan)
+	jsonMsgObj(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), plan, err)
+}
+
+func (a *ClientPlanController) updatePlan(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	plan := &model.ClientPlan{}
+	if err := c.ShouldBind(plan); err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	plan.Id = id
+	err := a.clientPlanService.UpdatePlan(plan)
+	jsonMsgObj(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), plan, err)
+}
+
+func (a *ClientPlanController) delPlan(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	err := a.clientPlanService.DelPlan(id)
+	jsonMsg(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), err)
+}
+
+// assignPlan 将请求体 emails 中的客户端迁移到套餐
+func (a *ClientPlanController) assignPlan(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Emails []string `json:"emails" form:"emails"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	result, err := a.clientPlanService.AssignPlan(id, req.Emails)
+	a.respondBulk(c, result, err)
+}
+
+// syncPlan 将套餐的当前限制重新应用到所有订阅客户端
+func (a *ClientPlanController) syncPlan(c *gin.Context) {
+	id, ok := a.planId(c)
+	if !ok {
+		return
+	}
+	result, err := a.clientPlanService.SyncPlan(id)
+	a.respondBulk(c, result, err)
+}
+
+// respondBulk 返回批量应用结果，客户端配置发生变化时标记需要重新应用 Xray 配置
+func (a *ClientPlanController) respondBulk(c *gin.Context, result *service.ClientPlanApplyResult, err error) {
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	if result.NeedRestart {
+		a.xrayService.SetToNeedRestart()
+	}
+	jsonMsgObj(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), result, nil)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"strconv"
+
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceLimitController 提供设备超限处理记录、手动解封和白名单管理接口。
+// 解封和白名单修改只更新数据库记录，由设备限制任务在下一轮检查时恢复 Xray 中的用户
+type DeviceLimitController struct {
+	deviceLimitService *service.DeviceLimitService
+}
+
+// NewDeviceLimitController 创建 DeviceLimitController 实例
+func NewDeviceLimitController(g *gin.RouterGroup) *DeviceLimitController {
+	a := &DeviceLimitController{
+		deviceLimitService: &service.DeviceLimitService{},
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *DeviceLimitController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/deviceLimit")
+
+	g.GET("/bans", a.getBans)
+	g.GET("/history/:email", a.getHistory)
+	g.POST("/unban/:email", a.unban)
+	g.GET("/whitelist", a.getWhitelist)
+	g.POST("/whitelist/add/:email", a.addWhitelist)
+	g.POST("/whitelist/del/:email", a.delWhitelist)
+}
+
+func (a *DeviceLimitController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), err)
+}
+
+// getBans 返回所有仍在生效的处理记录
+func (a *DeviceLimitController) getBans(c *gin.Context) {
+	bans, err := a.deviceLimitService.GetActiveBans()
+	jsonObj(c, bans, err)
+}
+
+// getHistory 返回客户端的处理历史，可通过 limit 参数限制条数
+func (a *DeviceLimitController) getHistory(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	history, err := a.deviceLimitService.GetBanHistory(c.Param("email"), limit)
+	jsonObj(c, history, err)
+}
+
+func (a *DeviceLimitController) unban(c *gin.Context) {
+	a.respond(c, a.deviceLimitService.Unban(c.Param("email")))
+}
+
+func (a *DeviceLimitController) getWhitelist(c *gin.Context) {
+	whitelist, err := a.deviceLimitService.GetWhitelist()
+	jsonObj(c, whitelist, err)
+}
+
+func (a *DeviceLimitController) addWhitelist(c *gin.Context) {
+	a.respond(c, a.deviceLimitService.AddWhitelist(c.Param("email")))
+}
+
+func (a *DeviceLimitController) delWhitelist(c *gin.Context) {
+	a.respond(c, a.deviceLimitService.RemoveWhitelist(c.Param("email")))
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"x-ui/web/global"
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeatInterval SSE 心跳间隔，防止反向代理因空闲而断开连接
+const eventHeartbeatInterval = 15 * time.Second
+
+// EventController 通过 SSE 向前端推送状态、流量、在线状态、封禁事件和日志
+type EventController struct {
+	BaseController
+
+	eventHub      *service.EventHub
+	serverService *service.ServerService
+
+	// lastStatus 由定时任务写入、各 SSE 连接读取
+	lastStatus atomic.Pointer[service.Status]
+	// 服务器上下文，服务器停止时结束所有长连接，避免阻塞平滑重启
+	serverCtx context.Context
+}
+
+// NewEventController 创建 EventController，路由挂载在已鉴权的 API 分组下
+func NewEventController(g *gin.RouterGroup, serverService *service.ServerService, eventHub *service.EventHub) *EventController {
+	a := &EventController{
+		eventHub:      eventHub,
+		serverService: serverService,
+		serverCtx:     context.Background(),
+	}
+	if webServer := global.GetWebServer(); webServer != nil && webServer.GetCtx() != nil {
+		a.serverCtx = webServer.GetCtx()
+	}
+	a.initRouter(g)
+	a.startTask()
+	return a
+}
+
+func (a *EventController) initRouter(g *gin.RouterGroup) {
+	g.GET("/events", a.stream)
+}
+
+// startTask 仅在有 status 订阅者时采集并推送服务器状态
+func (a *EventController) startTask() {
+	webServer := global.GetWebServer()
+	if webServer == nil || webServer.GetCron() == nil {
+		return
+	}
+	_, _ = webServer.GetCron().AddFunc("@every 2s", func() {
+		if !a.eventHub.HasSubscribers(service.TopicStatus) {
+			return
+		}
+		status := a.serverService.GetStatus(a.lastStatus.Load())
+		a.lastStatus.Store(status)
+		a.eventHub.Publish(service.TopicStatus, status)
+	})
+}
+
+// stream 建立 SSE 长连接，topics 参数为逗号分隔的主题列表，为空时订阅全部
+func (a *EventController) stream(c *gin.Context) {
+	topics := service.ParseEventTopics(c.Query("topics"))
+	sub := a.eventHub.Subscribe(topics, 0)
+	defer a.eventHub.Unsubscribe(sub)
+
+	// 长连接不受 http.Server WriteTimeout 的限制
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 连接建立后立即推送一次最新状态，前端无需等待下一个采集周期
+	if status := a.lastStatus.Load(); sub.Has(service.TopicStatus) && status != nil {
+		writeEvent(c, &service.Event{Topic: service.TopicStatus, Time: time.Now().UnixMilli(), Data: status})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-a.serverCtx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if err := writeEvent(c, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeEvent 按 SSE 格式写出单个事件
+func writeEvent(c *gin.Context, event *service.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Topic, data)
+	return err
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"io"
+	"strconv"
+
+	"x-ui/database/model"
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeoDataController 提供地理数据来源的管理接口：自定义来源、上传、立即更新和回滚。
+// 文件内容发生变化时重启 Xray，未变化时不重启
+type GeoDataController struct {
+	geoDataService service.GeoDataService
+	serverService  *service.ServerService
+}
+
+// NewGeoDataController 创建 GeoDataController 实例
+func NewGeoDataController(g *gin.RouterGroup, serverService *service.ServerService) *GeoDataController {
+	a := &GeoDataController{
+		serverService: serverService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *GeoDataController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/geo")
+
+	g.GET("/sources", a.getSources)
+	g.POST("/sources/add", a.addSource)
+	g.POST("/sources/update/:id", a.updateSource)
+	g.POST("/sources/del/:id", a.delSource)
+	g.POST("/sources/refresh/:id", a.refreshSource)
+	g.POST("/sources/upload/:id", a.uploadSource)
+	g.POST("/sources/rollback/:id", a.rollbackSource)
+}
+
+func (a *GeoDataController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+}
+
+// applyChange 文件发生变化时重启 Xray，使新的地理数据生效
+func (a *GeoDataController) applyChange(c *gin.Context, changed bool, err error) {
+	if err == nil && changed {
+		err = a.serverService.RestartXrayService()
+	}
+	jsonMsgObj(c, I18nWeb(c, "pages.index.geofileUpdatePopover"), gin.H{"changed": changed}, err)
+}
+
+func (a *GeoDataController) getSources(c *gin.Context) {
+	sources, err := a.geoDataService.GetGeoSources()
+	jsonObj(c, sources, err)
+}
+
+func (a *GeoDataController) addSource(c *gin.Context) {
+	source := &model.GeoSource{}
+	if err := c.ShouldBind(source); err != nil {
+		a.respond(c, err)
+		return
+	}
+	err := a.geoDataService.AddGeoSource(source)
+	jsonMsgObj(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), source, err)
+}
+
+func (a *GeoDataController) updateSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	source := &model.GeoSource{}
+	if err := c.ShouldBind(source); err != nil {
+		a.respond(c, err)
+		return
+	}
+	source.Id = id
+	a.respond(c, a.geoDataService.UpdateGeoSource(source))
+}
+
+func (a *GeoDataController) delSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.geoDataService.DelGeoSource(id))
+}
+
+func (a *GeoDataController) refreshSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	changed, err := a.geoDataService.RefreshGeoSource(id)
+	a.applyChange(c, changed, err)
+}
+
+// uploadSource 上传 .dat 文件或纯文本列表（表单字段 file），按来源格式校验或编译后安装
+func (a *GeoDataController) uploadSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	changed, err := a.geoDataService.UploadGeoSource(id, content)
+	a.applyChange(c, changed, err)
+}
+
+func (a *GeoDataController) rollbackSource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	err = a.geoDataService.RollbackGeoSource(id)
+	a.applyChange(c, err == nil, err)
+}
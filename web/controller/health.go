@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http"
+
+	"x-ui/web/service"
+	"x-ui/web/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController 提供无需登录的存活/就绪探针，登录用户可获得详细检查结果
+type HealthController struct {
+	BaseController
+
+	healthService *service.HealthService
+}
+
+// NewHealthController 创建 HealthController 实例
+func NewHealthController(g *gin.RouterGroup, healthService *service.HealthService) *HealthController {
+	a := &HealthController{
+		healthService: healthService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *HealthController) initRouter(g *gin.RouterGroup) {
+	g.GET("/healthz", a.healthz)
+	g.GET("/readyz", a.readyz)
+}
+
+func (a *HealthController) healthz(c *gin.Context) {
+	a.respond(c, a.healthService.Liveness(c.Request.Context()))
+}
+
+func (a *HealthController) readyz(c *gin.Context) {
+	a.respond(c, a.healthService.Readiness(c.Request.Context()))
+}
+
+// respond 失败时返回 503；未登录调用方只能看到整体状态，避免泄露内部信息
+func (a *HealthController) respond(c *gin.Context, report *service.HealthReport) {
+	code := http.StatusOK
+	if report.Status == service.HealthFail {
+		code = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	if session.IsLogin(c) {
+		c.JSON(code, report)
+		return
+	}
+	c.JSON(code, gin.H{"status": report.Status})
+}
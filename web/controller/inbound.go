@@ -14,14 +14,16 @@ import (
 )
 
 type InboundController struct {
-	inboundService *service.InboundService
-	xrayService    *service.XrayService
+	inboundService   *service.InboundService
+	xrayService      *service.XrayService
+	accessLogService *service.AccessLogService
 }
 
 func NewInboundController(g *gin.RouterGroup) *InboundController {
 	a := &InboundController{
-		inboundService: &service.InboundService{},
-		xrayService:    &service.XrayService{},
+		inboundService:   &service.InboundService{},
+		xrayService:      &service.XrayService{},
+		accessLogService: &service.AccessLogService{},
 	}
 	a.initRouter(g)
 	return a
@@ -32,12 +34,17 @@ func (a *InboundController) initRouter(g *gin.RouterGroup) {
 	g.GET("/get/:id", a.getInbound)
 	g.GET("/getClientTraffics/:email", a.getClientTraffics)
 	g.GET("/getClientTrafficsById/:id", a.getClientTrafficsById)
+	g.GET("/clientAccessLogs/:email", a.getClientAccessLogs)
+	g.GET("/clientTopDestinations/:email", a.getClientTopDestinations)
+	g.GET("/quotaArchives/:id", a.getQuotaArchives)
+	g.GET("/clientQuotaArchives/:email", a.getClientQuotaArchives)
 
 	g.POST("/add", a.addInbound)
 	g.POST("/del/:id", a.delInbound)
 	g.POST("/update/:id", a.updateInbound)
 	g.POST("/clientIps/:email", a.getClientIps)
 	g.POST("/clearClientIps/:email", a.clearClientIps)
+	g.POST("/clearClientAccessLogs/:email", a.clearClientAccessLogs)
 	g.POST("/addClient", a.addInboundClient)
 	g.POST("/:id/delClient/:clientId", a.delInboundClient)
 	g.POST("/updateClient/:clientId", a.updateInboundClient)
@@ -49,6 +56,11 @@ func (a *InboundController) initRouter(g *gin.RouterGroup) {
 	g.POST("/onlines", a.onlines)
 	g.POST("/lastOnline", a.lastOnline)
 	g.POST("/updateClientTraffic/:email", a.updateClientTraffic)
+	g.POST("/:id/rotateShadowsocksKey", a.rotateShadowsocksKey)
+
+	NewPortForwardController(g, a.inboundService, a.xrayService)
+	NewDeviceLimitController(g)
+	NewClientPlanController(g, a.inboundService, a.xrayService)
 }
 
 func (a *InboundController) getInbounds(c *gin.Context) {
@@ -194,6 +206,56 @@ func (a *InboundController) clearClientIps(c *gin.Context) {
 	jsonMsg(c, I18nWeb(c, "pages.inbounds.toasts.logCleanSuccess"), nil)
 }
 
+// getClientAccessLogs 查询客户端的访问记录，since 为 Unix 时间戳，默认返回最近 100 条
+func (a *InboundController) getClientAccessLogs(c *gin.Context) {
+	since, _ := strconv.ParseInt(c.Query("since"), 10, 64)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	entries, err := a.accessLogService.GetClientAccessLogs(c.Param("email"), since, limit)
+	jsonObj(c, entries, err)
+}
+
+// getClientTopDestinations 统计客户端最近 hours 小时（默认 24）内访问最多的目标
+func (a *InboundController) getClientTopDestinations(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	stats, err := a.accessLogService.GetClientTopDestinations(c.Param("email"), hours, limit)
+	jsonObj(c, stats, err)
+}
+
+// getQuotaArchives 返回入站按周期重置前归档的历史用量
+func (a *InboundController) getQuotaArchives(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "get"), err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	archives, err := a.inboundService.GetQuotaArchives(id, "", limit)
+	jsonObj(c, archives, err)
+}
+
+// getClientQuotaArchives 返回客户端按周期重置前归档的历史用量
+func (a *InboundController) getClientQuotaArchives(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	archives, err := a.inboundService.GetQuotaArchives(0, c.Param("email"), limit)
+	jsonObj(c, archives, err)
+}
+
+func (a *InboundController) clearClientAccessLogs(c *gin.Context) {
+	err := a.accessLogService.ClearClientAccessLogs(c.Param("email"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.inbounds.toasts.updateSuccess"), err)
+		return
+	}
+	jsonMsg(c, I18nWeb(c, "pages.inbounds.toasts.logCleanSuccess"), nil)
+}
+
 func (a *InboundController) addInboundClient(c *gin.Context) {
 	data := &model.Inbound{}
 	err := c.ShouldBind(data)
@@ -278,6 +340,26 @@ func (a *InboundController) resetClientTraffic(c *gin.Context) {
 	}
 }
 
+// rotateShadowsocksKey 更换 SS2022 入站的服务端密钥，rotateClients=true 时同时更换所有用户密钥，
+// 返回需要重新获取分享链接的客户端 email
+func (a *InboundController) rotateShadowsocksKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	rotateClients, _ := strconv.ParseBool(c.PostForm("rotateClients"))
+
+	emails, err := a.inboundService.RotateShadowsocksKey(id, rotateClients)
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	jsonMsgObj(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), emails, nil)
+	// 服务端密钥变化只能通过替换入站生效
+	a.xrayService.SetToNeedRestart()
+}
+
 func (a *InboundController) resetAllTraffics(c *gin.Context) {
 	err := a.inboundService.ResetAllTraffics()
 	if err != nil {
@@ -359,20 +441,10 @@ func (a *InboundController) validateInboundData(inbound *model.Inbound) error {
 		return fmt.Errorf("invalid port number: %d, must be between 1 and 65535", inbound.Port)
 	}
 
-	// 验证协议
-	validProtocols := map[model.Protocol]bool{
-		model.VMESS:       true,
-		model.VLESS:       true,
-		model.Tunnel:      true,
-		model.HTTP:        true,
-		model.Trojan:      true,
-		model.Shadowsocks: true,
-		model.Socks:       true,
-		model.WireGuard:   true,
-		model.TUN:         true,
-	}
-	if !validProtocols[inbound.Protocol] {
-		return fmt.Errorf("invalid protocol: %s", inbound.Protocol)
+	// 验证协议，Hysteria2、TUIC 需要选择 sing-box 后端
+	settingService := service.SettingService{}
+	if err := settingService.CheckCoreProtocol(inbound.Protocol); err != nil {
+		return err
 	}
 
 	// 验证 Settings 是否为有效的 JSON
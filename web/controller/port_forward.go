@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"x-ui/web/service"
+	"x-ui/web/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PortForwardController 提供基于 tunnel 入站的端口转发管理接口
+type PortForwardController struct {
+	inboundService *service.InboundService
+	xrayService    *service.XrayService
+}
+
+// NewPortForwardController 创建 PortForwardController 实例
+func NewPortForwardController(g *gin.RouterGroup, inboundService *service.InboundService, xrayService *service.XrayService) *PortForwardController {
+	a := &PortForwardController{
+		inboundService: inboundService,
+		xrayService:    xrayService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *PortForwardController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/forwards")
+
+	g.GET("/list", a.getForwards)
+	g.POST("/add", a.addForward)
+	g.POST("/update/:id", a.updateForward)
+	g.POST("/enable/:id", a.setEnable)
+	g.POST("/del/:id", a.delForward)
+}
+
+// respond 返回操作结果，配置发生变化时标记需要重新应用 Xray 配置
+func (a *PortForwardController) respond(c *gin.Context, obj any, needRestart bool, err error) {
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	jsonMsgObj(c, I18nWeb(c, "pages.inbounds.toasts.inboundUpdateSuccess"), obj, nil)
+	if needRestart {
+		a.xrayService.SetToNeedRestart()
+	}
+}
+
+// getForwards 返回端口转发列表及各自的流量统计
+func (a *PortForwardController) getForwards(c *gin.Context) {
+	forwards, err := a.inboundService.GetPortForwards()
+	jsonObj(c, forwards, err)
+}
+
+func (a *PortForwardController) addForward(c *gin.Context) {
+	forward := &service.PortForward{}
+	if err := c.ShouldBindJSON(forward); err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	user := session.GetLoginUser(c)
+	if user == nil {
+		jsonMsg(c, I18nWeb(c, "login.loginFailed"), errors.New("user not logged in"))
+		return
+	}
+	forward, needRestart, err := a.inboundService.AddPortForward(user.Id, forward)
+	a.respond(c, forward, needRestart, err)
+}
+
+func (a *PortForwardController) updateForward(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	forward := &service.PortForward{}
+	if err := c.ShouldBindJSON(forward); err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	forward.Id = id
+	forward, needRestart, err := a.inboundService.UpdatePortForward(forward)
+	a.respond(c, forward, needRestart, err)
+}
+
+func (a *PortForwardController) setEnable(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	enable, err := strconv.ParseBool(c.PostForm("enable"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	needRestart, err := a.inboundService.SetPortForwardEnable(id, enable)
+	a.respond(c, nil, needRestart, err)
+}
+
+func (a *PortForwardController) delForward(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "somethingWentWrong"), err)
+		return
+	}
+	needRestart, err := a.inboundService.DelPortForward(id)
+	a.respond(c, nil, needRestart, err)
+}
@@ -50,10 +50,15 @@ func (a *ServerController) initRouter(g *gin.RouterGroup) {
 	g.GET("/getNewmldsa65", a.getNewmldsa65)
 	g.GET("/getNewmlkem768", a.getNewmlkem768)
 	g.GET("/getNewVlessEnc", a.getNewVlessEnc)
+	g.GET("/xrayReconcile", a.getXrayReconcile)
+	g.GET("/outboundHealth", a.getOutboundHealth)
+	g.GET("/crashReports", a.getCrashReports)
+	g.GET("/crashReports/:name", a.getCrashReport)
 
 	g.POST("/stopXrayService", a.stopXrayService)
 	g.POST("/restartXrayService", a.restartXrayService)
 	g.POST("/installXray/:version", a.installXray)
+	g.POST("/installSingBox/:version", a.installSingBox)
 	g.POST("/updateGeofile", a.updateGeofile)
 	g.POST("/updateGeofile/:fileName", a.updateGeofile)
 	g.POST("/logs/:count", a.getLogs)
@@ -65,6 +70,8 @@ func (a *ServerController) initRouter(g *gin.RouterGroup) {
 	g.POST("/openPort", a.openPort)
 	g.GET("/getNewSNI", a.getNewSNI)
 	g.GET("/getRandomRealitySNI", a.getRandomRealitySNI)
+
+	NewGeoDataController(g, a.serverService)
 }
 
 func (a *ServerController) refreshStatus() {
@@ -117,6 +124,12 @@ func (a *ServerController) installXray(c *gin.Context) {
 	jsonMsg(c, I18nWeb(c, "pages.index.xraySwitchVersionPopover"), err)
 }
 
+func (a *ServerController) installSingBox(c *gin.Context) {
+	version := c.Param("version")
+	err := a.serverService.UpdateSingBox(version)
+	jsonMsg(c, I18nWeb(c, "pages.index.xraySwitchVersionPopover"), err)
+}
+
 func (a *ServerController) updateGeofile(c *gin.Context) {
 	fileName := c.Param("fileName")
 	// Validate the filename for security (prevent path traversal attacks)
@@ -149,6 +162,16 @@ func (a *ServerController) restartXrayService(c *gin.Context) {
 	jsonMsg(c, I18nWeb(c, "pages.xray.restartSuccess"), err)
 }
 
+// getXrayReconcile 返回最近一次配置应用时热更新的入站/用户以及需要重启的原因
+func (a *ServerController) getXrayReconcile(c *gin.Context) {
+	jsonObj(c, a.serverService.GetXrayReconcileResult(), nil)
+}
+
+// getOutboundHealth 返回各出站的存活状态、延迟历史和最近错误
+func (a *ServerController) getOutboundHealth(c *gin.Context) {
+	jsonObj(c, a.serverService.GetOutboundHealth(), nil)
+}
+
 func (a *ServerController) getLogs(c *gin.Context) {
 	count := c.Param("count")
 	level := c.PostForm("level")
@@ -232,6 +255,29 @@ func (a *ServerController) getDb(c *gin.Context) {
 	_, _ = c.Writer.Write(db)
 }
 
+// getCrashReports 返回 Xray 崩溃报告列表
+func (a *ServerController) getCrashReports(c *gin.Context) {
+	reports, err := a.serverService.GetCrashReports()
+	jsonObj(c, reports, err)
+}
+
+// getCrashReport 下载指定的崩溃报告
+func (a *ServerController) getCrashReport(c *gin.Context) {
+	name := c.Param("name")
+	if !isValidFilename(name) {
+		_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid filename"))
+		return
+	}
+	data, err := a.serverService.GetCrashReport(name)
+	if err != nil {
+		jsonMsg(c, "crash report", err)
+		return
+	}
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	_, _ = c.Writer.Write(data)
+}
+
 func isValidFilename(filename string) bool {
 	// Validate that the filename only contains allowed characters
 	return filenameRegex.MatchString(filename)
@@ -8,6 +8,7 @@ import (
 	"x-ui/config"
 	"x-ui/logger"
 	"x-ui/web/entity"
+	"x-ui/web/session"
 
 	"github.com/gin-gonic/gin"
 )
@@ -94,3 +95,11 @@ func getContext(h gin.H) gin.H {
 func isAjax(c *gin.Context) bool {
 	return c.GetHeader("X-Requested-With") == "XMLHttpRequest"
 }
+
+// loginUsername 返回当前登录用户名，用于记录操作人
+func loginUsername(c *gin.Context) string {
+	if user := session.GetLoginUser(c); user != nil {
+		return user.Username
+	}
+	return ""
+}
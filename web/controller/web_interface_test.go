@@ -336,7 +336,7 @@ func TestAPIController_BackuptoTgbot(t *testing.T) {
 
 	// 创建API控制器
 	// Controller calls initRouter which appends /panel/api, so we pass root group
-	apiController := NewAPIController(router.Group("/"), mockServerService)
+	apiController := NewAPIController(router.Group("/"), mockServerService, nil)
 	_ = apiController
 
 	// 创建测试请求
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"x-ui/database/model"
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// XrayOutboundController 提供出站的增删改查、分享链接导入和出站订阅管理接口
+type XrayOutboundController struct {
+	xraySettingService *service.XraySettingService
+}
+
+// NewXrayOutboundController 创建 XrayOutboundController 实例
+func NewXrayOutboundController(g *gin.RouterGroup, xraySettingService *service.XraySettingService) *XrayOutboundController {
+	a := &XrayOutboundController{
+		xraySettingService: xraySettingService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *XrayOutboundController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/outbounds")
+
+	g.GET("/", a.getOutbounds)
+	g.POST("/add", a.addOutbound)
+	g.POST("/update/:tag", a.updateOutbound)
+	g.POST("/del/:tag", a.delOutbound)
+	g.POST("/import", a.importLinks)
+
+	g.GET("/subscriptions", a.getSubscriptions)
+	g.POST("/subscriptions/add", a.addSubscription)
+	g.POST("/subscriptions/update/:id", a.updateSubscription)
+	g.POST("/subscriptions/del/:id", a.delSubscription)
+	g.POST("/subscriptions/refresh/:id", a.refreshSubscription)
+}
+
+func (a *XrayOutboundController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+}
+
+func (a *XrayOutboundController) getOutbounds(c *gin.Context) {
+	outbounds, err := a.xraySettingService.GetOutbounds()
+	jsonObj(c, outbounds, err)
+}
+
+func (a *XrayOutboundController) addOutbound(c *gin.Context) {
+	var config json.RawMessage
+	if err := c.ShouldBindJSON(&config); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.AddOutbound(config, loginUsername(c)))
+}
+
+func (a *XrayOutboundController) updateOutbound(c *gin.Context) {
+	var config json.RawMessage
+	if err := c.ShouldBindJSON(&config); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.UpdateOutbound(c.Param("tag"), config, loginUsername(c)))
+}
+
+func (a *XrayOutboundController) delOutbound(c *gin.Context) {
+	a.respond(c, a.xraySettingService.DelOutbound(c.Param("tag"), loginUsername(c)))
+}
+
+func (a *XrayOutboundController) importLinks(c *gin.Context) {
+	var req struct {
+		Links     string `json:"links" form:"links"`
+		TagPrefix string `json:"tagPrefix" form:"tagPrefix"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		a.respond(c, err)
+		return
+	}
+	tags, err := a.xraySettingService.ImportOutboundLinks(req.Links, req.TagPrefix, loginUsername(c))
+	jsonMsgObj(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), tags, err)
+}
+
+func (a *XrayOutboundController) getSubscriptions(c *gin.Context) {
+	subs, err := a.xraySettingService.GetOutboundSubscriptions()
+	jsonObj(c, subs, err)
+}
+
+func (a *XrayOutboundController) addSubscription(c *gin.Context) {
+	sub := &model.OutboundSubscription{}
+	if err := c.ShouldBind(sub); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.AddOutboundSubscription(sub, loginUsername(c)))
+}
+
+func (a *XrayOutboundController) updateSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	sub := &model.OutboundSubscription{}
+	if err := c.ShouldBind(sub); err != nil {
+		a.respond(c, err)
+		return
+	}
+	sub.Id = id
+	a.respond(c, a.xraySettingService.UpdateOutboundSubscription(sub))
+}
+
+func (a *XrayOutboundController) delSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.DelOutboundSubscription(id, loginUsername(c)))
+}
+
+func (a *XrayOutboundController) refreshSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	_, err = a.xraySettingService.RefreshOutboundSubscription(id, loginUsername(c))
+	a.respond(c, err)
+}
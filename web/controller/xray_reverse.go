@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net"
+
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// XrayReverseController 提供反向代理（portal/bridge）的创建、删除、bridge 配置导出和连接状态接口
+type XrayReverseController struct {
+	xraySettingService *service.XraySettingService
+	serverService      *service.ServerService
+}
+
+// NewXrayReverseController 创建 XrayReverseController 实例
+func NewXrayReverseController(g *gin.RouterGroup, xraySettingService *service.XraySettingService, serverService *service.ServerService) *XrayReverseController {
+	a := &XrayReverseController{
+		xraySettingService: xraySettingService,
+		serverService:      serverService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *XrayReverseController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/reverse")
+
+	g.GET("/", a.getTunnels)
+	g.POST("/add", a.addTunnel)
+	g.POST("/del/:tag", a.delTunnel)
+	g.GET("/bridge/:tag", a.getBridgeConfig)
+}
+
+func (a *XrayReverseController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+}
+
+// getTunnels 返回反向代理列表及 bridge 的连接状态
+func (a *XrayReverseController) getTunnels(c *gin.Context) {
+	tunnels, err := a.xraySettingService.GetReverseTunnels()
+	if err != nil {
+		jsonObj(c, nil, err)
+		return
+	}
+	jsonObj(c, a.serverService.GetReverseTunnelStatus(tunnels), nil)
+}
+
+// addTunnel 创建反向代理，返回补全后的参数（自动生成的 bridgeId 和 domain）
+func (a *XrayReverseController) addTunnel(c *gin.Context) {
+	tunnel := &service.ReverseTunnel{}
+	if err := c.ShouldBindJSON(tunnel); err != nil {
+		a.respond(c, err)
+		return
+	}
+	err := a.xraySettingService.AddReverseTunnel(tunnel, loginUsername(c))
+	jsonMsgObj(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), tunnel, err)
+}
+
+func (a *XrayReverseController) delTunnel(c *gin.Context) {
+	a.respond(c, a.xraySettingService.DelReverseTunnel(c.Param("tag"), loginUsername(c)))
+}
+
+// getBridgeConfig 导出 bridge 一侧的 Xray 配置，address 未指定时使用访问面板的主机名
+func (a *XrayReverseController) getBridgeConfig(c *gin.Context) {
+	address := c.Query("address")
+	if address == "" {
+		var err error
+		address, _, err = net.SplitHostPort(c.Request.Host)
+		if err != nil {
+			address = c.Request.Host
+		}
+	}
+	config, err := a.xraySettingService.GetReverseBridgeConfig(c.Param("tag"), address)
+	jsonObj(c, config, err)
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"strconv"
+
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// XrayRoutingController 提供路由规则、负载均衡器、DNS 服务器和 FakeDNS 地址池的结构化管理接口，
+// 所有修改都写回 Xray 配置模板，并与手动编辑模板一样经过完整校验和历史记录
+type XrayRoutingController struct {
+	xraySettingService *service.XraySettingService
+}
+
+// NewXrayRoutingController 创建 XrayRoutingController 实例
+func NewXrayRoutingController(g *gin.RouterGroup, xraySettingService *service.XraySettingService) *XrayRoutingController {
+	a := &XrayRoutingController{
+		xraySettingService: xraySettingService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *XrayRoutingController) initRouter(g *gin.RouterGroup) {
+	routing := g.Group("/routing")
+	routing.GET("/rules", a.getRules)
+	routing.POST("/rules/add", a.addRule)
+	routing.POST("/rules/update/:index", a.updateRule)
+	routing.POST("/rules/del/:index", a.delRule)
+	routing.POST("/rules/move", a.moveRule)
+
+	routing.GET("/balancers", a.getBalancers)
+	routing.POST("/balancers/save", a.saveBalancer)
+	routing.POST("/balancers/del/:tag", a.delBalancer)
+
+	dns := g.Group("/dns")
+	dns.GET("/servers", a.getDNSServers)
+	dns.POST("/servers/add", a.addDNSServer)
+	dns.POST("/servers/update/:index", a.updateDNSServer)
+	dns.POST("/servers/del/:index", a.delDNSServer)
+
+	dns.GET("/fakedns", a.getFakeDNSPools)
+	dns.POST("/fakedns/add", a.addFakeDNSPool)
+	dns.POST("/fakedns/update/:index", a.updateFakeDNSPool)
+	dns.POST("/fakedns/del/:index", a.delFakeDNSPool)
+}
+
+// indexParam 解析路径中的序号参数
+func indexParam(c *gin.Context) (int, bool) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+		return 0, false
+	}
+	return index, true
+}
+
+// insertPosition 解析可选的插入位置，未指定时追加到末尾
+func insertPosition(c *gin.Context) int {
+	index, err := strconv.Atoi(c.Query("index"))
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+func (a *XrayRoutingController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+}
+
+func (a *XrayRoutingController) getRules(c *gin.Context) {
+	rules, err := a.xraySettingService.GetRoutingRules()
+	jsonObj(c, rules, err)
+}
+
+func (a *XrayRoutingController) addRule(c *gin.Context) {
+	rule := service.RoutingRule{}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.AddRoutingRule(rule, insertPosition(c), loginUsername(c)))
+}
+
+func (a *XrayRoutingController) updateRule(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	rule := service.RoutingRule{}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.UpdateRoutingRule(index, rule, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) delRule(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	a.respond(c, a.xraySettingService.DelRoutingRule(index, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) moveRule(c *gin.Context) {
+	var req struct {
+		From int `json:"from" form:"from"`
+		To   int `json:"to" form:"to"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.MoveRoutingRule(req.From, req.To, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) getBalancers(c *gin.Context) {
+	balancers, err := a.xraySettingService.GetBalancers()
+	jsonObj(c, balancers, err)
+}
+
+func (a *XrayRoutingController) saveBalancer(c *gin.Context) {
+	balancer := &service.Balancer{}
+	if err := c.ShouldBindJSON(balancer); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.SaveBalancer(balancer, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) delBalancer(c *gin.Context) {
+	a.respond(c, a.xraySettingService.DelBalancer(c.Param("tag"), loginUsername(c)))
+}
+
+func (a *XrayRoutingController) getDNSServers(c *gin.Context) {
+	servers, err := a.xraySettingService.GetDNSServers()
+	jsonObj(c, servers, err)
+}
+
+func (a *XrayRoutingController) addDNSServer(c *gin.Context) {
+	server := &service.DNSServer{}
+	if err := c.ShouldBindJSON(server); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.AddDNSServer(server, insertPosition(c), loginUsername(c)))
+}
+
+func (a *XrayRoutingController) updateDNSServer(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	server := &service.DNSServer{}
+	if err := c.ShouldBindJSON(server); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.UpdateDNSServer(index, server, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) delDNSServer(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	a.respond(c, a.xraySettingService.DelDNSServer(index, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) getFakeDNSPools(c *gin.Context) {
+	pools, err := a.xraySettingService.GetFakeDNSPools()
+	jsonObj(c, pools, err)
+}
+
+func (a *XrayRoutingController) addFakeDNSPool(c *gin.Context) {
+	pool := &service.FakeDNSPool{}
+	if err := c.ShouldBindJSON(pool); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.AddFakeDNSPool(pool, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) updateFakeDNSPool(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	pool := &service.FakeDNSPool{}
+	if err := c.ShouldBindJSON(pool); err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.xraySettingService.UpdateFakeDNSPool(index, pool, loginUsername(c)))
+}
+
+func (a *XrayRoutingController) delFakeDNSPool(c *gin.Context) {
+	index, ok := indexParam(c)
+	if !ok {
+		return
+	}
+	a.respond(c, a.xraySettingService.DelFakeDNSPool(index, loginUsername(c)))
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"strconv"
+
 	"x-ui/web/service"
 
 	"github.com/gin-gonic/gin"
@@ -13,10 +15,12 @@ type XraySettingController struct {
 	OutboundService    *service.OutboundService
 	XrayService        *service.XrayService
 	WarpService        *service.WarpService
+	serverService      *service.ServerService
 }
 
-func NewXraySettingController(g *gin.RouterGroup) *XraySettingController {
+func NewXraySettingController(g *gin.RouterGroup, serverService *service.ServerService) *XraySettingController {
 	a := &XraySettingController{
+		serverService:      serverService,
 		XraySettingService: &service.XraySettingService{},
 		SettingService:     &service.SettingService{},
 		InboundService:     &service.InboundService{},
@@ -38,6 +42,16 @@ func (a *XraySettingController) initRouter(g *gin.RouterGroup) {
 	g.POST("/warp/:action", a.warp)
 	g.GET("/getOutboundsTraffic", a.getOutboundsTraffic)
 	g.POST("/resetOutboundsTraffic", a.resetOutboundsTraffic)
+
+	g.GET("/revisions", a.getRevisions)
+	g.GET("/revisions/diff", a.diffRevisions)
+	g.GET("/revisions/:id", a.getRevision)
+	g.POST("/revisions/:id/revert", a.revertRevision)
+
+	NewXrayRoutingController(g, a.XraySettingService)
+	NewXrayOutboundController(g, a.XraySettingService)
+	NewXrayReverseController(g, a.XraySettingService, a.serverService)
+	NewXrayWarpController(g, a.WarpService)
 }
 
 func (a *XraySettingController) getXraySetting(c *gin.Context) {
@@ -57,10 +71,57 @@ func (a *XraySettingController) getXraySetting(c *gin.Context) {
 
 func (a *XraySettingController) updateSetting(c *gin.Context) {
 	xraySetting := c.PostForm("xraySetting")
-	err := a.XraySettingService.SaveXraySetting(xraySetting)
+	err := a.XraySettingService.SaveXraySetting(xraySetting, loginUsername(c), c.PostForm("comment"))
 	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
 }
 
+func (a *XraySettingController) getRevisions(c *gin.Context) {
+	revisions, err := a.XraySettingService.GetRevisions()
+	jsonObj(c, revisions, err)
+}
+
+func (a *XraySettingController) getRevision(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.settings.toasts.getSettings"), err)
+		return
+	}
+	revision, err := a.XraySettingService.GetRevision(id)
+	jsonObj(c, revision, err)
+}
+
+// diffRevisions 比较两个历史版本，to 为空时与当前模板比较
+func (a *XraySettingController) diffRevisions(c *gin.Context) {
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.settings.toasts.getSettings"), err)
+		return
+	}
+	to, _ := strconv.Atoi(c.Query("to"))
+	diff, err := a.XraySettingService.DiffRevisions(from, to)
+	jsonObj(c, diff, err)
+}
+
+// revertRevision 恢复历史版本，恢复后与手动保存后一样重启 Xray 使其生效
+func (a *XraySettingController) revertRevision(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+		return
+	}
+	if err = a.XraySettingService.RevertToRevision(id, loginUsername(c)); err != nil {
+		jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+		return
+	}
+	if a.serverService != nil {
+		if err = a.serverService.RestartXrayService(); err != nil {
+			jsonMsg(c, I18nWeb(c, "pages.xray.restartError"), err)
+			return
+		}
+	}
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), nil)
+}
+
 func (a *XraySettingController) getDefaultXrayConfig(c *gin.Context) {
 	defaultJsonConfig, err := a.SettingService.GetDefaultXrayConfig()
 	if err != nil {
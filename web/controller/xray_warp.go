@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"strconv"
+
+	"x-ui/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// XrayWarpController 提供多个 WARP 账户的管理、WARP 出站的创建、接入点设置和账户轮换接口
+type XrayWarpController struct {
+	warpService *service.WarpService
+}
+
+// NewXrayWarpController 创建 XrayWarpController 实例
+func NewXrayWarpController(g *gin.RouterGroup, warpService *service.WarpService) *XrayWarpController {
+	a := &XrayWarpController{
+		warpService: warpService,
+	}
+	a.initRouter(g)
+	return a
+}
+
+func (a *XrayWarpController) initRouter(g *gin.RouterGroup) {
+	g = g.Group("/warp")
+
+	g.GET("/accounts", a.getAccounts)
+	g.GET("/endpoints", a.getEndpoints)
+	g.POST("/accounts/add", a.addAccount)
+	g.POST("/accounts/license/:id", a.setLicense)
+	g.POST("/accounts/enable/:id", a.setEnable)
+	g.POST("/accounts/refresh/:id", a.refreshAccount)
+	g.POST("/accounts/del/:id", a.delAccount)
+
+	g.POST("/outbounds/add", a.addOutbound)
+	g.POST("/outbounds/endpoint/:tag", a.setEndpoint)
+	g.POST("/outbounds/rotate/:tag", a.rotateOutbound)
+}
+
+func (a *XrayWarpController) respond(c *gin.Context, err error) {
+	jsonMsg(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), err)
+}
+
+func (a *XrayWarpController) getAccounts(c *gin.Context) {
+	accounts, err := a.warpService.GetWarpAccounts()
+	jsonObj(c, accounts, err)
+}
+
+func (a *XrayWarpController) getEndpoints(c *gin.Context) {
+	jsonObj(c, a.warpService.GetWarpEndpoints(), nil)
+}
+
+// addAccount 注册新账户，license 非空时同时绑定 WARP+ 许可证
+func (a *XrayWarpController) addAccount(c *gin.Context) {
+	account, err := a.warpService.CreateWarpAccount(c.PostForm("name"), c.PostForm("license"))
+	jsonMsgObj(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), account, err)
+}
+
+func (a *XrayWarpController) setLicense(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.warpService.SetWarpAccountLicense(id, c.PostForm("license")))
+}
+
+func (a *XrayWarpController) setEnable(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	enable, err := strconv.ParseBool(c.PostForm("enable"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.warpService.SetWarpAccountEnable(id, enable))
+}
+
+func (a *XrayWarpController) refreshAccount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.warpService.RefreshWarpAccount(id))
+}
+
+func (a *XrayWarpController) delAccount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		a.respond(c, err)
+		return
+	}
+	a.respond(c, a.warpService.DelWarpAccount(id))
+}
+
+// addOutbound 创建 WARP 出站，返回其使用的账户
+func (a *XrayWarpController) addOutbound(c *gin.Context) {
+	req := &service.WarpOutboundRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		a.respond(c, err)
+		return
+	}
+	account, err := a.warpService.CreateWarpOutbound(req, loginUsername(c))
+	jsonMsgObj(c, I18nWeb(c, "pages.settings.toasts.modifySettings"), account, err)
+}
+
+func (a *XrayWarpController) setEndpoint(c *gin.Context) {
+	a.respond(c, a.warpService.SetWarpOutboundEndpoint(c.Param("tag"), c.PostForm("endpoint"), loginUsername(c)))
+}
+
+func (a *XrayWarpController) rotateOutbound(c *gin.Context) {
+	a.respond(c, a.warpService.RotateWarpOutbound(c.Param("tag"), loginUsername(c)))
+}
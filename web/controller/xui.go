@@ -38,7 +38,7 @@ func (a *XUIController) initRouter(g *gin.RouterGroup) {
 	a.inboundController = NewInboundController(g)
 	a.serverController = NewServerController(g, a.serverService)
 	a.settingController = NewSettingController(g)
-	a.xraySettingController = NewXraySettingController(g)
+	a.xraySettingController = NewXraySettingController(g, a.serverService)
 }
 
 func (a *XUIController) index(c *gin.Context) {
@@ -41,6 +41,11 @@ type AllSetting struct {
 	TgCpu                       int    `json:"tgCpu" form:"tgCpu"`
 	TgLang                      string `json:"tgLang" form:"tgLang"`
 	LogStreamerEnabled          bool   `json:"logStreamerEnabled" form:"logStreamerEnabled"`
+	AccessLogEnabled            bool   `json:"accessLogEnabled" form:"accessLogEnabled"`
+	AccessLogRetention          int    `json:"accessLogRetention" form:"accessLogRetention"`
+	AccessLogAnonymize          string `json:"accessLogAnonymize" form:"accessLogAnonymize"`
+	DeviceLimitPolicy           string `json:"deviceLimitPolicy" form:"deviceLimitPolicy"`
+	DeviceLimitThrottle         int    `json:"deviceLimitThrottle" form:"deviceLimitThrottle"`
 	TimeLocation                string `json:"timeLocation" form:"timeLocation"`
 	TwoFactorEnable             bool   `json:"twoFactorEnable" form:"twoFactorEnable"`
 	TwoFactorToken              string `json:"twoFactorToken" form:"twoFactorToken"`
@@ -65,6 +70,8 @@ type AllSetting struct {
 	SubJsonMux                  string `json:"subJsonMux" form:"subJsonMux"`
 	SubJsonRules                string `json:"subJsonRules" form:"subJsonRules"`
 	Datepicker                  string `json:"datepicker" form:"datepicker"`
+	XrayCoreMode                string `json:"xrayCoreMode" form:"xrayCoreMode"`
+	CoreBackend                 string `json:"coreBackend" form:"coreBackend"`
 }
 
 func (s *AllSetting) CheckValid() error {
@@ -133,5 +140,41 @@ func (s *AllSetting) CheckValid() error {
 		return common.NewError("time location not exist:", s.TimeLocation)
 	}
 
+	switch s.XrayCoreMode {
+	case "":
+		s.XrayCoreMode = "external"
+	case "external", "embedded":
+	default:
+		return common.NewError("xray core mode is not valid:", s.XrayCoreMode)
+	}
+	switch s.CoreBackend {
+	case "":
+		s.CoreBackend = "xray"
+	case "xray", "sing-box":
+	default:
+		return common.NewError("core backend is not valid:", s.CoreBackend)
+	}
+
+	if s.AccessLogRetention < 0 {
+		return common.NewError("access log retention is not valid:", s.AccessLogRetention)
+	}
+	switch s.AccessLogAnonymize {
+	case "":
+		s.AccessLogAnonymize = "none"
+	case "none", "truncate", "hash":
+	default:
+		return common.NewError("access log anonymize mode is not valid:", s.AccessLogAnonymize)
+	}
+	switch s.DeviceLimitPolicy {
+	case "":
+		s.DeviceLimitPolicy = "kickAll"
+	case "kickAll", "rejectNewest", "throttle":
+	default:
+		return common.NewError("device limit policy is not valid:", s.DeviceLimitPolicy)
+	}
+	if s.DeviceLimitThrottle < 0 {
+		return common.NewError("device limit throttle speed is not valid:", s.DeviceLimitThrottle)
+	}
+
 	return nil
 }
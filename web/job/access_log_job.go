@@ -0,0 +1,143 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"x-ui/config"
+	"x-ui/database/model"
+	"x-ui/logger"
+	"x-ui/web/service"
+	"x-ui/xray"
+
+	"github.com/nxadm/tail"
+)
+
+// AccessLogJob 跟踪 Xray 访问日志，将客户端连接批量写入访问记录，并定期清理过期记录。
+// 访问日志文件被 ClearLogsJob 截断后 tail 会从头继续读取
+type AccessLogJob struct {
+	accessLogService *service.AccessLogService
+	tailer           *tail.Tail
+	pending          []*model.AccessLogEntry
+	lastPrune        time.Time
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// NewAccessLogJob 创建访问日志采集任务
+func NewAccessLogJob(accessLogService *service.AccessLogService) *AccessLogJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AccessLogJob{
+		accessLogService: accessLogService,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+}
+
+func (j *AccessLogJob) Name() string {
+	return "AccessLogJob"
+}
+
+func (j *AccessLogJob) Start() error {
+	enabled, err := j.accessLogService.GetAccessLogEnabled()
+	if err != nil || !enabled {
+		logger.Info("Access log collection is disabled")
+		return nil
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(config.AccessLogFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			// 未获取到日志路径前 lines 为 nil，select 不会从中读取
+			var lines chan *tail.Line
+			if j.tailer != nil {
+				lines = j.tailer.Lines
+			}
+			select {
+			case <-j.ctx.Done():
+				j.flush()
+				return
+			case line, ok := <-lines:
+				if !ok {
+					j.tailer = nil
+					continue
+				}
+				if line.Err != nil {
+					continue
+				}
+				if entry := service.ParseAccessLogLine(line.Text, time.Now()); entry != nil {
+					j.pending = append(j.pending, entry)
+					if len(j.pending) >= config.AccessLogBatchSize {
+						j.flush()
+					}
+				}
+			case <-ticker.C:
+				j.Run()
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *AccessLogJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	if j.tailer != nil {
+		_ = j.tailer.Stop()
+		j.tailer.Cleanup()
+		j.tailer = nil
+	}
+	return nil
+}
+
+// Run 写入积压的记录，按需开始跟踪日志文件并清理过期记录
+func (j *AccessLogJob) Run() {
+	j.flush()
+	if j.tailer == nil {
+		j.startTail()
+	}
+	if now := time.Now(); now.Sub(j.lastPrune) >= config.AccessLogPruneInterval {
+		j.lastPrune = now
+		if err := j.accessLogService.PruneAccessLogs(now); err != nil {
+			logger.Warning("Prune access logs failed:", err)
+		}
+	}
+}
+
+// startTail Xray 配置中设置了访问日志路径后开始从文件末尾跟踪
+func (j *AccessLogJob) startTail() {
+	logPath, err := xray.GetAccessLogPath()
+	if err != nil || logPath == "" || logPath == "none" {
+		return
+	}
+	tailer, err := tail.TailFile(logPath, tail.Config{
+		Follow:    true,
+		ReOpen:    true,
+		MustExist: false,
+		Poll:      true,
+		Location:  &tail.SeekInfo{Offset: 0, Whence: 2},
+		Logger:    tail.DiscardingLogger,
+	})
+	if err != nil {
+		logger.Warning("Tail access log failed:", err)
+		return
+	}
+	j.tailer = tailer
+	logger.Infof("Collecting client access logs from %s", logPath)
+}
+
+func (j *AccessLogJob) flush() {
+	if len(j.pending) == 0 {
+		return
+	}
+	if err := j.accessLogService.SaveAccessLogs(j.pending); err != nil {
+		logger.Warning("Save access logs failed:", err)
+	}
+	j.pending = nil
+}
@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,8 +32,10 @@ import (
 
 // ActiveClientIPs 用于在内存中跟踪每个用户的活跃IP (TTL机制)
 // 结构: map[用户email] -> map[IP地址] -> 最后活跃时间
+// clientIPFirstSeen 记录活跃 IP 首次出现的时间，rejectNewest 策略据此判断哪些是新设备，与 ActiveClientIPs 共用锁
 var (
 	ActiveClientIPs   = make(map[string]map[string]time.Time)
+	clientIPFirstSeen = make(map[string]map[string]time.Time)
 	activeClientsLock sync.RWMutex
 )
 
@@ -42,26 +45,39 @@ var (
 	maxTotalEmails = config.MaxTotalEmails
 )
 
-// ClientStatus 中文注释: 用于跟踪每个用户的状态（是否因为设备超限而被禁用）
-// 结构: map[用户email] -> 是否被禁用(true/false)
+// ClientStatus 中文注释: 用于跟踪已在 Xray 中生效的设备超限处理，处理记录本身持久化在数据库中
+// 结构: map[用户email] -> 处理时使用的策略及客户端信息，解除时按同一策略恢复
 var (
-	ClientStatus     = make(map[string]bool)
+	ClientStatus     = make(map[string]*deviceLimitBan)
 	clientStatusLock sync.RWMutex
 )
 
-// CheckDeviceLimitJob 重构后的设备限制任务，使用 LogStreamer 实现实时监控
+// deviceLimitBan 设备超限的处理记录
+type deviceLimitBan struct {
+	Policy string
+	Info   *service.DeviceLimitClient
+}
+
+// CheckDeviceLimitJob 设备限制任务。优先通过 Xray 的在线 IP 统计（statsUserOnline）获取客户端在线 IP，
+// 不依赖访问日志；统计不可用时退回到 LogStreamer 解析访问日志
 type CheckDeviceLimitJob struct {
 	inboundService *service.InboundService
 	xrayService    *service.XrayService
 	settingService service.SettingService
+	// 持久化的封禁记录和白名单
+	deviceLimitService *service.DeviceLimitService
 	// 新增 xrayApi 字段，用于持有 Xray API 客户端实例
 	xrayApi xray.XrayAPI
 	// 使用 LogStreamer 进行实时日志监控
 	logStreamer *LogStreamer
 	// 控制 LogStreamer 的启动和停止
 	isStreamerRunning bool
+	// 任务是否已启动
+	isRunning bool
 	// 注入 Telegram 服务用于发送通知，确保此行存在。
 	telegramService service.TelegramService
+	// 事件中心，用于向前端推送封禁/解封事件
+	eventHub *service.EventHub
 	// 等待组用于优雅关闭
 	wg sync.WaitGroup
 	// 上下文控制
@@ -89,9 +105,10 @@ func NewCheckDeviceLimitJob(inboundService *service.InboundService, xrayService
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &CheckDeviceLimitJob{
-		inboundService: inboundService,
-		xrayService:    xrayService,
-		settingService: settingService,
+		inboundService:     inboundService,
+		xrayService:        xrayService,
+		settingService:     settingService,
+		deviceLimitService: &service.DeviceLimitService{},
 		// 初始化 xrayApi 字段
 		xrayApi: xray.XrayAPI{},
 		// 将传入的 telegramService 赋值给结构体实例。
@@ -102,15 +119,25 @@ func NewCheckDeviceLimitJob(inboundService *service.InboundService, xrayService
 	}
 }
 
+// SetEventHub 注入事件中心，用于推送封禁/解封事件
+func (j *CheckDeviceLimitJob) SetEventHub(eventHub *service.EventHub) {
+	j.eventHub = eventHub
+}
+
 func (j *CheckDeviceLimitJob) Name() string {
 	return "CheckDeviceLimitJob"
 }
 
-// Start 启动重构后的设备限制任务（使用 LogStreamer）
+// Start 启动设备限制任务；启用 LogStreamer 时同时监控访问日志，作为在线 IP 统计不可用时的备用数据源
 func (j *CheckDeviceLimitJob) Start() error {
-	if j.isStreamerRunning {
+	if j.isRunning {
 		return nil
 	}
+	j.isRunning = true
+
+	// 启动设备限制检查的 goroutine，在线 IP 统计不依赖访问日志
+	j.wg.Add(1)
+	go j.limitCheckLoop()
 
 	// 检查 LogStreamer 是否启用
 	logStreamerEnabled, err := j.settingService.GetLogStreamerEnabled()
@@ -120,18 +147,15 @@ func (j *CheckDeviceLimitJob) Start() error {
 	}
 
 	if !logStreamerEnabled {
-		logger.Info("LogStreamer 已禁用，不启动实时日志监控")
-		// 即使不启动 LogStreamer，也启动设备限制检查的 goroutine（使用其他方式检查）
-		j.wg.Add(1)
-		go j.limitCheckLoop()
+		logger.Info("LogStreamer 已禁用，设备限制仅使用 Xray 在线 IP 统计")
 		return nil
 	}
 
 	// 检查日志路径并初始化 LogStreamer
 	logPath, err := xray.GetAccessLogPath()
 	if err != nil || logPath == "none" || logPath == "" {
-		logger.Warning("设备限制任务启动失败：无法获取有效的日志路径")
-		return fmt.Errorf("无效的日志路径: %v", err)
+		logger.Warning("无法获取有效的访问日志路径，设备限制仅使用 Xray 在线 IP 统计")
+		return nil
 	}
 
 	// 创建 LogStreamer
@@ -144,17 +168,13 @@ func (j *CheckDeviceLimitJob) Start() error {
 
 	j.isStreamerRunning = true
 
-	// 启动设备限制检查的 goroutine
-	j.wg.Add(1)
-	go j.limitCheckLoop()
-
-	logger.Infof("重构后的设备限制任务已启动，监控日志文件: %s", logPath)
+	logger.Infof("设备限制任务已启动，备用监控日志文件: %s", logPath)
 	return nil
 }
 
 // Stop 停止设备限制任务
 func (j *CheckDeviceLimitJob) Stop() error {
-	if !j.isStreamerRunning {
+	if !j.isRunning {
 		return nil
 	}
 
@@ -186,6 +206,7 @@ func (j *CheckDeviceLimitJob) Stop() error {
 	}
 
 	j.isStreamerRunning = false
+	j.isRunning = false
 	return nil
 }
 
@@ -223,8 +244,8 @@ func (j *CheckDeviceLimitJob) limitCheckLoop() {
 
 // performLimitCheck 执行设备限制检查
 func (j *CheckDeviceLimitJob) performLimitCheck() {
-	// 如果 LogStreamer 未运行，则跳过检查（因为没有实时数据）
-	if !j.isStreamerRunning {
+	// 在线 IP 统计不可用且 LogStreamer 未运行时跳过检查（因为没有实时数据）
+	if !j.collectOnlineIPs() && !j.isStreamerRunning {
 		return
 	}
 
@@ -235,6 +256,35 @@ func (j *CheckDeviceLimitJob) performLimitCheck() {
 	j.checkAllClientsLimit()
 }
 
+// collectOnlineIPs 从 Xray 在线 IP 统计中读取各客户端的在线 IP 并合并到 ActiveClientIPs，
+// 返回统计是否可用。统计中的时间为 IP 最近一次建立连接的时间，仍按 DeviceLimitActiveTTL 判断是否下线
+func (j *CheckDeviceLimitJob) collectOnlineIPs() bool {
+	if j.xrayService == nil || !j.xrayService.IsXrayRunning() {
+		return false
+	}
+	apiPort := j.xrayService.GetApiPort()
+	if apiPort == 0 {
+		return false
+	}
+	api := xray.XrayAPI{}
+	if err := api.Init(apiPort); err != nil {
+		return false
+	}
+	defer api.Close()
+
+	online, err := api.GetOnlineClientIPs()
+	if err != nil {
+		logger.Debug("[DeviceLimit] 无法获取在线 IP 统计:", err)
+		return false
+	}
+	for email, ips := range online {
+		for ip, lastSeen := range ips {
+			recordActiveClientIP(email, ip, lastSeen)
+		}
+	}
+	return true
+}
+
 // cleanupExpiredIPs 中文注释: 清理长时间不活跃的IP
 func (j *CheckDeviceLimitJob) cleanupExpiredIPs() {
 	activeClientsLock.Lock()
@@ -255,20 +305,51 @@ func (j *CheckDeviceLimitJob) cleanupExpiredIPs() {
 			delete(ActiveClientIPs, email)
 		}
 	}
+	// 同步移除已下线或被淘汰的 IP 的首次出现时间
+	for email, ips := range clientIPFirstSeen {
+		for ip := range ips {
+			if _, ok := ActiveClientIPs[email][ip]; !ok {
+				delete(ips, ip)
+			}
+		}
+		if len(ips) == 0 {
+			delete(clientIPFirstSeen, email)
+		}
+	}
 }
 
-// checkAllClientsLimit 中文注释: 核心功能，检查所有用户，对超限的执行封禁，对恢复的执行解封
+// checkAllClientsLimit 中文注释: 核心功能，检查所有用户，对超限的按配置的策略处理，对恢复的解除处理。
+// 客户端设置了 LimitIP 时优先于入站的设备数限制。处理记录保存在数据库中，ClientStatus 只记录已在 Xray 中生效的处理，
+// 每轮检查先使两者一致：手动解除的记录恢复用户，面板重启后仍在生效的记录重新应用
 func (j *CheckDeviceLimitJob) checkAllClientsLimit() {
 	if j.xrayService == nil {
 		logger.Warning("[DeviceLimit] XrayServices not ready, skipping cycle.")
 		return
 	}
-	db := repository.NewInboundRepository(database.GetDB()).GetDB()
-	var inbounds []*model.Inbound
-	// 这里仅查询启用了设备限制(device_limit > 0)并且自身是开启状态的入站规则
-	db.Where("device_limit > 0 AND enable = ?", true).Find(&inbounds)
+	clients, err := j.inboundService.GetDeviceLimitedClients()
+	if err != nil {
+		logger.Warning("[DeviceLimit] 获取受限客户端失败:", err)
+		return
+	}
+	bans, err := j.deviceLimitService.GetActiveBans()
+	if err != nil {
+		logger.Warning("[DeviceLimit] 获取封禁记录失败:", err)
+		return
+	}
+	// 白名单中的客户端不受设备数限制
+	whitelist, _ := j.deviceLimitService.GetWhitelistedEmails()
+	for email := range whitelist {
+		delete(clients, email)
+	}
+	activeBans := make(map[string]*model.DeviceLimitBan, len(bans))
+	for _, ban := range bans {
+		activeBans[ban.Email] = ban
+	}
 
-	if len(inbounds) == 0 {
+	clientStatusLock.Lock()
+	defer clientStatusLock.Unlock()
+
+	if len(clients) == 0 && len(ClientStatus) == 0 && len(activeBans) == 0 {
 		return
 	}
 
@@ -281,98 +362,185 @@ func (j *CheckDeviceLimitJob) checkAllClientsLimit() {
 	_ = j.xrayApi.Init(apiPort)
 	defer j.xrayApi.Close()
 
-	// 优化 - 在一次循环中同时获取 tag 和 protocol
-	inboundInfoMap := make(map[int]struct {
-		Limit    int
-		Tag      string
-		Protocol model.Protocol
-	})
-	for _, inbound := range inbounds {
-		inboundInfoMap[inbound.Id] = struct {
-			Limit    int
-			Tag      string
-			Protocol model.Protocol
-		}{Limit: inbound.DeviceLimit, Tag: inbound.Tag, Protocol: inbound.Protocol}
-	}
+	policy, throttleSpeed := j.deviceLimitPolicy()
 
 	// 获取当前的活跃客户端IP映射
-	activeClientIPs := j.logStreamer.GetActiveClientIPs()
+	activeClientIPs := snapshotActiveClientIPs()
+	firstSeen := snapshotClientIPFirstSeen()
 
-	activeClientsLock.RLock()
-	clientStatusLock.Lock()
-	defer activeClientsLock.RUnlock()
-	defer clientStatusLock.Unlock()
+	// 被拒绝的 IP 通过路由规则生效，发生变化时需要重新应用 Xray 配置
+	rejectedChanged := false
 
-	// 第一步: 处理当前在线的用户
-	for email, ips := range activeClientIPs {
-		traffic, err := j.inboundService.GetClientTrafficByEmail(email)
-		if err != nil || traffic == nil {
+	// 第一步: 记录已被手动解除或因加入白名单而结束、但仍在 Xray 中生效的处理，恢复用户
+	for email, applied := range ClientStatus {
+		if _, ok := activeBans[email]; ok {
 			continue
 		}
+		logger.Infof("用户 %s 的设备超限处理已被手动解除，恢复用户。", email)
+		rejectedChanged = j.unbanUser(email, len(activeClientIPs[email]), applied, "") || rejectedChanged
+	}
 
-		info, ok := inboundInfoMap[traffic.InboundId]
-		if !ok || info.Limit <= 0 {
+	// 第二步: 数据库中仍在生效、但尚未应用到 Xray 的处理（例如面板重启后），按记录重新应用
+	for email, ban := range activeBans {
+		if _, ok := ClientStatus[email]; ok {
+			continue
+		}
+		info, ok := clients[email]
+		if !ok {
+			if _, err := j.deviceLimitService.CloseBan(email, service.DeviceLimitUnbanLimitRemoved); err != nil {
+				logger.Warningf("结束用户 %s 的封禁记录失败: %v", email, err)
+			}
 			continue
 		}
+		rejectedChanged = j.restoreBan(email, ban, throttleSpeed, info) || rejectedChanged
+	}
 
-		isBanned := ClientStatus[email]
+	// 第三步: 处理所有受限的用户，离线用户的活跃IP数为0
+	for email, info := range clients {
+		ips := activeClientIPs[email]
 		activeIPCount := len(ips)
+		applied, isBanned := ClientStatus[email]
+		// SOCKS/HTTP 账户只能按路由规则拒绝新设备
+		clientPolicy := service.DeviceLimitPolicyFor(info.Protocol, policy)
 
-		// 调用封禁函数
-		if activeIPCount > info.Limit && !isBanned {
-			// 调用封禁函数时，传入当前的IP数用于记录日志
-			j.banUser(email, activeIPCount, &info)
+		// 记录重新应用失败，下一轮检查时重试
+		if !isBanned && activeBans[email] != nil {
+			continue
 		}
 
-		// 调用解封函数
-		if activeIPCount <= info.Limit && isBanned {
+		// 策略被修改时，先按原策略解除，再按新策略处理
+		if isBanned && applied.Policy != clientPolicy {
+			rejectedChanged = j.unbanUser(email, activeIPCount, applied, service.DeviceLimitUnbanPolicyChanged) || rejectedChanged
+			_, isBanned = ClientStatus[email]
+			if isBanned {
+				continue
+			}
+		}
+
+		switch {
+		case activeIPCount > info.Limit && clientPolicy == service.DeviceLimitPolicyRejectNewest:
+			rejectedChanged = j.rejectNewestIPs(email, ips, firstSeen[email], info, isBanned) || rejectedChanged
+		case activeIPCount > info.Limit && !isBanned:
+			// 调用封禁函数时，传入当前的IP用于记录
+			j.banUser(email, ips, clientPolicy, throttleSpeed, info)
+		case activeIPCount <= info.Limit && isBanned:
 			// 调用解封函数时，传入当前的IP数用于记录日志
-			j.unbanUser(email, activeIPCount, &info)
+			rejectedChanged = j.unbanUser(email, activeIPCount, ClientStatus[email], service.DeviceLimitUnbanRecovered) || rejectedChanged
 		}
 	}
 
-	// 第二步: 专门处理那些"已被封禁"但"已不在线"的用户，为他们解封
-	for email, isBanned := range ClientStatus {
-		if !isBanned {
+	// 第四步: 专门处理那些"已被封禁"但限制已取消、入站或客户端已停用的用户
+	for email, applied := range ClientStatus {
+		if _, ok := clients[email]; ok {
 			continue
 		}
-		if _, online := activeClientIPs[email]; !online {
-			traffic, err := j.inboundService.GetClientTrafficByEmail(email)
-			if err != nil || traffic == nil {
-				continue
-			}
-			info, ok := inboundInfoMap[traffic.InboundId]
-			if !ok {
-				continue
-			}
-			logger.Infof("已封禁用户 %s 已完全下线，执行解封操作。", email)
+		logger.Infof("已封禁用户 %s 不再受设备数限制，执行解封操作。", email)
+		rejectedChanged = j.unbanUser(email, len(activeClientIPs[email]), applied, service.DeviceLimitUnbanLimitRemoved) || rejectedChanged
+	}
 
-			// 调用解封函数，这种情况下：活跃IP数为0，我们直接传入0用于记录日志
-			j.unbanUser(email, 0, &info)
+	if rejectedChanged {
+		j.xrayService.SetToNeedRestart()
+	}
+}
+
+// deviceLimitPolicy 读取设备超限策略，限速策略未设置有效速率时退回 kickAll
+func (j *CheckDeviceLimitJob) deviceLimitPolicy() (string, int) {
+	policy, err := j.settingService.GetDeviceLimitPolicy()
+	if err != nil || policy == "" {
+		policy = service.DeviceLimitPolicyKickAll
+	}
+	throttleSpeed, _ := j.settingService.GetDeviceLimitThrottleSpeed()
+	if policy == service.DeviceLimitPolicyThrottle && throttleSpeed <= 0 {
+		policy = service.DeviceLimitPolicyKickAll
+	}
+	return policy, throttleSpeed
+}
+
+// sortedIPs 返回排序后的 IP 列表
+func sortedIPs(ips map[string]time.Time) []string {
+	result := make([]string, 0, len(ips))
+	for ip := range ips {
+		result = append(result, ip)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// newestIPs 按首次出现时间排序，返回超出 limit 的最新 IP
+func newestIPs(ips map[string]time.Time, firstSeen map[string]time.Time, limit int) []string {
+	ordered := sortedIPs(ips)
+	seen := func(ip string) time.Time {
+		if t, ok := firstSeen[ip]; ok {
+			return t
 		}
+		return ips[ip]
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return seen(ordered[a]).Before(seen(ordered[b]))
+	})
+	if len(ordered) <= limit {
+		return nil
 	}
+	return ordered[limit:]
 }
 
-// banUser 中文注释: 封装的封禁用户函数；IP数量超限，且用户当前未被封禁 -> 执行封禁 (UUID 替换)
-func (j *CheckDeviceLimitJob) banUser(email string, activeIPCount int, info *struct {
-	Limit    int
-	Tag      string
-	Protocol model.Protocol
-},
-) {
-	_, client, err := j.inboundService.GetClientByEmail(email)
-	if err != nil || client == nil {
-		return
+// rejectNewestIPs 按 rejectNewest 策略拒绝超出限制的最新 IP，已在线的设备不受影响。返回被拒绝的 IP 是否变化
+func (j *CheckDeviceLimitJob) rejectNewestIPs(email string, ips map[string]time.Time, firstSeen map[string]time.Time, info *service.DeviceLimitClient, isBanned bool) bool {
+	rejected := newestIPs(ips, firstSeen, info.Limit)
+	changed := service.SetDeviceLimitRejectedIPs(email, rejected)
+	if isBanned {
+		if changed {
+			if err := j.deviceLimitService.UpdateBanIPs(email, len(ips), rejected); err != nil {
+				logger.Warningf("更新用户 %s 的封禁记录失败: %v", email, err)
+			}
+		}
+		return changed
 	}
 
-	if j.xrayService == nil {
-		return
+	logger.Infof("〔设备限制〕超限：用户 %s. 限制: %d, 当前活跃: %d. 拒绝新设备 IP: %v", email, info.Limit, len(ips), rejected)
+	j.notifyDeviceLimit(email, info.Limit, len(ips), "超出限制的新设备已被拒绝连接！")
+	ClientStatus[email] = &deviceLimitBan{Policy: service.DeviceLimitPolicyRejectNewest, Info: info}
+	j.recordBan(email, service.DeviceLimitPolicyRejectNewest, len(ips), rejected, info)
+	return changed
+}
+
+// restoreBan 将数据库中仍在生效的处理重新应用到 Xray。返回被拒绝的 IP 是否变化
+func (j *CheckDeviceLimitJob) restoreBan(email string, ban *model.DeviceLimitBan, throttleSpeed int, info *service.DeviceLimitClient) bool {
+	// 记录的策略对该协议不可用时（例如 SOCKS/HTTP 账户的 kickAll 记录）结束记录，由下一轮检查按可用的策略重新处理
+	if service.DeviceLimitPolicyFor(info.Protocol, ban.Policy) != ban.Policy {
+		if _, err := j.deviceLimitService.CloseBan(email, service.DeviceLimitUnbanPolicyChanged); err != nil {
+			logger.Warningf("结束用户 %s 的封禁记录失败: %v", email, err)
+		}
+		return false
+	}
+	logger.Infof("〔设备限制〕恢复用户 %s 的 %s 处理记录。", email, ban.Policy)
+	if ban.Policy == service.DeviceLimitPolicyRejectNewest {
+		ClientStatus[email] = &deviceLimitBan{Policy: ban.Policy, Info: info}
+		return service.SetDeviceLimitRejectedIPs(email, service.DeviceLimitBanIPs(ban))
+	}
+	if j.applyBan(email, ban.Policy, throttleSpeed, info) {
+		ClientStatus[email] = &deviceLimitBan{Policy: ban.Policy, Info: info}
 	}
+	return false
+}
 
-	logger.Infof("〔设备限制〕超限：用户 %s. 限制: %d, 当前活跃: %d. 执行封禁掐网。", email, info.Limit, activeIPCount)
+// recordBan 保存处理记录并推送封禁事件
+func (j *CheckDeviceLimitJob) recordBan(email string, policy string, activeIPCount int, ips []string, info *service.DeviceLimitClient) {
+	err := j.deviceLimitService.RecordBan(&model.DeviceLimitBan{
+		Email:      email,
+		InboundTag: info.Tag,
+		Policy:     policy,
+		Limit:      info.Limit,
+		ActiveIPs:  activeIPCount,
+	}, ips)
+	if err != nil {
+		logger.Warningf("保存用户 %s 的封禁记录失败: %v", email, err)
+	}
+	j.eventHub.PublishBan(email, true, info.Limit, activeIPCount)
+}
 
-	// 以下是发送 Telegram 通知的核心代码，
-	// 它会调用我们注入的 telegramService 的 SendMessage 方法。
+// notifyDeviceLimit 异步发送设备超限的 Telegram 通知
+func (j *CheckDeviceLimitJob) notifyDeviceLimit(email string, limit int, activeIPCount int, action string) {
 	j.wg.Add(1)
 	go func() {
 		defer j.wg.Done()
@@ -382,13 +550,13 @@ func (j *CheckDeviceLimitJob) banUser(email string, activeIPCount int, info *str
 		}
 		tgMessage := fmt.Sprintf(
 			"<b>〔X-Panel面板〕设备超限提醒</b>\n\n"+
-				"  ------------------------------------\n"+
-				"  👤 用户 Email：%s\n"+
-				"  🖥️ 设备限制数量：%d\n"+
-				"  🌐 当前在线IP数：%d\n"+
-				"  ------------------------------------\n\n"+
-				"<b><i>⚠ 该用户已被自动掐网封禁！</i></b>",
-			email, info.Limit, activeIPCount,
+				"  ------------------------------------\n"+
+				"  👤 用户 Email：%s\n"+
+				"  🖥️ 设备限制数量：%d\n"+
+				"  🌐 当前在线IP数：%d\n"+
+				"  ------------------------------------\n\n"+
+				"<b><i>⚠ %s</i></b>",
+			email, limit, activeIPCount, action,
 		)
 		// 调用接口方法发送消息。
 		err := j.telegramService.SendMessage(tgMessage)
@@ -396,6 +564,64 @@ func (j *CheckDeviceLimitJob) banUser(email string, activeIPCount int, info *str
 			logger.Warningf("发送 Telegram 封禁通知失败: %v", err)
 		}
 	}()
+}
+
+// deviceLimitUser 将客户端转换为 XrayAPI.AddUser 所需的参数，保证所有键都存在
+func deviceLimitUser(client *model.Client, info *service.DeviceLimitClient, level int) map[string]any {
+	return map[string]any{
+		"email":    client.Email,
+		"id":       client.ID,
+		"flow":     client.Flow,
+		"password": client.Password,
+		"cipher":   info.Method,
+		"level":    level,
+	}
+}
+
+// banUser 中文注释: 封装的封禁用户函数；IP数量超限，且用户当前未被处理 -> kickAll 执行封禁 (UUID 替换)，
+// throttle 将用户切换到限速等级
+func (j *CheckDeviceLimitJob) banUser(email string, ips map[string]time.Time, policy string, throttleSpeed int, info *service.DeviceLimitClient) {
+	activeIPCount := len(ips)
+	if !j.applyBan(email, policy, throttleSpeed, info) {
+		return
+	}
+
+	// 封禁成功后才发送通知，在内存中记录该用户的处理策略，并持久化处理记录。
+	if policy == service.DeviceLimitPolicyThrottle {
+		logger.Infof("〔设备限制〕超限：用户 %s. 限制: %d, 当前活跃: %d. 限速至 %d KB/s。", email, info.Limit, activeIPCount, throttleSpeed)
+		j.notifyDeviceLimit(email, info.Limit, activeIPCount, fmt.Sprintf("该用户已被限速至 %d KB/s！", throttleSpeed))
+	} else {
+		logger.Infof("〔设备限制〕超限：用户 %s. 限制: %d, 当前活跃: %d. 执行封禁掐网。", email, info.Limit, activeIPCount)
+		j.notifyDeviceLimit(email, info.Limit, activeIPCount, "该用户已被自动掐网封禁！")
+	}
+	ClientStatus[email] = &deviceLimitBan{Policy: policy, Info: info}
+	j.recordBan(email, policy, activeIPCount, sortedIPs(ips), info)
+}
+
+// applyBan 通过 API 在 Xray 中应用 kickAll 或 throttle 处理，返回是否成功
+func (j *CheckDeviceLimitJob) applyBan(email string, policy string, throttleSpeed int, info *service.DeviceLimitClient) bool {
+	client := info.Client
+	user := deviceLimitUser(&client, info, client.SpeedLimit)
+
+	if policy == service.DeviceLimitPolicyThrottle {
+		// 限速等级的名字就是速率，由生成 Xray 配置时创建
+		user["level"] = throttleSpeed
+	} else {
+		// 使用随机UUID/Password用于"封禁"。客户端持有的还是旧的UUID，自然就无法通过验证
+		// 适用于 VMess/VLESS
+		if client.ID != "" {
+			user["id"] = RandomUUID()
+		}
+		// 适用于 Trojan/Shadowsocks，SS2022 的密钥必须是指定长度的 base64
+		if client.Password != "" {
+			user["password"] = RandomUUID()
+			if strings.HasPrefix(info.Method, "2022-") {
+				if key, err := service.GenerateSS2022Key(info.Method); err == nil {
+					user["password"] = key
+				}
+			}
+		}
+	}
 
 	// 步骤一：先从 Xray-Core 中删除该用户。
 	_ = j.xrayApi.RemoveUser(info.Tag, email)
@@ -403,65 +629,63 @@ func (j *CheckDeviceLimitJob) banUser(email string, activeIPCount int, info *str
 	// 使用配置的延时，解决竞态条件问题
 	time.Sleep(config.DeviceLimitOperationDelay)
 
-	// 创建一个带有随机UUID/Password的临时客户端配置用于"封禁"
-	tempClient := *client
-
-	// 适用于 VMess/VLESS
-	if tempClient.ID != "" {
-		tempClient.ID = RandomUUID()
+	// 步骤二：将修改后的用户添加回去。
+	if err := j.xrayApi.AddUser(string(info.Protocol), info.Tag, user); err != nil {
+		logger.Warningf("通过API封禁用户 %s 失败: %v", email, err)
+		return false
 	}
+	return true
+}
 
-	// 适用于 Trojan/Shadowsocks/Socks
-	if tempClient.Password != "" {
-		tempClient.Password = RandomUUID()
-	}
+// unbanUser 中文注释: 封装的解封用户函数；按处理时的策略恢复用户，reason 不为空时同时结束数据库中的处理记录。
+// 返回被拒绝的 IP 是否变化
+func (j *CheckDeviceLimitJob) unbanUser(email string, activeIPCount int, applied *deviceLimitBan, reason string) bool {
+	info := applied.Info
+	logger.Infof("〔设备数量〕已恢复：用户 %s. 限制: %d, 当前活跃: %d. 执行解封/恢复用户。", email, info.Limit, activeIPCount)
 
-	var clientMap map[string]interface{}
-	clientJson, _ := json.Marshal(tempClient)
-	_ = json.Unmarshal(clientJson, &clientMap)
+	changed := false
+	if applied.Policy == service.DeviceLimitPolicyRejectNewest {
+		changed = service.SetDeviceLimitRejectedIPs(email, nil)
+	} else if !j.restoreUser(email, info) {
+		return false
+	}
 
-	// 步骤二：将这个带有错误UUID/Password的临时用户添加回去。
-	// 客户端持有的还是旧的UUID，自然就无法通过验证，从而达到了"封禁"的效果。
-	err = j.xrayApi.AddUser(string(info.Protocol), info.Tag, clientMap)
-	if err != nil {
-		logger.Warningf("通过API封禁用户 %s 失败: %v", email, err)
-	} else {
-		// 封禁成功后，在内存中标记该用户为"已封禁"状态。
-		ClientStatus[email] = true
+	// 解封成功后，从内存中移除该用户的处理记录。
+	delete(ClientStatus, email)
+	if reason != "" {
+		if _, err := j.deviceLimitService.CloseBan(email, reason); err != nil {
+			logger.Warningf("结束用户 %s 的封禁记录失败: %v", email, err)
+		}
 	}
+	j.eventHub.PublishBan(email, false, info.Limit, activeIPCount)
+	return changed
 }
 
-// unbanUser 中文注释: 封装的解封用户函数；IP数量已恢复正常，但用户处于封禁状态 -> 执行解封 (恢复原始 UUID)
-func (j *CheckDeviceLimitJob) unbanUser(email string, activeIPCount int, info *struct {
-	Limit    int
-	Tag      string
-	Protocol model.Protocol
-},
-) {
+// restoreUser 将数据库中原始的、正确的用户信息重新添加回 Xray-Core，返回是否成功
+func (j *CheckDeviceLimitJob) restoreUser(email string, info *service.DeviceLimitClient) bool {
+	// 客户端已被删除时，重新生成的 Xray 配置中已没有该用户
 	_, client, err := j.inboundService.GetClientByEmail(email)
 	if err != nil || client == nil {
-		return
+		return true
 	}
-	logger.Infof("〔设备数量〕已恢复：用户 %s. 限制: %d, 当前活跃: %d. 执行解封/恢复用户。", email, info.Limit, activeIPCount)
 
 	// 步骤一：先从 Xray-Core 中删除用于"封禁"的那个临时用户。
 	_ = j.xrayApi.RemoveUser(info.Tag, email)
 
+	// 客户端已停用时不再添加回去
+	if !client.Enable {
+		return true
+	}
+
 	// 使用配置的延时，确保解封操作的稳定性
 	time.Sleep(config.DeviceLimitOperationDelay)
 
-	var clientMap map[string]interface{}
-	clientJson, _ := json.Marshal(client)
-	_ = json.Unmarshal(clientJson, &clientMap)
-
-	// 步骤二：将数据库中原始的、正确的用户信息重新添加回 Xray-Core，从而实现"解封"。
-	err = j.xrayApi.AddUser(string(info.Protocol), info.Tag, clientMap)
-	if err != nil {
+	// 步骤二：重新添加原始用户，从而实现"解封"。
+	if err := j.xrayApi.AddUser(string(info.Protocol), info.Tag, deviceLimitUser(client, info, client.SpeedLimit)); err != nil {
 		logger.Warningf("通过API恢复用户 %s 失败: %v", email, err)
-	} else {
-		// 解封成功后，从内存中移除该用户的"已封禁"状态标记。
-		delete(ClientStatus, email)
+		return false
 	}
+	return true
 }
 
 // =================================================================
@@ -0,0 +1,28 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewestIPs(t *testing.T) {
+	now := time.Now()
+	ips := map[string]time.Time{
+		"1.1.1.1": now,
+		"2.2.2.2": now,
+		"3.3.3.3": now,
+	}
+	// 最后活跃时间相同，按首次出现时间判断新设备
+	firstSeen := map[string]time.Time{
+		"1.1.1.1": now.Add(-3 * time.Minute),
+		"2.2.2.2": now.Add(-time.Minute),
+		"3.3.3.3": now.Add(-2 * time.Minute),
+	}
+	rejected := newestIPs(ips, firstSeen, 1)
+	if len(rejected) != 2 || rejected[0] != "3.3.3.3" || rejected[1] != "2.2.2.2" {
+		t.Errorf("unexpected rejected IPs: %v", rejected)
+	}
+	if rejected := newestIPs(ips, firstSeen, 3); len(rejected) != 0 {
+		t.Errorf("expected no rejection within limit, got %v", rejected)
+	}
+}
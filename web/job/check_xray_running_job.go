@@ -5,17 +5,16 @@ import (
 	"sync"
 	"time"
 
-	"x-ui/logger"
 	"x-ui/web/service"
 )
 
+// CheckXrayRunningJob 每秒检查 Xray 进程，崩溃后由 XrayService 的进程监控按退避策略重启
 type CheckXrayRunningJob struct {
 	xrayService *service.XrayService
 
-	checkTime int
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 func NewCheckXrayRunningJob(xrayService *service.XrayService) *CheckXrayRunningJob {
@@ -61,17 +60,5 @@ func (j *CheckXrayRunningJob) Run() {
 	if j.xrayService == nil {
 		return
 	}
-	if !j.xrayService.DidXrayCrash() {
-		j.checkTime = 0
-	} else {
-		j.checkTime++
-		// only restart if it's down 2 times in a row
-		if j.checkTime > 1 {
-			err := j.xrayService.RestartXray(false)
-			j.checkTime = 0
-			if err != nil {
-				logger.Error("Restart xray failed:", err)
-			}
-		}
-	}
+	j.xrayService.SuperviseXray(time.Now())
 }
@@ -0,0 +1,73 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/web/service"
+)
+
+// GeoUpdateJob 定期更新已到期的地理数据来源，文件内容变化后强制重启 Xray 以加载新文件
+type GeoUpdateJob struct {
+	geoDataService *service.GeoDataService
+	xrayService    *service.XrayService
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+// NewGeoUpdateJob 创建地理数据更新任务
+func NewGeoUpdateJob(geoDataService *service.GeoDataService, xrayService *service.XrayService) *GeoUpdateJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &GeoUpdateJob{
+		geoDataService: geoDataService,
+		xrayService:    xrayService,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+func (j *GeoUpdateJob) Name() string {
+	return "GeoUpdateJob"
+}
+
+func (j *GeoUpdateJob) Start() error {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		// 更新间隔以小时为单位，每 10 分钟检查一次哪些来源已到期
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *GeoUpdateJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *GeoUpdateJob) Run() {
+	if !j.geoDataService.RefreshDueGeoSources(time.Now()) {
+		return
+	}
+	// 配置本身没有变化，必须强制重启才能加载新的地理数据文件
+	if !j.xrayService.IsCoreRunning() {
+		return
+	}
+	if err := j.xrayService.RestartXray(true); err != nil {
+		logger.Warning("Restart xray after geo update failed:", err)
+	}
+}
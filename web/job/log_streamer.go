@@ -148,17 +148,15 @@ func (ls *LogStreamer) parseLogLine(line string) {
 		}
 
 		// 更新活跃客户端IP
-		ls.updateActiveClientIP(email, ip)
+		recordActiveClientIP(email, ip, time.Now())
 	}
 }
 
-// updateActiveClientIP 更新活跃客户端IP（带容量保护）
-func (ls *LogStreamer) updateActiveClientIP(email string, ip string) {
+// recordActiveClientIP 更新活跃客户端IP（带容量保护），只会将最后活跃时间向后推移
+func recordActiveClientIP(email string, ip string, seen time.Time) {
 	activeClientsLock.Lock()
 	defer activeClientsLock.Unlock()
 
-	now := time.Now()
-
 	ips, exists := ActiveClientIPs[email]
 	if !exists {
 		// 新用户：检查总用户数上限
@@ -171,8 +169,10 @@ func (ls *LogStreamer) updateActiveClientIP(email string, ip string) {
 	}
 
 	// 如果 IP 已存在，仅更新时间戳
-	if _, ok := ips[ip]; ok {
-		ips[ip] = now
+	if last, ok := ips[ip]; ok {
+		if seen.After(last) {
+			ips[ip] = seen
+		}
 		return
 	}
 
@@ -182,7 +182,13 @@ func (ls *LogStreamer) updateActiveClientIP(email string, ip string) {
 		evictOldestIP(ips)
 	}
 
-	ips[ip] = now
+	ips[ip] = seen
+	if clientIPFirstSeen[email] == nil {
+		clientIPFirstSeen[email] = make(map[string]time.Time)
+	}
+	if _, ok := clientIPFirstSeen[email][ip]; !ok {
+		clientIPFirstSeen[email][ip] = seen
+	}
 }
 
 // evictOldestEmail 淘汰 ActiveClientIPs 中最久未活跃的用户（调用方需持有写锁）
@@ -227,6 +233,11 @@ func evictOldestIP(ips map[string]time.Time) {
 
 // GetActiveClientIPs 获取当前活跃的客户端IP映射（供外部查询）
 func (ls *LogStreamer) GetActiveClientIPs() map[string]map[string]time.Time {
+	return snapshotActiveClientIPs()
+}
+
+// snapshotActiveClientIPs 返回 ActiveClientIPs 的副本
+func snapshotActiveClientIPs() map[string]map[string]time.Time {
 	activeClientsLock.RLock()
 	defer activeClientsLock.RUnlock()
 
@@ -241,3 +252,18 @@ func (ls *LogStreamer) GetActiveClientIPs() map[string]map[string]time.Time {
 
 	return result
 }
+
+// snapshotClientIPFirstSeen 返回各活跃 IP 首次出现时间的副本
+func snapshotClientIPFirstSeen() map[string]map[string]time.Time {
+	activeClientsLock.RLock()
+	defer activeClientsLock.RUnlock()
+
+	result := make(map[string]map[string]time.Time, len(clientIPFirstSeen))
+	for email, ips := range clientIPFirstSeen {
+		result[email] = make(map[string]time.Time, len(ips))
+		for ip, t := range ips {
+			result[email][ip] = t
+		}
+	}
+	return result
+}
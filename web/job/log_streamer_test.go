@@ -0,0 +1,41 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordActiveClientIP(t *testing.T) {
+	activeClientsLock.Lock()
+	ActiveClientIPs = make(map[string]map[string]time.Time)
+	activeClientsLock.Unlock()
+	t.Cleanup(func() {
+		activeClientsLock.Lock()
+		ActiveClientIPs = make(map[string]map[string]time.Time)
+		activeClientsLock.Unlock()
+	})
+
+	now := time.Now()
+	recordActiveClientIP("alice", "1.2.3.4", now)
+	// 在线统计中较旧的时间不会覆盖日志中更新的活跃时间
+	recordActiveClientIP("alice", "1.2.3.4", now.Add(-time.Minute))
+	recordActiveClientIP("alice", "5.6.7.8", now.Add(-time.Minute))
+
+	ls := NewLogStreamer("")
+	ls.parseLogLine("2024/01/02 03:04:05 from tcp:9.9.9.9:1234 accepted tcp:example.com:443 [in >> direct] email: bob")
+	ls.parseLogLine("2024/01/02 03:04:05 from 127.0.0.1:1234 accepted tcp:example.com:443 [api] email: bob")
+
+	snapshot := snapshotActiveClientIPs()
+	if len(snapshot["alice"]) != 2 || !snapshot["alice"]["1.2.3.4"].Equal(now) {
+		t.Errorf("unexpected alice IPs: %v", snapshot["alice"])
+	}
+	if len(snapshot["bob"]) != 1 {
+		t.Errorf("unexpected bob IPs: %v", snapshot["bob"])
+	}
+
+	// 副本修改不影响全局状态
+	delete(snapshot, "alice")
+	if len(snapshotActiveClientIPs()) != 2 {
+		t.Error("snapshot should be a copy")
+	}
+}
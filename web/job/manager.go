@@ -4,11 +4,14 @@ import (
 	"sync"
 
 	"x-ui/logger"
+
+	"go.uber.org/atomic"
 )
 
 type Manager struct {
-	jobs []Job
-	mu   sync.RWMutex
+	jobs    []Job
+	mu      sync.RWMutex
+	running atomic.Bool
 }
 
 func NewManager() *Manager {
@@ -35,6 +38,7 @@ func (m *Manager) StartAll() {
 			logger.Errorf("Failed to start job %s: %v", job.Name(), err)
 		}
 	}
+	m.running.Store(true)
 }
 
 func (m *Manager) StopAll() {
@@ -56,5 +60,11 @@ func (m *Manager) StopAll() {
 		}(j)
 	}
 	wg.Wait()
+	m.running.Store(false)
 	logger.Info("All background jobs stopped")
 }
+
+// IsRunning 返回后台任务是否处于运行状态
+func (m *Manager) IsRunning() bool {
+	return m.running.Load()
+}
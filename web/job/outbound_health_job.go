@@ -0,0 +1,66 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/web/service"
+)
+
+// OutboundHealthJob 定期读取 Xray 观测服务的出站健康状态
+type OutboundHealthJob struct {
+	xrayService *service.XrayService
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func NewOutboundHealthJob(xrayService *service.XrayService) *OutboundHealthJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &OutboundHealthJob{
+		xrayService: xrayService,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (j *OutboundHealthJob) Name() string {
+	return "OutboundHealthJob"
+}
+
+func (j *OutboundHealthJob) Start() error {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		// @every 30s，与 observatory 默认的探测间隔保持同一量级
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *OutboundHealthJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *OutboundHealthJob) Run() {
+	if !j.xrayService.IsXrayRunning() {
+		return
+	}
+	if err := j.xrayService.CollectOutboundHealth(); err != nil {
+		logger.Debug("collect outbound health failed:", err)
+	}
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"x-ui/web/service"
+)
+
+// OutboundSubscriptionJob 定期刷新到期的出站订阅，模板变化后标记 Xray 需要重启
+type OutboundSubscriptionJob struct {
+	xraySettingService *service.XraySettingService
+	xrayService        *service.XrayService
+	ctx                context.Context
+	cancel             context.CancelFunc
+	wg                 sync.WaitGroup
+}
+
+// NewOutboundSubscriptionJob 创建出站订阅刷新任务
+func NewOutboundSubscriptionJob(xraySettingService *service.XraySettingService, xrayService *service.XrayService) *OutboundSubscriptionJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &OutboundSubscriptionJob{
+		xraySettingService: xraySettingService,
+		xrayService:        xrayService,
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+}
+
+func (j *OutboundSubscriptionJob) Name() string {
+	return "OutboundSubscriptionJob"
+}
+
+func (j *OutboundSubscriptionJob) Start() error {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		// 订阅刷新间隔以分钟为单位，每分钟检查一次哪些订阅已到期
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *OutboundSubscriptionJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *OutboundSubscriptionJob) Run() {
+	if j.xraySettingService.RefreshDueOutboundSubscriptions() {
+		j.xrayService.SetToNeedRestart()
+	}
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/util/common"
+	"x-ui/web/service"
+)
+
+// QuotaResetJob 按入站和客户端配置的重置周期清零流量配额，归档上一周期用量并发送通知
+type QuotaResetJob struct {
+	inboundService  *service.InboundService
+	xrayService     *service.XrayService
+	settingService  service.SettingService
+	telegramService service.TelegramService
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+// NewQuotaResetJob 创建配额周期重置任务
+func NewQuotaResetJob(
+	inboundService *service.InboundService,
+	xrayService *service.XrayService,
+	settingService service.SettingService,
+	telegramService service.TelegramService,
+) *QuotaResetJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &QuotaResetJob{
+		inboundService:  inboundService,
+		xrayService:     xrayService,
+		settingService:  settingService,
+		telegramService: telegramService,
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
+func (j *QuotaResetJob) Name() string {
+	return "QuotaResetJob"
+}
+
+func (j *QuotaResetJob) Start() error {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *QuotaResetJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *QuotaResetJob) Run() {
+	loc, err := j.settingService.GetTimeLocation()
+	if err != nil {
+		loc = time.Local
+	}
+	result, err := j.inboundService.ResetScheduledQuotas(time.Now().In(loc))
+	if err != nil {
+		logger.Warning("reset scheduled quotas failed:", err)
+		return
+	}
+	if result.NeedRestart {
+		j.xrayService.SetToNeedRestart()
+	}
+	if len(result.Archives) > 0 {
+		j.notify(result)
+	}
+}
+
+// notify 发送本轮配额重置的汇总通知
+func (j *QuotaResetJob) notify(result *service.QuotaResetResult) {
+	if j.telegramService == nil || !j.telegramService.IsRunning() {
+		return
+	}
+	var lines []string
+	for _, archive := range result.Archives {
+		name := archive.Email
+		if name == "" {
+			name = fmt.Sprintf("入站 #%d", archive.InboundId)
+		}
+		lines = append(lines, fmt.Sprintf("  %s：上周期已用 %s", name, common.FormatTraffic(archive.Up+archive.Down)))
+	}
+	msg := "<b>〔X-Panel面板〕流量配额已按周期重置</b>\n\n" + strings.Join(lines, "\n")
+	if err := j.telegramService.SendMessage(msg); err != nil {
+		logger.Warningf("发送配额重置通知失败: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"x-ui/web/service"
+)
+
+// WarpCheckJob 定期检查 WARP 账户，出站使用的账户失效时轮换，模板变化后标记 Xray 需要重启
+type WarpCheckJob struct {
+	warpService *service.WarpService
+	xrayService *service.XrayService
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewWarpCheckJob 创建 WARP 账户检查任务
+func NewWarpCheckJob(warpService *service.WarpService, xrayService *service.XrayService) *WarpCheckJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WarpCheckJob{
+		warpService: warpService,
+		xrayService: xrayService,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (j *WarpCheckJob) Name() string {
+	return "WarpCheckJob"
+}
+
+func (j *WarpCheckJob) Start() error {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (j *WarpCheckJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *WarpCheckJob) Run() {
+	// 启用观测服务时结合出站存活状态判断账户是否可用
+	alive := make(map[string]bool)
+	if report := j.xrayService.GetOutboundHealth(); report != nil {
+		for _, outbound := range report.Outbounds {
+			alive[outbound.Tag] = outbound.Alive
+		}
+	}
+	if j.warpService.CheckWarpAccounts(alive) {
+		j.xrayService.SetToNeedRestart()
+	}
+}
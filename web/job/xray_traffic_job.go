@@ -13,6 +13,7 @@ type XrayTrafficJob struct {
 	xrayService     *service.XrayService
 	inboundService  *service.InboundService
 	outboundService *service.OutboundService
+	eventHub        *service.EventHub
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
@@ -29,6 +30,11 @@ func NewXrayTrafficJob(xrayService *service.XrayService, inboundService *service
 	}
 }
 
+// SetEventHub 注入事件中心，用于推送流量增量和在线状态变化
+func (j *XrayTrafficJob) SetEventHub(eventHub *service.EventHub) {
+	j.eventHub = eventHub
+}
+
 func (j *XrayTrafficJob) Name() string {
 	return "XrayTrafficJob"
 }
@@ -64,7 +70,7 @@ func (j *XrayTrafficJob) Run() {
 		return
 	}
 
-	if !j.xrayService.IsXrayRunning() {
+	if !j.xrayService.IsCoreRunning() {
 		return
 	}
 	traffics, clientTraffics, err := j.xrayService.GetXrayTraffic()
@@ -82,4 +88,11 @@ func (j *XrayTrafficJob) Run() {
 	if needRestart0 || needRestart1 {
 		j.xrayService.SetToNeedRestart()
 	}
+
+	if j.eventHub != nil {
+		if j.eventHub.HasSubscribers(service.TopicTraffic) {
+			j.eventHub.Publish(service.TopicTraffic, service.NewTrafficEvent(traffics, clientTraffics))
+		}
+		j.eventHub.PublishOnlineClients(j.xrayService.GetOnlineClients())
+	}
 }
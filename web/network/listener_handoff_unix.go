@@ -0,0 +1,52 @@
+//go:build !windows
+
+package network
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// ExecWithListeners 以当前路径上的(新)二进制替换当前进程，并把监听套接字传递给新进程
+// 进程 PID 保持不变，systemd 等进程管理器不会感知到重启。
+// env 与 extra 为需要额外传递给新进程的环境变量和文件，extra 由调用方负责在 exec 失败时关闭
+func ExecWithListeners(env []string, extra ...*os.File) error {
+	binary, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return err
+	}
+
+	spec, files, err := defaultPool.exportFiles()
+	defer func() {
+		// 只有 exec 失败时才会执行到这里
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	// File() 复制出的描述符带有 CLOEXEC 标记，需要清除才能在 exec 后保留
+	for _, f := range slices.Concat(extra, files) {
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_SETFD, 0); errno != 0 {
+			return errno
+		}
+	}
+
+	environ := make([]string, 0, len(os.Environ())+len(env)+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envInheritedListeners+"=") {
+			environ = append(environ, kv)
+		}
+	}
+	environ = append(environ, env...)
+	if spec != "" {
+		environ = append(environ, envInheritedListeners+"="+spec)
+	}
+
+	//nolint:gosec
+	return syscall.Exec(binary, os.Args, environ)
+}
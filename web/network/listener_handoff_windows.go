@@ -0,0 +1,13 @@
+//go:build windows
+
+package network
+
+import (
+	"errors"
+	"os"
+)
+
+// ExecWithListeners Windows 不支持通过 exec 传递监听套接字
+func ExecWithListeners(env []string, extra ...*os.File) error {
+	return errors.New("listener handoff is not supported on windows")
+}
@@ -0,0 +1,253 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+
+	"go.uber.org/atomic"
+)
+
+// envInheritedListeners 进程替换时用于传递监听套接字的环境变量
+// 格式: "tcp|0.0.0.0:2053=3,tcp|0.0.0.0:2096=4"
+const envInheritedListeners = "XUI_INHERITED_LISTENERS"
+
+// ListenerPool 管理可复用的 TCP 监听套接字
+// Server 停止时监听套接字不会真正关闭，而是保留在池中，新的 Server 实例绑定相同地址时直接接管，
+// 期间到达的新连接在内核 backlog 中排队，不会被拒绝。
+type ListenerPool struct {
+	mu      sync.Mutex
+	parked  map[string]*net.TCPListener
+	active  map[string]*net.TCPListener
+	inherit sync.Once
+}
+
+// NewListenerPool 创建 ListenerPool 实例
+func NewListenerPool() *ListenerPool {
+	return &ListenerPool{
+		parked: make(map[string]*net.TCPListener),
+		active: make(map[string]*net.TCPListener),
+	}
+}
+
+var defaultPool = NewListenerPool()
+
+// Listen 使用全局监听池监听地址
+func Listen(network, addr string) (net.Listener, error) {
+	return defaultPool.Listen(network, addr)
+}
+
+// CloseIdleListeners 关闭全局监听池中未被接管的监听套接字
+func CloseIdleListeners() {
+	defaultPool.CloseIdle()
+}
+
+// CloseAllListeners 关闭全局监听池中的所有监听套接字，用于进程退出
+func CloseAllListeners() {
+	defaultPool.CloseAll()
+}
+
+func listenerKey(network, addr string) string {
+	return network + "|" + addr
+}
+
+// Listen 优先接管池中保留的或从父进程继承的监听套接字，否则新建监听
+func (p *ListenerPool) Listen(network, addr string) (net.Listener, error) {
+	p.inherit.Do(p.loadInherited)
+
+	key := listenerKey(network, addr)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, busy := p.active[key]; busy {
+		return nil, fmt.Errorf("listener %s is already in use", key)
+	}
+
+	ln, ok := p.parked[key]
+	if ok {
+		delete(p.parked, key)
+		// 清除交接时设置的截止时间
+		_ = ln.SetDeadline(time.Time{})
+		logger.Infof("Reusing listener %s", key)
+	} else {
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tcp, isTCP := l.(*net.TCPListener)
+		if !isTCP {
+			return l, nil
+		}
+		ln = tcp
+	}
+
+	p.active[key] = ln
+	return &pooledListener{TCPListener: ln, pool: p, key: key}, nil
+}
+
+// park 将监听套接字放回池中，等待新的 Server 实例接管
+func (p *ListenerPool) park(key string, ln *net.TCPListener) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active[key] == ln {
+		delete(p.active, key)
+	}
+	p.parked[key] = ln
+}
+
+// CloseIdle 关闭所有未被接管的监听套接字（例如重启后端口已变更）
+func (p *ListenerPool) CloseIdle() {
+	p.inherit.Do(p.loadInherited)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, ln := range p.parked {
+		_ = ln.Close()
+		delete(p.parked, key)
+		logger.Infof("Closed idle listener %s", key)
+	}
+}
+
+// CloseAll 关闭所有监听套接字
+func (p *ListenerPool) CloseAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, ln := range p.parked {
+		_ = ln.Close()
+		delete(p.parked, key)
+	}
+	for key, ln := range p.active {
+		_ = ln.Close()
+		delete(p.active, key)
+	}
+}
+
+// exportFiles 导出所有监听套接字的文件描述符，用于传递给替换后的新进程
+func (p *ListenerPool) exportFiles() (string, []*os.File, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var specs []string
+	var files []*os.File
+	export := func(key string, ln *net.TCPListener) error {
+		f, err := ln.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		specs = append(specs, key+"="+strconv.Itoa(int(f.Fd())))
+		return nil
+	}
+	for key, ln := range p.parked {
+		if err := export(key, ln); err != nil {
+			return "", files, err
+		}
+	}
+	for key, ln := range p.active {
+		if err := export(key, ln); err != nil {
+			return "", files, err
+		}
+	}
+	return strings.Join(specs, ","), files, nil
+}
+
+// loadInherited 从环境变量恢复父进程传递的监听套接字
+func (p *ListenerPool) loadInherited() {
+	spec := os.Getenv(envInheritedListeners)
+	if spec == "" {
+		return
+	}
+	_ = os.Unsetenv(envInheritedListeners)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range strings.Split(spec, ",") {
+		key, fdStr, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil {
+			continue
+		}
+		f := os.NewFile(uintptr(fd), key)
+		if f == nil {
+			continue
+		}
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			logger.Warningf("Failed to inherit listener %s: %v", key, err)
+			continue
+		}
+		if tcp, ok := l.(*net.TCPListener); ok {
+			p.parked[key] = tcp
+			logger.Infof("Inherited listener %s", key)
+		} else {
+			_ = l.Close()
+		}
+	}
+}
+
+// pooledListener 包装池中的 TCP 监听套接字
+// Close 时不关闭底层套接字，而是中断当前 Accept 并将套接字交还给池
+type pooledListener struct {
+	*net.TCPListener
+	pool *ListenerPool
+	key  string
+
+	closed   atomic.Bool
+	acceptMu sync.RWMutex
+}
+
+func (l *pooledListener) Accept() (net.Conn, error) {
+	l.acceptMu.RLock()
+	defer l.acceptMu.RUnlock()
+	// 持锁后再检查，避免在套接字交还给池之后继续 Accept
+	if l.closed.Load() {
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.TCPListener.AcceptTCP()
+	if err != nil {
+		if l.closed.Load() {
+			return nil, net.ErrClosed
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (l *pooledListener) Close() error {
+	if !l.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	// 通过截止时间唤醒阻塞中的 Accept，并等待其返回后再交还套接字
+	_ = l.TCPListener.SetDeadline(time.Now())
+	l.acceptMu.Lock()
+	defer l.acceptMu.Unlock()
+	l.pool.park(l.key, l.TCPListener)
+	return nil
+}
+
+// DrainServer 在超时时间内平滑关闭 HTTP 服务器，超时后强制关闭剩余连接
+func DrainServer(srv *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Warningf("HTTP server did not drain within %v, closing remaining connections", timeout)
+		return srv.Close()
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func serveText(t *testing.T, p *ListenerPool, addr, body string) (*http.Server, string) {
+	t.Helper()
+	ln, err := p.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, ln.Addr().String()
+}
+
+func get(t *testing.T, addr string) string {
+	t.Helper()
+	client := &http.Client{Timeout: 2 * time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + addr)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestListenerPool_HandoffKeepsSocket(t *testing.T) {
+	p := NewListenerPool()
+	defer p.CloseAll()
+
+	oldSrv, addr := serveText(t, p, "127.0.0.1:0", "old")
+	if got := get(t, addr); got != "old" {
+		t.Fatalf("expected old, got %q", got)
+	}
+
+	// 旧实例停止后套接字被保留，新的连接排队等待
+	if err := DrainServer(oldSrv, time.Second); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+	if len(p.parked) != 1 {
+		t.Fatalf("expected 1 parked listener, got %d", len(p.parked))
+	}
+
+	newSrv, newAddr := serveText(t, p, "127.0.0.1:0", "new")
+	defer func() { _ = DrainServer(newSrv, time.Second) }()
+	if newAddr != addr {
+		t.Fatalf("expected reused address %s, got %s", addr, newAddr)
+	}
+	if got := get(t, addr); got != "new" {
+		t.Fatalf("expected new, got %q", got)
+	}
+}
+
+func TestListenerPool_RejectsDoubleListen(t *testing.T) {
+	p := NewListenerPool()
+	defer p.CloseAll()
+
+	ln, err := p.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	if _, err := p.Listen("tcp", "127.0.0.1:0"); err == nil {
+		t.Error("expected error when key is already active")
+	}
+}
+
+func TestListenerPool_CloseIdle(t *testing.T) {
+	p := NewListenerPool()
+	ln, err := p.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ln.Close()
+	// 重复关闭不应出错
+	if err := ln.Close(); err != nil {
+		t.Errorf("second close returned %v", err)
+	}
+	if len(p.parked) != 1 {
+		t.Fatalf("expected parked listener, got %d", len(p.parked))
+	}
+
+	p.CloseIdle()
+	if len(p.parked) != 0 {
+		t.Errorf("expected no parked listeners after CloseIdle, got %d", len(p.parked))
+	}
+}
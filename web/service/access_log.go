@@ -0,0 +1,201 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"x-ui/config"
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/logger"
+	"x-ui/util/common"
+)
+
+// 访问记录中来源 IP 的匿名化方式
+const (
+	AccessLogAnonymizeNone     = "none"
+	AccessLogAnonymizeTruncate = "truncate" // IPv4 保留 /24，IPv6 保留 /48
+	AccessLogAnonymizeHash     = "hash"     // 使用面板密钥做 HMAC，同一 IP 得到相同结果但不可还原
+)
+
+// accessLineRegex 匹配 Xray 访问日志中已接受的连接：
+// 2006/01/02 15:04:05.000000 from tcp:1.2.3.4:5678 accepted tcp:example.com:443 [in >> out] email: user
+var accessLineRegex = regexp.MustCompile(`^(?:(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})(?:\.\d+)? )?from (?:tcp:|udp:)?\[?([0-9a-fA-F\.:]+)\]?:\d+ accepted (?:(tcp|udp):)?(\S+)(?: \[([^\]]*)\])?.* email: (\S+)$`)
+
+// detourSeparators Xray 在访问日志中连接入站与出站标签使用的分隔符
+var detourSeparators = []string{" -> ", " >> ", " ==> "}
+
+// AccessLogOptions 访问日志采集配置
+type AccessLogOptions struct {
+	Enabled       bool
+	RetentionDays int
+	Anonymize     string
+}
+
+// AccessLogService 将 Xray 访问日志解析为按客户端的访问记录，并提供查询和统计
+type AccessLogService struct {
+	SettingService
+
+	accessLogRepo repository.AccessLogRepository
+}
+
+// getAccessLogRepo 返回 AccessLogRepository，支持延迟初始化
+func (s *AccessLogService) getAccessLogRepo() repository.AccessLogRepository {
+	if s.accessLogRepo == nil {
+		s.accessLogRepo = repository.NewAccessLogRepository(database.GetDB())
+	}
+	return s.accessLogRepo
+}
+
+// GetOptions 读取访问日志采集配置
+func (s *AccessLogService) GetOptions() (*AccessLogOptions, error) {
+	enabled, err := s.GetAccessLogEnabled()
+	if err != nil {
+		return nil, err
+	}
+	retention, err := s.GetAccessLogRetentionDays()
+	if err != nil {
+		return nil, err
+	}
+	anonymize, err := s.GetAccessLogAnonymize()
+	if err != nil {
+		return nil, err
+	}
+	return &AccessLogOptions{Enabled: enabled, RetentionDays: retention, Anonymize: anonymize}, nil
+}
+
+// ParseAccessLogLine 解析一行 Xray 访问日志，非客户端的已接受连接返回 nil
+func ParseAccessLogLine(line string, now time.Time) *model.AccessLogEntry {
+	match := accessLineRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return nil
+	}
+	sourceIp := match[2]
+	if sourceIp == "127.0.0.1" || sourceIp == "::1" {
+		return nil
+	}
+
+	entry := &model.AccessLogEntry{
+		Time:     now.Unix(),
+		Email:    match[6],
+		SourceIp: sourceIp,
+		Network:  match[3],
+	}
+	if match[1] != "" {
+		if t, err := time.ParseInLocation("2006/01/02 15:04:05", match[1], time.Local); err == nil {
+			entry.Time = t.Unix()
+		}
+	}
+	if entry.Network == "" {
+		entry.Network = "tcp"
+	}
+
+	entry.Host = match[4]
+	if host, port, err := net.SplitHostPort(match[4]); err == nil {
+		entry.Host = host
+		entry.Port, _ = strconv.Atoi(port)
+	}
+
+	entry.Outbound = match[5]
+	for _, sep := range detourSeparators {
+		if in, out, ok := strings.Cut(match[5], sep); ok {
+			entry.Inbound, entry.Outbound = in, out
+			break
+		}
+	}
+	return entry
+}
+
+// AnonymizeIP 按匿名化方式处理来源 IP
+func AnonymizeIP(ip string, mode string, secret []byte) string {
+	switch mode {
+	case AccessLogAnonymizeTruncate:
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return ip
+		}
+		if v4 := parsed.To4(); v4 != nil {
+			return v4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return parsed.Mask(net.CIDRMask(48, 128)).String()
+	case AccessLogAnonymizeHash:
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	default:
+		return ip
+	}
+}
+
+// SaveAccessLogs 按当前匿名化配置处理来源 IP 后写入访问记录
+func (s *AccessLogService) SaveAccessLogs(entries []*model.AccessLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	mode, err := s.GetAccessLogAnonymize()
+	if err != nil {
+		return err
+	}
+	var secret []byte
+	if mode == AccessLogAnonymizeHash {
+		if secret, err = s.GetSecret(); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		entry.SourceIp = AnonymizeIP(entry.SourceIp, mode, secret)
+	}
+	return s.getAccessLogRepo().CreateBatch(entries)
+}
+
+// PruneAccessLogs 删除超过保留天数的访问记录，并限制记录总数
+func (s *AccessLogService) PruneAccessLogs(now time.Time) error {
+	days, err := s.GetAccessLogRetentionDays()
+	if err != nil {
+		return err
+	}
+	repo := s.getAccessLogRepo()
+	if days > 0 {
+		deleted, err := repo.DeleteBefore(now.AddDate(0, 0, -days).Unix())
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			logger.Debugf("Pruned %d expired access log entries", deleted)
+		}
+	}
+	_, err = repo.Prune(config.AccessLogMaxEntries)
+	return err
+}
+
+// GetClientAccessLogs 查询客户端自 since 起的访问记录
+func (s *AccessLogService) GetClientAccessLogs(email string, since int64, limit int) ([]*model.AccessLogEntry, error) {
+	if email == "" {
+		return nil, common.NewError("client email is required")
+	}
+	return s.getAccessLogRepo().Find(&repository.AccessLogQuery{Email: email, Since: since, Limit: limit})
+}
+
+// GetClientTopDestinations 统计客户端最近 hours 小时内访问最多的目标
+func (s *AccessLogService) GetClientTopDestinations(email string, hours int, limit int) ([]*model.AccessDestinationStat, error) {
+	if email == "" {
+		return nil, common.NewError("client email is required")
+	}
+	if hours <= 0 {
+		hours = 24
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour).Unix()
+	return s.getAccessLogRepo().TopDestinations(email, since, limit)
+}
+
+// ClearClientAccessLogs 删除客户端的全部访问记录
+func (s *AccessLogService) ClearClientAccessLogs(email string) error {
+	return s.getAccessLogRepo().DeleteByEmail(email)
+}
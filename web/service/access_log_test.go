@@ -0,0 +1,115 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"x-ui/database/model"
+)
+
+func TestParseAccessLogLine(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		line     string
+		email    string
+		source   string
+		host     string
+		port     int
+		inbound  string
+		outbound string
+	}{
+		{"2024/01/02 03:04:05.123456 from tcp:1.2.3.4:5678 accepted tcp:www.example.com:443 [inbound-443 >> direct] email: alice", "alice", "1.2.3.4", "www.example.com", 443, "inbound-443", "direct"},
+		{"from [2001:db8::1]:5678 accepted udp:[2001:4860::8888]:53 [inbound-443 -> proxy] email: bob", "bob", "2001:db8::1", "2001:4860::8888", 53, "inbound-443", "proxy"},
+		{"from 1.2.3.4:5678 accepted tcp:8.8.8.8:853 [blocked] email: carol", "carol", "1.2.3.4", "8.8.8.8", 853, "", "blocked"},
+	}
+	for _, tt := range tests {
+		entry := ParseAccessLogLine(tt.line, now)
+		if entry == nil {
+			t.Fatalf("failed to parse %q", tt.line)
+		}
+		if entry.Email != tt.email || entry.SourceIp != tt.source || entry.Host != tt.host || entry.Port != tt.port ||
+			entry.Inbound != tt.inbound || entry.Outbound != tt.outbound {
+			t.Errorf("unexpected entry for %q: %+v", tt.line, entry)
+		}
+	}
+
+	withTime := ParseAccessLogLine(tests[0].line, now)
+	if expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local).Unix(); withTime.Time != expected {
+		t.Errorf("expected log time %d, got %d", expected, withTime.Time)
+	}
+	if ParseAccessLogLine(tests[1].line, now).Time != now.Unix() {
+		t.Error("lines without timestamp should use the current time")
+	}
+
+	ignored := []string{
+		"from 127.0.0.1:5678 accepted tcp:example.com:443 [api] email: alice",
+		"from 1.2.3.4:5678 accepted tcp:example.com:443 [direct]",
+		"from 1.2.3.4:5678 rejected  proxy/vless/encoding: invalid request user id",
+	}
+	for _, line := range ignored {
+		if entry := ParseAccessLogLine(line, now); entry != nil {
+			t.Errorf("line should be ignored: %q", line)
+		}
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	if got := AnonymizeIP("1.2.3.4", AccessLogAnonymizeTruncate, nil); got != "1.2.3.0" {
+		t.Errorf("unexpected truncated IPv4: %s", got)
+	}
+	if got := AnonymizeIP("2001:db8:1:2::1", AccessLogAnonymizeTruncate, nil); got != "2001:db8:1::" {
+		t.Errorf("unexpected truncated IPv6: %s", got)
+	}
+	a := AnonymizeIP("1.2.3.4", AccessLogAnonymizeHash, []byte("k1"))
+	if len(a) != 16 || a != AnonymizeIP("1.2.3.4", AccessLogAnonymizeHash, []byte("k1")) || a == AnonymizeIP("1.2.3.4", AccessLogAnonymizeHash, []byte("k2")) {
+		t.Errorf("hash anonymization should be stable per secret: %s", a)
+	}
+	if got := AnonymizeIP("1.2.3.4", AccessLogAnonymizeNone, nil); got != "1.2.3.4" {
+		t.Errorf("unexpected IP: %s", got)
+	}
+}
+
+func TestAccessLogService_SaveAndQuery(t *testing.T) {
+	setupTestDB(t)
+	s := &AccessLogService{}
+	if err := s.saveSetting("accessLogAnonymize", AccessLogAnonymizeTruncate); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	lines := []string{
+		"from 1.2.3.4:1000 accepted tcp:a.com:443 [in >> direct] email: alice",
+		"from 1.2.3.4:1001 accepted tcp:a.com:443 [in >> direct] email: alice",
+		"from 1.2.3.4:1002 accepted tcp:b.com:443 [in >> direct] email: alice",
+		"from 5.6.7.8:1003 accepted tcp:a.com:443 [in >> direct] email: bob",
+	}
+	for i, line := range lines {
+		entry := ParseAccessLogLine(line, now.Add(time.Duration(i)*time.Second))
+		if err := s.SaveAccessLogs([]*model.AccessLogEntry{entry}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, err := s.GetClientAccessLogs("alice", 0, 10)
+	if err != nil || len(logs) != 3 {
+		t.Fatalf("expected 3 access logs, got %d (%v)", len(logs), err)
+	}
+	if logs[0].Host != "b.com" || logs[0].SourceIp != "1.2.3.0" {
+		t.Errorf("unexpected latest access log: %+v", logs[0])
+	}
+
+	top, err := s.GetClientTopDestinations("alice", 24, 10)
+	if err != nil || len(top) != 2 || top[0].Host != "a.com" || top[0].Count != 2 {
+		t.Fatalf("unexpected top destinations: %+v (%v)", top, err)
+	}
+
+	if err := s.saveSetting("accessLogRetention", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PruneAccessLogs(now.Add(48 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if logs, _ := s.GetClientAccessLogs("bob", 0, 10); len(logs) != 0 {
+		t.Errorf("expected expired access logs to be pruned, got %d", len(logs))
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/util/common"
+)
+
+// ClientPlanService 管理客户端套餐。创建客户端时指定 planId 即按套餐填充限制字段，
+// 也可以把一批客户端迁移到套餐，或在套餐修改后重新应用到所有订阅客户端
+type ClientPlanService struct {
+	inboundService *InboundService
+	clientPlanRepo repository.ClientPlanRepository
+}
+
+// NewClientPlanService 创建 ClientPlanService 实例，客户端的修改通过传入的 InboundService 完成
+func NewClientPlanService(inboundService *InboundService) *ClientPlanService {
+	return &ClientPlanService{inboundService: inboundService}
+}
+
+// getInboundService 返回 InboundService，未注入时使用默认实例
+func (s *ClientPlanService) getInboundService() *InboundService {
+	if s.inboundService == nil {
+		s.inboundService = &InboundService{}
+	}
+	return s.inboundService
+}
+
+// getClientPlanRepo 返回 ClientPlanRepository，支持延迟初始化
+func (s *ClientPlanService) getClientPlanRepo() repository.ClientPlanRepository {
+	if s.clientPlanRepo == nil {
+		s.clientPlanRepo = repository.NewClientPlanRepository(database.GetDB())
+	}
+	return s.clientPlanRepo
+}
+
+// GetPlans 返回所有套餐
+func (s *ClientPlanService) GetPlans() ([]*model.ClientPlan, error) {
+	return s.getClientPlanRepo().FindAll()
+}
+
+// GetPlan 根据 ID 返回套餐
+func (s *ClientPlanService) GetPlan(id int) (*model.ClientPlan, error) {
+	return s.getClientPlanRepo().FindById(id)
+}
+
+// validateClientPlan 校验套餐字段
+func (s *ClientPlanService) validateClientPlan(plan *model.ClientPlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return common.NewError("plan name cannot be empty")
+	}
+	if plan.TotalGB < 0 || plan.ExpiryDays < 0 || plan.LimitIP < 0 || plan.SpeedLimit < 0 || plan.Reset < 0 {
+		return common.NewError("plan limits must be >= 0")
+	}
+	if _, err := ParseQuotaResetSchedule(plan.ResetSchedule); err != nil {
+		return err
+	}
+	exist, err := s.getClientPlanRepo().CheckNameExist(plan.Name, plan.Id)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return common.NewError("plan name already exists: ", plan.Name)
+	}
+	return nil
+}
+
+// AddPlan 创建套餐
+func (s *ClientPlanService) AddPlan(plan *model.ClientPlan) error {
+	plan.Id = 0
+	if err := s.validateClientPlan(plan); err != nil {
+		return err
+	}
+	plan.CreatedAt = time.Now().UnixMilli()
+	plan.UpdatedAt = plan.CreatedAt
+	return s.getClientPlanRepo().Create(plan)
+}
+
+// UpdatePlan 修改套餐，已订阅的客户端需要调用 SyncPlan 才会使用新的限制
+func (s *ClientPlanService) UpdatePlan(plan *model.ClientPlan) error {
+	old, err := s.GetPlan(plan.Id)
+	if err != nil {
+		return err
+	}
+	if err := s.validateClientPlan(plan); err != nil {
+		return err
+	}
+	plan.CreatedAt = old.CreatedAt
+	plan.UpdatedAt = time.Now().UnixMilli()
+	return s.getClientPlanRepo().Update(plan)
+}
+
+// DelPlan 删除套餐，仍有客户端订阅时拒绝删除
+func (s *ClientPlanService) DelPlan(id int) error {
+	emails, err := s.GetPlanClients(id)
+	if err != nil {
+		return err
+	}
+	if len(emails) > 0 {
+		return common.NewErrorf("plan is used by %d clients", len(emails))
+	}
+	return s.getClientPlanRepo().Delete(id)
+}
+
+// GetPlanClients 返回订阅了套餐的客户端 email
+func (s *ClientPlanService) GetPlanClients(planId int) ([]string, error) {
+	inbounds, err := s.getInboundService().GetAllInbounds()
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, inbound := range inbounds {
+		clients, err := s.getInboundService().GetClients(inbound)
+		if err != nil {
+			continue
+		}
+		for _, client := range clients {
+			if client.PlanId == planId && client.Email != "" {
+				emails = append(emails, client.Email)
+			}
+		}
+	}
+	return emails, nil
+}
+
+// ClientPlanApplyResult 批量应用套餐的结果，Failed 记录处理失败的客户端及原因
+type ClientPlanApplyResult struct {
+	Updated     int               `json:"updated"`
+	Failed      map[string]string `json:"failed"`
+	NeedRestart bool              `json:"-"`
+}
+
+// AssignPlan 将客户端迁移到套餐，按套餐设置全部限制并从现在起重新计算有效期
+func (s *ClientPlanService) AssignPlan(planId int, emails []string) (*ClientPlanApplyResult, error) {
+	plan, err := s.GetPlan(planId)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyPlan(plan, emails, true), nil
+}
+
+// SyncPlan 将套餐当前的限制重新应用到所有订阅客户端，保留客户端各自的到期时间
+func (s *ClientPlanService) SyncPlan(planId int) (*ClientPlanApplyResult, error) {
+	plan, err := s.GetPlan(planId)
+	if err != nil {
+		return nil, err
+	}
+	emails, err := s.GetPlanClients(planId)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyPlan(plan, emails, false), nil
+}
+
+// applyPlan 逐个更新客户端，单个客户端失败不影响其余客户端
+func (s *ClientPlanService) applyPlan(plan *model.ClientPlan, emails []string, withExpiry bool) *ClientPlanApplyResult {
+	result := &ClientPlanApplyResult{Failed: make(map[string]string)}
+	now := time.Now()
+	for _, email := range emails {
+		needRestart, err := s.getInboundService().updateClientByEmail(email, func(client map[string]any, protocol model.Protocol) {
+			applyClientPlan(client, plan, protocol, withExpiry, now)
+		})
+		if err != nil {
+			result.Failed[email] = err.Error()
+			continue
+		}
+		result.Updated++
+		result.NeedRestart = result.NeedRestart || needRestart
+	}
+	return result
+}
+
+// applyClientPlan 将套餐的限制写入客户端设置，withExpiry 为 false 时保留客户端原有的到期时间
+func applyClientPlan(client map[string]any, plan *model.ClientPlan, protocol model.Protocol, withExpiry bool, now time.Time) {
+	client["planId"] = plan.Id
+	client["totalGB"] = plan.TotalGB
+	client["limitIp"] = plan.LimitIP
+	client["speedLimit"] = plan.SpeedLimit
+	client["reset"] = plan.Reset
+	client["resetSchedule"] = plan.ResetSchedule
+	if protocol == model.VLESS {
+		client["flow"] = plan.Flow
+	}
+	if withExpiry {
+		var expiryTime int64
+		if plan.ExpiryDays > 0 {
+			expiryTime = now.AddDate(0, 0, plan.ExpiryDays).UnixMilli()
+		}
+		client["expiryTime"] = expiryTime
+	}
+}
+
+// applyClientPlans 按客户端设置中的 planId 填充新客户端的限制字段，套餐中的值优先于提交的值
+func (s *InboundService) applyClientPlans(inbound *model.Inbound) error {
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return nil
+	}
+	clients, ok := settings["clients"].([]any)
+	if !ok {
+		return nil
+	}
+
+	repo := repository.NewClientPlanRepository(s.getInboundRepo().GetDB())
+	plans := make(map[int]*model.ClientPlan)
+	applied := false
+	now := time.Now()
+	for _, item := range clients {
+		client, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		planId, _ := client["planId"].(float64)
+		if planId <= 0 {
+			continue
+		}
+		plan, ok := plans[int(planId)]
+		if !ok {
+			var err error
+			plan, err = repo.FindById(int(planId))
+			if err != nil {
+				return common.NewErrorf("client plan %d not found", int(planId))
+			}
+			plans[plan.Id] = plan
+		}
+		applyClientPlan(client, plan, inbound.Protocol, true, now)
+		applied = true
+	}
+	if !applied {
+		return nil
+	}
+
+	modifiedSettings, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	inbound.Settings = string(modifiedSettings)
+	return nil
+}
+
+// updateClientByEmail 修改客户端设置并通过 UpdateInboundClient 保存，沿用其校验、统计同步和热更新逻辑
+func (s *InboundService) updateClientByEmail(email string, update func(client map[string]any, protocol model.Protocol)) (bool, error) {
+	_, inbound, err := s.GetClientInboundByEmail(email)
+	if err != nil {
+		return false, err
+	}
+	if inbound == nil {
+		return false, common.NewError("Inbound Not Found For Email:", email)
+	}
+
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return false, err
+	}
+	clients, _ := settings["clients"].([]any)
+	var target map[string]any
+	for _, item := range clients {
+		if c, ok := item.(map[string]any); ok && c["email"] == email {
+			target = c
+			break
+		}
+	}
+	if target == nil {
+		return false, common.NewError("Client Not Found For Email:", email)
+	}
+
+	var clientId string
+	switch inbound.Protocol {
+	case model.Trojan:
+		clientId, _ = target["password"].(string)
+	case model.Shadowsocks, model.WireGuard, model.Socks, model.HTTP:
+		clientId = email
+	default:
+		clientId, _ = target["id"].(string)
+	}
+
+	update(target, inbound.Protocol)
+	target["updated_at"] = time.Now().UnixMilli()
+	settings["clients"] = []any{target}
+	modifiedSettings, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	inbound.Settings = string(modifiedSettings)
+	needRestart, err := s.UpdateInboundClient(inbound, clientId)
+	if err == nil {
+		s.invalidateSettingsCache(inbound.Id)
+	}
+	return needRestart, err
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"x-ui/database/model"
+)
+
+func TestClientPlanService(t *testing.T) {
+	setupTestDB(t)
+	s := &ClientPlanService{}
+	inboundService := s.getInboundService()
+
+	if err := s.AddPlan(&model.ClientPlan{Name: " "}); err == nil {
+		t.Error("empty plan name should be rejected")
+	}
+	if err := s.AddPlan(&model.ClientPlan{Name: "bad", ResetSchedule: "hourly"}); err == nil {
+		t.Error("invalid reset schedule should be rejected")
+	}
+	basic := &model.ClientPlan{Name: "basic", TotalGB: 10 << 30, ExpiryDays: 30, LimitIP: 2, Flow: "xtls-rprx-vision", ResetSchedule: "monthly"}
+	if err := s.AddPlan(basic); err != nil {
+		t.Fatalf("AddPlan failed: %v", err)
+	}
+	if err := s.AddPlan(&model.ClientPlan{Name: "basic"}); err == nil {
+		t.Error("duplicate plan name should be rejected")
+	}
+	pro := &model.ClientPlan{Name: "pro", TotalGB: 100 << 30, SpeedLimit: 1024}
+	if err := s.AddPlan(pro); err != nil {
+		t.Fatalf("AddPlan failed: %v", err)
+	}
+
+	inbound := &model.Inbound{
+		Tag: "in-plan", Port: 30021, Protocol: model.VLESS, Enable: true,
+		Settings: `{"clients":[{"id":"1","email":"sub","enable":true,"planId":` + strconv.Itoa(basic.Id) + `,"totalGB":1},{"id":"2","email":"free","enable":true}]}`,
+	}
+	if _, _, err := inboundService.AddInbound(inbound); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+	traffic, client, err := inboundService.GetClientByEmail("sub")
+	if err != nil {
+		t.Fatalf("GetClientByEmail failed: %v", err)
+	}
+	if client.TotalGB != basic.TotalGB || client.LimitIP != 2 || client.Flow != "xtls-rprx-vision" || client.ExpiryTime <= 0 || client.ResetSchedule != "monthly" {
+		t.Errorf("plan was not applied on creation: %+v", client)
+	}
+	if traffic.Total != basic.TotalGB || traffic.ExpiryTime != client.ExpiryTime {
+		t.Errorf("client traffic does not follow the plan: %+v", traffic)
+	}
+	expiry := client.ExpiryTime
+
+	result, err := s.AssignPlan(pro.Id, []string{"free", "missing"})
+	if err != nil {
+		t.Fatalf("AssignPlan failed: %v", err)
+	}
+	if result.Updated != 1 || result.Failed["missing"] == "" {
+		t.Errorf("unexpected assign result: %+v", result)
+	}
+	_, client, _ = inboundService.GetClientByEmail("free")
+	if client.PlanId != pro.Id || client.SpeedLimit != 1024 || client.ExpiryTime != 0 {
+		t.Errorf("client was not moved onto the plan: %+v", client)
+	}
+
+	basic.LimitIP = 5
+	basic.TotalGB = 20 << 30
+	if err := s.UpdatePlan(basic); err != nil {
+		t.Fatalf("UpdatePlan failed: %v", err)
+	}
+	result, err = s.SyncPlan(basic.Id)
+	if err != nil || result.Updated != 1 {
+		t.Fatalf("SyncPlan failed: %+v, %v", result, err)
+	}
+	traffic, client, _ = inboundService.GetClientByEmail("sub")
+	if client.LimitIP != 5 || traffic.Total != 20<<30 || client.ExpiryTime != expiry {
+		t.Errorf("sync should update limits and keep expiry: %+v", client)
+	}
+
+	if err := s.DelPlan(basic.Id); err == nil {
+		t.Error("plan in use should not be deleted")
+	}
+	if _, err := inboundService.AddInboundClient(&model.Inbound{
+		Id: inbound.Id, Settings: `{"clients":[{"id":"3","email":"ghost","enable":true,"planId":999}]}`,
+	}); err == nil {
+		t.Error("unknown plan should be rejected")
+	}
+}
+
+func TestApplyClientPlan_WireguardQuota(t *testing.T) {
+	// wireguard peer 的流量按 peer 统计，套餐的流量配额同样生效
+	peer := map[string]any{"email": "wg", "totalGB": float64(0)}
+	applyClientPlan(peer, &model.ClientPlan{Id: 1, TotalGB: 10 << 30}, model.WireGuard, false, time.Now())
+	if peer["totalGB"] != int64(10<<30) {
+		t.Errorf("plan quota not applied to wireguard peer: %v", peer["totalGB"])
+	}
+}
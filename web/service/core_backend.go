@@ -0,0 +1,391 @@
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"x-ui/config"
+	"x-ui/database/model"
+	"x-ui/logger"
+	"x-ui/singbox"
+	"x-ui/util/common"
+	"x-ui/xray"
+)
+
+// 代理核心后端
+const (
+	CoreBackendXray    = "xray"
+	CoreBackendSingBox = "sing-box"
+)
+
+// CoreBackend 代理核心后端：根据入站生成配置、启停核心、在线增删用户和读取流量统计。
+// Xray 始终是主核心，沿用 XrayService 原有的热更新、回滚和内嵌模式，承载全部 Xray 协议及模板中的路由和出站；
+// 选择 sing-box 后端时 sing-box 与 Xray 同时运行，只承载 Xray 不支持的协议（Hysteria2、TUIC）
+type CoreBackend interface {
+	Name() string
+	SupportsProtocol(protocol model.Protocol) bool
+	// Restart 生成配置并应用，isForce 为 false 时配置未变化则不做任何操作
+	Restart(isForce bool) error
+	Stop() error
+	IsRunning() bool
+	GetVersion() string
+	GetResult() string
+	// GetUptime 返回运行时间（秒），未运行时为 0
+	GetUptime() uint64
+	AddUser(inbound *model.Inbound, user map[string]any) error
+	RemoveUser(inbound *model.Inbound, email string) error
+	// GetTraffic 读取并清零自上次读取以来的流量
+	GetTraffic() ([]*xray.Traffic, []*xray.ClientTraffic, error)
+}
+
+// xrayProtocols Xray 支持的入站协议
+var xrayProtocols = map[model.Protocol]bool{
+	model.VMESS:       true,
+	model.VLESS:       true,
+	model.Tunnel:      true,
+	model.HTTP:        true,
+	model.Trojan:      true,
+	model.Shadowsocks: true,
+	model.Socks:       true,
+	model.WireGuard:   true,
+	model.TUN:         true,
+}
+
+// CheckCoreProtocol 检查入站协议能否由当前的代理核心提供。
+// Xray 协议不读取设置直接通过，sing-box 独有的协议要求已启用 sing-box 后端
+func (s *SettingService) CheckCoreProtocol(protocol model.Protocol) error {
+	if xrayProtocols[protocol] {
+		return nil
+	}
+	if !singbox.SupportsProtocol(protocol) {
+		return common.NewErrorf("invalid protocol: %s", protocol)
+	}
+	backend, err := s.GetCoreBackend()
+	if err != nil {
+		return err
+	}
+	if backend != CoreBackendSingBox {
+		return common.NewErrorf("protocol %s requires the sing-box core backend", protocol)
+	}
+	return nil
+}
+
+// GetCoreBackend 返回主代理核心，即 Xray
+func (s *XrayService) GetCoreBackend() CoreBackend {
+	return &xrayBackend{s: s}
+}
+
+// GetSingBoxBackend 返回与 Xray 同时运行的 sing-box 后端，未启用 sing-box 时返回 nil
+func (s *XrayService) GetSingBoxBackend() CoreBackend {
+	if s.coreBackendName() != CoreBackendSingBox {
+		return nil
+	}
+	return s.getSingBoxBackend()
+}
+
+// restartSingBox 启用 sing-box 时为 Xray 不支持的入站启动或重新加载 sing-box，未启用时停止 sing-box，调用方需持有 s.lock
+func (s *XrayService) restartSingBox(isForce bool) error {
+	if backend := s.GetSingBoxBackend(); backend != nil {
+		return backend.Restart(isForce)
+	}
+	s.stopSingBox()
+	return nil
+}
+
+// RestartSingBox 强制重新启动 sing-box，更新 sing-box 二进制后调用，未启用 sing-box 时不做任何操作
+func (s *XrayService) RestartSingBox() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.isManuallyStopped.Load() {
+		return nil
+	}
+	return s.restartSingBox(true)
+}
+
+// stopSingBox 停止 sing-box，已退出的进程也一并清除，不再视为崩溃
+func (s *XrayService) stopSingBox() {
+	sb := s.getSingBoxBackend()
+	if sb.IsRunning() {
+		logger.Info("Stopping sing-box")
+	}
+	_ = sb.Stop()
+}
+
+func (s *XrayService) coreBackendName() string {
+	if s.settingService == nil {
+		return CoreBackendXray
+	}
+	name, err := s.settingService.GetCoreBackend()
+	if err != nil || name == "" {
+		return CoreBackendXray
+	}
+	return name
+}
+
+func (s *XrayService) getSingBoxBackend() *singBoxBackend {
+	s.backendOnce.Do(func() {
+		s.singBox = &singBoxBackend{s: s}
+	})
+	return s.singBox
+}
+
+// IsCoreRunning 判断当前选择的代理核心是否正在运行
+func (s *XrayService) IsCoreRunning() bool {
+	return s.GetCoreBackend().IsRunning()
+}
+
+// xrayBackend Xray 后端，委托给 XrayService 原有的实现
+type xrayBackend struct {
+	s *XrayService
+}
+
+func (b *xrayBackend) Name() string { return CoreBackendXray }
+
+func (b *xrayBackend) SupportsProtocol(protocol model.Protocol) bool {
+	return xrayProtocols[protocol]
+}
+
+func (b *xrayBackend) Restart(isForce bool) error {
+	return b.s.restartXrayCore(isForce)
+}
+
+func (b *xrayBackend) Stop() error {
+	if !b.s.IsXrayRunning() {
+		return common.ErrXrayNotRunning
+	}
+	return b.s.process.Stop()
+}
+
+func (b *xrayBackend) IsRunning() bool {
+	return b.s.IsXrayRunning()
+}
+
+func (b *xrayBackend) GetVersion() string {
+	if b.s.process == nil {
+		return "Unknown"
+	}
+	return b.s.process.GetVersion()
+}
+
+func (b *xrayBackend) GetResult() string {
+	if b.s.process == nil {
+		return ""
+	}
+	return b.s.process.GetResult()
+}
+
+func (b *xrayBackend) GetUptime() uint64 {
+	if !b.s.IsXrayRunning() {
+		return 0
+	}
+	return b.s.process.GetUptime()
+}
+
+func (b *xrayBackend) AddUser(inbound *model.Inbound, user map[string]any) error {
+	if !b.s.IsXrayRunning() {
+		return common.ErrXrayNotRunning
+	}
+	api := xray.XrayAPI{}
+	if err := api.Init(b.s.process.GetAPIPort()); err != nil {
+		return err
+	}
+	defer api.Close()
+	return api.AddUser(string(inbound.Protocol), inbound.Tag, user)
+}
+
+func (b *xrayBackend) RemoveUser(inbound *model.Inbound, email string) error {
+	if !b.s.IsXrayRunning() {
+		return common.ErrXrayNotRunning
+	}
+	api := xray.XrayAPI{}
+	if err := api.Init(b.s.process.GetAPIPort()); err != nil {
+		return err
+	}
+	defer api.Close()
+	return api.RemoveUser(inbound.Tag, email)
+}
+
+func (b *xrayBackend) GetTraffic() ([]*xray.Traffic, []*xray.ClientTraffic, error) {
+	return b.s.getXrayCoreTraffic()
+}
+
+// singBoxBackend sing-box 后端，只承载 Xray 不支持的入站。sing-box 没有在线增删用户的接口，
+// 用户变更后重新生成配置并通知进程重新加载，已建立的连接不受影响
+type singBoxBackend struct {
+	s       *XrayService
+	mu      sync.Mutex
+	process *singbox.Process
+}
+
+func (b *singBoxBackend) Name() string { return CoreBackendSingBox }
+
+func (b *singBoxBackend) SupportsProtocol(protocol model.Protocol) bool {
+	return singbox.SupportsProtocol(protocol)
+}
+
+// GetSingBoxConfig 根据 Xray 不支持的入站生成 sing-box 配置，其余入站由 Xray 承载
+func (s *XrayService) GetSingBoxConfig() (*singbox.Config, error) {
+	if s.inboundService == nil {
+		return nil, common.NewError("inbound service not initialized")
+	}
+	inbounds, err := s.inboundService.GetAllInbounds()
+	if err != nil {
+		return nil, err
+	}
+	var singBoxInbounds []*model.Inbound
+	for _, inbound := range inbounds {
+		if !xrayProtocols[inbound.Protocol] {
+			singBoxInbounds = append(singBoxInbounds, inbound)
+		}
+	}
+	// 没有 V2Ray API 的 sing-box 无法加载含 v2ray_api 的配置，此时不开启流量统计
+	apiPort := 0
+	if singbox.HasV2RayAPI() {
+		apiPort = config.SingBoxAPIPort
+	}
+	return singbox.BuildConfig(singBoxInbounds, apiPort)
+}
+
+func (b *singBoxBackend) Restart(isForce bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, err := b.s.GetSingBoxConfig()
+	if err != nil {
+		return err
+	}
+	running := b.process != nil && b.process.IsRunning()
+	// 没有需要 sing-box 承载的入站时不运行 sing-box
+	if len(c.Inbounds) == 0 {
+		if running {
+			logger.Info("No inbound needs sing-box, stopping it")
+			err = b.process.Stop()
+		}
+		b.process = nil
+		return err
+	}
+	if running && !isForce && sameSingBoxConfig(b.process.GetConfig(), c) {
+		logger.Debug("It does not need to reload sing-box")
+		return nil
+	}
+	if err := singbox.TestConfig(c); err != nil {
+		if running {
+			b.s.reportConfigFailure(err, false)
+		}
+		return err
+	}
+
+	if running && !isForce {
+		if err := b.process.Reload(c); err == nil {
+			logger.Info("sing-box config reloaded")
+			return nil
+		} else {
+			logger.Warning("Reload sing-box failed, restarting:", err)
+		}
+	}
+	if running {
+		_ = b.process.Stop()
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if c.Experimental == nil {
+		logger.Warning(singbox.ErrV2RayAPIUnavailable)
+	}
+	b.process = singbox.NewProcess(c, config.SingBoxAPIPort)
+	if err := b.process.Start(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stop 停止 sing-box 并清除进程，之后不再把它的退出视为崩溃
+func (b *singBoxBackend) Stop() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.process
+	b.process = nil
+	if p == nil || !p.IsRunning() {
+		return common.NewError("sing-box is not running")
+	}
+	return p.Stop()
+}
+
+// crashedProcess 返回未经面板停止而退出的 sing-box 进程，没有时返回 nil
+func (b *singBoxBackend) crashedProcess() *singbox.Process {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.process == nil || b.process.IsRunning() {
+		return nil
+	}
+	return b.process
+}
+
+func (b *singBoxBackend) IsRunning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.process != nil && b.process.IsRunning()
+}
+
+func (b *singBoxBackend) GetVersion() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.process == nil {
+		return "Unknown"
+	}
+	return b.process.GetVersion()
+}
+
+// GetResult 返回进程最后一行输出；运行中的 sing-box 缺少 V2Ray API 时返回相应提示
+func (b *singBoxBackend) GetResult() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.process == nil {
+		return ""
+	}
+	if b.process.IsRunning() && b.process.GetConfig().Experimental == nil {
+		return singbox.ErrV2RayAPIUnavailable.Error()
+	}
+	return b.process.GetResult()
+}
+
+func (b *singBoxBackend) GetUptime() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.process == nil || !b.process.IsRunning() {
+		return 0
+	}
+	return b.process.GetUptime()
+}
+
+// AddUser 用户已写入数据库后调用，由重启任务重新生成配置并重新加载
+func (b *singBoxBackend) AddUser(*model.Inbound, map[string]any) error {
+	b.s.SetToNeedRestart()
+	return nil
+}
+
+// RemoveUser 用户已从数据库删除后调用，由重启任务重新生成配置并重新加载
+func (b *singBoxBackend) RemoveUser(*model.Inbound, string) error {
+	b.s.SetToNeedRestart()
+	return nil
+}
+
+func (b *singBoxBackend) GetTraffic() ([]*xray.Traffic, []*xray.ClientTraffic, error) {
+	b.mu.Lock()
+	p := b.process
+	b.mu.Unlock()
+	if p == nil || !p.IsRunning() {
+		return nil, nil, common.NewError("sing-box is not running")
+	}
+	if p.GetConfig().Experimental == nil {
+		return nil, nil, singbox.ErrV2RayAPIUnavailable
+	}
+	return singbox.GetTraffic(p.GetAPIPort(), true)
+}
+
+// sameSingBoxConfig 按序列化结果比较配置
+func sameSingBoxConfig(a, b *singbox.Config) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+)
+
+func TestCoreBackendSelection(t *testing.T) {
+	setupTestDB(t)
+	settingService := &SettingService{}
+	s := &XrayService{settingService: settingService}
+
+	if name := s.GetCoreBackend().Name(); name != CoreBackendXray {
+		t.Fatalf("default backend = %s", name)
+	}
+	if s.GetSingBoxBackend() != nil {
+		t.Error("sing-box should be disabled by default")
+	}
+	if err := settingService.CheckCoreProtocol(model.VLESS); err != nil {
+		t.Errorf("vless should be accepted: %v", err)
+	}
+	if err := settingService.CheckCoreProtocol(model.Hysteria2); err == nil {
+		t.Error("hysteria2 should require the sing-box backend")
+	}
+	if err := settingService.CheckCoreProtocol("invalid-protocol"); err == nil {
+		t.Error("unknown protocol should be rejected")
+	}
+
+	if err := settingService.saveSetting("coreBackend", CoreBackendSingBox); err != nil {
+		t.Fatal(err)
+	}
+	// sing-box 与 Xray 同时运行，Xray 仍是主核心
+	if name := s.GetCoreBackend().Name(); name != CoreBackendXray {
+		t.Fatalf("primary backend = %s", name)
+	}
+	backend := s.GetSingBoxBackend()
+	if backend == nil || backend.Name() != CoreBackendSingBox {
+		t.Fatalf("sing-box backend = %v", backend)
+	}
+	if backend != s.GetSingBoxBackend() {
+		t.Error("sing-box backend should be reused")
+	}
+	if !backend.SupportsProtocol(model.TUIC) || backend.SupportsProtocol(model.WireGuard) {
+		t.Error("unexpected sing-box protocol support")
+	}
+	if err := settingService.CheckCoreProtocol(model.TUIC); err != nil {
+		t.Errorf("tuic should be accepted with sing-box: %v", err)
+	}
+	if s.IsCoreRunning() || s.GetProcessUptime() != 0 {
+		t.Error("sing-box should not be running")
+	}
+
+	// sing-box 不支持在线增删用户，变更后由重启任务重新加载配置
+	if err := backend.AddUser(&model.Inbound{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsNeedRestartAndSetFalse() {
+		t.Error("adding a user should schedule a reload")
+	}
+}
+
+func TestGetSingBoxConfig(t *testing.T) {
+	setupTestDB(t)
+	s := &XrayService{settingService: &SettingService{}, inboundService: &InboundService{}}
+
+	tls := `{"security": "tls", "tlsSettings": {"certificates": [{"certificateFile": "/etc/cert.pem", "keyFile": "/etc/key.pem"}]}}`
+	inbounds := []*model.Inbound{
+		{Tag: "in-vless", Port: 30031, Enable: true, Protocol: model.VLESS, Settings: `{"clients": []}`},
+		{Tag: "in-hy2", Port: 30032, Enable: true, Protocol: model.Hysteria2, Settings: `{"clients": []}`, StreamSettings: tls},
+		// 缺少 tls 的 TUIC 入站无法由 sing-box 承载，只跳过该入站
+		{Tag: "in-tuic", Port: 30033, Enable: true, Protocol: model.TUIC, Settings: `{"clients": []}`},
+	}
+	for _, inbound := range inbounds {
+		if err := database.GetDB().Create(inbound).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := s.GetSingBoxConfig()
+	if err != nil {
+		t.Fatalf("GetSingBoxConfig failed: %v", err)
+	}
+	if len(c.Inbounds) != 1 || c.Inbounds[0]["tag"] != "in-hy2" {
+		t.Errorf("sing-box should only serve inbounds Xray cannot: %+v", c.Inbounds)
+	}
+}
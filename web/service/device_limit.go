@@ -0,0 +1,309 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/util/common"
+)
+
+// 设备超限时的处理策略
+const (
+	// DeviceLimitPolicyKickAll 替换用户凭据，断开该用户的全部设备，直到在线 IP 数恢复
+	DeviceLimitPolicyKickAll = "kickAll"
+	// DeviceLimitPolicyRejectNewest 通过路由规则拒绝超出限制的最新 IP，已在线的设备不受影响
+	DeviceLimitPolicyRejectNewest = "rejectNewest"
+	// DeviceLimitPolicyThrottle 将用户切换到限速等级，直到在线 IP 数恢复
+	DeviceLimitPolicyThrottle = "throttle"
+)
+
+// 设备超限处理结束的原因
+const (
+	// DeviceLimitUnbanRecovered 在线 IP 数已恢复到限制以内
+	DeviceLimitUnbanRecovered = "recovered"
+	// DeviceLimitUnbanManual 通过面板或 Telegram 机器人手动解除
+	DeviceLimitUnbanManual = "manual"
+	// DeviceLimitUnbanWhitelisted 客户端被加入白名单
+	DeviceLimitUnbanWhitelisted = "whitelisted"
+	// DeviceLimitUnbanLimitRemoved 限制已取消，或入站、客户端已停用
+	DeviceLimitUnbanLimitRemoved = "limitRemoved"
+	// DeviceLimitUnbanPolicyChanged 超限策略被修改，将按新策略重新处理
+	DeviceLimitUnbanPolicyChanged = "policyChanged"
+)
+
+// deviceLimitRuleTagPrefix 拒绝超限 IP 的路由规则标签前缀
+const deviceLimitRuleTagPrefix = "device-limit-"
+
+// deviceLimitRejectedIPs 按 rejectNewest 策略被拒绝的 IP，生成 Xray 配置时转换为路由规则
+// 结构: map[用户email] -> 被拒绝的 IP 列表
+var (
+	deviceLimitRejectedIPs  = make(map[string][]string)
+	deviceLimitRejectedLock sync.RWMutex
+)
+
+// DeviceLimitPolicyFor 返回对该协议的客户端实际使用的策略。SOCKS/HTTP 账户无法通过 API 替换凭据或调整等级，
+// kickAll 和 throttle 退回到按路由规则拒绝新设备（会话用户即账户用户名，路由规则可以匹配）
+func DeviceLimitPolicyFor(protocol model.Protocol, policy string) string {
+	if accountProtocols[protocol] {
+		return DeviceLimitPolicyRejectNewest
+	}
+	return policy
+}
+
+// DeviceLimitClient 受设备数限制的客户端及其所在入站的信息
+type DeviceLimitClient struct {
+	Limit    int
+	Tag      string
+	Protocol model.Protocol
+	// Method shadowsocks 入站的加密方式，通过 API 重新添加用户时需要
+	Method string
+	Client model.Client
+}
+
+// EffectiveDeviceLimit 返回客户端生效的设备数限制：客户端设置了 LimitIP 时优先，否则使用入站的 DeviceLimit
+func EffectiveDeviceLimit(inbound *model.Inbound, client *model.Client) int {
+	if client != nil && client.LimitIP > 0 {
+		return client.LimitIP
+	}
+	return inbound.DeviceLimit
+}
+
+// SetDeviceLimitRejectedIPs 设置用户被拒绝的 IP，ips 为空时移除该用户。返回是否发生变化，
+// 发生变化时调用方需要重新应用 Xray 配置使路由规则生效
+func SetDeviceLimitRejectedIPs(email string, ips []string) bool {
+	deviceLimitRejectedLock.Lock()
+	defer deviceLimitRejectedLock.Unlock()
+
+	if len(ips) == 0 {
+		if _, ok := deviceLimitRejectedIPs[email]; !ok {
+			return false
+		}
+		delete(deviceLimitRejectedIPs, email)
+		return true
+	}
+	sorted := slices.Clone(ips)
+	sort.Strings(sorted)
+	if slices.Equal(deviceLimitRejectedIPs[email], sorted) {
+		return false
+	}
+	deviceLimitRejectedIPs[email] = sorted
+	return true
+}
+
+// GetDeviceLimitRejectedIPs 返回当前被拒绝的 IP 的副本
+func GetDeviceLimitRejectedIPs() map[string][]string {
+	deviceLimitRejectedLock.RLock()
+	defer deviceLimitRejectedLock.RUnlock()
+
+	result := make(map[string][]string, len(deviceLimitRejectedIPs))
+	for email, ips := range deviceLimitRejectedIPs {
+		result[email] = slices.Clone(ips)
+	}
+	return result
+}
+
+// deviceLimitRules 为被拒绝的 IP 生成路由规则：该用户来自这些 IP 的连接全部转到 blackhole 出站
+func deviceLimitRules(rejected map[string][]string, outbound func(protocol string) string) []map[string]any {
+	emails := make([]string, 0, len(rejected))
+	for email := range rejected {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	rules := make([]map[string]any, 0, len(emails))
+	for _, email := range emails {
+		rules = append(rules, map[string]any{
+			"type":        "field",
+			"ruleTag":     deviceLimitRuleTagPrefix + email,
+			"user":        []string{email},
+			"source":      rejected[email],
+			"outboundTag": outbound("blackhole"),
+		})
+	}
+	return rules
+}
+
+// GetDeviceLimitedClients 返回所有启用的入站中设备数限制大于 0 的已启用客户端，以 email 为键。
+// WireGuard peer 在 Xray 中没有用户身份和在线 IP 统计，因此不受设备数限制
+func (s *InboundService) GetDeviceLimitedClients() (map[string]*DeviceLimitClient, error) {
+	var inbounds []*model.Inbound
+	err := s.getInboundRepo().GetDB().Model(model.Inbound{}).Where("enable = ?", true).Find(&inbounds).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*DeviceLimitClient)
+	for _, inbound := range inbounds {
+		if inbound.Protocol == model.WireGuard {
+			continue
+		}
+		clients, err := s.GetClients(inbound)
+		if err != nil {
+			continue
+		}
+		method := ""
+		if inbound.Protocol == model.Shadowsocks {
+			method = shadowsocksMethod(inbound)
+		}
+		for _, client := range clients {
+			limit := EffectiveDeviceLimit(inbound, &client)
+			if limit <= 0 || !client.Enable || client.Email == "" {
+				continue
+			}
+			result[client.Email] = &DeviceLimitClient{
+				Limit:    limit,
+				Tag:      inbound.Tag,
+				Protocol: inbound.Protocol,
+				Method:   method,
+				Client:   client,
+			}
+		}
+	}
+	return result, nil
+}
+
+// DeviceLimitService 管理持久化的设备超限处理记录和白名单。
+// 记录由 CheckDeviceLimitJob 写入，手动解除或加入白名单只结束记录，由任务在下一轮检查时恢复 Xray 中的用户
+type DeviceLimitService struct {
+	deviceLimitRepo repository.DeviceLimitRepository
+}
+
+// getDeviceLimitRepo 返回 DeviceLimitRepository，支持延迟初始化
+func (s *DeviceLimitService) getDeviceLimitRepo() repository.DeviceLimitRepository {
+	if s.deviceLimitRepo == nil {
+		s.deviceLimitRepo = repository.NewDeviceLimitRepository(database.GetDB())
+	}
+	return s.deviceLimitRepo
+}
+
+// GetActiveBans 返回所有仍在生效的处理记录
+func (s *DeviceLimitService) GetActiveBans() ([]*model.DeviceLimitBan, error) {
+	return s.getDeviceLimitRepo().FindActiveBans()
+}
+
+// GetBanHistory 返回客户端的处理历史，最新的在前
+func (s *DeviceLimitService) GetBanHistory(email string, limit int) ([]*model.DeviceLimitBan, error) {
+	return s.getDeviceLimitRepo().FindBanHistory(email, limit)
+}
+
+// DeviceLimitBanIPs 解析处理记录中保存的 IP 列表
+func DeviceLimitBanIPs(ban *model.DeviceLimitBan) []string {
+	var ips []string
+	if ban.IPs != "" {
+		_ = json.Unmarshal([]byte(ban.IPs), &ips)
+	}
+	return ips
+}
+
+// RecordBan 保存一次新的处理记录，同一客户端此前仍在生效的记录会被结束
+func (s *DeviceLimitService) RecordBan(ban *model.DeviceLimitBan, ips []string) error {
+	data, err := json.Marshal(ips)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	if _, err := s.getDeviceLimitRepo().CloseActiveBans(ban.Email, DeviceLimitUnbanPolicyChanged, now); err != nil {
+		return err
+	}
+	ban.Id = 0
+	ban.IPs = string(data)
+	ban.BannedAt = now
+	ban.UnbannedAt = 0
+	if ban.Reason == "" {
+		ban.Reason = fmt.Sprintf("%d active IPs exceed the limit of %d", ban.ActiveIPs, ban.Limit)
+	}
+	return s.getDeviceLimitRepo().CreateBan(ban)
+}
+
+// UpdateBanIPs 更新客户端仍在生效的记录中的 IP，用于 rejectNewest 策略下被拒绝的 IP 发生变化
+func (s *DeviceLimitService) UpdateBanIPs(email string, activeIPs int, ips []string) error {
+	ban, err := s.getDeviceLimitRepo().FindActiveBan(email)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(ips)
+	if err != nil {
+		return err
+	}
+	ban.IPs = string(data)
+	ban.ActiveIPs = activeIPs
+	return s.getDeviceLimitRepo().UpdateBan(ban)
+}
+
+// CloseBan 结束客户端仍在生效的处理记录，返回是否存在这样的记录
+func (s *DeviceLimitService) CloseBan(email string, reason string) (bool, error) {
+	closed, err := s.getDeviceLimitRepo().CloseActiveBans(email, reason, time.Now().Unix())
+	return closed > 0, err
+}
+
+// Unban 手动解除客户端的设备超限处理。若客户端仍然超限，下一轮检查会重新处理，
+// 需要长期豁免时应加入白名单
+func (s *DeviceLimitService) Unban(email string) error {
+	closed, err := s.CloseBan(email, DeviceLimitUnbanManual)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return common.NewErrorf("client %s is not banned by device limit", email)
+	}
+	return nil
+}
+
+// GetWhitelist 返回不受设备数限制的客户端
+func (s *DeviceLimitService) GetWhitelist() ([]*model.DeviceLimitWhitelist, error) {
+	return s.getDeviceLimitRepo().FindWhitelist()
+}
+
+// GetWhitelistedEmails 以集合形式返回白名单中的客户端 email
+func (s *DeviceLimitService) GetWhitelistedEmails() (map[string]bool, error) {
+	entries, err := s.getDeviceLimitRepo().FindWhitelist()
+	if err != nil {
+		return nil, err
+	}
+	emails := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		emails[entry.Email] = true
+	}
+	return emails, nil
+}
+
+// AddWhitelist 将客户端加入白名单并结束其仍在生效的处理记录
+func (s *DeviceLimitService) AddWhitelist(email string) error {
+	if email == "" {
+		return common.NewError("email cannot be empty")
+	}
+	inboundService := InboundService{}
+	if _, client, err := inboundService.GetClientByEmail(email); err != nil || client == nil {
+		return common.NewErrorf("client %s not found", email)
+	}
+	entries, err := s.GetWhitelistedEmails()
+	if err != nil {
+		return err
+	}
+	if !entries[email] {
+		err = s.getDeviceLimitRepo().AddWhitelist(&model.DeviceLimitWhitelist{Email: email, CreatedAt: time.Now().Unix()})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = s.CloseBan(email, DeviceLimitUnbanWhitelisted)
+	return err
+}
+
+// RemoveWhitelist 将客户端移出白名单
+func (s *DeviceLimitService) RemoveWhitelist(email string) error {
+	removed, err := s.getDeviceLimitRepo().RemoveWhitelist(email)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return common.NewErrorf("client %s is not whitelisted", email)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"testing"
+
+	"x-ui/database/model"
+)
+
+func TestEffectiveDeviceLimit(t *testing.T) {
+	inbound := &model.Inbound{DeviceLimit: 3}
+	if got := EffectiveDeviceLimit(inbound, &model.Client{}); got != 3 {
+		t.Errorf("expected inbound limit 3, got %d", got)
+	}
+	if got := EffectiveDeviceLimit(inbound, &model.Client{LimitIP: 1}); got != 1 {
+		t.Errorf("client LimitIP should override inbound limit, got %d", got)
+	}
+}
+
+func TestDeviceLimitPolicyFor(t *testing.T) {
+	if got := DeviceLimitPolicyFor(model.VLESS, DeviceLimitPolicyKickAll); got != DeviceLimitPolicyKickAll {
+		t.Errorf("vless should keep the configured policy, got %s", got)
+	}
+	for _, protocol := range []model.Protocol{model.Socks, model.HTTP} {
+		if got := DeviceLimitPolicyFor(protocol, DeviceLimitPolicyThrottle); got != DeviceLimitPolicyRejectNewest {
+			t.Errorf("%s should fall back to rejectNewest, got %s", protocol, got)
+		}
+	}
+}
+
+func TestSetDeviceLimitRejectedIPs(t *testing.T) {
+	t.Cleanup(func() {
+		SetDeviceLimitRejectedIPs("alice", nil)
+	})
+
+	if !SetDeviceLimitRejectedIPs("alice", []string{"5.6.7.8", "1.2.3.4"}) {
+		t.Error("expected change on first rejection")
+	}
+	if SetDeviceLimitRejectedIPs("alice", []string{"1.2.3.4", "5.6.7.8"}) {
+		t.Error("same IPs in a different order should not be a change")
+	}
+	rejected := GetDeviceLimitRejectedIPs()
+	if got := rejected["alice"]; len(got) != 2 || got[0] != "1.2.3.4" {
+		t.Errorf("unexpected rejected IPs: %v", got)
+	}
+
+	rules := deviceLimitRules(rejected, func(string) string { return "blocked" })
+	if len(rules) != 1 || rules[0]["ruleTag"] != deviceLimitRuleTagPrefix+"alice" || rules[0]["outboundTag"] != "blocked" {
+		t.Errorf("unexpected rules: %v", rules)
+	}
+
+	if !SetDeviceLimitRejectedIPs("alice", nil) || SetDeviceLimitRejectedIPs("alice", nil) {
+		t.Error("clearing should report a change only once")
+	}
+}
+
+func TestInboundService_GetDeviceLimitedClients(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	inbounds := []*model.Inbound{
+		{
+			Tag: "in-limited", Port: 30001, Protocol: model.VLESS, Enable: true, DeviceLimit: 2,
+			Settings: `{"clients":[{"id":"1","email":"a","enable":true},{"id":"2","email":"b","enable":true,"limitIp":5},{"id":"3","email":"c","enable":false}]}`,
+		},
+		{
+			Tag: "in-wg", Port: 30003, Protocol: model.WireGuard, Enable: true, DeviceLimit: 1,
+			Settings: `{"clients":[{"email":"wg","enable":true}]}`,
+		},
+		{
+			Tag: "in-open", Port: 30002, Protocol: model.Shadowsocks, Enable: true,
+			Settings: `{"method":"aes-256-gcm","password":"p","clients":[{"password":"x","email":"d","enable":true,"limitIp":1},{"password":"y","email":"e","enable":true}]}`,
+		},
+	}
+	for _, inbound := range inbounds {
+		if _, _, err := s.AddInbound(inbound); err != nil {
+			t.Fatalf("AddInbound failed: %v", err)
+		}
+	}
+
+	clients, err := s.GetDeviceLimitedClients()
+	if err != nil {
+		t.Fatalf("GetDeviceLimitedClients failed: %v", err)
+	}
+	if len(clients) != 3 || clients["a"].Limit != 2 || clients["b"].Limit != 5 || clients["d"].Limit != 1 {
+		t.Fatalf("unexpected limited clients: %+v", clients)
+	}
+	if clients["d"].Method != "aes-256-gcm" || clients["d"].Tag != "in-open" {
+		t.Errorf("unexpected shadowsocks client info: %+v", clients["d"])
+	}
+}
+
+func TestDeviceLimitService_Bans(t *testing.T) {
+	setupTestDB(t)
+	s := &DeviceLimitService{}
+	inboundService := &InboundService{}
+	if _, _, err := inboundService.AddInbound(&model.Inbound{
+		Tag: "in-ban", Port: 30003, Protocol: model.VLESS, Enable: true, DeviceLimit: 1,
+		Settings: `{"clients":[{"id":"1","email":"alice","enable":true}]}`,
+	}); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+
+	if err := s.RecordBan(&model.DeviceLimitBan{Email: "alice", Policy: DeviceLimitPolicyRejectNewest, Limit: 1, ActiveIPs: 2}, []string{"5.6.7.8"}); err != nil {
+		t.Fatalf("RecordBan failed: %v", err)
+	}
+	if err := s.UpdateBanIPs("alice", 3, []string{"5.6.7.8", "9.9.9.9"}); err != nil {
+		t.Fatalf("UpdateBanIPs failed: %v", err)
+	}
+	bans, err := s.GetActiveBans()
+	if err != nil || len(bans) != 1 || bans[0].Reason == "" || bans[0].ActiveIPs != 3 {
+		t.Fatalf("unexpected active bans: %+v, %v", bans, err)
+	}
+	if ips := DeviceLimitBanIPs(bans[0]); len(ips) != 2 {
+		t.Errorf("unexpected ban IPs: %v", ips)
+	}
+
+	if err := s.Unban("alice"); err != nil {
+		t.Fatalf("Unban failed: %v", err)
+	}
+	if err := s.Unban("alice"); err == nil {
+		t.Error("expected error when unbanning a client without an active ban")
+	}
+
+	_ = s.RecordBan(&model.DeviceLimitBan{Email: "alice", Policy: DeviceLimitPolicyKickAll, Limit: 1, ActiveIPs: 2}, nil)
+	if err := s.AddWhitelist("nobody"); err == nil {
+		t.Error("expected error for unknown client")
+	}
+	if err := s.AddWhitelist("alice"); err != nil {
+		t.Fatalf("AddWhitelist failed: %v", err)
+	}
+	// 重复加入不会报错
+	if err := s.AddWhitelist("alice"); err != nil {
+		t.Errorf("AddWhitelist should be idempotent: %v", err)
+	}
+	if emails, _ := s.GetWhitelistedEmails(); !emails["alice"] {
+		t.Error("alice should be whitelisted")
+	}
+	history, _ := s.GetBanHistory("alice", 0)
+	if len(history) != 2 || history[0].UnbanReason != DeviceLimitUnbanWhitelisted || history[1].UnbanReason != DeviceLimitUnbanManual {
+		t.Errorf("unexpected ban history: %+v", history)
+	}
+
+	if err := s.RemoveWhitelist("alice"); err != nil {
+		t.Fatalf("RemoveWhitelist failed: %v", err)
+	}
+	if err := s.RemoveWhitelist("alice"); err == nil {
+		t.Error("expected error when removing a client that is not whitelisted")
+	}
+}
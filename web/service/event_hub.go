@@ -0,0 +1,278 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/xray"
+)
+
+// EventTopic 推送事件的主题
+type EventTopic string
+
+const (
+	TopicStatus  EventTopic = "status"  // 服务器状态快照
+	TopicTraffic EventTopic = "traffic" // 流量增量
+	TopicOnline  EventTopic = "online"  // 客户端上线/下线
+	TopicBan     EventTopic = "ban"     // 设备限制封禁/解封
+	TopicLog     EventTopic = "log"     // 面板日志
+)
+
+// AllEventTopics 所有可订阅的主题
+var AllEventTopics = []EventTopic{TopicStatus, TopicTraffic, TopicOnline, TopicBan, TopicLog}
+
+// defaultSubscriberBuffer 每个订阅者的默认缓冲区大小
+const defaultSubscriberBuffer = 64
+
+// Event 推送给订阅者的单个事件
+type Event struct {
+	Topic EventTopic `json:"topic"`
+	Time  int64      `json:"time"`
+	Data  any        `json:"data"`
+}
+
+// OnlineTransition 客户端在线状态变化
+type OnlineTransition struct {
+	Online  []string `json:"online"`
+	Offline []string `json:"offline"`
+}
+
+// BanEvent 设备限制封禁/解封事件
+type BanEvent struct {
+	Email     string `json:"email"`
+	Banned    bool   `json:"banned"`
+	Limit     int    `json:"limit"`
+	ActiveIPs int    `json:"activeIps"`
+}
+
+// LogEvent 面板日志事件
+type LogEvent struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// TrafficEvent 一次流量采集周期的增量
+type TrafficEvent struct {
+	Inbounds  []TrafficDelta `json:"inbounds"`
+	Outbounds []TrafficDelta `json:"outbounds"`
+	Clients   []TrafficDelta `json:"clients"`
+}
+
+// TrafficDelta 单个入站/出站/客户端的流量增量
+type TrafficDelta struct {
+	Key  string `json:"key"`
+	Up   int64  `json:"up"`
+	Down int64  `json:"down"`
+}
+
+// NewTrafficEvent 将 Xray 统计结果转换为流量增量事件，跳过无变化的条目
+func NewTrafficEvent(traffics []*xray.Traffic, clientTraffics []*xray.ClientTraffic) *TrafficEvent {
+	event := &TrafficEvent{
+		Inbounds:  []TrafficDelta{},
+		Outbounds: []TrafficDelta{},
+		Clients:   []TrafficDelta{},
+	}
+	for _, t := range traffics {
+		if t == nil || (t.Up == 0 && t.Down == 0) {
+			continue
+		}
+		delta := TrafficDelta{Key: t.Tag, Up: t.Up, Down: t.Down}
+		if t.IsInbound {
+			event.Inbounds = append(event.Inbounds, delta)
+		} else if t.IsOutbound {
+			event.Outbounds = append(event.Outbounds, delta)
+		}
+	}
+	for _, ct := range clientTraffics {
+		if ct == nil || (ct.Up == 0 && ct.Down == 0) {
+			continue
+		}
+		event.Clients = append(event.Clients, TrafficDelta{Key: ct.Email, Up: ct.Up, Down: ct.Down})
+	}
+	return event
+}
+
+// EventSubscriber 单个订阅者，持有事件通道
+type EventSubscriber struct {
+	topics map[EventTopic]struct{}
+	ch     chan *Event
+}
+
+// C 返回事件通道，订阅取消后通道会被关闭
+func (s *EventSubscriber) C() <-chan *Event {
+	return s.ch
+}
+
+// Has 判断订阅者是否订阅了指定主题
+func (s *EventSubscriber) Has(topic EventTopic) bool {
+	_, ok := s.topics[topic]
+	return ok
+}
+
+// EventHub 进程内的事件发布/订阅中心，供 WebSocket/SSE 推送使用
+type EventHub struct {
+	mu          sync.RWMutex
+	subscribers map[*EventSubscriber]struct{}
+	logAttached bool
+
+	onlineMu   sync.Mutex
+	lastOnline map[string]struct{}
+}
+
+// NewEventHub 创建 EventHub 实例
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[*EventSubscriber]struct{}),
+		lastOnline:  make(map[string]struct{}),
+	}
+}
+
+// ParseEventTopics 解析逗号分隔的主题列表，空字符串或未知主题被忽略；结果为空时订阅全部主题
+func ParseEventTopics(raw string) []EventTopic {
+	valid := make(map[EventTopic]struct{}, len(AllEventTopics))
+	for _, t := range AllEventTopics {
+		valid[t] = struct{}{}
+	}
+
+	var topics []EventTopic
+	for _, part := range strings.Split(raw, ",") {
+		t := EventTopic(strings.ToLower(strings.TrimSpace(part)))
+		if _, ok := valid[t]; ok {
+			topics = append(topics, t)
+		}
+	}
+	if len(topics) == 0 {
+		return AllEventTopics
+	}
+	return topics
+}
+
+// Subscribe 订阅指定主题，buffer <= 0 时使用默认缓冲区大小
+func (h *EventHub) Subscribe(topics []EventTopic, buffer int) *EventSubscriber {
+	if buffer <= 0 {
+		buffer = defaultSubscriberBuffer
+	}
+	sub := &EventSubscriber{
+		topics: make(map[EventTopic]struct{}, len(topics)),
+		ch:     make(chan *Event, buffer),
+	}
+	for _, t := range topics {
+		sub.topics[t] = struct{}{}
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	h.syncLogListener()
+	return sub
+}
+
+// Unsubscribe 取消订阅并关闭订阅者通道
+func (h *EventHub) Unsubscribe(sub *EventSubscriber) {
+	h.mu.Lock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+	h.mu.Unlock()
+
+	h.syncLogListener()
+}
+
+// HasSubscribers 判断指定主题是否有订阅者，用于跳过无人关心的采集工作
+func (h *EventHub) HasSubscribers(topic EventTopic) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if sub.Has(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish 向订阅了该主题的所有订阅者发布事件
+// 慢速订阅者的缓冲区已满时直接丢弃该事件，不阻塞发布方
+func (h *EventHub) Publish(topic EventTopic, data any) {
+	if h == nil {
+		return
+	}
+	event := &Event{Topic: topic, Time: time.Now().UnixMilli(), Data: data}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if !sub.Has(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishOnlineClients 与上一次的在线列表比较，仅在有上线/下线变化时发布事件
+func (h *EventHub) PublishOnlineClients(onlines []string) {
+	if h == nil {
+		return
+	}
+	h.onlineMu.Lock()
+	current := make(map[string]struct{}, len(onlines))
+	transition := &OnlineTransition{}
+	for _, email := range onlines {
+		current[email] = struct{}{}
+		if _, ok := h.lastOnline[email]; !ok {
+			transition.Online = append(transition.Online, email)
+		}
+	}
+	for email := range h.lastOnline {
+		if _, ok := current[email]; !ok {
+			transition.Offline = append(transition.Offline, email)
+		}
+	}
+	h.lastOnline = current
+	h.onlineMu.Unlock()
+
+	if len(transition.Online) == 0 && len(transition.Offline) == 0 {
+		return
+	}
+	h.Publish(TopicOnline, transition)
+}
+
+// PublishBan 发布设备限制封禁/解封事件
+func (h *EventHub) PublishBan(email string, banned bool, limit int, activeIPs int) {
+	h.Publish(TopicBan, &BanEvent{Email: email, Banned: banned, Limit: limit, ActiveIPs: activeIPs})
+}
+
+// OnLog 实现 logger.LogListener 接口，将日志转发给 log 主题订阅者
+func (h *EventHub) OnLog(level logger.Level, message string, formattedLog string) {
+	h.Publish(TopicLog, &LogEvent{Level: level.String(), Message: message})
+}
+
+// syncLogListener 仅在存在 log 订阅者时挂载到 logger，避免无人订阅时的额外开销
+func (h *EventHub) syncLogListener() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	need := false
+	for sub := range h.subscribers {
+		if sub.Has(TopicLog) {
+			need = true
+			break
+		}
+	}
+	if need && !h.logAttached {
+		logger.AddLogListener(h)
+		h.logAttached = true
+	} else if !need && h.logAttached {
+		logger.RemoveLogListener(h)
+		h.logAttached = false
+	}
+}
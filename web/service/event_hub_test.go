@@ -0,0 +1,140 @@
+package service
+
+import (
+	"testing"
+
+	"x-ui/xray"
+)
+
+func TestParseEventTopics(t *testing.T) {
+	topics := ParseEventTopics("status, LOG,unknown")
+	if len(topics) != 2 || topics[0] != TopicStatus || topics[1] != TopicLog {
+		t.Errorf("unexpected topics: %v", topics)
+	}
+
+	// 空字符串或全部无效时订阅全部主题
+	if got := ParseEventTopics(""); len(got) != len(AllEventTopics) {
+		t.Errorf("empty topics should subscribe all, got %v", got)
+	}
+	if got := ParseEventTopics("foo,bar"); len(got) != len(AllEventTopics) {
+		t.Errorf("invalid topics should subscribe all, got %v", got)
+	}
+}
+
+func TestEventHub_PublishFiltersByTopic(t *testing.T) {
+	hub := NewEventHub()
+	statusSub := hub.Subscribe([]EventTopic{TopicStatus}, 4)
+	banSub := hub.Subscribe([]EventTopic{TopicBan}, 4)
+	defer hub.Unsubscribe(statusSub)
+	defer hub.Unsubscribe(banSub)
+
+	hub.PublishBan("user@example.com", true, 2, 3)
+
+	select {
+	case ev := <-banSub.C():
+		data, ok := ev.Data.(*BanEvent)
+		if !ok || data.Email != "user@example.com" || !data.Banned {
+			t.Errorf("unexpected ban event: %+v", ev.Data)
+		}
+	default:
+		t.Fatal("ban subscriber should receive event")
+	}
+
+	select {
+	case ev := <-statusSub.C():
+		t.Errorf("status subscriber should not receive %s event", ev.Topic)
+	default:
+	}
+}
+
+func TestEventHub_DropsWhenBufferFull(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe([]EventTopic{TopicStatus}, 1)
+	defer hub.Unsubscribe(sub)
+
+	// 缓冲区满时不应阻塞发布方
+	hub.Publish(TopicStatus, 1)
+	hub.Publish(TopicStatus, 2)
+
+	if got := len(sub.C()); got != 1 {
+		t.Errorf("expected 1 buffered event, got %d", got)
+	}
+}
+
+func TestEventHub_UnsubscribeClosesChannel(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe([]EventTopic{TopicLog}, 1)
+	if !hub.HasSubscribers(TopicLog) {
+		t.Fatal("expected log subscriber")
+	}
+
+	hub.Unsubscribe(sub)
+	if hub.HasSubscribers(TopicLog) {
+		t.Error("log subscriber should be removed")
+	}
+	if _, ok := <-sub.C(); ok {
+		t.Error("channel should be closed after unsubscribe")
+	}
+	// 重复取消订阅不应 panic
+	hub.Unsubscribe(sub)
+}
+
+func TestEventHub_PublishOnlineClientsTransitions(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe([]EventTopic{TopicOnline}, 4)
+	defer hub.Unsubscribe(sub)
+
+	hub.PublishOnlineClients([]string{"a", "b"})
+	ev := <-sub.C()
+	tr := ev.Data.(*OnlineTransition)
+	if len(tr.Online) != 2 || len(tr.Offline) != 0 {
+		t.Errorf("unexpected first transition: %+v", tr)
+	}
+
+	// 列表未变化时不发布事件
+	hub.PublishOnlineClients([]string{"b", "a"})
+	if len(sub.C()) != 0 {
+		t.Error("no event expected when online list is unchanged")
+	}
+
+	hub.PublishOnlineClients([]string{"b", "c"})
+	ev = <-sub.C()
+	tr = ev.Data.(*OnlineTransition)
+	if len(tr.Online) != 1 || tr.Online[0] != "c" || len(tr.Offline) != 1 || tr.Offline[0] != "a" {
+		t.Errorf("unexpected transition: %+v", tr)
+	}
+}
+
+func TestNewTrafficEvent(t *testing.T) {
+	event := NewTrafficEvent(
+		[]*xray.Traffic{
+			{IsInbound: true, Tag: "in-1", Up: 10, Down: 20},
+			{IsOutbound: true, Tag: "direct", Up: 1, Down: 2},
+			{IsInbound: true, Tag: "idle"},
+		},
+		[]*xray.ClientTraffic{
+			{Email: "a", Up: 5, Down: 6},
+			{Email: "idle"},
+		},
+	)
+
+	if len(event.Inbounds) != 1 || event.Inbounds[0].Key != "in-1" {
+		t.Errorf("unexpected inbounds: %+v", event.Inbounds)
+	}
+	if len(event.Outbounds) != 1 || event.Outbounds[0].Key != "direct" {
+		t.Errorf("unexpected outbounds: %+v", event.Outbounds)
+	}
+	if len(event.Clients) != 1 || event.Clients[0].Key != "a" {
+		t.Errorf("unexpected clients: %+v", event.Clients)
+	}
+}
+
+func TestEventHub_NilSafe(t *testing.T) {
+	var hub *EventHub
+	hub.Publish(TopicStatus, nil)
+	hub.PublishBan("a", true, 1, 2)
+	hub.PublishOnlineClients([]string{"a"})
+	if hub.HasSubscribers(TopicStatus) {
+		t.Error("nil hub should have no subscribers")
+	}
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/config"
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/logger"
+	"x-ui/util/common"
+	"x-ui/xray"
+)
+
+const (
+	// geoDownloadTimeout 下载地理数据文件的超时时间
+	geoDownloadTimeout = 5 * time.Minute
+	// geoDownloadMaxSize 地理数据文件的最大字节数
+	geoDownloadMaxSize = 128 << 20
+	// geoBackupSuffix 上一个版本的文件后缀，用于回滚
+	geoBackupSuffix = ".bak"
+)
+
+// 地理数据来源格式
+const (
+	GeoFormatDat  = "dat"
+	GeoFormatList = "list"
+)
+
+var (
+	geoFileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*\.dat$`)
+	geoCodePattern     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	sha256Pattern      = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// defaultGeoSources 内置的地理数据来源，与原先固定下载的文件一致
+var defaultGeoSources = []model.GeoSource{
+	{FileName: "geoip.dat", Type: xray.GeoTypeIP, Url: "https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geoip.dat",
+		ChecksumUrl: "https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geoip.dat.sha256sum"},
+	{FileName: "geosite.dat", Type: xray.GeoTypeSite, Url: "https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geosite.dat",
+		ChecksumUrl: "https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geosite.dat.sha256sum"},
+	{FileName: "geoip_IR.dat", Type: xray.GeoTypeIP, Url: "https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geoip.dat"},
+	{FileName: "geosite_IR.dat", Type: xray.GeoTypeSite, Url: "https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geosite.dat"},
+	{FileName: "geoip_RU.dat", Type: xray.GeoTypeIP, Url: "https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geoip.dat",
+		ChecksumUrl: "https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geoip.dat.sha256sum"},
+	{FileName: "geosite_RU.dat", Type: xray.GeoTypeSite, Url: "https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geosite.dat",
+		ChecksumUrl: "https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geosite.dat.sha256sum"},
+}
+
+// GeoDataService 管理地理数据来源：下载或上传、校验、编译纯文本列表、保留上一版本用于回滚。
+// 文件内容变化时由调用方重启 Xray
+type GeoDataService struct {
+	SettingService
+
+	geoSourceRepo repository.GeoSourceRepository
+}
+
+// geoDataMu 串行化文件的安装与回滚，控制器和定时任务各自持有服务实例，因此使用包级锁
+var geoDataMu sync.Mutex
+
+// getGeoSourceRepo 返回 GeoSourceRepository，支持延迟初始化
+func (s *GeoDataService) getGeoSourceRepo() repository.GeoSourceRepository {
+	if s.geoSourceRepo == nil {
+		s.geoSourceRepo = repository.NewGeoSourceRepository(database.GetDB())
+	}
+	return s.geoSourceRepo
+}
+
+// ensureDefaultSources 首次使用时写入内置来源
+func (s *GeoDataService) ensureDefaultSources() error {
+	count, err := s.getGeoSourceRepo().Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	for _, source := range defaultGeoSources {
+		source.Format = GeoFormatDat
+		source.Enable = true
+		source.Builtin = true
+		if err := s.getGeoSourceRepo().Create(&source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func geoFilePath(fileName string) string {
+	return filepath.Join(config.GetBinFolderPath(), fileName)
+}
+
+// GetGeoSources 返回全部地理数据来源
+func (s *GeoDataService) GetGeoSources() ([]*model.GeoSource, error) {
+	if err := s.ensureDefaultSources(); err != nil {
+		return nil, err
+	}
+	sources, err := s.getGeoSourceRepo().FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		_, err := os.Stat(geoFilePath(source.FileName) + geoBackupSuffix)
+		source.HasBackup = err == nil
+	}
+	return sources, nil
+}
+
+// AddGeoSource 添加自定义来源
+func (s *GeoDataService) AddGeoSource(source *model.GeoSource) error {
+	if err := s.ensureDefaultSources(); err != nil {
+		return err
+	}
+	source.Id = 0
+	source.Builtin = false
+	source.FileSha256 = ""
+	source.Size = 0
+	source.LastUpdate = 0
+	source.LastCheck = 0
+	source.LastError = ""
+	if err := validateGeoSource(source); err != nil {
+		return err
+	}
+	if _, err := s.getGeoSourceRepo().FindByFileName(source.FileName); err == nil {
+		return common.NewErrorf("geo file %s already exists", source.FileName)
+	}
+	return s.getGeoSourceRepo().Create(source)
+}
+
+// UpdateGeoSource 修改来源配置；内置来源不能修改文件名和类型
+func (s *GeoDataService) UpdateGeoSource(source *model.GeoSource) error {
+	old, err := s.getGeoSourceRepo().FindByID(source.Id)
+	if err != nil {
+		return err
+	}
+	if old.Builtin && (source.FileName != old.FileName || source.Type != old.Type) {
+		return common.NewError("cannot change file name or type of a builtin geo source")
+	}
+	if source.FileName != old.FileName {
+		return common.NewError("cannot change file name of a geo source")
+	}
+	old.Type = source.Type
+	old.Format = source.Format
+	old.Code = source.Code
+	old.Url = source.Url
+	old.ChecksumUrl = source.ChecksumUrl
+	old.Sha256 = source.Sha256
+	old.Interval = source.Interval
+	old.Enable = source.Enable
+	if err := validateGeoSource(old); err != nil {
+		return err
+	}
+	return s.getGeoSourceRepo().Update(old)
+}
+
+// DelGeoSource 删除自定义来源及其文件，仍被 Xray 模板引用的文件不能删除
+func (s *GeoDataService) DelGeoSource(id int) error {
+	source, err := s.getGeoSourceRepo().FindByID(id)
+	if err != nil {
+		return err
+	}
+	if source.Builtin {
+		return common.NewError("cannot delete a builtin geo source")
+	}
+	// 持有模板锁完成引用检查和删除，避免期间有规则开始引用该文件
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	template, err := s.GetXrayConfigTemplate()
+	if err == nil && strings.Contains(template, "ext:"+source.FileName+":") {
+		return common.NewErrorf("geo file %s is still referenced by the xray template", source.FileName)
+	}
+	if err := s.getGeoSourceRepo().Delete(id); err != nil {
+		return err
+	}
+	for _, path := range []string{geoFilePath(source.FileName), geoFilePath(source.FileName) + geoBackupSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warning("Failed to remove geo file:", err)
+		}
+	}
+	return nil
+}
+
+func validateGeoSource(source *model.GeoSource) error {
+	if !geoFileNamePattern.MatchString(source.FileName) {
+		return common.NewErrorf("invalid geo file name: %s", source.FileName)
+	}
+	if source.Type != xray.GeoTypeIP && source.Type != xray.GeoTypeSite {
+		return common.NewErrorf("invalid geo type: %s", source.Type)
+	}
+	switch source.Format {
+	case "":
+		source.Format = GeoFormatDat
+	case GeoFormatDat:
+	case GeoFormatList:
+		if !geoCodePattern.MatchString(source.Code) {
+			return common.NewErrorf("invalid geo list code: %q", source.Code)
+		}
+		source.Code = strings.ToUpper(source.Code)
+	default:
+		return common.NewErrorf("invalid geo format: %s", source.Format)
+	}
+	for _, raw := range []string{source.Url, source.ChecksumUrl} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return common.NewErrorf("invalid url: %s", raw)
+		}
+	}
+	if source.Sha256 != "" {
+		if !sha256Pattern.MatchString(source.Sha256) {
+			return common.NewErrorf("invalid sha256: %s", source.Sha256)
+		}
+		source.Sha256 = strings.ToLower(source.Sha256)
+	}
+	if source.Interval < 0 {
+		return common.NewErrorf("invalid update interval: %d", source.Interval)
+	}
+	if source.Interval > 0 && source.Url == "" {
+		return common.NewError("scheduled updates require a url")
+	}
+	return nil
+}
+
+// RefreshGeoSource 下载并安装来源的最新文件，返回文件是否发生变化
+func (s *GeoDataService) RefreshGeoSource(id int) (bool, error) {
+	source, err := s.getGeoSourceRepo().FindByID(id)
+	if err != nil {
+		return false, err
+	}
+	if source.Url == "" {
+		return false, common.NewErrorf("geo source %s has no url, upload the file instead", source.FileName)
+	}
+	return s.applyGeoSource(source, func() ([]byte, error) {
+		content, err := downloadGeoFile(source.Url)
+		if err != nil {
+			return nil, err
+		}
+		if source.Sha256 == "" && source.ChecksumUrl != "" {
+			expected, err := fetchGeoChecksum(source.ChecksumUrl)
+			if err != nil {
+				return nil, err
+			}
+			if err := verifyGeoChecksum(content, expected); err != nil {
+				return nil, err
+			}
+		}
+		return content, nil
+	})
+}
+
+// UploadGeoSource 使用上传的内容（.dat 文件或纯文本列表，取决于来源格式）安装文件
+func (s *GeoDataService) UploadGeoSource(id int, content []byte) (bool, error) {
+	if len(content) > geoDownloadMaxSize {
+		return false, common.NewError("geo file is too large")
+	}
+	source, err := s.getGeoSourceRepo().FindByID(id)
+	if err != nil {
+		return false, err
+	}
+	return s.applyGeoSource(source, func() ([]byte, error) { return content, nil })
+}
+
+// applyGeoSource 获取内容、校验、编译并安装，同时记录检查结果
+func (s *GeoDataService) applyGeoSource(source *model.GeoSource, fetch func() ([]byte, error)) (bool, error) {
+	geoDataMu.Lock()
+	defer geoDataMu.Unlock()
+
+	changed, err := func() (bool, error) {
+		content, err := fetch()
+		if err != nil {
+			return false, err
+		}
+		if source.Sha256 != "" {
+			if err := verifyGeoChecksum(content, source.Sha256); err != nil {
+				return false, err
+			}
+		}
+		data, err := buildGeoData(source, content)
+		if err != nil {
+			return false, err
+		}
+		return installGeoFile(source, data)
+	}()
+
+	now := time.Now().Unix()
+	source.LastCheck = now
+	if err != nil {
+		source.LastError = err.Error()
+	} else {
+		source.LastError = ""
+		if changed {
+			source.LastUpdate = now
+		}
+	}
+	if updateErr := s.getGeoSourceRepo().Update(source); updateErr != nil {
+		logger.Warning("Failed to update geo source:", updateErr)
+	}
+	return changed, err
+}
+
+// buildGeoData 列表格式编译为 .dat，.dat 格式解析校验后原样使用
+func buildGeoData(source *model.GeoSource, content []byte) ([]byte, error) {
+	if source.Format == GeoFormatList {
+		return xray.CompileGeoList(source.Type, source.Code, content)
+	}
+	if _, err := xray.ParseGeoData(source.Type, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// installGeoFile 内容未变化时不做任何操作；变化时把当前文件保留为 .bak 后原子替换
+func installGeoFile(source *model.GeoSource, data []byte) (bool, error) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	path := geoFilePath(source.FileName)
+
+	if current, err := os.ReadFile(path); err == nil {
+		currentSum := sha256.Sum256(current)
+		if hex.EncodeToString(currentSum[:]) == digest {
+			source.FileSha256 = digest
+			source.Size = int64(len(data))
+			return false, nil
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+geoBackupSuffix); err != nil {
+			_ = os.Remove(tmp)
+			return false, err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	source.FileSha256 = digest
+	source.Size = int64(len(data))
+	return true, nil
+}
+
+// RollbackGeoSource 将文件恢复为上一个版本，当前版本成为新的备份
+func (s *GeoDataService) RollbackGeoSource(id int) error {
+	geoDataMu.Lock()
+	defer geoDataMu.Unlock()
+
+	source, err := s.getGeoSourceRepo().FindByID(id)
+	if err != nil {
+		return err
+	}
+	path := geoFilePath(source.FileName)
+	backup := path + geoBackupSuffix
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return common.NewErrorf("geo file %s has no previous version", source.FileName)
+		}
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.Rename(backup, tmp); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, backup); err != nil {
+			_ = os.Rename(tmp, backup)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	source.FileSha256 = hex.EncodeToString(sum[:])
+	source.Size = int64(len(data))
+	source.LastUpdate = time.Now().Unix()
+	source.LastError = ""
+	return s.getGeoSourceRepo().Update(source)
+}
+
+// RefreshGeoSources 更新文件名为 fileName 的来源，fileName 为空时更新全部已启用且有 url 的来源。
+// 返回内容发生变化的文件
+func (s *GeoDataService) RefreshGeoSources(fileName string) ([]string, error) {
+	sources, err := s.GetGeoSources()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	var errs []string
+	found := false
+	for _, source := range sources {
+		if fileName != "" && source.FileName != fileName {
+			continue
+		}
+		if fileName == "" && (!source.Enable || source.Url == "") {
+			continue
+		}
+		found = true
+		sourceChanged, err := s.RefreshGeoSource(source.Id)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source.FileName, err))
+			continue
+		}
+		if sourceChanged {
+			changed = append(changed, source.FileName)
+		}
+	}
+	if fileName != "" && !found {
+		return nil, common.NewErrorf("geo file %s not found", fileName)
+	}
+	if len(errs) > 0 {
+		return changed, common.NewError(strings.Join(errs, "\n"))
+	}
+	return changed, nil
+}
+
+// RefreshDueGeoSources 更新已到期的来源，返回是否有文件发生变化
+func (s *GeoDataService) RefreshDueGeoSources(now time.Time) bool {
+	sources, err := s.GetGeoSources()
+	if err != nil {
+		logger.Warning("Failed to load geo sources:", err)
+		return false
+	}
+	changed := false
+	for _, source := range sources {
+		if !source.Enable || source.Url == "" || source.Interval <= 0 ||
+			now.Unix()-source.LastCheck < int64(source.Interval)*3600 {
+			continue
+		}
+		sourceChanged, err := s.RefreshGeoSource(source.Id)
+		if err != nil {
+			logger.Warningf("Update geo file %s failed: %v", source.FileName, err)
+			continue
+		}
+		if sourceChanged {
+			logger.Infof("Geo file %s updated", source.FileName)
+		}
+		changed = changed || sourceChanged
+	}
+	return changed
+}
+
+func downloadGeoFile(rawURL string) ([]byte, error) {
+	client := &http.Client{Timeout: geoDownloadTimeout}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "X-Panel/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, common.NewErrorf("failed to download %s: %v", rawURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, common.NewErrorf("failed to download %s: status %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, geoDownloadMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > geoDownloadMaxSize {
+		return nil, common.NewErrorf("geo file %s is too large", rawURL)
+	}
+	return data, nil
+}
+
+// fetchGeoChecksum 下载 sha256sum 格式的校验文件并取第一个校验和
+func fetchGeoChecksum(rawURL string) (string, error) {
+	data, err := downloadGeoFile(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return parseGeoChecksum(data)
+}
+
+func parseGeoChecksum(data []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && sha256Pattern.MatchString(fields[0]) {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", common.NewError("checksum file contains no sha256")
+}
+
+func verifyGeoChecksum(content []byte, expected string) error {
+	sum := sha256.Sum256(content)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+		return common.NewErrorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"x-ui/config"
+	"x-ui/database/model"
+	"x-ui/xray"
+)
+
+// geoTestServer 提供可修改内容的列表文件及其 sha256sum 校验文件
+type geoTestServer struct {
+	mu       sync.Mutex
+	content  string
+	checksum string
+}
+
+func (g *geoTestServer) set(content string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sum := sha256.Sum256([]byte(content))
+	g.content = content
+	g.checksum = hex.EncodeToString(sum[:]) + "  list.txt\n"
+}
+
+func (g *geoTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch r.URL.Path {
+	case "/list.txt":
+		_, _ = w.Write([]byte(g.content))
+	case "/list.txt.sha256sum":
+		_, _ = w.Write([]byte(g.checksum))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func setupGeoTest(t *testing.T) (*GeoDataService, *geoTestServer, string) {
+	t.Helper()
+	setupTestDB(t)
+	binDir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", binDir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+	g := &geoTestServer{}
+	g.set("10.0.0.0/8\n")
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	return &GeoDataService{}, g, srv.URL
+}
+
+func TestGeoSourcesSeedBuiltins(t *testing.T) {
+	s, _, _ := setupGeoTest(t)
+
+	sources, err := s.GetGeoSources()
+	if err != nil {
+		t.Fatalf("GetGeoSources: %v", err)
+	}
+	if len(sources) != len(defaultGeoSources) {
+		t.Fatalf("got %d sources, want %d", len(sources), len(defaultGeoSources))
+	}
+	for _, source := range sources {
+		if !source.Builtin || !source.Enable {
+			t.Errorf("builtin source %s not marked builtin/enabled", source.FileName)
+		}
+	}
+	if err := s.DelGeoSource(sources[0].Id); err == nil {
+		t.Error("expected error deleting builtin source")
+	}
+}
+
+func TestGeoSourceValidation(t *testing.T) {
+	s, _, baseURL := setupGeoTest(t)
+
+	invalid := []*model.GeoSource{
+		{FileName: "../evil.dat", Type: xray.GeoTypeIP},
+		{FileName: "a.dat", Type: "geofoo"},
+		{FileName: "a.dat", Type: xray.GeoTypeIP, Format: GeoFormatList},
+		{FileName: "a.dat", Type: xray.GeoTypeIP, Url: "ftp://example.com/a.dat"},
+		{FileName: "a.dat", Type: xray.GeoTypeIP, Sha256: "abc"},
+		{FileName: "a.dat", Type: xray.GeoTypeIP, Interval: 24},
+		{FileName: "geoip.dat", Type: xray.GeoTypeIP, Url: baseURL + "/geoip.dat"},
+	}
+	for i, source := range invalid {
+		if err := s.AddGeoSource(source); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, source)
+		}
+	}
+}
+
+func TestGeoSourceRefreshRollback(t *testing.T) {
+	s, server, baseURL := setupGeoTest(t)
+
+	source := &model.GeoSource{
+		FileName:    "office.dat",
+		Type:        xray.GeoTypeIP,
+		Format:      GeoFormatList,
+		Code:        "office",
+		Url:         baseURL + "/list.txt",
+		ChecksumUrl: baseURL + "/list.txt.sha256sum",
+		Interval:    1,
+		Enable:      true,
+	}
+	if err := s.AddGeoSource(source); err != nil {
+		t.Fatalf("AddGeoSource: %v", err)
+	}
+
+	changed, err := s.RefreshGeoSource(source.Id)
+	if err != nil || !changed {
+		t.Fatalf("first refresh = %v, %v; want changed", changed, err)
+	}
+	path := geoFilePath("office.dat")
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read installed file: %v", err)
+	}
+	if codes, err := xray.ParseGeoData(xray.GeoTypeIP, first); err != nil || codes[0] != "OFFICE" {
+		t.Errorf("installed file codes = %v, %v", codes, err)
+	}
+
+	// 内容未变化时不替换文件
+	if changed, err := s.RefreshGeoSource(source.Id); err != nil || changed {
+		t.Errorf("unchanged refresh = %v, %v; want unchanged", changed, err)
+	}
+
+	server.set("10.0.0.0/8\n172.16.0.0/12\n")
+	if changed, err := s.RefreshGeoSource(source.Id); err != nil || !changed {
+		t.Fatalf("second refresh = %v, %v; want changed", changed, err)
+	}
+	if _, err := os.Stat(path + geoBackupSuffix); err != nil {
+		t.Fatalf("expected backup of previous version: %v", err)
+	}
+
+	if err := s.RollbackGeoSource(source.Id); err != nil {
+		t.Fatalf("RollbackGeoSource: %v", err)
+	}
+	restored, _ := os.ReadFile(path)
+	if string(restored) != string(first) {
+		t.Error("rollback did not restore the previous version")
+	}
+
+	// 校验和不匹配时保留当前文件并记录错误
+	server.mu.Lock()
+	server.content = "192.168.0.0/16\n"
+	server.mu.Unlock()
+	if _, err := s.RefreshGeoSource(source.Id); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != string(first) {
+		t.Error("file replaced despite checksum mismatch")
+	}
+	saved, _ := s.getGeoSourceRepo().FindByID(source.Id)
+	if saved.LastError == "" {
+		t.Error("expected LastError to be recorded")
+	}
+}
+
+func TestGeoSourceUploadAndDue(t *testing.T) {
+	s, _, baseURL := setupGeoTest(t)
+
+	manual := &model.GeoSource{FileName: "manual.dat", Type: xray.GeoTypeSite, Format: GeoFormatList, Code: "ads"}
+	if err := s.AddGeoSource(manual); err != nil {
+		t.Fatalf("AddGeoSource: %v", err)
+	}
+	if _, err := s.UploadGeoSource(manual.Id, []byte("bad prefix:example.com")); err == nil {
+		t.Error("expected error for invalid list")
+	}
+	if changed, err := s.UploadGeoSource(manual.Id, []byte("example.com\n")); err != nil || !changed {
+		t.Fatalf("upload = %v, %v; want changed", changed, err)
+	}
+	if _, err := os.Stat(filepath.Join(config.GetBinFolderPath(), "manual.dat")); err != nil {
+		t.Errorf("uploaded file missing: %v", err)
+	}
+
+	scheduled := &model.GeoSource{FileName: "office.dat", Type: xray.GeoTypeIP, Format: GeoFormatList, Code: "office",
+		Url: baseURL + "/list.txt", Interval: 1, Enable: true}
+	if err := s.AddGeoSource(scheduled); err != nil {
+		t.Fatalf("AddGeoSource: %v", err)
+	}
+	// 内置来源未启用时不下载外部地址
+	sources, _ := s.GetGeoSources()
+	for _, source := range sources {
+		if source.Builtin {
+			source.Enable = false
+			_ = s.UpdateGeoSource(source)
+		}
+	}
+
+	now := time.Now()
+	if !s.RefreshDueGeoSources(now) {
+		t.Fatal("expected due source to be refreshed")
+	}
+	if s.RefreshDueGeoSources(now.Add(30 * time.Minute)) {
+		t.Error("source refreshed before its interval elapsed")
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"x-ui/database"
+	"x-ui/xray"
+)
+
+// HealthStatus 单项检查的结果
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthWarn HealthStatus = "warn"
+	HealthFail HealthStatus = "fail"
+	HealthSkip HealthStatus = "skip"
+)
+
+const (
+	// healthCheckTimeout 单项检查的超时时间
+	healthCheckTimeout = 2 * time.Second
+	// certExpiryWarnWindow 证书临近过期的告警窗口
+	certExpiryWarnWindow = 7 * 24 * time.Hour
+)
+
+// HealthCheck 单项检查详情
+type HealthCheck struct {
+	Status HealthStatus `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// HealthReport 健康检查报告
+type HealthReport struct {
+	Status HealthStatus            `json:"status"`
+	Time   int64                   `json:"time"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// ComponentStateProvider 提供生命周期组件状态，由 bootstrap.LifecycleManager 实现
+type ComponentStateProvider interface {
+	States() map[string]string
+}
+
+// HealthService 提供存活(liveness)与就绪(readiness)检查
+type HealthService struct {
+	settingService *SettingService
+	xrayService    *XrayService
+	components     ComponentStateProvider
+}
+
+// NewHealthService 创建 HealthService 实例
+func NewHealthService(settingService *SettingService, xrayService *XrayService) *HealthService {
+	return &HealthService{
+		settingService: settingService,
+		xrayService:    xrayService,
+	}
+}
+
+// SetComponentStateProvider 注入生命周期组件状态来源
+func (s *HealthService) SetComponentStateProvider(provider ComponentStateProvider) {
+	s.components = provider
+}
+
+// Liveness 存活检查：进程能响应请求且数据库可用即视为存活
+func (s *HealthService) Liveness(ctx context.Context) *HealthReport {
+	return s.buildReport(map[string]*HealthCheck{
+		"database": s.checkDatabase(ctx),
+	})
+}
+
+// Readiness 就绪检查：数据库、Xray 进程、gRPC API、后台任务和证书均正常才视为就绪
+func (s *HealthService) Readiness(ctx context.Context) *HealthReport {
+	return s.buildReport(map[string]*HealthCheck{
+		"database": s.checkDatabase(ctx),
+		"xray":     s.checkXrayProcess(),
+		"xrayApi":  s.checkXrayAPI(ctx),
+		"jobs":     s.checkComponents(),
+		"cert":     s.checkCertificate(),
+	})
+}
+
+// buildReport 汇总各项检查结果，任一项失败则整体失败，有告警则整体告警
+func (s *HealthService) buildReport(checks map[string]*HealthCheck) *HealthReport {
+	status := HealthOK
+	for _, c := range checks {
+		if c.Status == HealthFail {
+			status = HealthFail
+			break
+		}
+		if c.Status == HealthWarn {
+			status = HealthWarn
+		}
+	}
+	return &HealthReport{
+		Status: status,
+		Time:   time.Now().Unix(),
+		Checks: checks,
+	}
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) *HealthCheck {
+	db := database.GetDB()
+	if db == nil {
+		return &HealthCheck{Status: HealthFail, Detail: "database not initialized"}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	return &HealthCheck{Status: HealthOK}
+}
+
+func (s *HealthService) checkXrayProcess() *HealthCheck {
+	if s.xrayService == nil {
+		return &HealthCheck{Status: HealthFail, Detail: "xray service not initialized"}
+	}
+	if s.xrayService.IsXrayRunning() {
+		return &HealthCheck{Status: HealthOK, Detail: s.xrayService.GetXrayVersion()}
+	}
+	if err := s.xrayService.GetXrayErr(); err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	return &HealthCheck{Status: HealthFail, Detail: "xray is not running"}
+}
+
+func (s *HealthService) checkXrayAPI(ctx context.Context) *HealthCheck {
+	if s.xrayService == nil || !s.xrayService.IsXrayRunning() {
+		return &HealthCheck{Status: HealthSkip, Detail: "xray is not running"}
+	}
+	apiPort := s.xrayService.GetApiPort()
+	if apiPort == 0 {
+		return &HealthCheck{Status: HealthFail, Detail: "xray api port unknown"}
+	}
+
+	var api xray.XrayAPI
+	if err := api.Init(apiPort); err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	defer api.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := api.Ping(ctx); err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	return &HealthCheck{Status: HealthOK, Detail: fmt.Sprintf("127.0.0.1:%d", apiPort)}
+}
+
+func (s *HealthService) checkComponents() *HealthCheck {
+	if s.components == nil {
+		return &HealthCheck{Status: HealthSkip, Detail: "lifecycle manager not attached"}
+	}
+	states := s.components.States()
+	for name, state := range states {
+		if state != "running" {
+			return &HealthCheck{Status: HealthFail, Detail: fmt.Sprintf("%s is %s", name, state)}
+		}
+	}
+	return &HealthCheck{Status: HealthOK, Detail: fmt.Sprintf("%d components running", len(states))}
+}
+
+func (s *HealthService) checkCertificate() *HealthCheck {
+	if s.settingService == nil {
+		return &HealthCheck{Status: HealthSkip}
+	}
+	certFile, err := s.settingService.GetCertFile()
+	if err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	keyFile, err := s.settingService.GetKeyFile()
+	if err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	if certFile == "" || keyFile == "" {
+		return &HealthCheck{Status: HealthSkip, Detail: "no certificate configured"}
+	}
+	return checkCertificateFiles(certFile, keyFile, time.Now())
+}
+
+// checkCertificateFiles 校验证书与私钥是否匹配以及有效期
+func checkCertificateFiles(certFile, keyFile string, now time.Time) *HealthCheck {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+	if len(pair.Certificate) == 0 {
+		return &HealthCheck{Status: HealthFail, Detail: "empty certificate chain"}
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return &HealthCheck{Status: HealthFail, Detail: err.Error()}
+	}
+
+	notAfter := leaf.NotAfter.Format(time.RFC3339)
+	switch {
+	case now.Before(leaf.NotBefore):
+		return &HealthCheck{Status: HealthFail, Detail: "certificate not yet valid"}
+	case now.After(leaf.NotAfter):
+		return &HealthCheck{Status: HealthFail, Detail: "certificate expired at " + notAfter}
+	case leaf.NotAfter.Sub(now) < certExpiryWarnWindow:
+		return &HealthCheck{Status: HealthWarn, Detail: "certificate expires at " + notAfter}
+	}
+	return &HealthCheck{Status: HealthOK, Detail: "valid until " + notAfter}
+}
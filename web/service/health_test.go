@@ -0,0 +1,131 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert 生成自签名证书并写入临时目录
+func writeTestCert(t *testing.T, notBefore, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "x-ui.test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCheckCertificateFiles(t *testing.T) {
+	now := time.Now()
+
+	cert, key := writeTestCert(t, now.Add(-time.Hour), now.Add(90*24*time.Hour))
+	if got := checkCertificateFiles(cert, key, now); got.Status != HealthOK {
+		t.Errorf("valid cert: expected ok, got %s (%s)", got.Status, got.Detail)
+	}
+
+	cert, key = writeTestCert(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	if got := checkCertificateFiles(cert, key, now); got.Status != HealthWarn {
+		t.Errorf("expiring cert: expected warn, got %s", got.Status)
+	}
+
+	cert, key = writeTestCert(t, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	if got := checkCertificateFiles(cert, key, now); got.Status != HealthFail {
+		t.Errorf("expired cert: expected fail, got %s", got.Status)
+	}
+
+	if got := checkCertificateFiles("/nonexistent/cert", "/nonexistent/key", now); got.Status != HealthFail {
+		t.Errorf("missing cert: expected fail, got %s", got.Status)
+	}
+}
+
+type fakeComponentStates map[string]string
+
+func (f fakeComponentStates) States() map[string]string { return f }
+
+func TestHealthService_CheckComponents(t *testing.T) {
+	s := NewHealthService(nil, nil)
+	if got := s.checkComponents(); got.Status != HealthSkip {
+		t.Errorf("no provider: expected skip, got %s", got.Status)
+	}
+
+	s.SetComponentStateProvider(fakeComponentStates{"BackgroundJobs": "running"})
+	if got := s.checkComponents(); got.Status != HealthOK {
+		t.Errorf("running: expected ok, got %s", got.Status)
+	}
+
+	s.SetComponentStateProvider(fakeComponentStates{"BackgroundJobs": "stopped"})
+	if got := s.checkComponents(); got.Status != HealthFail {
+		t.Errorf("stopped: expected fail, got %s", got.Status)
+	}
+}
+
+func TestHealthService_BuildReport(t *testing.T) {
+	s := NewHealthService(nil, nil)
+
+	report := s.buildReport(map[string]*HealthCheck{
+		"a": {Status: HealthOK},
+		"b": {Status: HealthSkip},
+	})
+	if report.Status != HealthOK {
+		t.Errorf("expected ok, got %s", report.Status)
+	}
+
+	report = s.buildReport(map[string]*HealthCheck{
+		"a": {Status: HealthOK},
+		"b": {Status: HealthWarn},
+	})
+	if report.Status != HealthWarn {
+		t.Errorf("expected warn, got %s", report.Status)
+	}
+
+	report = s.buildReport(map[string]*HealthCheck{
+		"a": {Status: HealthWarn},
+		"b": {Status: HealthFail},
+	})
+	if report.Status != HealthFail {
+		t.Errorf("expected fail, got %s", report.Status)
+	}
+}
+
+func TestHealthService_XrayNotRunning(t *testing.T) {
+	s := NewHealthService(nil, &XrayService{})
+	if got := s.checkXrayProcess(); got.Status != HealthFail {
+		t.Errorf("expected fail when xray is not running, got %s", got.Status)
+	}
+	if got := s.checkXrayAPI(t.Context()); got.Status != HealthSkip {
+		t.Errorf("expected api check skipped when xray is not running, got %s", got.Status)
+	}
+}
@@ -22,7 +22,7 @@ type InboundService struct {
 	xrayApi           xray.XrayAPI
 	xrayService       *XrayService
 	tgService         TelegramService
-	settingsCache     map[int]map[string]any
+	settingsCache     map[int]cachedSettings
 	cacheMutex        sync.RWMutex
 	inboundRepo       repository.InboundRepository
 	clientTrafficRepo repository.ClientTrafficRepository
@@ -41,7 +41,7 @@ func NewInboundService(
 		clientTrafficRepo: clientTrafficRepo,
 		clientIPRepo:      clientIPRepo,
 		xrayApi:           *xrayApi,
-		settingsCache:     make(map[int]map[string]any),
+		settingsCache:     make(map[int]cachedSettings),
 	}
 }
 
@@ -146,6 +146,22 @@ func (s *InboundService) AddInbound(inbound *model.Inbound) (*model.Inbound, boo
 		return inbound, false, common.NewError("tag already exists: ", inbound.Tag)
 	}
 
+	if err := s.prepareWireguardInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := s.prepareAccountInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := s.prepareShadowsocksInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := s.applyClientPlans(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := validateQuotaResetSchedules(inbound); err != nil {
+		return inbound, false, err
+	}
+
 	existEmail, err := s.checkEmailExistForInbound(inbound)
 	if err != nil {
 		return inbound, false, err
@@ -198,7 +214,8 @@ func (s *InboundService) AddInbound(inbound *model.Inbound) (*model.Inbound, boo
 	}
 
 	// Send one-click config notification if TG service is available
-	if s.tgService != nil && s.tgService.IsRunning() {
+	// 端口转发没有可分享的链接，不发送通知
+	if s.tgService != nil && s.tgService.IsRunning() && !isPortForward(result.inbound) {
 		go func() {
 			time.Sleep(2 * time.Second)
 			err := s.tgService.SendOneClickConfig(result.inbound, true, 0)
@@ -239,6 +256,25 @@ func (s *InboundService) UpdateInbound(inbound *model.Inbound) (*model.Inbound,
 		return inbound, false, err
 	}
 
+	if err := s.prepareWireguardInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := s.prepareAccountInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := s.prepareShadowsocksInbound(inbound); err != nil {
+		return inbound, false, err
+	}
+	if err := validateQuotaResetSchedules(inbound); err != nil {
+		return inbound, false, err
+	}
+	// 重置周期未变时保留上次重置时间，变更后由重置任务从当前时间重新计算周期
+	if inbound.ResetSchedule == oldInbound.ResetSchedule {
+		inbound.LastReset = oldInbound.LastReset
+	} else {
+		inbound.LastReset = 0
+	}
+
 	// Clear stream settings cache
 	s.invalidateSettingsCache(inbound.Id)
 
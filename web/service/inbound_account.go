@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+
+	"x-ui/database/model"
+	"x-ui/util/random"
+)
+
+// accountProtocols 使用用户名/密码账户的入站协议。账户按客户端管理：email 即用户名，
+// Xray 会以用户名作为会话用户的 email，因此用户流量统计可直接对应到 client_traffics
+var accountProtocols = map[model.Protocol]bool{
+	model.Socks: true,
+	model.HTTP:  true,
+}
+
+// prepareAccountSettings 将旧版 accounts 合并到 clients，并补全客户端的密码、subId 和 enable。
+// 没有任何账户的 HTTP 入站（不需要认证）保持原样
+func prepareAccountSettings(settings map[string]any) {
+	if auth, _ := settings["auth"].(string); auth == "noauth" {
+		return
+	}
+	clients, _ := settings["clients"].([]any)
+	if accounts, ok := settings["accounts"].([]any); ok {
+		for _, a := range accounts {
+			account, ok := a.(map[string]any)
+			if !ok {
+				continue
+			}
+			user, _ := account["user"].(string)
+			pass, _ := account["pass"].(string)
+			clients = append(clients, map[string]any{"email": user, "password": pass})
+		}
+	}
+	delete(settings, "accounts")
+	if len(clients) == 0 {
+		delete(settings, "clients")
+		return
+	}
+	prepareAccountClients(clients)
+	settings["clients"] = clients
+}
+
+// prepareAccountClients 补全账户客户端缺失的密码、subId 和 enable
+func prepareAccountClients(clients []any) {
+	for _, c := range clients {
+		client, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if email, _ := client["email"].(string); email == "" {
+			client["email"] = random.LowerNumSeq(10)
+		}
+		if password, _ := client["password"].(string); password == "" {
+			client["password"] = random.Seq(16)
+		}
+		if subId, _ := client["subId"].(string); subId == "" {
+			client["subId"] = random.LowerNumSeq(16)
+		}
+		if _, ok := client["enable"]; !ok {
+			client["enable"] = true
+		}
+	}
+}
+
+// prepareAccountInbound 补全新建或整体更新的 SOCKS/HTTP 入站设置
+func (s *InboundService) prepareAccountInbound(inbound *model.Inbound) error {
+	if !accountProtocols[inbound.Protocol] {
+		return nil
+	}
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return err
+	}
+	prepareAccountSettings(settings)
+	modified, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	inbound.Settings = string(modified)
+	return nil
+}
+
+// accountOf 将客户端转换为 Xray accounts 项
+func accountOf(client map[string]any) map[string]any {
+	email, _ := client["email"].(string)
+	password, _ := client["password"].(string)
+	return map[string]any{"user": email, "pass": password}
+}
+
+// applyAccounts 用启用的客户端替换 settings 中的 accounts。没有可用账户时返回 false，
+// 此时入站不应写入 Xray 配置：HTTP 入站没有账户即不认证，SOCKS 入站则拒绝所有连接
+func applyAccounts(protocol model.Protocol, settings map[string]any, accounts []any) bool {
+	delete(settings, "clients")
+	if len(accounts) == 0 {
+		return false
+	}
+	settings["accounts"] = accounts
+	if protocol == model.Socks {
+		settings["auth"] = "password"
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/xray"
+)
+
+func TestPrepareAccountSettings(t *testing.T) {
+	settings := map[string]any{
+		"auth":     "password",
+		"accounts": []any{map[string]any{"user": "legacy", "pass": "secret"}},
+		"clients":  []any{map[string]any{"email": "alice"}},
+	}
+	prepareAccountSettings(settings)
+	if _, ok := settings["accounts"]; ok {
+		t.Error("legacy accounts should be merged into clients")
+	}
+	clients, _ := settings["clients"].([]any)
+	if len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %v", settings["clients"])
+	}
+	alice := clients[0].(map[string]any)
+	if password, _ := alice["password"].(string); password == "" || alice["subId"] == "" || alice["enable"] != true {
+		t.Errorf("alice not prepared: %v", alice)
+	}
+	legacy := clients[1].(map[string]any)
+	if legacy["email"] != "legacy" || legacy["password"] != "secret" {
+		t.Errorf("legacy account converted wrongly: %v", legacy)
+	}
+
+	// 不认证的 SOCKS 和没有账户的 HTTP 入站保持原样
+	noauth := map[string]any{"auth": "noauth"}
+	prepareAccountSettings(noauth)
+	if _, ok := noauth["clients"]; ok {
+		t.Error("noauth socks should stay unmanaged")
+	}
+	open := map[string]any{"accounts": []any{}, "allowTransparent": false}
+	prepareAccountSettings(open)
+	if _, ok := open["clients"]; ok {
+		t.Error("http without accounts should stay unmanaged")
+	}
+}
+
+func TestApplyAccounts(t *testing.T) {
+	settings := map[string]any{"udp": true, "clients": []any{}}
+	accounts := []any{accountOf(map[string]any{"email": "alice", "password": "pw", "subId": "x"})}
+	if !applyAccounts(model.Socks, settings, accounts) {
+		t.Fatal("expected accounts to be applied")
+	}
+	if settings["auth"] != "password" {
+		t.Errorf("socks auth = %v, want password", settings["auth"])
+	}
+	if _, ok := settings["clients"]; ok {
+		t.Error("clients should be removed from xray settings")
+	}
+	got := settings["accounts"].([]any)[0].(map[string]any)
+	if got["user"] != "alice" || got["pass"] != "pw" || len(got) != 2 {
+		t.Errorf("unexpected account: %v", got)
+	}
+
+	// 所有账户都被禁用时 HTTP 入站不能变成无需认证的代理
+	if applyAccounts(model.HTTP, map[string]any{"clients": []any{}}, nil) {
+		t.Error("expected inbound without enabled accounts to be skipped")
+	}
+}
+
+func TestInboundService_AccountClients(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	inbound := &model.Inbound{
+		Tag:      "socks-in",
+		Protocol: model.Socks,
+		Port:     1080,
+		Enable:   true,
+		Settings: `{"auth":"password","accounts":[{"user":"legacy","pass":"secret"}],"udp":false,"ip":"127.0.0.1"}`,
+	}
+	if _, _, err := s.AddInbound(inbound); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+
+	var count int64
+	database.GetDB().Model(xray.ClientTraffic{}).Where("email = ?", "legacy").Count(&count)
+	if count != 1 {
+		t.Errorf("legacy account should get a client_traffics row, got %d", count)
+	}
+
+	needRestart, err := s.AddInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"bob","enable":true,"totalGB":2048}]}`,
+	})
+	if err != nil {
+		t.Fatalf("AddInboundClient failed: %v", err)
+	}
+	if !needRestart {
+		t.Error("adding a socks account should require the inbound to be reapplied")
+	}
+
+	stored, _ := s.GetInbound(inbound.Id)
+	clients, _ := s.GetClients(stored)
+	var bob *model.Client
+	for i := range clients {
+		if clients[i].Email == "bob" {
+			bob = &clients[i]
+		}
+	}
+	if bob == nil || bob.Password == "" || bob.SubID == "" {
+		t.Fatalf("bob not prepared: %+v", bob)
+	}
+
+	if _, err := s.DelInboundClient(inbound.Id, "bob"); err != nil {
+		t.Fatalf("DelInboundClient failed: %v", err)
+	}
+	database.GetDB().Model(xray.ClientTraffic{}).Where("email = ?", "bob").Count(&count)
+	if count != 0 {
+		t.Error("bob client_traffics row should be removed")
+	}
+}
@@ -124,6 +124,16 @@ func (s *InboundService) checkIsEnabledByEmail(clientEmail string) (bool, error)
 // =============================================================================
 
 func (s *InboundService) AddInboundClient(data *model.Inbound) (bool, error) {
+	if err := s.prepareAddedClients(data); err != nil {
+		return false, err
+	}
+	if err := s.applyClientPlans(data); err != nil {
+		return false, err
+	}
+	if err := validateQuotaResetSchedules(data); err != nil {
+		return false, err
+	}
+
 	clients, err := s.GetClients(data)
 	if err != nil {
 		return false, err
@@ -148,7 +158,7 @@ func (s *InboundService) AddInboundClient(data *model.Inbound) (bool, error) {
 		return false, err
 	}
 
-	oldClients := oldSettings["clients"].([]any)
+	oldClients, _ := oldSettings["clients"].([]any)
 
 	var newSettings map[string]any
 	err = json.Unmarshal([]byte(data.Settings), &newSettings)
@@ -161,6 +171,17 @@ func (s *InboundService) AddInboundClient(data *model.Inbound) (bool, error) {
 	// Merge clients
 	allClients := append(oldClients, newClients...)
 	oldSettings["clients"] = allClients
+	if oldInbound.Protocol == model.WireGuard {
+		if err := prepareWireguardSettings(oldSettings); err != nil {
+			return false, err
+		}
+	}
+	if accountProtocols[oldInbound.Protocol] {
+		prepareAccountSettings(oldSettings)
+	}
+	if err := prepareShadowsocksSettings(oldSettings); err != nil {
+		return false, err
+	}
 
 	modifiedSettings, err := json.MarshalIndent(oldSettings, "", "  ")
 	if err != nil {
@@ -210,6 +231,41 @@ func (s *InboundService) AddInboundClient(data *model.Inbound) (bool, error) {
 	})
 }
 
+// prepareAddedClients 按入站协议补全待添加客户端的生成字段（WireGuard 密钥和地址、SOCKS/HTTP 密码、SS2022 密钥等）
+func (s *InboundService) prepareAddedClients(data *model.Inbound) error {
+	oldInbound, err := s.GetInbound(data.Id)
+	if err != nil {
+		return err
+	}
+	var newSettings map[string]any
+	if err := json.Unmarshal([]byte(data.Settings), &newSettings); err != nil {
+		return err
+	}
+	newClients, _ := newSettings["clients"].([]any)
+
+	switch {
+	case oldInbound.Protocol == model.WireGuard:
+		if err := prepareWireguardClients(oldInbound, newClients); err != nil {
+			return err
+		}
+	case accountProtocols[oldInbound.Protocol]:
+		prepareAccountClients(newClients)
+	case oldInbound.Protocol == model.Shadowsocks:
+		if err := prepareShadowsocksClients(shadowsocksMethod(oldInbound), newClients); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	modified, err := json.Marshal(newSettings)
+	if err != nil {
+		return err
+	}
+	data.Settings = string(modified)
+	return nil
+}
+
 func (s *InboundService) DelInboundClient(inboundId int, clientId string) (bool, error) {
 	oldInbound, err := s.GetInbound(inboundId)
 	if err != nil {
@@ -234,7 +290,7 @@ func (s *InboundService) DelInboundClient(inboundId int, clientId string) (bool,
 			switch oldInbound.Protocol {
 			case "trojan":
 				id = c["password"].(string)
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				id = c["email"].(string)
 			default:
 				id = c["id"].(string)
@@ -281,7 +337,8 @@ func (s *InboundService) DelInboundClient(inboundId int, clientId string) (bool,
 		oldInbound.Settings = string(modifiedSettings)
 		s.invalidateSettingsCache(oldInbound.Id)
 
-		if err := tx.Save(oldInbound).Error; err != nil {
+		// 预加载的 ClientStats 仍包含已删除客户端的统计，保存时跳过关联以免被重新写入
+		if err := tx.Omit("ClientStats").Save(oldInbound).Error; err != nil {
 			return false, err
 		}
 
@@ -290,6 +347,13 @@ func (s *InboundService) DelInboundClient(inboundId int, clientId string) (bool,
 }
 
 func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId string) (bool, error) {
+	if err := s.prepareUpdatedShadowsocksClient(data, clientId); err != nil {
+		return false, err
+	}
+	if err := validateQuotaResetSchedules(data); err != nil {
+		return false, err
+	}
+
 	clients, err := s.GetClients(data)
 	if err != nil {
 		return false, err
@@ -299,7 +363,6 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 	if err != nil {
 		return false, err
 	}
-
 	oldClients, err := s.GetClients(oldInbound)
 	if err != nil {
 		return false, err
@@ -307,13 +370,14 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 
 	// Find old email
 	oldEmail := ""
+	oldResetSchedule := ""
 	for _, oldClient := range oldClients {
-		switch data.Protocol {
+		switch oldInbound.Protocol {
 		case "trojan":
 			if oldClient.Password == clientId {
 				oldEmail = oldClient.Email
 			}
-		case "shadowsocks":
+		case "shadowsocks", "wireguard", "socks", "http":
 			if oldClient.Email == clientId {
 				oldEmail = oldClient.Email
 			}
@@ -327,6 +391,12 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 	if oldEmail == "" {
 		return false, common.NewError("Client not found")
 	}
+	for _, oldClient := range oldClients {
+		if oldClient.Email == oldEmail {
+			oldResetSchedule = oldClient.ResetSchedule
+			break
+		}
+	}
 
 	// Check for duplicate email if email changed
 	for _, client := range clients {
@@ -362,10 +432,10 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 		for i, client := range settingsClients {
 			c := client.(map[string]any)
 			id := ""
-			switch data.Protocol {
+			switch oldInbound.Protocol {
 			case "trojan":
 				id = c["password"].(string)
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				id = c["email"].(string)
 			default:
 				id = c["id"].(string)
@@ -373,6 +443,9 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 			if id == clientId {
 				// Update client
 				if len(newClients) > 0 {
+					if newPeer, ok := newClients[0].(map[string]any); ok && oldInbound.Protocol == model.WireGuard {
+						inheritWireguardPeer(newPeer, c)
+					}
 					settingsClients[i] = newClients[0]
 
 					// Update client stat
@@ -380,6 +453,12 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 						if err := s.UpdateClientStat(tx, oldEmail, &newClient); err != nil {
 							return false, err
 						}
+						// 重置周期变更后由重置任务从当前时间重新计算周期，与入站的处理一致
+						if newClient.ResetSchedule != oldResetSchedule {
+							if err := tx.Model(xray.ClientTraffic{}).Where("email = ?", newClient.Email).Update("last_reset", 0).Error; err != nil {
+								return false, err
+							}
+						}
 
 						// Update client IPs if email changed
 						if oldEmail != newClient.Email {
@@ -420,6 +499,17 @@ func (s *InboundService) UpdateInboundClient(data *model.Inbound, clientId strin
 		}
 
 		oldSettings["clients"] = settingsClients
+		if oldInbound.Protocol == model.WireGuard {
+			if err := prepareWireguardSettings(oldSettings); err != nil {
+				return false, err
+			}
+		}
+		if accountProtocols[oldInbound.Protocol] {
+			prepareAccountSettings(oldSettings)
+		}
+		if err := prepareShadowsocksSettings(oldSettings); err != nil {
+			return false, err
+		}
 		modifiedSettings, err := json.MarshalIndent(oldSettings, "", "  ")
 		if err != nil {
 			return false, err
@@ -497,7 +587,7 @@ func (s *InboundService) SetClientTelegramUserID(trafficId int, tgId int64) (boo
 			switch inbound.Protocol {
 			case "trojan":
 				clientId = oldClient.Password
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				clientId = oldClient.Email
 			default:
 				clientId = oldClient.ID
@@ -560,7 +650,7 @@ func (s *InboundService) ToggleClientEnableByEmail(clientEmail string) (bool, bo
 			switch inbound.Protocol {
 			case "trojan":
 				clientId = oldClient.Password
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				clientId = oldClient.Email
 			default:
 				clientId = oldClient.ID
@@ -626,7 +716,7 @@ func (s *InboundService) ResetClientIpLimitByEmail(clientEmail string, count int
 			switch inbound.Protocol {
 			case "trojan":
 				clientId = oldClient.Password
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				clientId = oldClient.Email
 			default:
 				clientId = oldClient.ID
@@ -688,7 +778,7 @@ func (s *InboundService) ResetClientExpiryTimeByEmail(clientEmail string, expiry
 			switch inbound.Protocol {
 			case "trojan":
 				clientId = oldClient.Password
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				clientId = oldClient.Email
 			default:
 				clientId = oldClient.ID
@@ -753,7 +843,7 @@ func (s *InboundService) ResetClientTrafficLimitByEmail(clientEmail string, tota
 			switch inbound.Protocol {
 			case "trojan":
 				clientId = oldClient.Password
-			case "shadowsocks":
+			case "shadowsocks", "wireguard", "socks", "http":
 				clientId = oldClient.Email
 			default:
 				clientId = oldClient.ID
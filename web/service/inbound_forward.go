@@ -0,0 +1,357 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"x-ui/database/model"
+	"x-ui/util/common"
+	"x-ui/util/json_util"
+	"x-ui/xray"
+)
+
+// portForwardTagPrefix 端口转发以 tunnel 入站保存，标签带有固定前缀，生成 Xray 配置时为其追加路由规则。
+// 手动创建的 tunnel 入站不受影响
+const portForwardTagPrefix = "forward-"
+
+// portForwardPanelFields 仅面板使用、不写入 Xray 配置的 tunnel 入站设置
+var portForwardPanelFields = []string{"portEnd", "allowedSources"}
+
+var portForwardNetworks = map[string]bool{"tcp": true, "udp": true, "tcp,udp": true}
+
+// PortForward 端口转发：本机端口或端口范围 → 目标地址。
+// TargetPort 为 0 时转发到与本机端口相同的目标端口，适用于端口范围
+type PortForward struct {
+	Id             int      `json:"id"`
+	Tag            string   `json:"tag"`
+	Remark         string   `json:"remark"`
+	Enable         bool     `json:"enable"`
+	Listen         string   `json:"listen,omitempty"`
+	Port           int      `json:"port"`
+	PortEnd        int      `json:"portEnd,omitempty"`
+	TargetHost     string   `json:"targetHost"`
+	TargetPort     int      `json:"targetPort"`
+	Network        string   `json:"network"`
+	AllowedSources []string `json:"allowedSources,omitempty"`
+	Up             int64    `json:"up"`
+	Down           int64    `json:"down"`
+}
+
+// forwardSettings 端口转发 tunnel 入站的 settings
+type forwardSettings struct {
+	Address        string   `json:"address"`
+	Port           int      `json:"port"`
+	Network        string   `json:"network"`
+	FollowRedirect bool     `json:"followRedirect"`
+	PortEnd        int      `json:"portEnd,omitempty"`
+	AllowedSources []string `json:"allowedSources,omitempty"`
+}
+
+// isPortForward 判断入站是否由端口转发管理
+func isPortForward(inbound *model.Inbound) bool {
+	return inbound.Protocol == model.Tunnel && strings.HasPrefix(inbound.Tag, portForwardTagPrefix)
+}
+
+// portForwardOf 从 tunnel 入站还原端口转发
+func portForwardOf(inbound *model.Inbound) (*PortForward, error) {
+	var settings forwardSettings
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return nil, err
+	}
+	return &PortForward{
+		Id:             inbound.Id,
+		Tag:            inbound.Tag,
+		Remark:         inbound.Remark,
+		Enable:         inbound.Enable,
+		Listen:         inbound.Listen,
+		Port:           inbound.Port,
+		PortEnd:        settings.PortEnd,
+		TargetHost:     settings.Address,
+		TargetPort:     settings.Port,
+		Network:        settings.Network,
+		AllowedSources: settings.AllowedSources,
+		Up:             inbound.Up,
+		Down:           inbound.Down,
+	}, nil
+}
+
+// normalizePortForward 校验并规范化端口转发参数
+func normalizePortForward(forward *PortForward) error {
+	forward.TargetHost = strings.TrimSpace(forward.TargetHost)
+	if forward.TargetHost == "" {
+		return common.NewError("target host cannot be empty")
+	}
+	if forward.Port < 1 || forward.Port > 65535 {
+		return common.NewErrorf("invalid local port %d", forward.Port)
+	}
+	if forward.PortEnd == forward.Port {
+		forward.PortEnd = 0
+	}
+	if forward.PortEnd != 0 && (forward.PortEnd < forward.Port || forward.PortEnd > 65535) {
+		return common.NewErrorf("invalid local port range %d-%d", forward.Port, forward.PortEnd)
+	}
+	if forward.TargetPort < 0 || forward.TargetPort > 65535 {
+		return common.NewErrorf("invalid target port %d", forward.TargetPort)
+	}
+	if forward.Network == "" {
+		forward.Network = "tcp,udp"
+	}
+	if !portForwardNetworks[forward.Network] {
+		return common.NewError("invalid network: ", forward.Network)
+	}
+
+	sources := make([]string, 0, len(forward.AllowedSources))
+	for _, source := range forward.AllowedSources {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(source); err != nil {
+			if _, err := netip.ParseAddr(source); err != nil {
+				return common.NewError("invalid source IP or CIDR: ", source)
+			}
+		}
+		sources = append(sources, source)
+	}
+	forward.AllowedSources = sources
+	return nil
+}
+
+// portForwardInbound 将端口转发转换为 tunnel 入站
+func portForwardInbound(forward *PortForward) (*model.Inbound, error) {
+	settings, err := json.MarshalIndent(forwardSettings{
+		Address:        forward.TargetHost,
+		Port:           forward.TargetPort,
+		Network:        forward.Network,
+		PortEnd:        forward.PortEnd,
+		AllowedSources: forward.AllowedSources,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tag := forward.Tag
+	if tag == "" {
+		tag = portForwardTagPrefix + strconv.Itoa(forward.Port)
+		if !isAnyListen(forward.Listen) {
+			tag = portForwardTagPrefix + net.JoinHostPort(forward.Listen, strconv.Itoa(forward.Port))
+		}
+	}
+	return &model.Inbound{
+		Id:       forward.Id,
+		Remark:   forward.Remark,
+		Enable:   forward.Enable,
+		Listen:   forward.Listen,
+		Port:     forward.Port,
+		Protocol: model.Tunnel,
+		Settings: string(settings),
+		Tag:      tag,
+		Sniffing: `{"enabled":false}`,
+	}, nil
+}
+
+// portRange 返回入站监听的端口范围，端口转发可以监听一段连续端口
+func portRange(inbound *model.Inbound) (int, int) {
+	end := inbound.Port
+	if inbound.Protocol == model.Tunnel {
+		var settings forwardSettings
+		if json.Unmarshal([]byte(inbound.Settings), &settings) == nil && settings.PortEnd > end {
+			end = settings.PortEnd
+		}
+	}
+	return inbound.Port, end
+}
+
+// isAnyListen 判断监听地址是否为全部地址
+func isAnyListen(listen string) bool {
+	return listen == "" || listen == "0.0.0.0" || listen == "::" || listen == "::0"
+}
+
+// checkPortRangeExist 检查端口范围是否与已有入站（包括端口转发的端口范围）重叠
+func (s *InboundService) checkPortRangeExist(listen string, start int, end int, ignoreId int) (bool, error) {
+	inbounds, err := s.GetAllInbounds()
+	if err != nil {
+		return false, err
+	}
+	for _, inbound := range inbounds {
+		if inbound.Id == ignoreId {
+			continue
+		}
+		if !isAnyListen(listen) && !isAnyListen(inbound.Listen) && inbound.Listen != listen {
+			continue
+		}
+		from, to := portRange(inbound)
+		if start <= to && from <= end {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetPortForwards 返回所有端口转发及其流量统计
+func (s *InboundService) GetPortForwards() ([]*PortForward, error) {
+	inbounds, err := s.GetAllInbounds()
+	if err != nil {
+		return nil, err
+	}
+	forwards := make([]*PortForward, 0)
+	for _, inbound := range inbounds {
+		if !isPortForward(inbound) {
+			continue
+		}
+		forward, err := portForwardOf(inbound)
+		if err != nil {
+			return nil, common.NewErrorf("invalid port forward %s: %v", inbound.Tag, err)
+		}
+		forwards = append(forwards, forward)
+	}
+	return forwards, nil
+}
+
+// getPortForward 读取端口转发对应的入站，非端口转发入站返回错误
+func (s *InboundService) getPortForward(id int) (*model.Inbound, error) {
+	inbound, err := s.GetInbound(id)
+	if err != nil {
+		return nil, err
+	}
+	if !isPortForward(inbound) {
+		return nil, common.NewErrorf("inbound %s is not a port forward", inbound.Tag)
+	}
+	return inbound, nil
+}
+
+// AddPortForward 创建端口转发，生成对应的 tunnel 入站
+func (s *InboundService) AddPortForward(userId int, forward *PortForward) (*PortForward, bool, error) {
+	forward.Id = 0
+	forward.Tag = ""
+	if err := normalizePortForward(forward); err != nil {
+		return nil, false, err
+	}
+	end := max(forward.PortEnd, forward.Port)
+	exist, err := s.checkPortRangeExist(forward.Listen, forward.Port, end, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	if exist {
+		return nil, false, common.NewErrorf("port %d-%d overlaps an existing inbound", forward.Port, end)
+	}
+
+	inbound, err := portForwardInbound(forward)
+	if err != nil {
+		return nil, false, err
+	}
+	inbound.UserId = userId
+	inbound, needRestart, err := s.AddInbound(inbound)
+	if err != nil {
+		return nil, false, err
+	}
+	forward, err = portForwardOf(inbound)
+	return forward, needRestart, err
+}
+
+// UpdatePortForward 修改端口转发，保留原有标签和流量统计
+func (s *InboundService) UpdatePortForward(forward *PortForward) (*PortForward, bool, error) {
+	oldInbound, err := s.getPortForward(forward.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := normalizePortForward(forward); err != nil {
+		return nil, false, err
+	}
+	end := max(forward.PortEnd, forward.Port)
+	exist, err := s.checkPortRangeExist(forward.Listen, forward.Port, end, forward.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	if exist {
+		return nil, false, common.NewErrorf("port %d-%d overlaps an existing inbound", forward.Port, end)
+	}
+
+	forward.Tag = oldInbound.Tag
+	inbound, err := portForwardInbound(forward)
+	if err != nil {
+		return nil, false, err
+	}
+	inbound.UserId = oldInbound.UserId
+	inbound.Up, inbound.Down, inbound.AllTime = oldInbound.Up, oldInbound.Down, oldInbound.AllTime
+	inbound.Total, inbound.ExpiryTime = oldInbound.Total, oldInbound.ExpiryTime
+	inbound, needRestart, err := s.UpdateInbound(inbound)
+	if err != nil {
+		return nil, false, err
+	}
+	forward, err = portForwardOf(inbound)
+	return forward, needRestart, err
+}
+
+// SetPortForwardEnable 启用或停用端口转发
+func (s *InboundService) SetPortForwardEnable(id int, enable bool) (bool, error) {
+	inbound, err := s.getPortForward(id)
+	if err != nil {
+		return false, err
+	}
+	if inbound.Enable == enable {
+		return false, nil
+	}
+	err = s.getInboundRepo().GetDB().Model(model.Inbound{}).Where("id = ?", id).Update("enable", enable).Error
+	return err == nil, err
+}
+
+// DelPortForward 删除端口转发及其 tunnel 入站
+func (s *InboundService) DelPortForward(id int) (bool, error) {
+	if _, err := s.getPortForward(id); err != nil {
+		return false, err
+	}
+	return s.DelInbound(id)
+}
+
+// =============================================================================
+// Xray 配置
+// =============================================================================
+
+// applyPortForwardSettings 移除端口转发的面板字段，端口范围写入 inboundConfig.PortRange
+func applyPortForwardSettings(inboundConfig *xray.InboundConfig, settings map[string]any) error {
+	if portEnd, _ := settings["portEnd"].(float64); int(portEnd) > inboundConfig.Port {
+		inboundConfig.PortRange = fmt.Sprintf("%d-%d", inboundConfig.Port, int(portEnd))
+	}
+	for _, field := range portForwardPanelFields {
+		delete(settings, field)
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	inboundConfig.Settings = json_util.RawMessage(data)
+	return nil
+}
+
+// portForwardRules 生成端口转发的路由规则：转发流量直连目标，设置了来源白名单时其余来源被阻断
+func portForwardRules(forwards []*PortForward, outbound func(protocol string) string) []map[string]any {
+	var rules []map[string]any
+	for _, forward := range forwards {
+		if len(forward.AllowedSources) == 0 {
+			rules = append(rules, map[string]any{
+				"type":        "field",
+				"inboundTag":  []string{forward.Tag},
+				"outboundTag": outbound("freedom"),
+			})
+			continue
+		}
+		rules = append(rules,
+			map[string]any{
+				"type":        "field",
+				"inboundTag":  []string{forward.Tag},
+				"source":      forward.AllowedSources,
+				"outboundTag": outbound("freedom"),
+			},
+			map[string]any{
+				"type":        "field",
+				"inboundTag":  []string{forward.Tag},
+				"outboundTag": outbound("blackhole"),
+			},
+		)
+	}
+	return rules
+}
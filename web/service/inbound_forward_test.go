@@ -0,0 +1,151 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"x-ui/database/model"
+	"x-ui/util/json_util"
+	"x-ui/xray"
+)
+
+func TestNormalizePortForward(t *testing.T) {
+	forward := &PortForward{Port: 8000, PortEnd: 8000, TargetHost: " 10.0.0.2 ", AllowedSources: []string{"1.2.3.4", " ", "10.0.0.0/8"}}
+	if err := normalizePortForward(forward); err != nil {
+		t.Fatalf("normalizePortForward failed: %v", err)
+	}
+	if forward.TargetHost != "10.0.0.2" || forward.PortEnd != 0 || forward.Network != "tcp,udp" || len(forward.AllowedSources) != 2 {
+		t.Errorf("unexpected normalized forward: %+v", forward)
+	}
+
+	invalid := []*PortForward{
+		{Port: 8000},
+		{Port: 0, TargetHost: "h"},
+		{Port: 8000, PortEnd: 7000, TargetHost: "h"},
+		{Port: 8000, TargetHost: "h", Network: "icmp"},
+		{Port: 8000, TargetHost: "h", AllowedSources: []string{"not-an-ip"}},
+	}
+	for _, f := range invalid {
+		if err := normalizePortForward(f); err == nil {
+			t.Errorf("expected error for %+v", f)
+		}
+	}
+}
+
+func TestPrependRoutingRules(t *testing.T) {
+	config := &xray.Config{
+		OutboundConfigs: json_util.RawMessage(`[{"tag":"proxy","protocol":"vless"},{"tag":"direct","protocol":"freedom"}]`),
+		RouterConfig:    json_util.RawMessage(`{"domainStrategy":"AsIs","rules":[{"type":"field","inboundTag":["api"],"outboundTag":"api"}]}`),
+	}
+	forwards := []*PortForward{
+		{Tag: "forward-8000"},
+		{Tag: "forward-9000", AllowedSources: []string{"1.2.3.4"}},
+	}
+	rejected := map[string][]string{"alice": {"5.6.7.8"}}
+	err := prependRoutingRules(config, func(outbound func(string) string) []map[string]any {
+		return append(portForwardRules(forwards, outbound), deviceLimitRules(rejected, outbound)...)
+	})
+	if err != nil {
+		t.Fatalf("prependRoutingRules failed: %v", err)
+	}
+
+	var outbounds []map[string]any
+	_ = json.Unmarshal(config.OutboundConfigs, &outbounds)
+	if len(outbounds) != 3 || outbounds[2]["tag"] != generatedOutboundTags["blackhole"] {
+		t.Errorf("expected a blackhole outbound to be added: %s", config.OutboundConfigs)
+	}
+
+	var routing struct {
+		DomainStrategy string           `json:"domainStrategy"`
+		Rules          []map[string]any `json:"rules"`
+	}
+	_ = json.Unmarshal(config.RouterConfig, &routing)
+	if routing.DomainStrategy != "AsIs" || len(routing.Rules) != 5 {
+		t.Fatalf("unexpected routing: %s", config.RouterConfig)
+	}
+	if routing.Rules[0]["outboundTag"] != "direct" || routing.Rules[1]["source"] == nil {
+		t.Errorf("forward rules not generated correctly: %v", routing.Rules)
+	}
+	if routing.Rules[2]["outboundTag"] != generatedOutboundTags["blackhole"] || routing.Rules[4]["outboundTag"] != "api" {
+		t.Errorf("allowlist should be followed by a block rule and template rules: %v", routing.Rules)
+	}
+	if routing.Rules[3]["ruleTag"] != deviceLimitRuleTagPrefix+"alice" {
+		t.Errorf("device limit rule not generated: %v", routing.Rules[3])
+	}
+
+	// 没有生成规则时不修改配置
+	untouched := &xray.Config{RouterConfig: json_util.RawMessage(`{"rules":[]}`)}
+	_ = prependRoutingRules(untouched, func(func(string) string) []map[string]any { return nil })
+	if string(untouched.RouterConfig) != `{"rules":[]}` || untouched.OutboundConfigs != nil {
+		t.Errorf("config changed without rules: %s %s", untouched.RouterConfig, untouched.OutboundConfigs)
+	}
+}
+
+func TestApplyPortForwardSettings(t *testing.T) {
+	inboundConfig := &xray.InboundConfig{Port: 10000, Protocol: "tunnel"}
+	settings := map[string]any{"address": "10.0.0.2", "port": float64(0), "portEnd": float64(10010), "allowedSources": []any{"1.2.3.4"}}
+	if err := applyPortForwardSettings(inboundConfig, settings); err != nil {
+		t.Fatalf("applyPortForwardSettings failed: %v", err)
+	}
+	if inboundConfig.PortRange != "10000-10010" {
+		t.Errorf("PortRange = %q, want 10000-10010", inboundConfig.PortRange)
+	}
+	var got map[string]any
+	_ = json.Unmarshal(inboundConfig.Settings, &got)
+	if _, ok := got["portEnd"]; ok {
+		t.Errorf("panel fields should be removed: %s", inboundConfig.Settings)
+	}
+}
+
+func TestInboundService_PortForwards(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	forward, needRestart, err := s.AddPortForward(1, &PortForward{
+		Remark:     "ssh",
+		Enable:     true,
+		Port:       20000,
+		PortEnd:    20010,
+		TargetHost: "192.168.1.10",
+		Network:    "tcp",
+	})
+	if err != nil {
+		t.Fatalf("AddPortForward failed: %v", err)
+	}
+	if !needRestart || forward.Tag != "forward-20000" || forward.PortEnd != 20010 {
+		t.Errorf("unexpected forward: %+v", forward)
+	}
+
+	// 端口范围内的端口不能再被其他入站使用
+	if _, _, err := s.AddPortForward(1, &PortForward{Port: 20005, TargetHost: "h"}); err == nil {
+		t.Error("expected overlap error for port forward")
+	}
+	if _, _, err := s.AddInbound(&model.Inbound{Tag: "inbound-20008", Port: 20008, Protocol: model.VLESS, Settings: `{"clients":[]}`}); err == nil {
+		t.Error("expected overlap error for inbound inside a forward range")
+	}
+
+	forward.AllowedSources = []string{"10.0.0.0/8"}
+	forward.TargetPort = 22
+	updated, _, err := s.UpdatePortForward(forward)
+	if err != nil {
+		t.Fatalf("UpdatePortForward failed: %v", err)
+	}
+	if updated.Tag != forward.Tag || updated.TargetPort != 22 || len(updated.AllowedSources) != 1 {
+		t.Errorf("unexpected updated forward: %+v", updated)
+	}
+
+	if needRestart, err := s.SetPortForwardEnable(forward.Id, false); err != nil || !needRestart {
+		t.Errorf("SetPortForwardEnable = %v, %v", needRestart, err)
+	}
+	forwards, err := s.GetPortForwards()
+	if err != nil || len(forwards) != 1 || forwards[0].Enable {
+		t.Errorf("unexpected forwards: %+v, %v", forwards, err)
+	}
+
+	if _, err := s.DelPortForward(forward.Id); err != nil {
+		t.Fatalf("DelPortForward failed: %v", err)
+	}
+	if forwards, _ := s.GetPortForwards(); len(forwards) != 0 {
+		t.Errorf("forward not deleted: %+v", forwards)
+	}
+}
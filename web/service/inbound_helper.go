@@ -18,13 +18,20 @@ import (
 // 缓存管理
 // =============================================================================
 
+// cachedSettings 解析后的入站 settings 及其原始文本。原始文本不一致时缓存失效，
+// 避免同一 id 的新旧 settings（如更新入站、添加客户端时）读到旧的解析结果
+type cachedSettings struct {
+	raw      string
+	settings map[string]any
+}
+
 func (s *InboundService) getParsedSettings(inboundId int, settingsStr string) map[string]any {
 	s.cacheMutex.RLock()
 	if s.settingsCache != nil {
 		cached, exists := s.settingsCache[inboundId]
-		if exists {
+		if exists && cached.raw == settingsStr {
 			s.cacheMutex.RUnlock()
-			return cached
+			return cached.settings
 		}
 	}
 	s.cacheMutex.RUnlock()
@@ -33,7 +40,7 @@ func (s *InboundService) getParsedSettings(inboundId int, settingsStr string) ma
 	defer s.cacheMutex.Unlock()
 
 	if s.settingsCache == nil {
-		s.settingsCache = make(map[int]map[string]any)
+		s.settingsCache = make(map[int]cachedSettings)
 	}
 
 	var settings map[string]any
@@ -42,7 +49,7 @@ func (s *InboundService) getParsedSettings(inboundId int, settingsStr string) ma
 		return nil
 	}
 
-	s.settingsCache[inboundId] = settings
+	s.settingsCache[inboundId] = cachedSettings{raw: settingsStr, settings: settings}
 
 	return settings
 }
@@ -58,7 +65,12 @@ func (s *InboundService) invalidateSettingsCache(inboundId int) {
 // =============================================================================
 
 func (s *InboundService) checkPortExist(listen string, port int, ignoreId int) (bool, error) {
-	return s.getInboundRepo().CheckPortExist(listen, port, ignoreId)
+	exist, err := s.getInboundRepo().CheckPortExist(listen, port, ignoreId)
+	if err != nil || exist {
+		return exist, err
+	}
+	// 端口转发可以占用一段端口，需要额外检查端口范围
+	return s.checkPortRangeExist(listen, port, port, ignoreId)
 }
 
 func (s *InboundService) getAllEmails() ([]string, error) {
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"x-ui/database/model"
+	"x-ui/logger"
+	"x-ui/util/common"
+)
+
+// ss2022KeySizes Shadowsocks 2022 各加密方式要求的密钥长度（字节）。
+// 服务端密钥和用户密钥均为对应长度随机数据的 base64 编码
+var ss2022KeySizes = map[string]int{
+	"2022-blake3-aes-128-gcm":       16,
+	"2022-blake3-aes-256-gcm":       32,
+	"2022-blake3-chacha20-poly1305": 32,
+}
+
+// isSS2022 判断是否为 Shadowsocks 2022 加密方式
+func isSS2022(method string) bool {
+	return strings.HasPrefix(method, "2022-")
+}
+
+// ss2022MultiUser 判断加密方式是否支持多用户，Xray 只为 blake3-aes-*-gcm 提供多用户模式
+func ss2022MultiUser(method string) bool {
+	return strings.Contains(method, "aes")
+}
+
+// GenerateSS2022Key 按加密方式生成长度正确的 base64 密钥
+func GenerateSS2022Key(method string) (string, error) {
+	size, ok := ss2022KeySizes[method]
+	if !ok {
+		return "", common.NewError("unsupported shadowsocks 2022 method: ", method)
+	}
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// validateSS2022Key 校验密钥是否为对应长度的 base64 编码，name 用于错误信息
+func validateSS2022Key(method string, key string, name string) error {
+	size, ok := ss2022KeySizes[method]
+	if !ok {
+		return common.NewError("unsupported shadowsocks 2022 method: ", method)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return common.NewErrorf("%s is not valid base64 for %s", name, method)
+	}
+	if len(decoded) != size {
+		return common.NewErrorf("%s must be a %d-byte key for %s, got %d bytes", name, size, method, len(decoded))
+	}
+	return nil
+}
+
+// prepareShadowsocksSettings 为 Shadowsocks 2022 入站补全缺失的服务端密钥和用户密钥并校验长度，
+// 在 Xray 拒绝配置之前给出明确错误。非 2022 加密方式不做处理
+func prepareShadowsocksSettings(settings map[string]any) error {
+	method, _ := settings["method"].(string)
+	if !isSS2022(method) {
+		return nil
+	}
+	if password, _ := settings["password"].(string); password == "" {
+		key, err := GenerateSS2022Key(method)
+		if err != nil {
+			return err
+		}
+		settings["password"] = key
+	} else if err := validateSS2022Key(method, password, "server key"); err != nil {
+		return err
+	}
+
+	clients, _ := settings["clients"].([]any)
+	return prepareShadowsocksClients(method, clients)
+}
+
+// prepareShadowsocksClients 为 2022 多用户入站的客户端生成或校验密钥。Xray 要求多用户模式下用户不设置 method
+func prepareShadowsocksClients(method string, clients []any) error {
+	if !isSS2022(method) {
+		return nil
+	}
+	if len(clients) > 0 && !ss2022MultiUser(method) {
+		return common.NewErrorf("%s does not support multiple users, use a 2022-blake3-aes method", method)
+	}
+	for _, c := range clients {
+		client, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		email, _ := client["email"].(string)
+		if password, _ := client["password"].(string); password == "" {
+			key, err := GenerateSS2022Key(method)
+			if err != nil {
+				return err
+			}
+			client["password"] = key
+		} else if err := validateSS2022Key(method, password, "key of client "+email); err != nil {
+			return err
+		}
+		if _, ok := client["method"]; ok {
+			client["method"] = ""
+		}
+	}
+	return nil
+}
+
+// prepareShadowsocksInbound 补全并校验新建或整体更新的 Shadowsocks 入站设置
+func (s *InboundService) prepareShadowsocksInbound(inbound *model.Inbound) error {
+	if inbound.Protocol != model.Shadowsocks {
+		return nil
+	}
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return err
+	}
+	method, _ := settings["method"].(string)
+	if !isSS2022(method) {
+		return nil
+	}
+	if err := prepareShadowsocksSettings(settings); err != nil {
+		return err
+	}
+	modified, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	inbound.Settings = string(modified)
+	return nil
+}
+
+// shadowsocksMethod 读取 Shadowsocks 入站的加密方式
+func shadowsocksMethod(inbound *model.Inbound) string {
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return ""
+	}
+	method, _ := settings["method"].(string)
+	return method
+}
+
+// prepareUpdatedShadowsocksClient 更新 SS2022 客户端时沿用未携带的旧密钥并校验新密钥，
+// 使通过 API 下发给 Xray 的用户密钥与保存的设置一致
+func (s *InboundService) prepareUpdatedShadowsocksClient(data *model.Inbound, clientId string) error {
+	oldInbound, err := s.GetInbound(data.Id)
+	if err != nil {
+		return err
+	}
+	method := shadowsocksMethod(oldInbound)
+	if oldInbound.Protocol != model.Shadowsocks || !isSS2022(method) {
+		return nil
+	}
+	oldClients, err := s.GetClients(oldInbound)
+	if err != nil {
+		return err
+	}
+	var newSettings map[string]any
+	if err := json.Unmarshal([]byte(data.Settings), &newSettings); err != nil {
+		return err
+	}
+	newClients, _ := newSettings["clients"].([]any)
+	for _, c := range newClients {
+		client, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if password, _ := client["password"].(string); password != "" {
+			continue
+		}
+		for _, old := range oldClients {
+			if old.Email == clientId {
+				client["password"] = old.Password
+			}
+		}
+	}
+	if err := prepareShadowsocksClients(method, newClients); err != nil {
+		return err
+	}
+	modified, err := json.Marshal(newSettings)
+	if err != nil {
+		return err
+	}
+	data.Settings = string(modified)
+	return nil
+}
+
+// RotateShadowsocksKey 为 Shadowsocks 2022 入站更换服务端密钥，rotateClients 为 true 时同时更换所有用户密钥。
+// 分享链接包含服务端密钥，更换后所有用户需要重新获取链接，返回受影响的客户端 email
+func (s *InboundService) RotateShadowsocksKey(inboundId int, rotateClients bool) ([]string, error) {
+	inbound, err := s.GetInbound(inboundId)
+	if err != nil {
+		return nil, err
+	}
+	if inbound.Protocol != model.Shadowsocks {
+		return nil, common.NewError("inbound is not shadowsocks: ", inbound.Tag)
+	}
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return nil, err
+	}
+	method, _ := settings["method"].(string)
+	if !isSS2022(method) {
+		return nil, common.NewError("key rotation requires a shadowsocks 2022 method, got ", method)
+	}
+
+	key, err := GenerateSS2022Key(method)
+	if err != nil {
+		return nil, err
+	}
+	settings["password"] = key
+
+	var emails []string
+	clients, _ := settings["clients"].([]any)
+	for _, c := range clients {
+		client, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if rotateClients {
+			client["password"] = ""
+		}
+		if email, _ := client["email"].(string); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if err := prepareShadowsocksSettings(settings); err != nil {
+		return nil, err
+	}
+
+	modified, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	inbound.Settings = string(modified)
+	s.invalidateSettingsCache(inbound.Id)
+	if err := s.getInboundRepo().GetDB().Model(model.Inbound{}).Where("id = ?", inbound.Id).
+		Update("settings", inbound.Settings).Error; err != nil {
+		return nil, err
+	}
+	logger.Infof("Shadowsocks 2022 key of inbound %s rotated, %d client links re-issued", inbound.Tag, len(emails))
+	return emails, nil
+}
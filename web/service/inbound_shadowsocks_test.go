@@ -0,0 +1,165 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"x-ui/database/model"
+)
+
+func TestGenerateSS2022Key(t *testing.T) {
+	for method, size := range ss2022KeySizes {
+		key, err := GenerateSS2022Key(method)
+		if err != nil {
+			t.Fatalf("GenerateSS2022Key(%s) failed: %v", method, err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(decoded) != size {
+			t.Errorf("%s key %q decodes to %d bytes, want %d", method, key, len(decoded), size)
+		}
+		if err := validateSS2022Key(method, key, "key"); err != nil {
+			t.Errorf("generated key rejected: %v", err)
+		}
+	}
+	if _, err := GenerateSS2022Key("aes-256-gcm"); err == nil {
+		t.Error("expected error for non-2022 method")
+	}
+}
+
+func TestPrepareShadowsocksSettings(t *testing.T) {
+	settings := map[string]any{
+		"method": "2022-blake3-aes-128-gcm",
+		"clients": []any{
+			map[string]any{"email": "alice", "method": "2022-blake3-aes-128-gcm"},
+		},
+	}
+	if err := prepareShadowsocksSettings(settings); err != nil {
+		t.Fatalf("prepareShadowsocksSettings failed: %v", err)
+	}
+	if err := validateSS2022Key("2022-blake3-aes-128-gcm", settings["password"].(string), "server key"); err != nil {
+		t.Errorf("server key not generated: %v", err)
+	}
+	alice := settings["clients"].([]any)[0].(map[string]any)
+	if err := validateSS2022Key("2022-blake3-aes-128-gcm", alice["password"].(string), "alice"); err != nil {
+		t.Errorf("client key not generated: %v", err)
+	}
+	if alice["method"] != "" {
+		t.Errorf("client method should be cleared for 2022, got %v", alice["method"])
+	}
+
+	// 32 字节的密钥不能用于 aes-128
+	key256, _ := GenerateSS2022Key("2022-blake3-aes-256-gcm")
+	wrongServer := map[string]any{"method": "2022-blake3-aes-128-gcm", "password": key256}
+	if err := prepareShadowsocksSettings(wrongServer); err == nil {
+		t.Error("expected server key length error")
+	}
+	wrongClient := map[string]any{
+		"method":  "2022-blake3-aes-256-gcm",
+		"clients": []any{map[string]any{"email": "bob", "password": "short"}},
+	}
+	if err := prepareShadowsocksSettings(wrongClient); err == nil {
+		t.Error("expected client key error")
+	}
+	chacha := map[string]any{
+		"method":  "2022-blake3-chacha20-poly1305",
+		"clients": []any{map[string]any{"email": "carol"}},
+	}
+	if err := prepareShadowsocksSettings(chacha); err == nil {
+		t.Error("expected multi-user error for chacha20")
+	}
+
+	// 经典加密方式不受影响
+	classic := map[string]any{"method": "aes-256-gcm", "password": "anything"}
+	if err := prepareShadowsocksSettings(classic); err != nil || classic["password"] != "anything" {
+		t.Errorf("classic settings changed: %v, %v", classic, err)
+	}
+}
+
+func TestInboundService_ShadowsocksKeys(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	method := "2022-blake3-aes-256-gcm"
+	inbound := &model.Inbound{
+		Tag:      "ss-in",
+		Protocol: model.Shadowsocks,
+		Port:     8388,
+		Enable:   true,
+		Settings: `{"method":"2022-blake3-aes-256-gcm","network":"tcp,udp","clients":[{"email":"alice","enable":true}]}`,
+	}
+	if _, _, err := s.AddInbound(inbound); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+
+	load := func() (map[string]any, map[string]string) {
+		stored, err := s.GetInbound(inbound.Id)
+		if err != nil {
+			t.Fatalf("GetInbound failed: %v", err)
+		}
+		var settings map[string]any
+		_ = json.Unmarshal([]byte(stored.Settings), &settings)
+		keys := make(map[string]string)
+		clients, _ := s.GetClients(stored)
+		for _, c := range clients {
+			keys[c.Email] = c.Password
+		}
+		return settings, keys
+	}
+
+	if _, err := s.AddInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"bob","enable":true}]}`,
+	}); err != nil {
+		t.Fatalf("AddInboundClient failed: %v", err)
+	}
+	settings, keys := load()
+	serverKey := settings["password"].(string)
+	for email, key := range keys {
+		if err := validateSS2022Key(method, key, email); err != nil {
+			t.Errorf("client %s key invalid: %v", email, err)
+		}
+	}
+
+	if _, err := s.AddInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"carol","password":"dG9vIHNob3J0","enable":true}]}`,
+	}); err == nil {
+		t.Error("expected error for a wrongly sized client key")
+	}
+
+	// 更新时未携带密钥，应沿用原值
+	if _, err := s.UpdateInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"bob","enable":true,"totalGB":1024}]}`,
+	}, "bob"); err != nil {
+		t.Fatalf("UpdateInboundClient failed: %v", err)
+	}
+	_, updated := load()
+	if updated["bob"] != keys["bob"] {
+		t.Error("bob key changed on update")
+	}
+
+	emails, err := s.RotateShadowsocksKey(inbound.Id, false)
+	if err != nil {
+		t.Fatalf("RotateShadowsocksKey failed: %v", err)
+	}
+	if len(emails) != 2 {
+		t.Errorf("expected 2 affected clients, got %v", emails)
+	}
+	rotated, rotatedKeys := load()
+	if rotated["password"] == serverKey {
+		t.Error("server key not rotated")
+	}
+	if rotatedKeys["alice"] != keys["alice"] {
+		t.Error("client keys should be kept unless requested")
+	}
+
+	if _, err := s.RotateShadowsocksKey(inbound.Id, true); err != nil {
+		t.Fatalf("RotateShadowsocksKey with clients failed: %v", err)
+	}
+	_, rotatedKeys = load()
+	if rotatedKeys["alice"] == keys["alice"] {
+		t.Error("client key not rotated")
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"encoding/json"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"x-ui/database/model"
+	"x-ui/util/common"
+	json_util "x-ui/util/json_util"
+	"x-ui/util/random"
+	"x-ui/util/wireguard"
+	"x-ui/xray"
+)
+
+// wireguardDefaultPool 未配置 addressPool 时使用的地址池，与面板默认的 10.0.0.x peer 地址一致
+const wireguardDefaultPool = "10.0.0.0/24"
+
+// wireguardPeerOutboundPrefix peer 流量统计出站的标签前缀，标签为前缀加 peer 的 email
+const wireguardPeerOutboundPrefix = "wg-peer:"
+
+// wireguardPanelFields 仅面板使用、不写入 Xray 配置的 wireguard 入站设置
+var wireguardPanelFields = []string{"clients", "addressPool", "dns"}
+
+// =============================================================================
+// Peer 准备
+// =============================================================================
+
+// prepareWireguardServer 补全服务端私钥和地址池，返回地址池
+func prepareWireguardServer(settings map[string]any) (string, error) {
+	if secretKey, _ := settings["secretKey"].(string); secretKey == "" {
+		privateKey, _, err := wireguard.GenerateKeyPair()
+		if err != nil {
+			return "", err
+		}
+		settings["secretKey"] = privateKey
+	} else if _, err := wireguard.PublicKey(secretKey); err != nil {
+		return "", common.NewError("invalid wireguard secretKey")
+	}
+
+	pool, _ := settings["addressPool"].(string)
+	if pool == "" {
+		pool = wireguardDefaultPool
+		settings["addressPool"] = pool
+	}
+	if _, err := wireguard.ServerAddress(pool); err != nil {
+		return "", common.NewErrorf("invalid wireguard address pool %s: %v", pool, err)
+	}
+	return pool, nil
+}
+
+// prepareWireguardSettings 补全 wireguard 入站设置：服务端密钥、地址池以及每个 peer 的密钥、地址、email 和 subId。
+// 旧版写在 peers 中的 peer 会合并到 clients，之后按客户端管理
+func prepareWireguardSettings(settings map[string]any) error {
+	pool, err := prepareWireguardServer(settings)
+	if err != nil {
+		return err
+	}
+	clients, _ := settings["clients"].([]any)
+	if peers, ok := settings["peers"].([]any); ok {
+		clients = append(clients, peers...)
+	}
+	delete(settings, "peers")
+	if clients == nil {
+		clients = []any{}
+	}
+	if err := prepareWireguardPeers(clients, pool, nil); err != nil {
+		return err
+	}
+	settings["clients"] = clients
+	return nil
+}
+
+// prepareWireguardPeers 为 peers 生成缺失的密钥对并从地址池分配地址，used 为已被其他 peer 占用的地址
+func prepareWireguardPeers(peers []any, pool string, used []string) error {
+	taken := make(map[netip.Addr]bool)
+	for _, u := range used {
+		if prefix, err := netip.ParsePrefix(u); err == nil {
+			taken[prefix.Addr()] = true
+		}
+	}
+
+	// 先登记显式指定的地址，再为其余 peer 分配，避免自动分配与之冲突
+	var pending []map[string]any
+	for _, p := range peers {
+		peer, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		addresses := wireguardAddresses(peer["allowedIPs"])
+		if len(addresses) == 0 {
+			pending = append(pending, peer)
+			continue
+		}
+		for _, address := range addresses {
+			prefix, err := netip.ParsePrefix(address)
+			if err != nil {
+				return common.NewErrorf("invalid wireguard peer address %s", address)
+			}
+			if taken[prefix.Addr()] {
+				return common.NewErrorf("wireguard peer address %s is already assigned", address)
+			}
+			taken[prefix.Addr()] = true
+			used = append(used, address)
+		}
+		if err := prepareWireguardPeer(peer); err != nil {
+			return err
+		}
+	}
+
+	for _, peer := range pending {
+		address, err := wireguard.AllocateAddress(pool, used)
+		if err != nil {
+			return err
+		}
+		used = append(used, address)
+		peer["allowedIPs"] = []any{address}
+		if err := prepareWireguardPeer(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareWireguardPeer 补全单个 peer 的密钥和客户端字段
+func prepareWireguardPeer(peer map[string]any) error {
+	privateKey, _ := peer["privateKey"].(string)
+	publicKey, _ := peer["publicKey"].(string)
+	switch {
+	case privateKey == "" && publicKey == "":
+		var err error
+		if privateKey, publicKey, err = wireguard.GenerateKeyPair(); err != nil {
+			return err
+		}
+		peer["privateKey"] = privateKey
+		peer["publicKey"] = publicKey
+	case privateKey != "":
+		// 公钥始终由私钥推导，避免两者不匹配
+		derived, err := wireguard.PublicKey(privateKey)
+		if err != nil {
+			return common.NewErrorf("invalid private key for wireguard peer %v", peer["email"])
+		}
+		peer["publicKey"] = derived
+	}
+	// 仅提供公钥时私钥保存在用户设备上，此时无法导出客户端配置
+
+	if email, _ := peer["email"].(string); email == "" {
+		peer["email"] = "wg-" + random.LowerNumSeq(8)
+	}
+	if subId, _ := peer["subId"].(string); subId == "" {
+		peer["subId"] = random.LowerNumSeq(16)
+	}
+	if _, ok := peer["enable"]; !ok {
+		peer["enable"] = true
+	}
+	return nil
+}
+
+// inheritWireguardPeer 更新客户端时沿用旧 peer 的密钥和地址，避免未携带这些字段时重新生成
+func inheritWireguardPeer(newPeer map[string]any, oldPeer map[string]any) {
+	for _, key := range []string{"privateKey", "publicKey", "preSharedKey", "allowedIPs"} {
+		if _, ok := newPeer[key]; ok {
+			continue
+		}
+		if value, ok := oldPeer[key]; ok {
+			newPeer[key] = value
+		}
+	}
+}
+
+// wireguardAddresses 读取 allowedIPs，单个地址补全为 /32 或 /128 前缀
+func wireguardAddresses(value any) []string {
+	var addresses []string
+	switch v := value.(type) {
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s != "" {
+				addresses = append(addresses, s)
+			}
+		}
+	case []string:
+		addresses = append(addresses, v...)
+	}
+	for i, address := range addresses {
+		if addr, err := netip.ParseAddr(address); err == nil {
+			addresses[i] = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+	}
+	return addresses
+}
+
+// prepareWireguardInbound 补全新建或整体更新的 wireguard 入站设置
+func (s *InboundService) prepareWireguardInbound(inbound *model.Inbound) error {
+	if inbound.Protocol != model.WireGuard {
+		return nil
+	}
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return err
+	}
+	if err := prepareWireguardSettings(settings); err != nil {
+		return err
+	}
+	modified, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	inbound.Settings = string(modified)
+	return nil
+}
+
+// prepareWireguardClients 为添加到 wireguard 入站的新 peer 生成密钥、分配地址，避开已有 peer 占用的地址
+func prepareWireguardClients(oldInbound *model.Inbound, newClients []any) error {
+	var oldSettings map[string]any
+	if err := json.Unmarshal([]byte(oldInbound.Settings), &oldSettings); err != nil {
+		return err
+	}
+	pool, err := prepareWireguardServer(oldSettings)
+	if err != nil {
+		return err
+	}
+
+	var used []string
+	for _, key := range []string{"clients", "peers"} {
+		peers, _ := oldSettings[key].([]any)
+		for _, p := range peers {
+			if peer, ok := p.(map[string]any); ok {
+				used = append(used, wireguardAddresses(peer["allowedIPs"])...)
+			}
+		}
+	}
+	return prepareWireguardPeers(newClients, pool, used)
+}
+
+// =============================================================================
+// Xray 配置
+// =============================================================================
+
+// wireguardPeer 将 wireguard 客户端转换为 Xray peers 项，只保留 Xray 识别的字段
+func wireguardPeer(client map[string]any) map[string]any {
+	peer := map[string]any{
+		"publicKey":  client["publicKey"],
+		"allowedIPs": wireguardAddresses(client["allowedIPs"]),
+	}
+	if psk, _ := client["preSharedKey"].(string); psk != "" {
+		peer["preSharedKey"] = psk
+	}
+	if keepAlive, _ := client["keepAlive"].(float64); keepAlive > 0 {
+		peer["keepAlive"] = int(keepAlive)
+	}
+	return peer
+}
+
+// applyWireguardPeers 用启用的 peer 替换 settings 中的客户端列表并移除面板字段
+func applyWireguardPeers(settings map[string]any, peers []any) {
+	for _, field := range wireguardPanelFields {
+		delete(settings, field)
+	}
+	if peers == nil {
+		peers = []any{}
+	}
+	settings["peers"] = peers
+}
+
+// =============================================================================
+// Peer 流量统计
+// =============================================================================
+
+// wireguardPeerRoute 需要统计流量的 peer。Xray 不提供 peer 级别的流量统计，
+// 来自 peer 隧道地址的连接先交给以 peer 命名的 loopback 出站计数，再以 loopback 入站标签重新路由
+type wireguardPeerRoute struct {
+	InboundTag string
+	Email      string
+	Addresses  []string
+}
+
+// wireguardLoopbackTag peer 连接经 loopback 出站重新路由时使用的入站标签
+func wireguardLoopbackTag(inboundTag string) string {
+	return inboundTag + "-peers"
+}
+
+// wireguardPeerRouteOf 返回 peer 的流量统计路由，没有 email 或地址的 peer 无法统计
+func wireguardPeerRouteOf(inboundTag string, client map[string]any) (wireguardPeerRoute, bool) {
+	email, _ := client["email"].(string)
+	addresses := wireguardAddresses(client["allowedIPs"])
+	return wireguardPeerRoute{InboundTag: inboundTag, Email: email, Addresses: addresses}, email != "" && len(addresses) > 0
+}
+
+// applyWireguardPeerStats 为每个 peer 添加 loopback 出站和按隧道地址分流的路由规则，规则置于模板规则之前。
+// 模板中匹配 wireguard 入站标签的规则同时匹配 loopback 入站标签，使 peer 的连接在重新路由后仍按原规则处理
+func applyWireguardPeerStats(config *xray.Config, routes []wireguardPeerRoute) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	var outbounds []any
+	if len(config.OutboundConfigs) > 0 && string(config.OutboundConfigs) != "null" {
+		if err := json.Unmarshal(config.OutboundConfigs, &outbounds); err != nil {
+			return common.NewError("invalid outbounds in template:", err)
+		}
+	}
+	routing := make(map[string]any)
+	if len(config.RouterConfig) > 0 && string(config.RouterConfig) != "null" {
+		if err := json.Unmarshal(config.RouterConfig, &routing); err != nil {
+			return common.NewError("invalid routing in template:", err)
+		}
+	}
+
+	loopbackTags := make(map[string]string)
+	rules := make([]any, 0, len(routes))
+	for _, route := range routes {
+		loopbackTags[route.InboundTag] = wireguardLoopbackTag(route.InboundTag)
+		tag := wireguardPeerOutboundPrefix + route.Email
+		outbounds = append(outbounds, map[string]any{
+			"tag":      tag,
+			"protocol": "loopback",
+			"settings": map[string]any{"inboundTag": wireguardLoopbackTag(route.InboundTag)},
+		})
+		rules = append(rules, map[string]any{
+			"type":        "field",
+			"inboundTag":  []string{route.InboundTag},
+			"source":      route.Addresses,
+			"outboundTag": tag,
+		})
+	}
+
+	existing, _ := routing["rules"].([]any)
+	for _, r := range existing {
+		rule, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		inboundTags, _ := rule["inboundTag"].([]any)
+		for _, t := range inboundTags {
+			name, _ := t.(string)
+			if loopback, ok := loopbackTags[name]; ok && !slices.Contains(inboundTags, any(loopback)) {
+				inboundTags = append(inboundTags, loopback)
+			}
+		}
+		if len(inboundTags) > 0 {
+			rule["inboundTag"] = inboundTags
+		}
+	}
+	routing["rules"] = append(rules, existing...)
+
+	data, err := json.Marshal(outbounds)
+	if err != nil {
+		return err
+	}
+	config.OutboundConfigs = json_util.RawMessage(data)
+	if data, err = json.Marshal(routing); err != nil {
+		return err
+	}
+	config.RouterConfig = json_util.RawMessage(data)
+	return nil
+}
+
+// splitWireguardPeerTraffic 将 peer 流量统计出站的流量转为按 email 的客户端流量，其余流量原样返回
+func splitWireguardPeerTraffic(traffic []*xray.Traffic) ([]*xray.Traffic, []*xray.ClientTraffic) {
+	var others []*xray.Traffic
+	var peers []*xray.ClientTraffic
+	for _, t := range traffic {
+		if email, ok := strings.CutPrefix(t.Tag, wireguardPeerOutboundPrefix); ok && t.IsOutbound {
+			peers = append(peers, &xray.ClientTraffic{Email: email, Up: t.Up, Down: t.Down})
+			continue
+		}
+		others = append(others, t)
+	}
+	return others, peers
+}
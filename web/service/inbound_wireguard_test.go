@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/util/wireguard"
+	"x-ui/xray"
+)
+
+func TestPrepareWireguardSettings(t *testing.T) {
+	peerKey, _, _ := wireguard.GenerateKeyPair()
+	settings := map[string]any{
+		"mtu": float64(1420),
+		"peers": []any{
+			map[string]any{"privateKey": peerKey, "publicKey": "stale", "allowedIPs": []any{"10.0.0.2/32"}},
+		},
+		"clients": []any{
+			map[string]any{"email": "alice"},
+		},
+	}
+	if err := prepareWireguardSettings(settings); err != nil {
+		t.Fatalf("prepareWireguardSettings failed: %v", err)
+	}
+	if _, ok := settings["peers"]; ok {
+		t.Error("legacy peers should be merged into clients")
+	}
+	if settings["addressPool"] != wireguardDefaultPool {
+		t.Errorf("addressPool = %v, want default", settings["addressPool"])
+	}
+	secretKey, _ := settings["secretKey"].(string)
+	if _, err := wireguard.PublicKey(secretKey); err != nil {
+		t.Errorf("secretKey not generated: %v", err)
+	}
+
+	clients := settings["clients"].([]any)
+	if len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %d", len(clients))
+	}
+	alice := clients[0].(map[string]any)
+	if got := wireguardAddresses(alice["allowedIPs"]); len(got) != 1 || got[0] != "10.0.0.3/32" {
+		t.Errorf("alice allowedIPs = %v, want 10.0.0.3/32", got)
+	}
+	if alice["privateKey"] == "" || alice["publicKey"] == "" || alice["subId"] == "" || alice["enable"] != true {
+		t.Errorf("alice not fully prepared: %v", alice)
+	}
+	legacy := clients[1].(map[string]any)
+	wantPub, _ := wireguard.PublicKey(peerKey)
+	if legacy["publicKey"] != wantPub {
+		t.Errorf("legacy publicKey = %v, want derived %s", legacy["publicKey"], wantPub)
+	}
+	if email, _ := legacy["email"].(string); email == "" {
+		t.Error("legacy peer should get an email")
+	}
+
+	duplicate := map[string]any{
+		"clients": []any{
+			map[string]any{"email": "a", "allowedIPs": []any{"10.0.0.5"}},
+			map[string]any{"email": "b", "allowedIPs": []any{"10.0.0.5/32"}},
+		},
+	}
+	if err := prepareWireguardSettings(duplicate); err == nil {
+		t.Error("expected duplicate address error")
+	}
+}
+
+func TestWireguardPeer(t *testing.T) {
+	settings := map[string]any{"secretKey": "k", "addressPool": "10.0.0.0/24", "dns": "9.9.9.9", "clients": []any{}}
+	peer := wireguardPeer(map[string]any{
+		"email":      "alice",
+		"privateKey": "priv",
+		"publicKey":  "pub",
+		"allowedIPs": []any{"10.0.0.2"},
+		"keepAlive":  float64(25),
+		"subId":      "sub",
+	})
+	applyWireguardPeers(settings, []any{peer})
+
+	data, _ := json.Marshal(settings)
+	var got struct {
+		SecretKey   string           `json:"secretKey"`
+		Peers       []map[string]any `json:"peers"`
+		Clients     any              `json:"clients"`
+		AddressPool any              `json:"addressPool"`
+		DNS         any              `json:"dns"`
+	}
+	_ = json.Unmarshal(data, &got)
+	if got.Clients != nil || got.AddressPool != nil || got.DNS != nil {
+		t.Errorf("panel fields should be removed: %s", data)
+	}
+	if len(got.Peers) != 1 {
+		t.Fatalf("expected 1 peer, got %s", data)
+	}
+	p := got.Peers[0]
+	if p["publicKey"] != "pub" || p["keepAlive"] != float64(25) || p["privateKey"] != nil || p["email"] != nil {
+		t.Errorf("unexpected peer: %v", p)
+	}
+	if ips, _ := p["allowedIPs"].([]any); len(ips) != 1 || ips[0] != "10.0.0.2/32" {
+		t.Errorf("allowedIPs = %v, want [10.0.0.2/32]", p["allowedIPs"])
+	}
+}
+
+func TestApplyWireguardPeerStats(t *testing.T) {
+	config := &xray.Config{
+		OutboundConfigs: []byte(`[{"tag":"direct","protocol":"freedom"}]`),
+		RouterConfig:    []byte(`{"rules":[{"type":"field","inboundTag":["wg-in"],"outboundTag":"warp"},{"type":"field","ip":["geoip:private"],"outboundTag":"blocked"}]}`),
+	}
+	route, ok := wireguardPeerRouteOf("wg-in", map[string]any{"email": "alice", "allowedIPs": []any{"10.0.0.2"}})
+	if !ok {
+		t.Fatal("peer with email and address should be tracked")
+	}
+	if _, ok := wireguardPeerRouteOf("wg-in", map[string]any{"email": "bob"}); ok {
+		t.Error("peer without address cannot be tracked")
+	}
+	if err := applyWireguardPeerStats(config, []wireguardPeerRoute{route}); err != nil {
+		t.Fatal(err)
+	}
+
+	var outbounds []map[string]any
+	_ = json.Unmarshal(config.OutboundConfigs, &outbounds)
+	if len(outbounds) != 2 || outbounds[0]["tag"] != "direct" || outbounds[1]["tag"] != "wg-peer:alice" || outbounds[1]["protocol"] != "loopback" {
+		t.Fatalf("unexpected outbounds: %s", config.OutboundConfigs)
+	}
+	var routing struct {
+		Rules []struct {
+			InboundTag  []string `json:"inboundTag"`
+			Source      []string `json:"source"`
+			OutboundTag string   `json:"outboundTag"`
+		} `json:"rules"`
+	}
+	_ = json.Unmarshal(config.RouterConfig, &routing)
+	if len(routing.Rules) != 3 {
+		t.Fatalf("unexpected rules: %s", config.RouterConfig)
+	}
+	first := routing.Rules[0]
+	if first.OutboundTag != "wg-peer:alice" || len(first.Source) != 1 || first.Source[0] != "10.0.0.2/32" {
+		t.Errorf("peer rule should come first: %+v", first)
+	}
+	// 重新路由后的连接仍应命中模板中匹配 wireguard 入站的规则
+	if tags := routing.Rules[1].InboundTag; len(tags) != 2 || tags[1] != "wg-in-peers" {
+		t.Errorf("template rule should also match the loopback tag: %v", tags)
+	}
+
+	traffic, peers := splitWireguardPeerTraffic([]*xray.Traffic{
+		{IsOutbound: true, Tag: "wg-peer:alice", Up: 1, Down: 2},
+		{IsOutbound: true, Tag: "direct", Up: 3, Down: 4},
+	})
+	if len(traffic) != 1 || traffic[0].Tag != "direct" {
+		t.Errorf("peer outbounds should not be reported as outbound traffic: %+v", traffic)
+	}
+	if len(peers) != 1 || peers[0].Email != "alice" || peers[0].Up != 1 || peers[0].Down != 2 {
+		t.Errorf("unexpected peer traffic: %+v", peers)
+	}
+}
+
+func TestInboundService_WireguardClients(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	inbound := &model.Inbound{
+		Tag:      "wg-in",
+		Protocol: model.WireGuard,
+		Port:     51820,
+		Enable:   true,
+		Settings: `{"mtu":1420,"addressPool":"10.9.0.0/24","clients":[{"email":"alice","subId":"sub-a","enable":true}]}`,
+	}
+	if _, _, err := s.AddInbound(inbound); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+
+	peerByEmail := func(email string) *model.Client {
+		stored, err := s.GetInbound(inbound.Id)
+		if err != nil {
+			t.Fatalf("GetInbound failed: %v", err)
+		}
+		clients, _ := s.GetClients(stored)
+		for i := range clients {
+			if clients[i].Email == email {
+				return &clients[i]
+			}
+		}
+		return nil
+	}
+
+	alice := peerByEmail("alice")
+	if alice == nil || alice.PrivateKey == "" || len(alice.AllowedIPs) != 1 || alice.AllowedIPs[0] != "10.9.0.2/32" {
+		t.Fatalf("alice not prepared on AddInbound: %+v", alice)
+	}
+
+	needRestart, err := s.AddInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"bob","subId":"sub-b","enable":true,"totalGB":1024,"expiryTime":1900000000000}]}`,
+	})
+	if err != nil {
+		t.Fatalf("AddInboundClient failed: %v", err)
+	}
+	if !needRestart {
+		t.Error("adding a wireguard peer should require the inbound to be reapplied")
+	}
+	bob := peerByEmail("bob")
+	if bob == nil || len(bob.AllowedIPs) != 1 || bob.AllowedIPs[0] != "10.9.0.3/32" {
+		t.Fatalf("bob allocation wrong: %+v", bob)
+	}
+
+	var traffic xray.ClientTraffic
+	if err := database.GetDB().Where("email = ?", "bob").First(&traffic).Error; err != nil {
+		t.Fatalf("bob has no client_traffics row: %v", err)
+	}
+	if traffic.Total != 1024 || traffic.ExpiryTime != 1900000000000 {
+		t.Errorf("bob traffic limits not stored: %+v", traffic)
+	}
+
+	// 更新时未携带密钥和地址，应沿用原值
+	if _, err := s.UpdateInboundClient(&model.Inbound{
+		Id:       inbound.Id,
+		Settings: `{"clients":[{"email":"bob","subId":"sub-b","enable":true,"keepAlive":25}]}`,
+	}, "bob"); err != nil {
+		t.Fatalf("UpdateInboundClient failed: %v", err)
+	}
+	updated := peerByEmail("bob")
+	if updated == nil || updated.PrivateKey != bob.PrivateKey || updated.AllowedIPs[0] != bob.AllowedIPs[0] || updated.KeepAlive != 25 {
+		t.Errorf("bob keys or address changed on update: %+v", updated)
+	}
+
+	if _, err := s.DelInboundClient(inbound.Id, "bob"); err != nil {
+		t.Fatalf("DelInboundClient failed: %v", err)
+	}
+	if peerByEmail("bob") != nil {
+		t.Error("bob should be removed")
+	}
+}
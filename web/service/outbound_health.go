@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"x-ui/config"
+	"x-ui/logger"
+	"x-ui/util/common"
+	"x-ui/xray"
+)
+
+// LatencySample 一次观测采样
+type LatencySample struct {
+	Time  int64 `json:"time"`
+	Alive bool  `json:"alive"`
+	Delay int64 `json:"delay"`
+}
+
+// OutboundHealth 出站的最新健康状态及延迟历史
+type OutboundHealth struct {
+	xray.OutboundStatus
+	History []LatencySample `json:"history"`
+}
+
+// BalancerHealth 负载均衡器的健康状态，Dead 表示其全部被观测的出站均不可用
+type BalancerHealth struct {
+	Tag       string   `json:"tag"`
+	Outbounds []string `json:"outbounds"`
+	AliveNum  int      `json:"aliveNum"`
+	Dead      bool     `json:"dead"`
+}
+
+// OutboundHealthReport 出站观测结果汇总
+type OutboundHealthReport struct {
+	Time      int64             `json:"time"`
+	Outbounds []*OutboundHealth `json:"outbounds"`
+	Balancers []*BalancerHealth `json:"balancers,omitempty"`
+}
+
+// CollectOutboundHealth 从运行中 Xray 的观测服务读取各出站状态，记录延迟历史，
+// 并在负载均衡器的出站全部失效或恢复时发送通知
+func (s *XrayService) CollectOutboundHealth() error {
+	if !s.IsXrayRunning() {
+		return common.ErrXrayNotRunning
+	}
+	runningConfig := s.process.GetConfig()
+	if runningConfig == nil || !runningConfig.HasObservatory() {
+		s.resetOutboundHealth()
+		return nil
+	}
+
+	api := xray.XrayAPI{}
+	if err := api.Init(s.process.GetAPIPort()); err != nil {
+		return err
+	}
+	defer api.Close()
+	statuses, err := api.GetOutboundStatus()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range s.updateOutboundHealth(statuses, configBalancers(runningConfig), time.Now()) {
+		logger.Warning(msg)
+		if s.tgService != nil && s.tgService.IsRunning() {
+			_ = s.tgService.SendMessage(msg)
+		}
+	}
+	return nil
+}
+
+// GetOutboundHealth 返回最近一次采集的出站观测结果，未启用观测时返回 nil
+func (s *XrayService) GetOutboundHealth() *OutboundHealthReport {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	if s.healthTime == 0 {
+		return nil
+	}
+
+	report := &OutboundHealthReport{Time: s.healthTime}
+	for _, h := range s.outboundHealth {
+		item := *h
+		item.History = append([]LatencySample(nil), h.History...)
+		report.Outbounds = append(report.Outbounds, &item)
+	}
+	sort.Slice(report.Outbounds, func(i, j int) bool { return report.Outbounds[i].Tag < report.Outbounds[j].Tag })
+	for _, b := range s.balancerHealth {
+		item := *b
+		report.Balancers = append(report.Balancers, &item)
+	}
+	sort.Slice(report.Balancers, func(i, j int) bool { return report.Balancers[i].Tag < report.Balancers[j].Tag })
+	return report
+}
+
+func (s *XrayService) resetOutboundHealth() {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.outboundHealth = nil
+	s.balancerHealth = nil
+	s.healthTime = 0
+}
+
+// updateOutboundHealth 合并新的观测结果并返回需要通知的负载均衡器状态变化
+func (s *XrayService) updateOutboundHealth(statuses []*xray.OutboundStatus, balancers []*Balancer, now time.Time) []string {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if s.outboundHealth == nil {
+		s.outboundHealth = make(map[string]*OutboundHealth)
+	}
+	seen := make(map[string]bool, len(statuses))
+	for _, st := range statuses {
+		seen[st.Tag] = true
+		h, ok := s.outboundHealth[st.Tag]
+		if !ok {
+			h = &OutboundHealth{}
+			s.outboundHealth[st.Tag] = h
+		}
+		h.OutboundStatus = *st
+		h.History = append(h.History, LatencySample{Time: now.Unix(), Alive: st.Alive, Delay: st.Delay})
+		if over := len(h.History) - config.OutboundHealthHistorySize; over > 0 {
+			h.History = h.History[over:]
+		}
+	}
+	// 出站已从观测中移除时丢弃其历史
+	for tag := range s.outboundHealth {
+		if !seen[tag] {
+			delete(s.outboundHealth, tag)
+		}
+	}
+	s.healthTime = now.Unix()
+
+	var alerts []string
+	previous := s.balancerHealth
+	s.balancerHealth = make(map[string]*BalancerHealth, len(balancers))
+	for _, b := range balancers {
+		bh := &BalancerHealth{Tag: b.Tag}
+		for tag, h := range s.outboundHealth {
+			if !matchesSelector(tag, b.Selector) {
+				continue
+			}
+			bh.Outbounds = append(bh.Outbounds, tag)
+			if h.Alive {
+				bh.AliveNum++
+			}
+		}
+		sort.Strings(bh.Outbounds)
+		// 只有存在被观测的出站时才判断负载均衡器是否失效
+		bh.Dead = len(bh.Outbounds) > 0 && bh.AliveNum == 0
+		s.balancerHealth[b.Tag] = bh
+
+		wasDead := previous[b.Tag] != nil && previous[b.Tag].Dead
+		switch {
+		case bh.Dead && !wasDead:
+			alerts = append(alerts, fmt.Sprintf("Balancer %s is down: all outbounds (%s) are unreachable", b.Tag, strings.Join(bh.Outbounds, ", ")))
+		case !bh.Dead && wasDead:
+			alerts = append(alerts, fmt.Sprintf("Balancer %s recovered: %d of %d outbounds alive", b.Tag, bh.AliveNum, len(bh.Outbounds)))
+		}
+	}
+	return alerts
+}
+
+// matchesSelector 判断出站标签是否匹配负载均衡器的前缀选择器
+func matchesSelector(tag string, selectors []string) bool {
+	for _, selector := range selectors {
+		if strings.HasPrefix(tag, selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// configBalancers 解析配置中的负载均衡器
+func configBalancers(c *xray.Config) []*Balancer {
+	var routing struct {
+		Balancers []*Balancer `json:"balancers"`
+	}
+	if len(c.RouterConfig) == 0 || json.Unmarshal(c.RouterConfig, &routing) != nil {
+		return nil
+	}
+	return routing.Balancers
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"x-ui/config"
+	"x-ui/xray"
+)
+
+func TestXrayService_UpdateOutboundHealth(t *testing.T) {
+	s := &XrayService{}
+	balancers := []*Balancer{{Tag: "auto", Selector: []string{"proxy-"}}, {Tag: "idle", Selector: []string{"none-"}}}
+	now := time.Unix(1000, 0)
+
+	alerts := s.updateOutboundHealth([]*xray.OutboundStatus{
+		{Tag: "proxy-a", Alive: true, Delay: 120},
+		{Tag: "proxy-b", Alive: false, LastError: "timeout"},
+		{Tag: "direct", Alive: true, Delay: 5},
+	}, balancers, now)
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected alerts %v", alerts)
+	}
+
+	alerts = s.updateOutboundHealth([]*xray.OutboundStatus{
+		{Tag: "proxy-a", Alive: false, LastError: "timeout"},
+		{Tag: "proxy-b", Alive: false, LastError: "timeout"},
+	}, balancers, now.Add(time.Minute))
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "Balancer auto is down") {
+		t.Fatalf("expected down alert, got %v", alerts)
+	}
+	// 持续失效时不重复通知
+	alerts = s.updateOutboundHealth([]*xray.OutboundStatus{
+		{Tag: "proxy-a", Alive: false},
+		{Tag: "proxy-b", Alive: false},
+	}, balancers, now.Add(2*time.Minute))
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected repeated alerts %v", alerts)
+	}
+	alerts = s.updateOutboundHealth([]*xray.OutboundStatus{
+		{Tag: "proxy-a", Alive: true, Delay: 80},
+		{Tag: "proxy-b", Alive: false},
+	}, balancers, now.Add(3*time.Minute))
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "recovered") {
+		t.Fatalf("expected recovery alert, got %v", alerts)
+	}
+
+	report := s.GetOutboundHealth()
+	if report == nil || len(report.Outbounds) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	a := report.Outbounds[0]
+	if a.Tag != "proxy-a" || a.Delay != 80 || len(a.History) != 4 {
+		t.Errorf("unexpected outbound health %+v", a)
+	}
+	if len(report.Balancers) != 2 || report.Balancers[0].AliveNum != 1 || report.Balancers[1].Dead {
+		t.Errorf("unexpected balancer health %+v %+v", report.Balancers[0], report.Balancers[1])
+	}
+
+	for i := 0; i < config.OutboundHealthHistorySize+10; i++ {
+		s.updateOutboundHealth([]*xray.OutboundStatus{{Tag: "proxy-a", Alive: true}}, nil, now)
+	}
+	if h := s.GetOutboundHealth().Outbounds[0].History; len(h) != config.OutboundHealthHistorySize {
+		t.Errorf("history not trimmed: %d", len(h))
+	}
+}
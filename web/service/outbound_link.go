@@ -0,0 +1,360 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"x-ui/util/common"
+)
+
+// ParsedOutbound 由分享链接解析得到的出站，Remark 取自链接中的备注（# 后的部分）
+type ParsedOutbound struct {
+	Remark   string         `json:"remark"`
+	Outbound map[string]any `json:"outbound"`
+}
+
+// ParseShareLink 将 vless://、vmess://、trojan://、ss:// 分享链接解析为 Xray 出站配置
+func ParseShareLink(link string) (*ParsedOutbound, error) {
+	link = strings.TrimSpace(link)
+	scheme, _, found := strings.Cut(link, "://")
+	if !found {
+		return nil, common.NewErrorf("invalid share link: %s", truncateLink(link))
+	}
+	switch strings.ToLower(scheme) {
+	case "vless":
+		return parseVlessLink(link)
+	case "vmess":
+		return parseVmessLink(link)
+	case "trojan":
+		return parseTrojanLink(link)
+	case "ss":
+		return parseShadowsocksLink(link)
+	default:
+		return nil, common.NewErrorf("unsupported share link scheme: %s", scheme)
+	}
+}
+
+// ParseShareLinks 解析多行分享链接或订阅内容（支持整体 base64 编码），返回成功解析的出站与失败原因
+func ParseShareLinks(content string) ([]*ParsedOutbound, []error) {
+	content = strings.TrimSpace(content)
+	if !strings.Contains(content, "://") {
+		if decoded, err := decodeBase64(content); err == nil {
+			content = string(decoded)
+		}
+	}
+
+	var outbounds []*ParsedOutbound
+	var errs []error
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parsed, err := ParseShareLink(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		outbounds = append(outbounds, parsed)
+	}
+	return outbounds, errs
+}
+
+func parseVlessLink(link string) (*ParsedOutbound, error) {
+	u, host, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	id := u.User.Username()
+	if id == "" {
+		return nil, common.NewError("vless link is missing user id")
+	}
+	q := u.Query()
+	encryption := q.Get("encryption")
+	if encryption == "" {
+		encryption = "none"
+	}
+	user := map[string]any{"id": id, "encryption": encryption}
+	if flow := q.Get("flow"); flow != "" {
+		user["flow"] = flow
+	}
+	return &ParsedOutbound{
+		Remark: u.Fragment,
+		Outbound: map[string]any{
+			"protocol": "vless",
+			"settings": map[string]any{
+				"vnext": []any{map[string]any{"address": host, "port": port, "users": []any{user}}},
+			},
+			"streamSettings": buildLinkStream(q),
+		},
+	}, nil
+}
+
+func parseTrojanLink(link string) (*ParsedOutbound, error) {
+	u, host, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	password := u.User.Username()
+	if password == "" {
+		return nil, common.NewError("trojan link is missing password")
+	}
+	q := u.Query()
+	// trojan 默认使用 TLS
+	if q.Get("security") == "" {
+		q.Set("security", "tls")
+	}
+	return &ParsedOutbound{
+		Remark: u.Fragment,
+		Outbound: map[string]any{
+			"protocol": "trojan",
+			"settings": map[string]any{
+				"servers": []any{map[string]any{"address": host, "port": port, "password": password}},
+			},
+			"streamSettings": buildLinkStream(q),
+		},
+	}, nil
+}
+
+// parseVmessLink 解析 v2rayN 格式的 vmess 链接：vmess://base64(json)
+func parseVmessLink(link string) (*ParsedOutbound, error) {
+	data, err := decodeBase64(strings.TrimPrefix(link[len("vmess://"):], "//"))
+	if err != nil {
+		return nil, common.NewError("invalid vmess link:", err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, common.NewError("invalid vmess link:", err)
+	}
+	str := func(key string) string {
+		switch val := v[key].(type) {
+		case string:
+			return val
+		case float64:
+			return strconv.Itoa(int(val))
+		}
+		return ""
+	}
+	port, err := strconv.Atoi(str("port"))
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, common.NewErrorf("invalid vmess port: %s", str("port"))
+	}
+	if str("add") == "" || str("id") == "" {
+		return nil, common.NewError("vmess link is missing address or id")
+	}
+	security := str("scy")
+	if security == "" {
+		security = "auto"
+	}
+
+	q := url.Values{}
+	q.Set("type", str("net"))
+	q.Set("headerType", str("type"))
+	q.Set("host", str("host"))
+	q.Set("path", str("path"))
+	q.Set("sni", str("sni"))
+	q.Set("alpn", str("alpn"))
+	q.Set("fp", str("fp"))
+	q.Set("security", str("tls"))
+	if str("net") == "grpc" {
+		q.Set("serviceName", str("path"))
+	}
+
+	return &ParsedOutbound{
+		Remark: str("ps"),
+		Outbound: map[string]any{
+			"protocol": "vmess",
+			"settings": map[string]any{
+				"vnext": []any{map[string]any{
+					"address": str("add"),
+					"port":    port,
+					"users":   []any{map[string]any{"id": str("id"), "security": security}},
+				}},
+			},
+			"streamSettings": buildLinkStream(q),
+		},
+	}, nil
+}
+
+// parseShadowsocksLink 支持 SIP002 (ss://base64(method:password)@host:port#remark)
+// 以及旧格式 (ss://base64(method:password@host:port)#remark)
+func parseShadowsocksLink(link string) (*ParsedOutbound, error) {
+	body := link[len("ss://"):]
+	remark := ""
+	if i := strings.Index(body, "#"); i >= 0 {
+		remark, _ = url.PathUnescape(body[i+1:])
+		body = body[:i]
+	}
+	if i := strings.Index(body, "?"); i >= 0 {
+		body = body[:i]
+	}
+	body = strings.TrimSuffix(body, "/")
+
+	userInfo, hostPort, found := strings.Cut(body, "@")
+	if !found {
+		decoded, err := decodeBase64(body)
+		if err != nil {
+			return nil, common.NewError("invalid ss link:", err)
+		}
+		at := strings.LastIndex(string(decoded), "@")
+		if at < 0 {
+			return nil, common.NewError("invalid ss link: missing server")
+		}
+		userInfo, hostPort = string(decoded[:at]), string(decoded[at+1:])
+	} else if decoded, err := decodeBase64(userInfo); err == nil && strings.Contains(string(decoded), ":") {
+		userInfo = string(decoded)
+	} else {
+		userInfo, _ = url.PathUnescape(userInfo)
+	}
+
+	method, password, ok := strings.Cut(userInfo, ":")
+	if !ok || method == "" || password == "" {
+		return nil, common.NewError("invalid ss link: missing method or password")
+	}
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, common.NewError("invalid ss link:", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, common.NewErrorf("invalid ss port: %s", portStr)
+	}
+
+	return &ParsedOutbound{
+		Remark: remark,
+		Outbound: map[string]any{
+			"protocol": "shadowsocks",
+			"settings": map[string]any{
+				"servers": []any{map[string]any{"address": host, "port": port, "method": method, "password": password}},
+			},
+		},
+	}, nil
+}
+
+func parseLinkURL(link string) (*url.URL, string, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, "", 0, common.NewErrorf("invalid share link: %v", err)
+	}
+	host := u.Hostname()
+	port, err := strconv.Atoi(u.Port())
+	if host == "" || err != nil || port <= 0 || port > 65535 {
+		return nil, "", 0, common.NewErrorf("invalid server address in link: %s", truncateLink(link))
+	}
+	return u, host, port, nil
+}
+
+// buildLinkStream 根据分享链接中的 type/security 等参数生成 streamSettings
+func buildLinkStream(q url.Values) map[string]any {
+	network := q.Get("type")
+	if network == "" {
+		network = "tcp"
+	}
+	stream := map[string]any{"network": network}
+
+	host, path := q.Get("host"), q.Get("path")
+	switch network {
+	case "tcp", "raw":
+		if q.Get("headerType") == "http" {
+			request := map[string]any{}
+			if path != "" {
+				request["path"] = strings.Split(path, ",")
+			}
+			if host != "" {
+				request["headers"] = map[string]any{"Host": strings.Split(host, ",")}
+			}
+			stream["tcpSettings"] = map[string]any{"header": map[string]any{"type": "http", "request": request}}
+		}
+	case "kcp", "mkcp":
+		kcp := map[string]any{}
+		if headerType := q.Get("headerType"); headerType != "" {
+			kcp["header"] = map[string]any{"type": headerType}
+		}
+		if seed := q.Get("seed"); seed != "" {
+			kcp["seed"] = seed
+		}
+		stream["kcpSettings"] = kcp
+	case "ws":
+		stream["wsSettings"] = map[string]any{"path": path, "host": host}
+	case "grpc":
+		grpc := map[string]any{"serviceName": q.Get("serviceName")}
+		if authority := q.Get("authority"); authority != "" {
+			grpc["authority"] = authority
+		}
+		if q.Get("mode") == "multi" {
+			grpc["multiMode"] = true
+		}
+		stream["grpcSettings"] = grpc
+	case "httpupgrade":
+		stream["httpupgradeSettings"] = map[string]any{"path": path, "host": host}
+	case "xhttp", "splithttp":
+		stream["network"] = "xhttp"
+		xhttp := map[string]any{"path": path, "host": host}
+		if mode := q.Get("mode"); mode != "" {
+			xhttp["mode"] = mode
+		}
+		stream["xhttpSettings"] = xhttp
+	}
+
+	switch security := q.Get("security"); security {
+	case "tls":
+		stream["security"] = "tls"
+		tls := map[string]any{}
+		if sni := q.Get("sni"); sni != "" {
+			tls["serverName"] = sni
+		}
+		if fp := q.Get("fp"); fp != "" {
+			tls["fingerprint"] = fp
+		}
+		if alpn := q.Get("alpn"); alpn != "" {
+			tls["alpn"] = strings.Split(alpn, ",")
+		}
+		stream["tlsSettings"] = tls
+	case "reality":
+		stream["security"] = "reality"
+		reality := map[string]any{
+			"serverName": q.Get("sni"),
+			"publicKey":  q.Get("pbk"),
+			"shortId":    q.Get("sid"),
+		}
+		if fp := q.Get("fp"); fp != "" {
+			reality["fingerprint"] = fp
+		} else {
+			reality["fingerprint"] = "chrome"
+		}
+		if spx := q.Get("spx"); spx != "" {
+			reality["spiderX"] = spx
+		}
+		if pqv := q.Get("pqv"); pqv != "" {
+			reality["mldsa65Verify"] = pqv
+		}
+		stream["realitySettings"] = reality
+	default:
+		stream["security"] = "none"
+	}
+	return stream
+}
+
+// decodeBase64 兼容标准与 URL 安全的 base64，以及有无填充两种形式
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	s = strings.NewReplacer("\n", "", "\r", "").Replace(s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, common.NewError("invalid base64 content")
+}
+
+// truncateLink 避免在错误信息中输出完整的凭据
+func truncateLink(link string) string {
+	if len(link) > 24 {
+		return link[:24] + "..."
+	}
+	return link
+}
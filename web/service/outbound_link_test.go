@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseShareLink_Vless(t *testing.T) {
+	link := "vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?type=grpc&serviceName=svc&mode=multi&security=reality&sni=www.example.com&fp=chrome&pbk=PUBKEY&sid=ab&flow=xtls-rprx-vision#My%20Node"
+	parsed, err := ParseShareLink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Remark != "My Node" || parsed.Outbound["protocol"] != "vless" {
+		t.Fatalf("unexpected result %+v", parsed)
+	}
+	vnext := parsed.Outbound["settings"].(map[string]any)["vnext"].([]any)[0].(map[string]any)
+	if vnext["address"] != "example.com" || vnext["port"] != 443 {
+		t.Errorf("unexpected server %+v", vnext)
+	}
+	user := vnext["users"].([]any)[0].(map[string]any)
+	if user["flow"] != "xtls-rprx-vision" || user["encryption"] != "none" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	stream := parsed.Outbound["streamSettings"].(map[string]any)
+	if stream["network"] != "grpc" || stream["security"] != "reality" {
+		t.Errorf("unexpected stream %+v", stream)
+	}
+	if grpc := stream["grpcSettings"].(map[string]any); grpc["multiMode"] != true || grpc["serviceName"] != "svc" {
+		t.Errorf("unexpected grpc settings %+v", grpc)
+	}
+	if reality := stream["realitySettings"].(map[string]any); reality["publicKey"] != "PUBKEY" || reality["shortId"] != "ab" {
+		t.Errorf("unexpected reality settings %+v", reality)
+	}
+}
+
+func TestParseShareLink_Vmess(t *testing.T) {
+	body := `{"v":"2","ps":"hk","add":"1.2.3.4","port":"8080","id":"b831381d-6324-4d53-ad4f-8cda48b30811","net":"ws","host":"cdn.example.com","path":"/ws","tls":"tls","sni":"cdn.example.com"}`
+	parsed, err := ParseShareLink("vmess://" + base64.StdEncoding.EncodeToString([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Remark != "hk" || parsed.Outbound["protocol"] != "vmess" {
+		t.Fatalf("unexpected result %+v", parsed)
+	}
+	stream := parsed.Outbound["streamSettings"].(map[string]any)
+	ws := stream["wsSettings"].(map[string]any)
+	if ws["path"] != "/ws" || ws["host"] != "cdn.example.com" || stream["security"] != "tls" {
+		t.Errorf("unexpected stream %+v", stream)
+	}
+}
+
+func TestParseShareLink_TrojanAndShadowsocks(t *testing.T) {
+	parsed, err := ParseShareLink("trojan://secret@example.com:443?sni=example.com#t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream := parsed.Outbound["streamSettings"].(map[string]any); stream["security"] != "tls" {
+		t.Errorf("trojan should default to tls, got %+v", stream)
+	}
+
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte("aes-256-gcm:pass"))
+	for _, link := range []string{
+		"ss://" + userInfo + "@example.com:8388#ss1",
+		"ss://2022-blake3-aes-128-gcm:a2V5@example.com:8388#ss1",
+		"ss://" + base64.StdEncoding.EncodeToString([]byte("aes-256-gcm:pass@example.com:8388")) + "#ss1",
+	} {
+		parsed, err := ParseShareLink(link)
+		if err != nil {
+			t.Fatalf("%s: %v", link, err)
+		}
+		server := parsed.Outbound["settings"].(map[string]any)["servers"].([]any)[0].(map[string]any)
+		if server["address"] != "example.com" || server["port"] != 8388 || server["method"] == "" || parsed.Remark != "ss1" {
+			t.Errorf("%s: unexpected server %+v", link, server)
+		}
+	}
+
+	for _, link := range []string{"http://example.com", "vless://@example.com:443", "ss://bm90LWEtbGluaw", "vless://id@example.com"} {
+		if _, err := ParseShareLink(link); err == nil {
+			t.Errorf("expected %q to be rejected", link)
+		}
+	}
+}
+
+func TestParseShareLinks_Base64Subscription(t *testing.T) {
+	content := "trojan://a@example.com:443#one\n\ninvalid-line\nvless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443#two\n"
+	parsed, errs := ParseShareLinks(base64.StdEncoding.EncodeToString([]byte(content)))
+	if len(parsed) != 2 || len(errs) != 1 {
+		t.Fatalf("expected 2 parsed and 1 error, got %d and %d", len(parsed), len(errs))
+	}
+}
@@ -0,0 +1,661 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/logger"
+	"x-ui/util/common"
+)
+
+const (
+	// outboundSubscriptionTimeout 拉取远程订阅的超时时间
+	outboundSubscriptionTimeout = 30 * time.Second
+	// outboundSubscriptionMaxSize 远程订阅内容的最大字节数
+	outboundSubscriptionMaxSize = 4 << 20
+)
+
+var (
+	tagPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	tagUnsafeChars   = regexp.MustCompile(`[^\p{L}\p{N}_.-]+`)
+)
+
+// Outbound 模板中的一个出站，Subscription 为来源订阅的 ID（手动添加的出站为 0）
+type Outbound struct {
+	Tag          string          `json:"tag"`
+	Protocol     string          `json:"protocol"`
+	Subscription int             `json:"subscription"`
+	Config       json.RawMessage `json:"config"`
+}
+
+// outboundMeta 出站中与标签引用相关的字段
+type outboundMeta struct {
+	Tag           string `json:"tag"`
+	Protocol      string `json:"protocol"`
+	ProxySettings *struct {
+		Tag string `json:"tag"`
+	} `json:"proxySettings"`
+	StreamSettings *struct {
+		Sockopt *struct {
+			DialerProxy string `json:"dialerProxy"`
+		} `json:"sockopt"`
+	} `json:"streamSettings"`
+}
+
+// chainTags 返回出站通过 proxySettings.tag 或 sockopt.dialerProxy 引用的前置出站
+func (m *outboundMeta) chainTags() []string {
+	var tags []string
+	if m.ProxySettings != nil && m.ProxySettings.Tag != "" {
+		tags = append(tags, m.ProxySettings.Tag)
+	}
+	if m.StreamSettings != nil && m.StreamSettings.Sockopt != nil && m.StreamSettings.Sockopt.DialerProxy != "" {
+		tags = append(tags, m.StreamSettings.Sockopt.DialerProxy)
+	}
+	return tags
+}
+
+func decodeOutboundMeta(raw json.RawMessage) (*outboundMeta, error) {
+	meta := &outboundMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return nil, common.NewError("invalid outbound:", err)
+	}
+	return meta, nil
+}
+
+func (t *routingTemplate) outbounds() ([]json.RawMessage, error) {
+	var list []json.RawMessage
+	if err := decodeList(json.RawMessage(t.config.OutboundConfigs), &list); err != nil {
+		return nil, common.NewError("invalid outbounds:", err)
+	}
+	return list, nil
+}
+
+func (t *routingTemplate) setOutbounds(list []json.RawMessage) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	t.config.OutboundConfigs = data
+	return nil
+}
+
+func outboundIndex(list []json.RawMessage, tag string) int {
+	for i, raw := range list {
+		if meta, err := decodeOutboundMeta(raw); err == nil && meta.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// outboundReferences 返回引用了指定出站标签的位置描述，用于删除或重命名前的检查
+func (t *routingTemplate) outboundReferences(list []json.RawMessage, tag string) []string {
+	var refs []string
+	for i, raw := range t.rules {
+		if rule, err := decodeRoutingRule(raw); err == nil && rule.OutboundTag == tag {
+			refs = append(refs, fmt.Sprintf("routing rule #%d", i))
+		}
+	}
+	for _, raw := range t.bals {
+		var b Balancer
+		if json.Unmarshal(raw, &b) == nil && b.FallbackTag == tag {
+			refs = append(refs, fmt.Sprintf("balancer %s", b.Tag))
+		}
+	}
+	for _, raw := range list {
+		meta, err := decodeOutboundMeta(raw)
+		if err != nil || meta.Tag == tag {
+			continue
+		}
+		for _, chained := range meta.chainTags() {
+			if chained == tag {
+				refs = append(refs, fmt.Sprintf("outbound %s", meta.Tag))
+			}
+		}
+	}
+	return refs
+}
+
+// validateOutbounds 检查出站标签唯一、链式代理引用的出站存在且不形成环
+func validateOutbounds(list []json.RawMessage) error {
+	next := make(map[string][]string, len(list))
+	for _, raw := range list {
+		meta, err := decodeOutboundMeta(raw)
+		if err != nil {
+			return err
+		}
+		if meta.Tag == "" {
+			continue
+		}
+		if _, ok := next[meta.Tag]; ok {
+			return common.NewErrorf("duplicate outbound tag: %s", meta.Tag)
+		}
+		next[meta.Tag] = meta.chainTags()
+	}
+	for tag, chained := range next {
+		for _, c := range chained {
+			if _, ok := next[c]; !ok {
+				return common.NewErrorf("outbound %s chains to unknown outbound: %s", tag, c)
+			}
+		}
+	}
+
+	// 沿链路深度优先遍历检测环
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(next))
+	var visit func(tag string) error
+	visit = func(tag string) error {
+		switch state[tag] {
+		case visiting:
+			return common.NewErrorf("outbound chain loop detected at: %s", tag)
+		case done:
+			return nil
+		}
+		state[tag] = visiting
+		for _, c := range next[tag] {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		state[tag] = done
+		return nil
+	}
+	for tag := range next {
+		if err := visit(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscriptionTagPrefix 订阅出站的标签前缀，订阅中的节点以 prefix-remark 命名
+func subscriptionTagPrefix(prefix string) string {
+	return prefix + "-"
+}
+
+// =============================================================================
+// 出站
+// =============================================================================
+
+// GetOutbounds 返回模板中的全部出站，并标注来源订阅
+func (s *XraySettingService) GetOutbounds() ([]*Outbound, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return nil, err
+	}
+	subs, _ := s.getOutboundSubscriptionRepo().FindAll()
+	owners := make(map[string]int)
+	for _, sub := range subs {
+		for tag := range subscriptionOwnedTags(sub) {
+			owners[tag] = sub.Id
+		}
+	}
+
+	outbounds := make([]*Outbound, 0, len(list))
+	for _, raw := range list {
+		meta, err := decodeOutboundMeta(raw)
+		if err != nil {
+			return nil, err
+		}
+		outbound := &Outbound{Tag: meta.Tag, Protocol: meta.Protocol, Subscription: owners[meta.Tag], Config: raw}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+// AddOutbound 添加出站，出站必须带有唯一的标签以便被路由规则和链式代理引用
+func (s *XraySettingService) AddOutbound(config json.RawMessage, author string) error {
+	meta, err := decodeOutboundMeta(config)
+	if err != nil {
+		return err
+	}
+	if meta.Tag == "" || meta.Protocol == "" {
+		return common.NewError("outbound tag and protocol are required")
+	}
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	list = append(list, config)
+	if err := validateOutbounds(list); err != nil {
+		return err
+	}
+	if err := t.setOutbounds(list); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, "add outbound "+meta.Tag)
+}
+
+// UpdateOutbound 修改指定标签的出站；被引用的出站不允许修改标签
+func (s *XraySettingService) UpdateOutbound(tag string, config json.RawMessage, author string) error {
+	meta, err := decodeOutboundMeta(config)
+	if err != nil {
+		return err
+	}
+	if meta.Tag == "" || meta.Protocol == "" {
+		return common.NewError("outbound tag and protocol are required")
+	}
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	index := outboundIndex(list, tag)
+	if index < 0 {
+		return common.NewErrorf("outbound not found: %s", tag)
+	}
+	if meta.Tag != tag {
+		if refs := t.outboundReferences(list, tag); len(refs) > 0 {
+			return common.NewErrorf("outbound %s is referenced by %s", tag, strings.Join(refs, ", "))
+		}
+	}
+	list[index] = config
+	if err := validateOutbounds(list); err != nil {
+		return err
+	}
+	if err := t.setOutbounds(list); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, "update outbound "+tag)
+}
+
+// DelOutbound 删除指定标签的出站，仍被路由规则、负载均衡器或其他出站引用时拒绝删除
+func (s *XraySettingService) DelOutbound(tag string, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	index := outboundIndex(list, tag)
+	if index < 0 {
+		return common.NewErrorf("outbound not found: %s", tag)
+	}
+	if refs := t.outboundReferences(list, tag); len(refs) > 0 {
+		return common.NewErrorf("outbound %s is referenced by %s", tag, strings.Join(refs, ", "))
+	}
+	list = append(list[:index], list[index+1:]...)
+	if err := t.setOutbounds(list); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, "delete outbound "+tag)
+}
+
+// ImportOutboundLinks 将分享链接（每行一个，或整体 base64 编码）导入为出站，返回新出站的标签
+func (s *XraySettingService) ImportOutboundLinks(content string, tagPrefix string, author string) ([]string, error) {
+	parsed, errs := ParseShareLinks(content)
+	if len(parsed) == 0 {
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return nil, common.NewError("no share link found")
+	}
+	for _, err := range errs {
+		logger.Warning("Skip invalid share link:", err)
+	}
+	if tagPrefix == "" {
+		tagPrefix = "proxy"
+	}
+
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return nil, err
+	}
+	entries, tags, err := buildLinkOutbounds(parsed, tagPrefix, list)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, entries...)
+	if err := validateOutbounds(list); err != nil {
+		return nil, err
+	}
+	if err := t.setOutbounds(list); err != nil {
+		return nil, err
+	}
+	if err := s.saveRoutingTemplate(t, author, fmt.Sprintf("import %d outbounds", len(tags))); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// buildLinkOutbounds 为解析得到的出站分配 prefix-remark 形式的唯一标签，existing 中已有的标签不会重复使用
+func buildLinkOutbounds(parsed []*ParsedOutbound, prefix string, existing []json.RawMessage) ([]json.RawMessage, []string, error) {
+	used := make(map[string]bool, len(existing))
+	for _, raw := range existing {
+		if meta, err := decodeOutboundMeta(raw); err == nil {
+			used[meta.Tag] = true
+		}
+	}
+
+	entries := make([]json.RawMessage, 0, len(parsed))
+	tags := make([]string, 0, len(parsed))
+	for i, p := range parsed {
+		name := strings.Trim(tagUnsafeChars.ReplaceAllString(p.Remark, "_"), "_")
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		tag := subscriptionTagPrefix(prefix) + name
+		for n := 2; used[tag]; n++ {
+			tag = fmt.Sprintf("%s%s-%d", subscriptionTagPrefix(prefix), name, n)
+		}
+		used[tag] = true
+
+		p.Outbound["tag"] = tag
+		raw, err := json.Marshal(p.Outbound)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, raw)
+		tags = append(tags, tag)
+	}
+	return entries, tags, nil
+}
+
+// =============================================================================
+// 出站订阅
+// =============================================================================
+
+// getOutboundSubscriptionRepo 返回 OutboundSubscriptionRepository，支持延迟初始化
+func (s *XraySettingService) getOutboundSubscriptionRepo() repository.OutboundSubscriptionRepository {
+	if s.outboundSubRepo == nil {
+		s.outboundSubRepo = repository.NewOutboundSubscriptionRepository(database.GetDB())
+	}
+	return s.outboundSubRepo
+}
+
+// GetOutboundSubscriptions 返回全部出站订阅
+func (s *XraySettingService) GetOutboundSubscriptions() ([]*model.OutboundSubscription, error) {
+	return s.getOutboundSubscriptionRepo().FindAll()
+}
+
+func validateOutboundSubscription(sub *model.OutboundSubscription) error {
+	u, err := url.Parse(sub.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return common.NewErrorf("invalid subscription url: %s", sub.Url)
+	}
+	if !tagPrefixPattern.MatchString(sub.TagPrefix) {
+		return common.NewErrorf("invalid tag prefix: %s", sub.TagPrefix)
+	}
+	if sub.Interval < 0 {
+		return common.NewError("subscription interval must not be negative")
+	}
+	return nil
+}
+
+// AddOutboundSubscription 添加出站订阅并立即拉取一次
+func (s *XraySettingService) AddOutboundSubscription(sub *model.OutboundSubscription, author string) error {
+	if err := validateOutboundSubscription(sub); err != nil {
+		return err
+	}
+	sub.Id = 0
+	sub.LastUpdate = 0
+	sub.LastError = ""
+	sub.Count = 0
+	sub.Tags = ""
+	if err := s.getOutboundSubscriptionRepo().Create(sub); err != nil {
+		return err
+	}
+	// 首次拉取失败时不保留订阅，避免留下没有任何出站的记录
+	if _, err := s.RefreshOutboundSubscription(sub.Id, author); err != nil {
+		if delErr := s.getOutboundSubscriptionRepo().Delete(sub.Id); delErr != nil {
+			logger.Warning("Failed to delete outbound subscription:", delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateOutboundSubscription 修改出站订阅；标签前缀不可修改，以免已生成的出站失去归属
+func (s *XraySettingService) UpdateOutboundSubscription(sub *model.OutboundSubscription) error {
+	old, err := s.getOutboundSubscriptionRepo().FindByID(sub.Id)
+	if err != nil {
+		return err
+	}
+	if sub.TagPrefix != old.TagPrefix {
+		return common.NewError("subscription tag prefix cannot be changed")
+	}
+	if err := validateOutboundSubscription(sub); err != nil {
+		return err
+	}
+	old.Remark = sub.Remark
+	old.Url = sub.Url
+	old.Interval = sub.Interval
+	old.Enable = sub.Enable
+	return s.getOutboundSubscriptionRepo().Update(old)
+}
+
+// DelOutboundSubscription 删除出站订阅及其生成的出站，有出站仍被引用时拒绝删除
+func (s *XraySettingService) DelOutboundSubscription(id int, author string) error {
+	sub, err := s.getOutboundSubscriptionRepo().FindByID(id)
+	if err != nil {
+		return err
+	}
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	kept, removed := splitSubscriptionOutbounds(list, subscriptionOwnedTags(sub))
+	if len(removed) > 0 {
+		for _, tag := range removed {
+			if refs := t.outboundReferences(kept, tag); len(refs) > 0 {
+				return common.NewErrorf("outbound %s is referenced by %s", tag, strings.Join(refs, ", "))
+			}
+		}
+		if err := t.setOutbounds(kept); err != nil {
+			return err
+		}
+		if err := s.saveRoutingTemplate(t, author, "delete outbound subscription "+sub.TagPrefix); err != nil {
+			return err
+		}
+	}
+	return s.getOutboundSubscriptionRepo().Delete(id)
+}
+
+// subscriptionOwnedTags 返回订阅记录的出站标签
+func subscriptionOwnedTags(sub *model.OutboundSubscription) map[string]bool {
+	owned := make(map[string]bool)
+	if sub.Tags == "" {
+		return owned
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(sub.Tags), &tags); err != nil {
+		logger.Warningf("Invalid tags of outbound subscription %s: %v", sub.TagPrefix, err)
+		return owned
+	}
+	for _, tag := range tags {
+		owned[tag] = true
+	}
+	return owned
+}
+
+// splitSubscriptionOutbounds 拆分出不属于订阅的出站以及属于订阅的出站标签
+func splitSubscriptionOutbounds(list []json.RawMessage, owned map[string]bool) ([]json.RawMessage, []string) {
+	kept := make([]json.RawMessage, 0, len(list))
+	var removed []string
+	for _, raw := range list {
+		if meta, err := decodeOutboundMeta(raw); err == nil && owned[meta.Tag] {
+			removed = append(removed, meta.Tag)
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	return kept, removed
+}
+
+// fetchOutboundSubscription 拉取订阅内容
+func fetchOutboundSubscription(subURL string) (string, error) {
+	client := &http.Client{Timeout: outboundSubscriptionTimeout}
+	req, err := http.NewRequest(http.MethodGet, subURL, nil)
+	if err != nil {
+		return "", err
+	}
+	// 多数订阅服务按客户端类型返回内容，使用通用客户端标识以获取分享链接格式
+	req.Header.Set("User-Agent", "v2rayN")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", common.NewErrorf("subscription returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, outboundSubscriptionMaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > outboundSubscriptionMaxSize {
+		return "", common.NewError("subscription content too large")
+	}
+	return string(data), nil
+}
+
+// RefreshOutboundSubscription 重新拉取订阅并替换其生成的出站，返回模板是否发生变化。
+// 仍被引用但已从订阅中消失的出站会被保留，避免路由规则失效
+func (s *XraySettingService) RefreshOutboundSubscription(id int, author string) (bool, error) {
+	sub, err := s.getOutboundSubscriptionRepo().FindByID(id)
+	if err != nil {
+		return false, err
+	}
+	changed, tags, count, err := s.refreshSubscriptionOutbounds(sub, author)
+	sub.LastUpdate = time.Now().Unix()
+	if err != nil {
+		sub.LastError = err.Error()
+	} else {
+		sub.LastError = ""
+		sub.Count = count
+		data, _ := json.Marshal(tags)
+		sub.Tags = string(data)
+	}
+	if updateErr := s.getOutboundSubscriptionRepo().Update(sub); updateErr != nil {
+		logger.Warning("Failed to update outbound subscription:", updateErr)
+	}
+	return changed, err
+}
+
+// refreshSubscriptionOutbounds 用订阅的最新内容替换其出站，返回模板是否变化、订阅现在拥有的出站标签和节点数
+func (s *XraySettingService) refreshSubscriptionOutbounds(sub *model.OutboundSubscription, author string) (bool, []string, int, error) {
+	content, err := fetchOutboundSubscription(sub.Url)
+	if err != nil {
+		return false, nil, 0, err
+	}
+	parsed, errs := ParseShareLinks(content)
+	if len(parsed) == 0 {
+		if len(errs) > 0 {
+			return false, nil, 0, errs[0]
+		}
+		return false, nil, 0, common.NewError("subscription contains no supported share link")
+	}
+
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return false, nil, 0, err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return false, nil, 0, err
+	}
+	kept, oldTags := splitSubscriptionOutbounds(list, subscriptionOwnedTags(sub))
+	entries, newTags, err := buildLinkOutbounds(parsed, sub.TagPrefix, kept)
+	if err != nil {
+		return false, nil, 0, err
+	}
+
+	fresh := make(map[string]bool, len(newTags))
+	for _, tag := range newTags {
+		fresh[tag] = true
+	}
+	owned := newTags
+	updated := append(kept, entries...)
+	for _, tag := range oldTags {
+		if fresh[tag] || len(t.outboundReferences(updated, tag)) == 0 {
+			continue
+		}
+		logger.Warningf("Outbound %s disappeared from subscription %s but is still referenced, keeping it", tag, sub.TagPrefix)
+		updated = append(updated, list[outboundIndex(list, tag)])
+		owned = append(owned, tag)
+	}
+	if err := validateOutbounds(updated); err != nil {
+		return false, nil, 0, err
+	}
+
+	before, _ := json.Marshal(list)
+	after, _ := json.Marshal(updated)
+	if string(before) == string(after) {
+		return false, owned, len(entries), nil
+	}
+	if err := t.setOutbounds(updated); err != nil {
+		return false, nil, 0, err
+	}
+	if err := s.saveRoutingTemplate(t, author, "refresh outbound subscription "+sub.TagPrefix); err != nil {
+		return false, nil, 0, err
+	}
+	return true, owned, len(entries), nil
+}
+
+// RefreshDueOutboundSubscriptions 刷新所有到期的已启用订阅，返回模板是否发生变化
+func (s *XraySettingService) RefreshDueOutboundSubscriptions() bool {
+	subs, err := s.getOutboundSubscriptionRepo().FindAll()
+	if err != nil {
+		logger.Warning("Failed to load outbound subscriptions:", err)
+		return false
+	}
+	now := time.Now().Unix()
+	changed := false
+	for _, sub := range subs {
+		if !sub.Enable || sub.Interval <= 0 || now-sub.LastUpdate < int64(sub.Interval)*60 {
+			continue
+		}
+		subChanged, err := s.RefreshOutboundSubscription(sub.Id, "system")
+		if err != nil {
+			logger.Warningf("Refresh outbound subscription %s failed: %v", sub.TagPrefix, err)
+			continue
+		}
+		changed = changed || subChanged
+	}
+	return changed
+}
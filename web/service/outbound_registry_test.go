@@ -0,0 +1,151 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"x-ui/database/model"
+)
+
+func TestXraySettingService_OutboundCRUD(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	if err := s.AddOutbound(json.RawMessage(`{"tag":"relay","protocol":"freedom","proxySettings":{"tag":"direct"}}`), "admin"); err != nil {
+		t.Fatalf("add outbound failed: %v", err)
+	}
+	invalid := []string{
+		`{"tag":"direct","protocol":"freedom"}`,
+		`{"protocol":"freedom"}`,
+		`{"tag":"x","protocol":"freedom","proxySettings":{"tag":"missing"}}`,
+	}
+	for _, config := range invalid {
+		if err := s.AddOutbound(json.RawMessage(config), "admin"); err == nil {
+			t.Errorf("invalid outbound accepted: %s", config)
+		}
+	}
+	// 链式代理不能形成环
+	if err := s.UpdateOutbound("direct", json.RawMessage(`{"tag":"direct","protocol":"freedom","streamSettings":{"sockopt":{"dialerProxy":"relay"}}}`), "admin"); err == nil {
+		t.Error("expected chain loop to be rejected")
+	}
+
+	// 被链式代理或路由规则引用的出站不能删除或改名
+	if err := s.DelOutbound("direct", "admin"); err == nil || !strings.Contains(err.Error(), "outbound relay") {
+		t.Errorf("expected reference error, got %v", err)
+	}
+	if err := s.AddRoutingRule(RoutingRule{"domain": []string{"full:example.com"}, "outboundTag": "relay"}, -1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateOutbound("relay", json.RawMessage(`{"tag":"relay2","protocol":"freedom"}`), "admin"); err == nil {
+		t.Error("expected rename of referenced outbound to be rejected")
+	}
+	if err := s.DelRoutingRule(0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelOutbound("relay", "admin"); err != nil {
+		t.Fatalf("delete outbound failed: %v", err)
+	}
+
+	outbounds, err := s.GetOutbounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbounds) != 2 || outbounds[0].Tag != "direct" || outbounds[1].Protocol != "blackhole" {
+		t.Errorf("unexpected outbounds %+v", outbounds)
+	}
+}
+
+func TestXraySettingService_ImportOutboundLinks(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	links := "trojan://secret@example.com:443#HK 01\ntrojan://secret@example.org:443#HK 01"
+	tags, err := s.ImportOutboundLinks(links, "imp", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != "imp-HK_01" || tags[1] != "imp-HK_01-2" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if err := s.AddRoutingRule(RoutingRule{"domain": []string{"full:example.com"}, "outboundTag": tags[1]}, -1, "admin"); err != nil {
+		t.Errorf("imported outbound cannot be used by routing rule: %v", err)
+	}
+}
+
+func TestXraySettingService_OutboundSubscription(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	body := "trojan://secret@example.com:443#a\ntrojan://secret@example.com:443#b"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	// 与订阅前缀相同的手动出站不属于订阅
+	if err := s.AddOutbound(json.RawMessage(`{"tag":"air-a","protocol":"freedom"}`), "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &model.OutboundSubscription{Url: server.URL, TagPrefix: "air", Interval: 60, Enable: true}
+	if err := s.AddOutboundSubscription(sub, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	sub, _ = s.getOutboundSubscriptionRepo().FindByID(sub.Id)
+	if sub.Count != 2 || sub.LastUpdate == 0 || sub.LastError != "" {
+		t.Fatalf("unexpected subscription state %+v", sub)
+	}
+
+	// 内容未变化时不修改模板
+	changed, err := s.RefreshOutboundSubscription(sub.Id, "admin")
+	if err != nil || changed {
+		t.Fatalf("expected unchanged refresh, got %v %v", changed, err)
+	}
+
+	// 被引用的节点从订阅中消失时保留
+	if err := s.AddRoutingRule(RoutingRule{"domain": []string{"full:example.com"}, "outboundTag": "air-b"}, -1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	body = "trojan://secret@example.com:443#c"
+	changed, err = s.RefreshOutboundSubscription(sub.Id, "admin")
+	if err != nil || !changed {
+		t.Fatalf("expected changed refresh, got %v %v", changed, err)
+	}
+	outbounds, _ := s.GetOutbounds()
+	var tags []string
+	for _, o := range outbounds {
+		tags = append(tags, o.Tag)
+		if owned := o.Tag != "air-a" && strings.HasPrefix(o.Tag, "air-"); owned != (o.Subscription == sub.Id) {
+			t.Errorf("outbound %s has wrong subscription %d", o.Tag, o.Subscription)
+		}
+	}
+	if got := strings.Join(tags, ","); got != "direct,blocked,air-a,air-c,air-b" {
+		t.Errorf("unexpected outbounds after refresh: %s", got)
+	}
+
+	if err := s.DelOutboundSubscription(sub.Id, "admin"); err == nil {
+		t.Error("expected delete of referenced subscription to be rejected")
+	}
+	if err := s.DelRoutingRule(0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelOutboundSubscription(sub.Id, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	outbounds, _ = s.GetOutbounds()
+	if len(outbounds) != 3 || outbounds[2].Tag != "air-a" {
+		t.Errorf("only subscription outbounds should be removed: %+v", outbounds)
+	}
+
+	// 首次拉取失败时不保留订阅记录
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := s.AddOutboundSubscription(&model.OutboundSubscription{Url: failing.URL, TagPrefix: "down"}, "admin"); err == nil {
+		t.Error("expected failed first refresh to be reported")
+	}
+	if subs, _ := s.GetOutboundSubscriptions(); len(subs) != 0 {
+		t.Errorf("failed subscription should not be kept: %+v", subs)
+	}
+}
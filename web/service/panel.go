@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"x-ui/logger"
+	"x-ui/util/common"
 )
 
 type PanelService struct{}
@@ -29,3 +30,22 @@ func (s *PanelService) RestartPanel(delay time.Duration) error {
 	}()
 	return nil
 }
+
+// UpgradePanel 通知主进程以磁盘上的新二进制替换自身，监听套接字会被交接给新进程
+func (s *PanelService) UpgradePanel(delay time.Duration) error {
+	if upgradePanelSignal == nil {
+		return common.NewError("panel upgrade handoff is not supported on this platform")
+	}
+	p, err := os.FindProcess(syscall.Getpid())
+	if err != nil {
+		return err
+	}
+	go func() {
+		time.Sleep(delay)
+		err := p.Signal(upgradePanelSignal)
+		if err != nil {
+			logger.Error("failed to send upgrade signal:", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradePanelSignal 通知主进程以新二进制替换自身并交接监听套接字
+var upgradePanelSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,8 @@
+//go:build windows
+
+package service
+
+import "os"
+
+// upgradePanelSignal Windows 不支持二进制升级交接
+var upgradePanelSignal os.Signal
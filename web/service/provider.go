@@ -14,7 +14,10 @@ var ServiceSet = wire.NewSet(
 	NewInboundService,
 	NewXrayService,
 	NewServerService,
+	NewClientPlanService,
 	NewTgBot,
+	NewEventHub,
+	NewHealthService,
 	// 接口绑定：将 *Tgbot 实例绑定到 TelegramService 接口
 	wire.Bind(new(TelegramService), new(*Tgbot)),
 	// 提供基础结构体
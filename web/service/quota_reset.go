@@ -0,0 +1,302 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/logger"
+	"x-ui/util/common"
+	"x-ui/xray"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// 配额重置周期的类型
+const (
+	// QuotaResetDaily 每天 0 点重置
+	QuotaResetDaily = "daily"
+	// QuotaResetWeekly 每周指定的一天重置，weekly:1 表示周一，0 为周日，缺省为周一
+	QuotaResetWeekly = "weekly"
+	// QuotaResetMonthly 每月指定的一天重置，monthly:15 表示每月 15 日，当月没有该日时在最后一天重置，缺省为 1 日
+	QuotaResetMonthly = "monthly"
+	// QuotaResetCron 按标准 5 段 cron 表达式重置，例如 cron:0 0 1,15 * *
+	QuotaResetCron = "cron"
+)
+
+// QuotaResetSchedule 解析后的配额重置周期，时间按面板时区计算
+type QuotaResetSchedule struct {
+	Kind string
+	Day  int
+	cron cron.Schedule
+}
+
+// ParseQuotaResetSchedule 解析配额重置周期。支持 daily、weekly[:0-6]、monthly[:1-31] 和 cron:<表达式>，
+// 空字符串表示不按周期重置，返回 nil
+func ParseQuotaResetSchedule(spec string) (*QuotaResetSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	kind, arg, hasArg := strings.Cut(spec, ":")
+	arg = strings.TrimSpace(arg)
+	schedule := &QuotaResetSchedule{Kind: kind}
+
+	switch kind {
+	case QuotaResetDaily:
+		if hasArg {
+			return nil, common.NewError("invalid quota reset schedule: ", spec)
+		}
+	case QuotaResetWeekly, QuotaResetMonthly:
+		low, high, day := 0, 6, 1
+		if kind == QuotaResetMonthly {
+			low, high = 1, 31
+		}
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < low || n > high {
+				return nil, common.NewError("invalid quota reset day: ", spec)
+			}
+			day = n
+		}
+		schedule.Day = day
+	case QuotaResetCron:
+		expr, err := cron.ParseStandard(arg)
+		if err != nil {
+			return nil, common.NewErrorf("invalid quota reset cron %q: %v", arg, err)
+		}
+		schedule.cron = expr
+	default:
+		return nil, common.NewError("invalid quota reset schedule: ", spec)
+	}
+	return schedule, nil
+}
+
+// Next 返回 after 之后的下一个重置时间
+func (q *QuotaResetSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	midnight := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, loc)
+
+	switch q.Kind {
+	case QuotaResetDaily:
+		return midnight.AddDate(0, 0, 1)
+	case QuotaResetWeekly:
+		days := (q.Day - int(midnight.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return midnight.AddDate(0, 0, days)
+	case QuotaResetMonthly:
+		next := monthDay(after.Year(), after.Month(), q.Day, loc)
+		if !next.After(after) {
+			next = monthDay(after.Year(), after.Month()+1, q.Day, loc)
+		}
+		return next
+	default:
+		return q.cron.Next(after)
+	}
+}
+
+// monthDay 返回指定月份的第 day 天 0 点，超过当月天数时取最后一天
+func monthDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// validateQuotaResetSchedules 校验入站及其客户端的配额重置周期
+func validateQuotaResetSchedules(inbound *model.Inbound) error {
+	if _, err := ParseQuotaResetSchedule(inbound.ResetSchedule); err != nil {
+		return err
+	}
+	var settings struct {
+		Clients []struct {
+			Email         string `json:"email"`
+			ResetSchedule string `json:"resetSchedule"`
+		} `json:"clients"`
+	}
+	if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil {
+		return nil
+	}
+	for _, client := range settings.Clients {
+		if _, err := ParseQuotaResetSchedule(client.ResetSchedule); err != nil {
+			return common.NewErrorf("client %s: %v", client.Email, err)
+		}
+	}
+	return nil
+}
+
+// QuotaResetResult 一轮按周期重置配额的结果，Archives 为本轮归档的上一周期用量
+type QuotaResetResult struct {
+	Archives    []*model.QuotaUsageArchive
+	NeedRestart bool
+}
+
+// quotaResetDue 判断配额是否到了重置时间。lastReset 为 0 时从 now 开始计算周期，返回新的 lastReset
+func quotaResetDue(schedule *QuotaResetSchedule, lastReset int64, now time.Time) (bool, int64) {
+	if lastReset == 0 {
+		return false, now.UnixMilli()
+	}
+	next := schedule.Next(time.UnixMilli(lastReset).In(now.Location()))
+	if next.After(now) {
+		return false, lastReset
+	}
+	// 面板停机跨越多个周期时只重置一次
+	return true, now.UnixMilli()
+}
+
+// ResetScheduledQuotas 重置到期的入站和客户端流量配额，并归档上一周期的用量。
+// 因流量耗尽被停用的入站和客户端在重置后重新启用（已到期的除外）
+func (s *InboundService) ResetScheduledQuotas(now time.Time) (*QuotaResetResult, error) {
+	result := &QuotaResetResult{}
+	err := database.WithTx(func(tx *gorm.DB) error {
+		var inbounds []*model.Inbound
+		if err := tx.Model(model.Inbound{}).Find(&inbounds).Error; err != nil {
+			return err
+		}
+		for _, inbound := range inbounds {
+			if err := s.resetInboundQuota(tx, inbound, now, result); err != nil {
+				return err
+			}
+			if err := s.resetClientQuotas(tx, inbound, now, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resetInboundQuota 按入站的重置周期清零入站流量，因流量耗尽被停用的入站重新启用（已到期的除外）
+func (s *InboundService) resetInboundQuota(tx *gorm.DB, inbound *model.Inbound, now time.Time, result *QuotaResetResult) error {
+	schedule, err := ParseQuotaResetSchedule(inbound.ResetSchedule)
+	if err != nil {
+		logger.Warningf("inbound %s: %v", inbound.Tag, err)
+		return nil
+	}
+	if schedule == nil {
+		return nil
+	}
+	due, lastReset := quotaResetDue(schedule, inbound.LastReset, now)
+	if !due {
+		if lastReset != inbound.LastReset {
+			return tx.Model(model.Inbound{}).Where("id = ?", inbound.Id).Update("last_reset", lastReset).Error
+		}
+		return nil
+	}
+
+	archive := &model.QuotaUsageArchive{
+		InboundId:   inbound.Id,
+		Up:          inbound.Up,
+		Down:        inbound.Down,
+		Total:       inbound.Total,
+		PeriodStart: inbound.LastReset,
+		PeriodEnd:   lastReset,
+	}
+	if err := tx.Create(archive).Error; err != nil {
+		return err
+	}
+	updates := map[string]any{
+		"up":         0,
+		"down":       0,
+		"last_reset": lastReset,
+	}
+	// 流量耗尽被停用的入站重新启用，已到期的仍保持停用
+	exhausted := inbound.Total > 0 && inbound.Up+inbound.Down >= inbound.Total
+	expired := inbound.ExpiryTime > 0 && inbound.ExpiryTime <= now.UnixMilli()
+	if !inbound.Enable && exhausted && !expired {
+		updates["enable"] = true
+		result.NeedRestart = true
+	}
+	if err := tx.Model(model.Inbound{}).Where("id = ?", inbound.Id).Updates(updates).Error; err != nil {
+		return err
+	}
+	result.Archives = append(result.Archives, archive)
+	return nil
+}
+
+// resetClientQuotas 按客户端的重置周期清零客户端流量
+func (s *InboundService) resetClientQuotas(tx *gorm.DB, inbound *model.Inbound, now time.Time, result *QuotaResetResult) error {
+	clients, err := s.GetClients(inbound)
+	if err != nil {
+		return nil
+	}
+	for _, client := range clients {
+		schedule, err := ParseQuotaResetSchedule(client.ResetSchedule)
+		if err != nil {
+			logger.Warningf("client %s: %v", client.Email, err)
+			continue
+		}
+		if schedule == nil || client.Email == "" {
+			continue
+		}
+		var traffic xray.ClientTraffic
+		if err := tx.Model(xray.ClientTraffic{}).Where("email = ?", client.Email).First(&traffic).Error; err != nil {
+			continue
+		}
+		due, lastReset := quotaResetDue(schedule, traffic.LastReset, now)
+		if !due {
+			if lastReset != traffic.LastReset {
+				if err := tx.Model(xray.ClientTraffic{}).Where("id = ?", traffic.Id).Update("last_reset", lastReset).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		archive := &model.QuotaUsageArchive{
+			InboundId:   inbound.Id,
+			Email:       traffic.Email,
+			Up:          traffic.Up,
+			Down:        traffic.Down,
+			Total:       traffic.Total,
+			PeriodStart: traffic.LastReset,
+			PeriodEnd:   lastReset,
+		}
+		if err := tx.Create(archive).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{
+			"up":         0,
+			"down":       0,
+			"last_reset": lastReset,
+		}
+		// 流量耗尽被停用的客户端重新启用，已到期的仍保持停用
+		exhausted := traffic.Total > 0 && traffic.Up+traffic.Down >= traffic.Total
+		expired := traffic.ExpiryTime > 0 && traffic.ExpiryTime <= now.UnixMilli()
+		if !traffic.Enable && exhausted && !expired {
+			updates["enable"] = true
+			result.NeedRestart = true
+		}
+		if err := tx.Model(xray.ClientTraffic{}).Where("id = ?", traffic.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+		result.Archives = append(result.Archives, archive)
+	}
+	return nil
+}
+
+// GetQuotaArchives 返回入站（email 为空）或客户端的历史周期用量，最新的在前，limit 为 0 时不限制条数
+func (s *InboundService) GetQuotaArchives(inboundId int, email string, limit int) ([]*model.QuotaUsageArchive, error) {
+	query := s.getInboundRepo().GetDB().Model(model.QuotaUsageArchive{})
+	if email != "" {
+		query = query.Where("email = ?", email)
+	} else {
+		query = query.Where("inbound_id = ? AND email = ''", inboundId)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var archives []*model.QuotaUsageArchive
+	if err := query.Order("id desc").Find(&archives).Error; err != nil {
+		return nil, err
+	}
+	return archives, nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/xray"
+)
+
+func TestParseQuotaResetSchedule(t *testing.T) {
+	valid := []string{"", "daily", "weekly", "weekly:0", "monthly", "monthly:31", "cron:0 0 1,15 * *"}
+	for _, spec := range valid {
+		if _, err := ParseQuotaResetSchedule(spec); err != nil {
+			t.Errorf("%q should be valid: %v", spec, err)
+		}
+	}
+	invalid := []string{"hourly", "daily:1", "weekly:7", "monthly:0", "monthly:x", "cron:bad"}
+	for _, spec := range invalid {
+		if _, err := ParseQuotaResetSchedule(spec); err == nil {
+			t.Errorf("%q should be invalid", spec)
+		}
+	}
+}
+
+func TestQuotaResetSchedule_Next(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, loc)
+	}
+
+	cases := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"daily", at(2026, 3, 10, 15), at(2026, 3, 11, 0)},
+		// 2026-03-10 为周二
+		{"weekly", at(2026, 3, 10, 15), at(2026, 3, 16, 0)},
+		{"weekly:2", at(2026, 3, 10, 0), at(2026, 3, 17, 0)},
+		{"monthly", at(2026, 3, 10, 15), at(2026, 4, 1, 0)},
+		{"monthly:15", at(2026, 3, 10, 15), at(2026, 3, 15, 0)},
+		{"monthly:31", at(2026, 2, 10, 15), at(2026, 2, 28, 0)},
+		{"monthly:31", at(2026, 2, 28, 0), at(2026, 3, 31, 0)},
+		{"cron:0 0 1,15 * *", at(2026, 3, 10, 15), at(2026, 3, 15, 0)},
+	}
+	for _, c := range cases {
+		schedule, err := ParseQuotaResetSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", c.spec, err)
+		}
+		if got := schedule.Next(c.after); !got.Equal(c.want) {
+			t.Errorf("%s after %v: expected %v, got %v", c.spec, c.after, c.want, got)
+		}
+	}
+}
+
+func TestInboundService_ResetScheduledQuotas(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	inbound := &model.Inbound{
+		Tag: "in-quota", Port: 30011, Protocol: model.VLESS, Enable: true, ResetSchedule: "monthly",
+		Settings: `{"clients":[{"id":"1","email":"monthly","enable":true,"resetSchedule":"monthly"},{"id":"2","email":"never","enable":true}]}`,
+	}
+	if _, _, err := s.AddInbound(inbound); err != nil {
+		t.Fatalf("AddInbound failed: %v", err)
+	}
+	bad := &model.Inbound{
+		Tag: "in-bad", Port: 30012, Protocol: model.VLESS, Enable: true,
+		Settings: `{"clients":[{"id":"3","email":"bad","enable":true,"resetSchedule":"hourly"}]}`,
+	}
+	if _, _, err := s.AddInbound(bad); err == nil {
+		t.Error("invalid client reset schedule should be rejected")
+	}
+
+	loc := time.UTC
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, loc)
+	result, err := s.ResetScheduledQuotas(start)
+	if err != nil {
+		t.Fatalf("ResetScheduledQuotas failed: %v", err)
+	}
+	if len(result.Archives) != 0 {
+		t.Fatalf("first run should only start the period, got %d archives", len(result.Archives))
+	}
+
+	db := database.GetDB()
+	db.Model(model.Inbound{}).Where("id = ?", inbound.Id).Updates(map[string]any{"up": 100, "down": 200})
+	db.Model(xray.ClientTraffic{}).Where("email IN ?", []string{"monthly", "never"}).
+		Updates(map[string]any{"up": 60, "down": 40, "total": 100, "enable": false})
+
+	result, err = s.ResetScheduledQuotas(start.AddDate(0, 0, 5))
+	if err != nil || len(result.Archives) != 0 {
+		t.Fatalf("nothing should be due before the 1st, got %v, %v", result, err)
+	}
+
+	end := time.Date(2026, 4, 1, 0, 1, 0, 0, loc)
+	result, err = s.ResetScheduledQuotas(end)
+	if err != nil {
+		t.Fatalf("ResetScheduledQuotas failed: %v", err)
+	}
+	if len(result.Archives) != 2 || !result.NeedRestart {
+		t.Fatalf("expected inbound and client archives with restart, got %+v", result)
+	}
+
+	var traffic xray.ClientTraffic
+	db.Model(xray.ClientTraffic{}).Where("email = ?", "monthly").First(&traffic)
+	if traffic.Up != 0 || traffic.Down != 0 || !traffic.Enable || traffic.LastReset != end.UnixMilli() {
+		t.Errorf("client quota was not reset: %+v", traffic)
+	}
+	var untouched xray.ClientTraffic
+	if err := db.Model(xray.ClientTraffic{}).Where("email = ?", "never").First(&untouched).Error; err != nil {
+		t.Fatalf("load client traffic failed: %v", err)
+	}
+	if untouched.Up != 60 || untouched.Enable {
+		t.Errorf("client without schedule should be untouched: %+v", untouched)
+	}
+	reloaded, _ := s.GetInbound(inbound.Id)
+	if reloaded.Up != 0 || reloaded.Down != 0 {
+		t.Errorf("inbound quota was not reset: up=%d down=%d", reloaded.Up, reloaded.Down)
+	}
+
+	archives, err := s.GetQuotaArchives(0, "monthly", 0)
+	if err != nil || len(archives) != 1 || archives[0].Up+archives[0].Down != 100 || archives[0].PeriodStart != start.UnixMilli() {
+		t.Errorf("unexpected client archives: %+v, %v", archives, err)
+	}
+	archives, err = s.GetQuotaArchives(inbound.Id, "", 0)
+	if err != nil || len(archives) != 1 || archives[0].Down != 200 {
+		t.Errorf("unexpected inbound archives: %+v, %v", archives, err)
+	}
+
+	// 修改客户端的重置周期后从下一轮任务开始重新计算周期
+	if _, err := s.updateClientByEmail("monthly", func(client map[string]any, _ model.Protocol) {
+		client["resetSchedule"] = "daily"
+	}); err != nil {
+		t.Fatalf("update client schedule failed: %v", err)
+	}
+	db.Model(xray.ClientTraffic{}).Where("email = ?", "monthly").First(&traffic)
+	if traffic.LastReset != 0 {
+		t.Errorf("changing the schedule should clear last reset, got %d", traffic.LastReset)
+	}
+	result, err = s.ResetScheduledQuotas(end.Add(time.Hour))
+	if err != nil || len(result.Archives) != 0 {
+		t.Errorf("new schedule should start a fresh period, got %+v, %v", result, err)
+	}
+}
+
+func TestInboundService_ResetScheduledQuotas_ReenablesInbound(t *testing.T) {
+	setupTestDB(t)
+	s := &InboundService{}
+
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 1, 0, 1, 0, 0, time.UTC)
+	exhausted := &model.Inbound{
+		Tag: "in-exhausted", Port: 30021, Protocol: model.VLESS, Enable: true, ResetSchedule: "monthly",
+		Settings: `{"clients":[{"id":"1","email":"exhausted","enable":true}]}`,
+	}
+	expired := &model.Inbound{
+		Tag: "in-expired", Port: 30022, Protocol: model.VLESS, Enable: true, ResetSchedule: "monthly",
+		Settings: `{"clients":[{"id":"2","email":"expired","enable":true}]}`,
+	}
+	for _, inbound := range []*model.Inbound{exhausted, expired} {
+		if _, _, err := s.AddInbound(inbound); err != nil {
+			t.Fatalf("AddInbound failed: %v", err)
+		}
+	}
+	if _, err := s.ResetScheduledQuotas(start); err != nil {
+		t.Fatalf("ResetScheduledQuotas failed: %v", err)
+	}
+
+	// 两个入站都因流量耗尽被停用，其中一个同时已到期
+	db := database.GetDB()
+	db.Model(model.Inbound{}).Where("id IN ?", []int{exhausted.Id, expired.Id}).
+		Updates(map[string]any{"up": 60, "down": 40, "total": 100, "enable": false})
+	db.Model(model.Inbound{}).Where("id = ?", expired.Id).Update("expiry_time", start.AddDate(0, 0, 1).UnixMilli())
+
+	result, err := s.ResetScheduledQuotas(end)
+	if err != nil {
+		t.Fatalf("ResetScheduledQuotas failed: %v", err)
+	}
+	if len(result.Archives) != 2 || !result.NeedRestart {
+		t.Fatalf("expected two inbound archives with restart, got %+v", result)
+	}
+	reloaded, _ := s.GetInbound(exhausted.Id)
+	if !reloaded.Enable || reloaded.Up != 0 || reloaded.Down != 0 {
+		t.Errorf("exhausted inbound should be re-enabled: %+v", reloaded)
+	}
+	reloaded, _ = s.GetInbound(expired.Id)
+	if reloaded.Enable || reloaded.Up != 0 {
+		t.Errorf("expired inbound should stay disabled: %+v", reloaded)
+	}
+}
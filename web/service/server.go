@@ -38,6 +38,13 @@ const (
 	Error   ProcessState = "error"
 )
 
+// SingBoxStatus 与 Xray 同时运行的 sing-box 的状态，缺少 V2Ray API 等问题通过 ErrorMsg 报告
+type SingBoxStatus struct {
+	State    ProcessState `json:"state"`
+	ErrorMsg string       `json:"errorMsg"`
+	Version  string       `json:"version"`
+}
+
 type Status struct {
 	T           time.Time `json:"-"`
 	Cpu         float64   `json:"cpu"`
@@ -57,9 +64,13 @@ type Status struct {
 		Total   uint64 `json:"total"`
 	} `json:"disk"`
 	Xray struct {
-		State    ProcessState `json:"state"`
-		ErrorMsg string       `json:"errorMsg"`
-		Version  string       `json:"version"`
+		State      ProcessState          `json:"state"`
+		ErrorMsg   string                `json:"errorMsg"`
+		Version    string                `json:"version"`
+		Backend    string                `json:"backend"`
+		Health     *OutboundHealthReport `json:"health,omitempty"`
+		Supervisor *SupervisorStatus     `json:"supervisor,omitempty"`
+		SingBox    *SingBoxStatus        `json:"singBox,omitempty"`
 	} `json:"xray"`
 	Uptime   uint64    `json:"uptime"`
 	Loads    []float64 `json:"loads"`
@@ -303,9 +314,12 @@ func (s *ServerService) GetStatus(lastStatus *Status) *Status {
 
 	// Xray status
 	if s.xrayService != nil {
-		if s.xrayService.IsXrayRunning() {
+		status.Xray.Backend = s.xrayService.coreBackendName()
+		if s.xrayService.IsCoreRunning() {
 			status.Xray.State = Running
-			status.Xray.ErrorMsg = ""
+			// 配置被拒绝或已回滚时，进程仍在运行但需要向用户展示原因
+			status.Xray.ErrorMsg = s.xrayService.GetXrayResult()
+			status.Xray.Health = s.xrayService.GetOutboundHealth()
 		} else {
 			err := s.xrayService.GetXrayErr()
 			if err != nil {
@@ -316,6 +330,16 @@ func (s *ServerService) GetStatus(lastStatus *Status) *Status {
 			status.Xray.ErrorMsg = s.xrayService.GetXrayResult()
 		}
 		status.Xray.Version = s.xrayService.GetXrayVersion()
+		supervisor := s.xrayService.GetSupervisorStatus()
+		status.Xray.Supervisor = &supervisor
+		if sb := s.xrayService.GetSingBoxBackend(); sb != nil {
+			status.Xray.SingBox = &SingBoxStatus{State: Stop, ErrorMsg: sb.GetResult(), Version: sb.GetVersion()}
+			if sb.IsRunning() {
+				status.Xray.SingBox.State = Running
+			} else if s.xrayService.getSingBoxBackend().crashedProcess() != nil {
+				status.Xray.SingBox.State = Error
+			}
+		}
 	} else {
 		status.Xray.State = Stop
 		status.Xray.ErrorMsg = "Xray service not initialized"
@@ -327,7 +351,7 @@ func (s *ServerService) GetStatus(lastStatus *Status) *Status {
 	status.AppStats.Mem = rtm.Sys
 	//nolint:gosec
 	status.AppStats.Threads = uint32(runtime.NumGoroutine())
-	if s.xrayService != nil && s.xrayService.IsXrayRunning() {
+	if s.xrayService != nil && s.xrayService.IsCoreRunning() {
 		status.AppStats.Uptime = s.xrayService.GetProcessUptime()
 	} else {
 		status.AppStats.Uptime = 0
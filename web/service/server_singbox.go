@@ -0,0 +1,189 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/singbox"
+	"x-ui/util/common"
+)
+
+// =============================================================================
+// sing-box 版本管理
+// =============================================================================
+
+var singBoxVersionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// singBoxAssetName 返回 sing-box 官方发布包的文件名，如 sing-box-1.12.0-linux-amd64.tar.gz
+func singBoxAssetName(version string) string {
+	arch := runtime.GOARCH
+	if arch == "arm" {
+		arch = "armv7"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "GOARM" && setting.Value != "" {
+					arch = "armv" + strings.TrimSuffix(setting.Value, ",softfloat")
+				}
+			}
+		}
+	}
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("sing-box-%s-%s-%s.%s", strings.TrimPrefix(version, "v"), runtime.GOOS, arch, ext)
+}
+
+// UpdateSingBox 下载指定版本的 sing-box 官方发布包并安装到 bin 目录，启用 sing-box 后端时重新启动 sing-box。
+// 官方发布的二进制未编译 with_v2ray_api，安装后无法统计 Hysteria2/TUIC 流量，此时在日志和通知中报告
+func (s *ServerService) UpdateSingBox(version string) error {
+	if !singBoxVersionPattern.MatchString(version) {
+		return common.NewErrorf("invalid sing-box version: %s", version)
+	}
+	go func() {
+		logger.Infof("开始更新 sing-box 到版本: %s", version)
+		msg := fmt.Sprintf("✅ <b>sing-box 更新成功！</b>\n\n版本: <code>%s</code>", version)
+		err := s.installSingBox(version)
+		switch {
+		case err != nil:
+			logger.Errorf("sing-box 版本更新失败: %v", err)
+			msg = fmt.Sprintf("❌ <b>sing-box 更新失败</b>\n\n版本: <code>%s</code>\n\n错误信息: %v", version, err)
+		case !singbox.HasV2RayAPI():
+			logger.Warning("sing-box 已更新，但", singbox.ErrV2RayAPIUnavailable)
+			msg += "\n\n⚠️ 该版本未编译 with_v2ray_api，Hysteria2/TUIC 入站的流量将无法统计。"
+		default:
+			logger.Infof("sing-box 版本更新成功: %s", version)
+		}
+		if s.tgService != nil && s.tgService.IsRunning() {
+			if err := s.tgService.SendMessage(msg); err != nil {
+				logger.Warningf("发送 sing-box 更新通知失败: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *ServerService) installSingBox(version string) error {
+	url := fmt.Sprintf("https://github.com/SagerNet/sing-box/releases/download/%s/%s", version, singBoxAssetName(version))
+	client := &http.Client{Timeout: 120 * time.Second}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("创建下载请求失败: %v", err)
+	}
+	req.Header.Set("User-Agent", "Xray-UI-Panel/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载 sing-box 失败: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载失败，GitHub返回状态码: %d", resp.StatusCode)
+	}
+
+	archive, err := os.CreateTemp("", "sing-box-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archive.Name())
+	}()
+	size, err := io.Copy(archive, resp.Body)
+	if err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+
+	// 先写入临时文件再替换，避免正在运行的 sing-box 读到不完整的二进制
+	target := singbox.GetBinaryPath()
+	tmp := target + ".new"
+	if err := extractSingBox(archive, size, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("解压 sing-box 失败: %v", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if s.xrayService != nil {
+		return s.xrayService.RestartSingBox()
+	}
+	return nil
+}
+
+// extractSingBox 从发布包中取出 sing-box 可执行文件写入 dest
+func extractSingBox(archive *os.File, size int64, dest string) error {
+	binaryName := "sing-box"
+	if runtime.GOOS == "windows" {
+		binaryName = "sing-box.exe"
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var src io.Reader
+	if runtime.GOOS == "windows" {
+		reader, err := zip.NewReader(archive, size)
+		if err != nil {
+			return err
+		}
+		for _, f := range reader.File {
+			if path.Base(f.Name) == binaryName {
+				rc, err := f.Open()
+				if err != nil {
+					return err
+				}
+				defer func() { _ = rc.Close() }()
+				src = rc
+				break
+			}
+		}
+	} else {
+		gzipReader, err := gzip.NewReader(archive)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gzipReader.Close() }()
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if path.Base(header.Name) == binaryName && header.Typeflag == tar.TypeReg {
+				src = tarReader
+				break
+			}
+		}
+	}
+	if src == nil {
+		return fmt.Errorf("%s not found in archive", binaryName)
+	}
+
+	_ = os.MkdirAll(filepath.Dir(dest), 0o750)
+	//nolint:gosec
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	// Limit decompression size to 200MB to prevent DoS (G110)
+	_, err = io.Copy(file, io.LimitReader(src, 200*1024*1024))
+	return err
+}
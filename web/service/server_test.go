@@ -1,6 +1,8 @@
 package service
 
 import (
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -208,3 +210,16 @@ func TestServerService_GetNewUUID(t *testing.T) {
 		t.Errorf("UUID length = %d, want 36", len(uuid))
 	}
 }
+
+func TestServerService_UpdateSingBoxVersion(t *testing.T) {
+	s := &ServerService{}
+	for _, version := range []string{"", "1.12.0", "v1.12", "v1.12.0/../x"} {
+		if err := s.UpdateSingBox(version); err == nil {
+			t.Errorf("UpdateSingBox(%q) should be rejected", version)
+		}
+	}
+	name := singBoxAssetName("v1.12.0")
+	if !strings.HasPrefix(name, "sing-box-1.12.0-"+runtime.GOOS+"-") {
+		t.Errorf("unexpected asset name %s", name)
+	}
+}
@@ -17,7 +17,6 @@ import (
 	"strings"
 	"time"
 
-	"x-ui/config"
 	"x-ui/logger"
 	"x-ui/util/common"
 	"x-ui/xray"
@@ -108,6 +107,14 @@ func (s *ServerService) GetXrayVersions() ([]string, error) {
 	return versions, nil
 }
 
+// GetReverseTunnelStatus 返回反向代理的 bridge 连接状态，Xray 未运行时全部视为未连接
+func (s *ServerService) GetReverseTunnelStatus(tunnels []*ReverseTunnel) []*ReverseTunnelStatus {
+	if s.xrayService == nil {
+		return (&XrayService{}).GetReverseTunnelStatus(tunnels)
+	}
+	return s.xrayService.GetReverseTunnelStatus(tunnels)
+}
+
 func (s *ServerService) StopXrayService() error {
 	err := s.xrayService.StopXray()
 	if err != nil {
@@ -118,6 +125,8 @@ func (s *ServerService) StopXrayService() error {
 }
 
 func (s *ServerService) RestartXrayService() error {
+	// 手动重启时解除崩溃循环状态
+	s.xrayService.ResetSupervisor()
 	err := s.xrayService.RestartXray(true)
 	if err != nil {
 		logger.Error("start xray failed:", err)
@@ -126,6 +135,26 @@ func (s *ServerService) RestartXrayService() error {
 	return nil
 }
 
+// GetXrayReconcileResult 返回最近一次 Xray 配置应用的结果（热更新或重启）
+func (s *ServerService) GetXrayReconcileResult() *ReconcileResult {
+	return s.xrayService.GetLastReconcile()
+}
+
+// GetCrashReports 返回 Xray 崩溃报告列表
+func (s *ServerService) GetCrashReports() ([]*xray.CrashReport, error) {
+	return xray.ListCrashReports()
+}
+
+// GetCrashReport 返回指定崩溃报告的内容
+func (s *ServerService) GetCrashReport(name string) ([]byte, error) {
+	return xray.ReadCrashReport(name)
+}
+
+// GetOutboundHealth 返回 Xray 观测服务报告的出站存活状态、延迟历史和负载均衡器状态
+func (s *ServerService) GetOutboundHealth() *OutboundHealthReport {
+	return s.xrayService.GetOutboundHealth()
+}
+
 // detectSystemArchitecture 检测系统实际架构
 func detectSystemArchitecture() string {
 	// 尝试使用 uname -m 检测系统架构
@@ -388,109 +417,22 @@ func (s *ServerService) IsValidGeofileName(filename string) bool {
 }
 
 func (s *ServerService) UpdateGeofile(fileName string) error {
-	files := []struct {
-		URL      string
-		FileName string
-	}{
-		{"https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geoip.dat", "geoip.dat"},
-		{"https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geosite.dat", "geosite.dat"},
-		{"https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geoip.dat", "geoip_IR.dat"},
-		{"https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geosite.dat", "geosite_IR.dat"},
-		{"https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geoip.dat", "geoip_RU.dat"},
-		{"https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geosite.dat", "geosite_RU.dat"},
-	}
-
-	downloadFile := func(url, destPath string) error {
-		// 创建带超时的HTTP客户端
-		client := &http.Client{
-			Timeout: 60 * time.Second, // 60秒超时
-		}
-
-		// 创建请求并添加User-Agent头部
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return common.NewErrorf("创建下载请求失败: %v", err)
-		}
-		req.Header.Set("User-Agent", "Xray-UI-Panel/1.0")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			return common.NewErrorf("Failed to download Geofile from %s: %v", url, err)
-		}
-		defer func() { _ = resp.Body.Close() }()
-
-		// 检查HTTP状态码
-		if resp.StatusCode != http.StatusOK {
-			return common.NewErrorf("下载失败，服务器返回状态码: %d", resp.StatusCode)
-		}
-
-		//nolint:gosec
-		file, err := os.Create(destPath)
-		if err != nil {
-			return common.NewErrorf("Failed to create Geofile %s: %v", destPath, err)
-		}
-		defer func() { _ = file.Close() }()
-
-		_, err = io.Copy(file, resp.Body)
-		if err != nil {
-			return common.NewErrorf("Failed to save Geofile %s: %v", destPath, err)
-		}
-
-		return nil
+	if fileName != "" && !s.IsValidGeofileName(fileName) {
+		return common.NewErrorf("Invalid geofile name: contains unsafe path characters: %s", fileName)
 	}
 
 	var errorMessages []string
-
-	if fileName == "" {
-		for _, file := range files {
-			destPath := fmt.Sprintf("%s/%s", config.GetBinFolderPath(), file.FileName)
-
-			if err := downloadFile(file.URL, destPath); err != nil {
-				errorMessages = append(errorMessages, fmt.Sprintf("Error downloading Geofile '%s': %v", file.FileName, err))
-			}
-		}
-	} else {
-		// Use the centralized validation function
-		if !s.IsValidGeofileName(fileName) {
-			return common.NewErrorf("Invalid geofile name: contains unsafe path characters: %s", fileName)
-		}
-
-		// Ensure the filename matches exactly one from our allowlist
-		isAllowed := false
-		for _, file := range files {
-			if fileName == file.FileName {
-				isAllowed = true
-				break
-			}
-		}
-
-		if !isAllowed {
-			return common.NewErrorf("Invalid geofile name: %s not in allowlist", fileName)
-		}
-
-		destPath := fmt.Sprintf("%s/%s", config.GetBinFolderPath(), fileName)
-
-		var fileURL string
-		for _, file := range files {
-			if file.FileName == fileName {
-				fileURL = file.URL
-				break
-			}
-		}
-
-		if fileURL == "" {
-			// This should practically not be reached because of the isAllowed check above
-			errorMessages = append(errorMessages, fmt.Sprintf("File '%s' not found in the list of Geofiles", fileName))
-		} else {
-			if err := downloadFile(fileURL, destPath); err != nil {
-				errorMessages = append(errorMessages, fmt.Sprintf("Error downloading Geofile '%s': %v", fileName, err))
-			}
-		}
+	geoDataService := GeoDataService{}
+	changed, err := geoDataService.RefreshGeoSources(fileName)
+	if err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Error updating Geofile: %v", err))
 	}
 
-	err := s.RestartXrayService()
-	if err != nil {
-		errorMessages = append(errorMessages, fmt.Sprintf("Updated Geofile '%s' but Failed to start Xray: %v", fileName, err))
+	// 只有文件内容发生变化时才需要重启 Xray
+	if len(changed) > 0 {
+		if err := s.RestartXrayService(); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("Updated Geofile '%s' but Failed to start Xray: %v", strings.Join(changed, ", "), err))
+		}
 	}
 
 	if len(errorMessages) > 0 {
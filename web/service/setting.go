@@ -51,6 +51,11 @@ var defaultValueMap = map[string]string{
 	"tgLogLevel":          "warn",
 	"localLogEnabled":     "false",
 	"logStreamerEnabled":  "false",
+	"accessLogEnabled":    "false",
+	"accessLogRetention":  "7",
+	"accessLogAnonymize":  "none",
+	"deviceLimitPolicy":   "kickAll",
+	"deviceLimitThrottle": "128",
 	"tgCpu":               "80",
 	"tgLang":              "zh-CN",
 	"twoFactorEnable":     "false",
@@ -75,6 +80,8 @@ var defaultValueMap = map[string]string{
 	"subJsonRules":        "",
 	"datepicker":          "gregorian",
 	"warp":                "",
+	"xrayCoreMode":        "external",
+	"coreBackend":         "xray",
 }
 
 type SettingService struct {
@@ -456,6 +463,31 @@ func (s *SettingService) SetLogStreamerEnabled(value bool) error {
 	return s.setBool("logStreamerEnabled", value)
 }
 
+// GetAccessLogEnabled 是否将 Xray 访问日志采集为按客户端的访问记录
+func (s *SettingService) GetAccessLogEnabled() (bool, error) {
+	return s.getBool("accessLogEnabled")
+}
+
+// GetAccessLogRetentionDays 访问记录保留天数，0 表示只按总条数清理
+func (s *SettingService) GetAccessLogRetentionDays() (int, error) {
+	return s.getInt("accessLogRetention")
+}
+
+// GetAccessLogAnonymize 访问记录中来源 IP 的匿名化方式：none、truncate 或 hash
+func (s *SettingService) GetAccessLogAnonymize() (string, error) {
+	return s.getString("accessLogAnonymize")
+}
+
+// GetDeviceLimitPolicy 设备数超限时的处理策略：kickAll、rejectNewest 或 throttle
+func (s *SettingService) GetDeviceLimitPolicy() (string, error) {
+	return s.getString("deviceLimitPolicy")
+}
+
+// GetDeviceLimitThrottleSpeed throttle 策略下超限用户的限速，单位 KB/s
+func (s *SettingService) GetDeviceLimitThrottleSpeed() (int, error) {
+	return s.getInt("deviceLimitThrottle")
+}
+
 func (s *SettingService) GetTgCpu() (int, error) {
 	return s.getInt("tgCpu")
 }
@@ -649,6 +681,16 @@ func (s *SettingService) GetDatepicker() (string, error) {
 	return s.getString("datepicker")
 }
 
+// GetXrayCoreMode 返回 Xray 核心运行模式：external 启动独立进程，embedded 在面板进程内运行
+func (s *SettingService) GetXrayCoreMode() (string, error) {
+	return s.getString("xrayCoreMode")
+}
+
+// GetCoreBackend 返回代理核心后端：xray 或 sing-box
+func (s *SettingService) GetCoreBackend() (string, error) {
+	return s.getString("coreBackend")
+}
+
 func (s *SettingService) GetWarp() (string, error) {
 	return s.getString("warp")
 }
@@ -167,6 +167,24 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 					content := strings.Join(logs, "\n")
 					t.sendLongMessage(chatId, content)
 				}
+			case "device_unban":
+				deviceLimitService := DeviceLimitService{}
+				if err := deviceLimitService.Unban(dataArray[1]); err != nil {
+					t.sendCallbackAnswerTgBot(callbackQuery.ID, "❌ "+err.Error())
+					return
+				}
+				t.sendCallbackAnswerTgBot(callbackQuery.ID, "✅ 已解封，用户将在下一轮检查时恢复")
+				t.deleteMessageTgBot(chatId, callbackQuery.Message.GetMessageID())
+				t.showDeviceLimitBans(chatId)
+			case "device_whitelist":
+				deviceLimitService := DeviceLimitService{}
+				if err := deviceLimitService.AddWhitelist(dataArray[1]); err != nil {
+					t.sendCallbackAnswerTgBot(callbackQuery.ID, "❌ "+err.Error())
+					return
+				}
+				t.sendCallbackAnswerTgBot(callbackQuery.ID, "✅ 已加入白名单，不再受设备数限制")
+				t.deleteMessageTgBot(chatId, callbackQuery.Message.GetMessageID())
+				t.showDeviceLimitBans(chatId)
 			default:
 				email := dataArray[1]
 				switch dataArray[0] {
@@ -322,6 +340,7 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 				case "add_client_limit_traffic_c":
 					limitTraffic, _ := strconv.Atoi(dataArray[1])
 					client_TotalGB = int64(limitTraffic) * 1024 * 1024 * 1024
+					client_PlanId = 0
 					messageId := callbackQuery.Message.GetMessageID()
 					inbound, err := t.inboundService.GetInbound(receiver_inbound_ID)
 					if err != nil {
@@ -542,6 +561,7 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 						date = client_ExpiryTime - int64(days*24*60*60000)
 					}
 					client_ExpiryTime = date
+					client_PlanId = 0
 
 					messageId := callbackQuery.Message.GetMessageID()
 					inbound, err := t.inboundService.GetInbound(receiver_inbound_ID)
@@ -725,10 +745,32 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 					}
 					t.sendCallbackAnswerTgBot(callbackQuery.ID, t.I18nBot("tgbot.answers.errorOperation"))
 					t.searchClient(chatId, email, callbackQuery.Message.GetMessageID())
+				case "add_client_plan":
+					planId, _ := strconv.Atoi(dataArray[1])
+					plan, err := t.clientPlanService.GetPlan(planId)
+					if err != nil {
+						t.sendCallbackAnswerTgBot(callbackQuery.ID, err.Error())
+						return
+					}
+					inbound, err := t.inboundService.GetInbound(receiver_inbound_ID)
+					if err != nil {
+						t.sendCallbackAnswerTgBot(callbackQuery.ID, err.Error())
+						return
+					}
+					t.applyClientPlanDefaults(plan, inbound.Protocol)
+					message_text, err := t.BuildInboundClientDataMessage(inbound.Remark, inbound.Protocol)
+					if err != nil {
+						t.sendCallbackAnswerTgBot(callbackQuery.ID, err.Error())
+						return
+					}
+
+					t.addClient(callbackQuery.Message.GetChat().ID, message_text, callbackQuery.Message.GetMessageID())
+					t.sendCallbackAnswerTgBot(callbackQuery.ID, t.I18nBot("tgbot.answers.successfulOperation"))
 				case "add_client_ip_limit_c":
 					if len(dataArray) == 2 {
 						count, _ := strconv.Atoi(dataArray[1])
 						client_LimitIP = count
+						client_PlanId = 0
 					}
 
 					messageId := callbackQuery.Message.GetMessageID()
@@ -1136,6 +1178,8 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 			),
 		)
 		t.editMessageCallbackTgBot(chatId, callbackQuery.Message.GetMessageID(), inlineKeyboard)
+	case "add_client_ch_default_plan":
+		t.showClientPlans(chatId, callbackQuery.Message.GetMessageID())
 	case "add_client_ch_default_ip_limit":
 		inlineKeyboard := tu.InlineKeyboard(
 			tu.InlineKeyboardRow(
@@ -1190,7 +1234,7 @@ func (t *Tgbot) answerCallback(callbackQuery *telego.CallbackQuery, isAdmin bool
 		}
 		t.addClient(chatId, message_text, messageId)
 		t.sendCallbackAnswerTgBot(callbackQuery.ID, t.I18nBot("tgbot.answers.canceled", "Email=="+client_Email))
-	case "add_client_default_ip_limit":
+	case "add_client_default_ip_limit", "add_client_default_plan":
 		messageId := callbackQuery.Message.GetMessageID()
 		inbound, err := t.inboundService.GetInbound(receiver_inbound_ID)
 		if err != nil {
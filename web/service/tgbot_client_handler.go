@@ -86,9 +86,10 @@ func (t *Tgbot) BuildJSONForProtocol(protocol model.Protocol) (string, error) {
                 "tgId": "%s",
                 "subId": "%s",
                 "comment": "%s",
-                "reset": %d
+                "reset": %d,
+                "planId": %d
             }]
-        }`, client_Id, client_Security, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset)
+        }`, client_Id, client_Security, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset, client_PlanId)
 
 	case model.VLESS:
 		jsonString = fmt.Sprintf(`{
@@ -103,9 +104,10 @@ func (t *Tgbot) BuildJSONForProtocol(protocol model.Protocol) (string, error) {
                 "tgId": "%s",
                 "subId": "%s",
                 "comment": "%s",
-                "reset": %d
+                "reset": %d,
+                "planId": %d
             }]
-        }`, client_Id, client_Flow, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset)
+        }`, client_Id, client_Flow, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset, client_PlanId)
 
 	case model.Trojan:
 		jsonString = fmt.Sprintf(`{
@@ -119,9 +121,10 @@ func (t *Tgbot) BuildJSONForProtocol(protocol model.Protocol) (string, error) {
                 "tgId": "%s",
                 "subId": "%s",
                 "comment": "%s",
-                "reset": %d
+                "reset": %d,
+                "planId": %d
             }]
-        }`, client_TrPassword, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset)
+        }`, client_TrPassword, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset, client_PlanId)
 
 	case model.Shadowsocks:
 		jsonString = fmt.Sprintf(`{
@@ -136,9 +139,10 @@ func (t *Tgbot) BuildJSONForProtocol(protocol model.Protocol) (string, error) {
                 "tgId": "%s",
                 "subId": "%s",
                 "comment": "%s",
-                "reset": %d
+                "reset": %d,
+                "planId": %d
             }]
-        }`, client_Method, client_ShPassword, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset)
+        }`, client_Method, client_ShPassword, client_Email, client_LimitIP, client_TotalGB, client_ExpiryTime, client_Enable, client_TgID, client_SubID, client_Comment, client_Reset, client_PlanId)
 
 	default:
 		return "", common.ErrInvalidProtocol
@@ -350,6 +354,36 @@ func (t *Tgbot) searchClientIps(chatId int64, email string, messageID ...int) {
 	}
 }
 
+// showDeviceLimitBans 列出仍在生效的设备超限处理，每个客户端提供解封和加入白名单按钮
+func (t *Tgbot) showDeviceLimitBans(chatId int64) {
+	deviceLimitService := DeviceLimitService{}
+	bans, err := deviceLimitService.GetActiveBans()
+	if err != nil {
+		t.SendMsgToTgbot(chatId, fmt.Sprintf("❌ 获取封禁记录失败: %v", err))
+		return
+	}
+	if len(bans) == 0 {
+		t.SendMsgToTgbot(chatId, "✅ 当前没有因设备超限被处理的用户")
+		return
+	}
+
+	var output strings.Builder
+	output.WriteString("🚫 <b>设备超限处理</b>\n")
+	rows := make([][]telego.InlineKeyboardButton, 0, len(bans)+1)
+	for _, ban := range bans {
+		output.WriteString(fmt.Sprintf("\n👤 %s\n  策略: %s  限制: %d  在线IP: %d\n  时间: %s\n",
+			ban.Email, ban.Policy, ban.Limit, ban.ActiveIPs, time.Unix(ban.BannedAt, 0).Format("2006-01-02 15:04:05")))
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton("🔓 解封 "+ban.Email).WithCallbackData(t.encodeQuery("device_unban "+ban.Email)),
+			tu.InlineKeyboardButton("🛡 白名单").WithCallbackData(t.encodeQuery("device_whitelist "+ban.Email)),
+		))
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton("❌ 关闭").WithCallbackData(t.encodeQuery("close_menu")),
+	))
+	t.SendMsgToTgbot(chatId, output.String(), tu.InlineKeyboard(rows...))
+}
+
 func (t *Tgbot) clientTelegramUserInfo(chatId int64, email string, messageID ...int) {
 	traffic, client, err := t.inboundService.GetClientByEmail(email)
 	if err != nil {
@@ -467,6 +501,9 @@ func (t *Tgbot) addClient(chatId int64, msg string, messageID ...int) {
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.change_comment")).WithCallbackData("add_client_ch_default_comment"),
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.ipLimit")).WithCallbackData("add_client_ch_default_ip_limit"),
 			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.plan")).WithCallbackData("add_client_ch_default_plan"),
+			),
 			tu.InlineKeyboardRow(
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitDisable")).WithCallbackData("add_client_submit_disable"),
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitEnable")).WithCallbackData("add_client_submit_enable"),
@@ -494,6 +531,9 @@ func (t *Tgbot) addClient(chatId int64, msg string, messageID ...int) {
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.change_comment")).WithCallbackData("add_client_ch_default_comment"),
 				tu.InlineKeyboardButton("ip limit").WithCallbackData("add_client_ch_default_ip_limit"),
 			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.plan")).WithCallbackData("add_client_ch_default_plan"),
+			),
 			tu.InlineKeyboardRow(
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitDisable")).WithCallbackData("add_client_submit_disable"),
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitEnable")).WithCallbackData("add_client_submit_enable"),
@@ -521,6 +561,9 @@ func (t *Tgbot) addClient(chatId int64, msg string, messageID ...int) {
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.change_comment")).WithCallbackData("add_client_ch_default_comment"),
 				tu.InlineKeyboardButton("ip limit").WithCallbackData("add_client_ch_default_ip_limit"),
 			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.plan")).WithCallbackData("add_client_ch_default_plan"),
+			),
 			tu.InlineKeyboardRow(
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitDisable")).WithCallbackData("add_client_submit_disable"),
 				tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.submitEnable")).WithCallbackData("add_client_submit_enable"),
@@ -538,6 +581,48 @@ func (t *Tgbot) addClient(chatId int64, msg string, messageID ...int) {
 	}
 }
 
+// ================== 套餐选择 ==================
+
+// showClientPlans 显示可用于新客户端的套餐列表
+func (t *Tgbot) showClientPlans(chatId int64, messageID int) {
+	plans, err := t.clientPlanService.GetPlans()
+	if err != nil {
+		t.SendMsgToTgbot(chatId, fmt.Sprintf("❌ 获取套餐失败: %v", err))
+		return
+	}
+	rows := [][]telego.InlineKeyboardButton{
+		tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(t.I18nBot("tgbot.buttons.cancel")).WithCallbackData(t.encodeQuery("add_client_default_plan")),
+		),
+	}
+	for _, plan := range plans {
+		traffic := t.I18nBot("tgbot.unlimited")
+		if plan.TotalGB > 0 {
+			traffic = common.FormatTraffic(plan.TotalGB)
+		}
+		label := fmt.Sprintf("%s · %s · %d天", plan.Name, traffic, plan.ExpiryDays)
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(label).WithCallbackData(t.encodeQuery("add_client_plan "+strconv.Itoa(plan.Id))),
+		))
+	}
+	t.editMessageCallbackTgBot(chatId, messageID, tu.InlineKeyboard(rows...))
+}
+
+// applyClientPlanDefaults 按套餐设置新客户端的限制，提交时由 AddInboundClient 按 planId 重新应用
+func (t *Tgbot) applyClientPlanDefaults(plan *model.ClientPlan, protocol model.Protocol) {
+	client_PlanId = plan.Id
+	client_TotalGB = plan.TotalGB
+	client_LimitIP = plan.LimitIP
+	client_Reset = plan.Reset
+	client_ExpiryTime = 0
+	if plan.ExpiryDays > 0 {
+		client_ExpiryTime = time.Now().AddDate(0, 0, plan.ExpiryDays).UnixMilli()
+	}
+	if protocol == model.VLESS {
+		client_Flow = plan.Flow
+	}
+}
+
 // ================== 初始化客户端默认值 ==================
 
 func (t *Tgbot) initClientDefaults() {
@@ -556,6 +641,7 @@ func (t *Tgbot) initClientDefaults() {
 	client_ShPassword = random.Base64Bytes(32)
 	client_TrPassword = random.LowerNumSeq(10)
 	client_Method = ""
+	client_PlanId = 0
 }
 
 // ================== 批量复制链接 ==================
@@ -163,6 +163,13 @@ func (t *Tgbot) answerCommand(message *telego.Message, chatId int64, isAdmin boo
 		} else {
 			handleUnknownCommand()
 		}
+	case "bans":
+		onlyMessage = true
+		if isAdmin {
+			t.showDeviceLimitBans(chatId)
+		} else {
+			handleUnknownCommand()
+		}
 	default:
 		handleUnknownCommand()
 	}
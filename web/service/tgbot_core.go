@@ -60,6 +60,7 @@ var (
 	client_ShPassword   string
 	client_TrPassword   string
 	client_Method       string
+	client_PlanId       int
 )
 
 var (
@@ -76,11 +77,12 @@ const (
 )
 
 type Tgbot struct {
-	inboundService *InboundService
-	settingService *SettingService
-	serverService  *ServerService
-	xrayService    *XrayService
-	lastStatus     *Status
+	inboundService    *InboundService
+	settingService    *SettingService
+	serverService     *ServerService
+	xrayService       *XrayService
+	clientPlanService *ClientPlanService
+	lastStatus        *Status
 
 	// state 封装了 Bot 的运行时状态（新架构）
 	// 目前保持向后兼容，逐步将全局变量迁移到此处
@@ -136,15 +138,17 @@ func NewTgBot(
 	settingService *SettingService,
 	serverService *ServerService,
 	xrayService *XrayService,
+	clientPlanService *ClientPlanService,
 	lastStatus *Status,
 ) *Tgbot {
 	t := &Tgbot{
-		inboundService: inboundService,
-		settingService: settingService,
-		serverService:  serverService,
-		xrayService:    xrayService,
-		lastStatus:     lastStatus,
-		state:          NewBotState(),
+		inboundService:    inboundService,
+		settingService:    settingService,
+		serverService:     serverService,
+		xrayService:       xrayService,
+		clientPlanService: clientPlanService,
+		lastStatus:        lastStatus,
+		state:             NewBotState(),
 	}
 
 	return t
@@ -285,6 +289,7 @@ func (t *Tgbot) Start(i18nFS embed.FS) error {
 
 			{Command: "restartx", Description: "重启X-Panel面板"},
 			{Command: "xrayversion", Description: "管理Xray版本"},
+			{Command: "bans", Description: "设备超限封禁管理"},
 		},
 	})
 	if err != nil {
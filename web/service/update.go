@@ -15,7 +15,6 @@ import (
 	"strings"
 	"time"
 
-	"x-ui/config"
 	"x-ui/logger"
 )
 
@@ -422,13 +421,18 @@ func (s *ServerService) UpdatePanel(version string) error {
 				logger.Warningf("重新加载 systemd 失败: %v, 输出: %s", err, string(output))
 			}
 
-			cmd = exec.Command("systemctl", "restart", "x-ui")
-			output, err = cmd.CombinedOutput()
-			if err != nil {
-				updateErr = fmt.Errorf("重启面板服务失败: %v, 输出: %s", err, string(output))
-				logger.Errorf("重启面板服务失败: %v, 输出: %s", err, string(output))
+			// 优先在进程内替换二进制并交接监听套接字，面板端口不中断；不支持时回退为 systemctl 重启
+			if err := NewPanelService().UpgradePanel(5 * time.Second); err == nil {
+				logger.Info("已安排面板平滑升级，新二进制将接管现有监听端口")
 			} else {
-				logger.Info("面板服务重启成功")
+				cmd = exec.Command("systemctl", "restart", "x-ui")
+				output, err = cmd.CombinedOutput()
+				if err != nil {
+					updateErr = fmt.Errorf("重启面板服务失败: %v, 输出: %s", err, string(output))
+					logger.Errorf("重启面板服务失败: %v, 输出: %s", err, string(output))
+				} else {
+					logger.Info("面板服务重启成功")
+				}
 			}
 
 			// 停止其他可能的服务
@@ -486,87 +490,24 @@ func (s *ServerService) UpdateGeoData() error {
 		}
 
 		var updateErr error
-
-		// 2. 定义要更新的 Geo 文件列表
-		geoFiles := []struct {
-			URL      string
-			FileName string
-		}{
-			{"https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geoip.dat", "geoip.dat"},
-			{"https://github.com/Loyalsoldier/v2ray-rules-dat/releases/latest/download/geosite.dat", "geosite.dat"},
-			{"https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geoip.dat", "geoip_IR.dat"},
-			{"https://github.com/chocolate4u/Iran-v2ray-rules/releases/latest/download/geosite.dat", "geosite_IR.dat"},
-			{"https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geoip.dat", "geoip_RU.dat"},
-			{"https://github.com/runetfreedom/russia-v2ray-rules-dat/releases/latest/download/geosite.dat", "geosite_RU.dat"},
-		}
-
-		// 3. 下载并更新所有 Geo 文件
-		downloadFile := func(url, destPath string) error {
-			// 创建带超时的HTTP客户端
-			client := &http.Client{
-				Timeout: 60 * time.Second, // 60秒超时
-			}
-
-			// 创建请求并添加User-Agent头部
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				return fmt.Errorf("创建下载请求失败: %v", err)
-			}
-			req.Header.Set("User-Agent", "X-Panel/1.0")
-
-			resp, err := client.Do(req)
-			if err != nil {
-				return fmt.Errorf("下载失败: %v", err)
-			}
-			defer func() { _ = resp.Body.Close() }()
-
-			// 检查HTTP状态码
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("下载失败，服务器返回状态码: %d", resp.StatusCode)
-			}
-
-			// 备份现有文件（如果存在）
-			if _, err := os.Stat(destPath); err == nil {
-				backupPath := destPath + ".bak"
-				if err := os.Rename(destPath, backupPath); err != nil {
-					logger.Warningf("备份文件 %s 失败: %v", destPath, err)
-				} else {
-					logger.Infof("成功备份文件: %s", destPath)
-				}
-			}
-
-			//nolint:gosec
-			file, err := os.Create(destPath)
-			if err != nil {
-				return fmt.Errorf("创建文件 %s 失败: %v", destPath, err)
-			}
-			defer func() { _ = file.Close() }()
-
-			_, err = io.Copy(file, resp.Body)
-			if err != nil {
-				return fmt.Errorf("保存文件 %s 失败: %v", destPath, err)
-			}
-
-			return nil
-		}
-
 		var errorMessages []string
-		var successCount int
 
-		for _, file := range geoFiles {
-			destPath := fmt.Sprintf("%s/%s", config.GetBinFolderPath(), file.FileName)
-			logger.Infof("正在下载并更新: %s", file.FileName)
-
-			if err := downloadFile(file.URL, destPath); err != nil {
-				errorMessages = append(errorMessages, fmt.Sprintf("❌ 更新 %s 失败: %v", file.FileName, err))
-				logger.Errorf("更新 %s 失败: %v", file.FileName, err)
-			} else {
-				successCount++
-				logger.Infof("✅ 成功更新: %s", file.FileName)
+		// 2. 按地理数据来源下载、校验并更新文件，内容未变化的文件会被跳过
+		geoDataService := GeoDataService{}
+		changed, err := geoDataService.RefreshGeoSources("")
+		if err != nil {
+			updateErr = err
+			for _, line := range strings.Split(err.Error(), "\n") {
+				errorMessages = append(errorMessages, fmt.Sprintf("❌ 更新 %s", line))
 			}
+			logger.Errorf("更新 Geo 文件失败: %v", err)
+		}
+		successCount := len(changed)
+		for _, fileName := range changed {
+			logger.Infof("✅ 成功更新: %s", fileName)
 		}
 
-		// 4. 重启 Xray 服务
+		// 3. 只有文件内容发生变化时才重启 Xray 服务
 		if successCount > 0 {
 			logger.Info("重启 Xray 服务以应用新的 Geo 数据...")
 			err := s.RestartXrayService()
@@ -579,26 +520,21 @@ func (s *ServerService) UpdateGeoData() error {
 			}
 		}
 
-		// 5. 发送结果通知
+		// 4. 发送结果通知
 		if tgAvailable {
-			if successCount > 0 {
+			switch {
+			case successCount > 0 && len(errorMessages) == 0:
 				// 更新成功通知
 				successMessage := fmt.Sprintf("🎉 <b>Geo 数据更新成功！</b>\n\n✅ 成功更新 %d 个文件\n🔄 Xray 服务已重启\n\n更新的文件:\n", successCount)
-				for _, file := range geoFiles {
-					successMessage += fmt.Sprintf("• %s\n", file.FileName)
+				for _, fileName := range changed {
+					successMessage += fmt.Sprintf("• %s\n", fileName)
 				}
 				successMessage += "\n✨ Geo 数据已生效！"
 
 				if err := s.tgService.SendMessage(successMessage); err != nil {
 					logger.Warningf("发送 Geo 数据更新成功通知失败: %v", err)
 				}
-			} else if successCount == 0 {
-				// 完全失败通知
-				failMessage := "❌ <b>Geo 数据更新失败</b>\n\n没有成功更新任何文件，请检查网络连接和日志。"
-				if err := s.tgService.SendMessage(failMessage); err != nil {
-					logger.Warningf("发送 Geo 数据更新失败通知失败: %v", err)
-				}
-			} else {
+			case successCount > 0:
 				// 部分成功通知
 				warningMessage := fmt.Sprintf("⚠️ <b>Geo 数据更新完成（部分失败）</b>\n\n✅ 成功更新 %d 个文件\n❌ 部分文件更新失败\n\n", successCount)
 				for _, errorMsg := range errorMessages {
@@ -609,6 +545,20 @@ func (s *ServerService) UpdateGeoData() error {
 				if err := s.tgService.SendMessage(warningMessage); err != nil {
 					logger.Warningf("发送 Geo 数据更新警告通知失败: %v", err)
 				}
+			case len(errorMessages) > 0:
+				// 完全失败通知
+				failMessage := "❌ <b>Geo 数据更新失败</b>\n\n没有成功更新任何文件，请检查网络连接和日志。\n\n"
+				for _, errorMsg := range errorMessages {
+					failMessage += errorMsg + "\n"
+				}
+				if err := s.tgService.SendMessage(failMessage); err != nil {
+					logger.Warningf("发送 Geo 数据更新失败通知失败: %v", err)
+				}
+			default:
+				// 文件均未变化，无需重启
+				if err := s.tgService.SendMessage("✅ <b>Geo 数据已是最新</b>\n\n所有文件内容均未变化，Xray 无需重启。"); err != nil {
+					logger.Warningf("发送 Geo 数据更新通知失败: %v", err)
+				}
 			}
 		}
 
@@ -617,7 +567,7 @@ func (s *ServerService) UpdateGeoData() error {
 		} else if successCount > 0 {
 			logger.Infof("Geo 数据更新完成，成功更新 %d 个文件", successCount)
 		} else {
-			logger.Warning("Geo 数据更新失败，没有成功更新任何文件")
+			logger.Info("Geo 数据已是最新，没有文件发生变化")
 		}
 	}()
 
@@ -2,175 +2,878 @@ package service
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
 	"x-ui/logger"
 	"x-ui/util/common"
+	"x-ui/util/wireguard"
+)
+
+const (
+	// legacyWarpAccountName 旧版单账户接口（/xray/warp/:action）使用的账户名
+	legacyWarpAccountName = "warp"
+	// warpAPITimeout 访问 Cloudflare WARP API 的超时时间
+	warpAPITimeout = 30 * time.Second
+	// warpMaxFailures 连续失败达到该次数后轮换出站使用的账户
+	warpMaxFailures = 3
+	// warpDefaultEndpoint 默认的 WARP 接入点
+	warpDefaultEndpoint = "engage.cloudflareclient.com:2408"
+)
+
+// warpAPIBase Cloudflare WARP 注册接口地址，测试时替换为本地服务
+var warpAPIBase = "https://api.cloudflareclient.com/v0a2158"
+
+var (
+	// warpEndpointHosts 可选的 WARP 接入地址，没有空闲账户可轮换时依次切换
+	warpEndpointHosts = []string{
+		"engage.cloudflareclient.com",
+		"162.159.192.1",
+		"162.159.193.10",
+		"162.159.195.1",
+		"188.114.96.1",
+		"188.114.97.1",
+	}
+	// warpEndpointPorts WARP 接入点接受的 UDP 端口
+	warpEndpointPorts = []int{
+		2408, 500, 854, 859, 864, 878, 880, 890, 891, 894, 903, 908, 928, 934, 939, 942,
+		943, 945, 946, 955, 968, 987, 988, 1002, 1010, 1014, 1018, 1070, 1074, 1180, 1387,
+		1701, 1843, 2371, 2506, 3138, 3476, 3581, 3854, 4177, 4198, 4233, 4500, 5279, 5956,
+		7103, 7152, 7156, 7281, 7559, 8319, 8742, 8854, 8886,
+	}
 )
 
 type WarpService struct {
-	SettingService
+	XraySettingService
+
+	warpAccountRepo repository.WarpAccountRepository
 }
 
 // NewWarpService 创建 WarpService 实例
 func NewWarpService(settingService *SettingService) *WarpService {
-	return &WarpService{SettingService: *settingService}
+	return &WarpService{XraySettingService: XraySettingService{SettingService: *settingService}}
 }
 
-func (s *WarpService) GetWarpData() (string, error) {
+// getWarpAccountRepo 返回 WarpAccountRepository，支持延迟初始化
+func (s *WarpService) getWarpAccountRepo() repository.WarpAccountRepository {
+	if s.warpAccountRepo == nil {
+		s.warpAccountRepo = repository.NewWarpAccountRepository(database.GetDB())
+	}
+	return s.warpAccountRepo
+}
+
+// WarpEndpoints 可选的接入地址和端口
+type WarpEndpoints struct {
+	Default string   `json:"default"`
+	Hosts   []string `json:"hosts"`
+	Ports   []int    `json:"ports"`
+}
+
+// WarpOutboundRequest 创建 WARP 出站的参数。AccountId 为 0 时自动选择空闲账户；
+// Domains/IPs 非空时同时添加指向该出站的路由规则
+type WarpOutboundRequest struct {
+	Tag       string   `json:"tag" form:"tag"`
+	AccountId int      `json:"accountId" form:"accountId"`
+	Endpoint  string   `json:"endpoint" form:"endpoint"`
+	Domains   []string `json:"domains" form:"domains"`
+	IPs       []string `json:"ips" form:"ips"`
+}
+
+// warpRegistration Cloudflare 注册信息中用到的字段
+type warpRegistration struct {
+	Id      string `json:"id"`
+	Token   string `json:"token"`
+	Account struct {
+		AccountType string `json:"account_type"`
+		License     string `json:"license"`
+		Quota       int64  `json:"quota"`
+	} `json:"account"`
+	Config struct {
+		ClientId  string `json:"client_id"`
+		Interface struct {
+			Addresses struct {
+				V4 string `json:"v4"`
+				V6 string `json:"v6"`
+			} `json:"addresses"`
+		} `json:"interface"`
+		Peers []struct {
+			PublicKey string `json:"public_key"`
+		} `json:"peers"`
+	} `json:"config"`
+}
+
+// warpAPIRequest 调用 WARP API，返回原始响应；success 为 false 时返回其中的错误信息
+func warpAPIRequest(method, path, token string, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, warpAPIBase+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("CF-Client-Version", "a-7.21-0721")
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: warpAPITimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Success *bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(data, &result) == nil && result.Success != nil && !*result.Success {
+		if len(result.Errors) > 0 {
+			return nil, common.NewError(result.Errors[0].Code, result.Errors[0].Message)
+		}
+		return nil, common.NewError("warp api request failed")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, common.NewErrorf("warp api returned status %d", resp.StatusCode)
+	}
+	return data, nil
+}
+
+// registerWarp 注册新设备，返回注册信息及原始响应
+func registerWarp(publicKey string) (*warpRegistration, []byte, error) {
+	hostName, _ := os.Hostname()
+	body := map[string]string{
+		"key":   publicKey,
+		"tos":   time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"type":  "PC",
+		"model": "x-ui",
+		"name":  hostName,
+	}
+	data, err := warpAPIRequest(http.MethodPost, "/reg", "", body)
+	if err != nil {
+		return nil, nil, err
+	}
+	reg := &warpRegistration{}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, nil, err
+	}
+	if reg.Id == "" || reg.Token == "" {
+		return nil, nil, common.NewError("warp registration returned no device id")
+	}
+	return reg, data, nil
+}
+
+// fetchWarpRegistration 读取账户的注册信息
+func fetchWarpRegistration(account *model.WarpAccount) (*warpRegistration, []byte, error) {
+	data, err := warpAPIRequest(http.MethodGet, "/reg/"+account.DeviceId, account.AccessToken, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	reg := &warpRegistration{}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, nil, err
+	}
+	return reg, data, nil
+}
+
+// applyWarpRegistration 将注册信息写入账户
+func applyWarpRegistration(account *model.WarpAccount, reg *warpRegistration) {
+	if reg.Id != "" {
+		account.DeviceId = reg.Id
+	}
+	if reg.Token != "" {
+		account.AccessToken = reg.Token
+	}
+	if reg.Account.License != "" {
+		account.LicenseKey = reg.Account.License
+	}
+	account.AccountType = reg.Account.AccountType
+	account.Quota = reg.Account.Quota
+	if reg.Config.ClientId != "" {
+		account.ClientId = reg.Config.ClientId
+	}
+	if addrs := reg.Config.Interface.Addresses; addrs.V4 != "" || addrs.V6 != "" {
+		account.AddressV4 = addrs.V4
+		account.AddressV6 = addrs.V6
+	}
+	if len(reg.Config.Peers) > 0 && reg.Config.Peers[0].PublicKey != "" {
+		account.PeerPublicKey = reg.Config.Peers[0].PublicKey
+	}
+}
+
+// =============================================================================
+// 账户
+// =============================================================================
+
+// migrateLegacyWarp 将旧版 warp 设置中的单个注册导入为账户
+func (s *WarpService) migrateLegacyWarp() error {
+	count, err := s.getWarpAccountRepo().Count()
+	if err != nil || count > 0 {
+		return err
+	}
 	warp, err := s.GetWarp()
+	if err != nil || warp == "" {
+		return err
+	}
+	var warpData map[string]string
+	if err := json.Unmarshal([]byte(warp), &warpData); err != nil {
+		logger.Warning("Ignore invalid legacy warp setting:", err)
+		return nil
+	}
+	account := &model.WarpAccount{
+		Name:        legacyWarpAccountName,
+		DeviceId:    warpData["device_id"],
+		AccessToken: warpData["access_token"],
+		PrivateKey:  warpData["private_key"],
+		LicenseKey:  warpData["license_key"],
+		Enable:      true,
+		Healthy:     true,
+	}
+	if err := s.getWarpAccountRepo().Create(account); err != nil {
+		return err
+	}
+	return s.SetWarp("")
+}
+
+// GetWarpAccounts 返回全部 WARP 账户，并清除已不存在的出站的关联
+func (s *WarpService) GetWarpAccounts() ([]*model.WarpAccount, error) {
+	if err := s.migrateLegacyWarp(); err != nil {
+		return nil, err
+	}
+	accounts, err := s.getWarpAccountRepo().FindAll()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return warp, nil
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return accounts, nil
+	}
+	tags := t.outboundTags()
+	for _, account := range accounts {
+		if account.OutboundTag != "" && !tags[account.OutboundTag] {
+			account.OutboundTag = ""
+			if err := s.getWarpAccountRepo().Update(account); err != nil {
+				logger.Warning("Failed to update warp account:", err)
+			}
+		}
+	}
+	return accounts, nil
 }
 
-func (s *WarpService) DelWarpData() error {
-	err := s.SetWarp("")
+// CreateWarpAccount 注册新的 WARP 账户，license 非空时同时绑定 WARP+ 许可证
+func (s *WarpService) CreateWarpAccount(name string, license string) (*model.WarpAccount, error) {
+	if err := s.migrateLegacyWarp(); err != nil {
+		return nil, err
+	}
+	if !tagPrefixPattern.MatchString(name) {
+		return nil, common.NewErrorf("invalid warp account name: %s", name)
+	}
+	if _, err := s.getWarpAccountRepo().FindByName(name); err == nil {
+		return nil, common.NewErrorf("warp account %s already exists", name)
+	}
+	account, _, err := s.registerWarpAccount(name, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if license != "" {
+		if err := s.SetWarpAccountLicense(account.Id, license); err != nil {
+			return account, err
+		}
+		return s.getWarpAccountRepo().FindByID(account.Id)
+	}
+	return account, nil
+}
+
+// registerWarpAccount 注册设备并保存为账户；privateKey 为空时自动生成密钥对
+func (s *WarpService) registerWarpAccount(name string, privateKey string, publicKey string) (*model.WarpAccount, []byte, error) {
+	if privateKey == "" {
+		var err error
+		if privateKey, publicKey, err = wireguard.GenerateKeyPair(); err != nil {
+			return nil, nil, err
+		}
+	}
+	reg, raw, err := registerWarp(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	account := &model.WarpAccount{
+		Name:       name,
+		PrivateKey: privateKey,
+		Enable:     true,
+		Healthy:    true,
+		LastCheck:  time.Now().Unix(),
+	}
+	applyWarpRegistration(account, reg)
+	if err := s.getWarpAccountRepo().Create(account); err != nil {
+		return nil, nil, err
+	}
+	return account, raw, nil
+}
+
+// SetWarpAccountLicense 为账户绑定 WARP+ 许可证，并刷新账户类型和流量配额
+func (s *WarpService) SetWarpAccountLicense(id int, license string) error {
+	account, err := s.getWarpAccountRepo().FindByID(id)
 	if err != nil {
 		return err
 	}
-	return nil
+	if strings.TrimSpace(license) == "" {
+		return common.NewError("warp license is required")
+	}
+	path := fmt.Sprintf("/reg/%s/account", account.DeviceId)
+	if _, err := warpAPIRequest(http.MethodPut, path, account.AccessToken, map[string]string{"license": license}); err != nil {
+		return err
+	}
+	account.LicenseKey = license
+	if reg, _, err := fetchWarpRegistration(account); err == nil {
+		applyWarpRegistration(account, reg)
+	} else {
+		logger.Warning("Failed to refresh warp account after setting license:", err)
+	}
+	return s.getWarpAccountRepo().Update(account)
 }
 
-func (s *WarpService) GetWarpConfig() (string, error) {
-	var warpData map[string]string
-	warp, err := s.GetWarp()
+// SetWarpAccountEnable 启用或停用账户，停用的账户不会被用于轮换
+func (s *WarpService) SetWarpAccountEnable(id int, enable bool) error {
+	account, err := s.getWarpAccountRepo().FindByID(id)
 	if err != nil {
-		return "", err
+		return err
 	}
-	err = json.Unmarshal([]byte(warp), &warpData)
+	account.Enable = enable
+	return s.getWarpAccountRepo().Update(account)
+}
+
+// DelWarpAccount 删除账户；正在被出站使用的账户需要先轮换或删除出站
+func (s *WarpService) DelWarpAccount(id int) error {
+	// 与创建出站互斥，避免删除刚被出站占用的账户
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	accounts, err := s.GetWarpAccounts()
 	if err != nil {
-		return "", err
+		return err
+	}
+	for _, account := range accounts {
+		if account.Id != id {
+			continue
+		}
+		if account.OutboundTag != "" {
+			return common.NewErrorf("warp account %s is used by outbound %s", account.Name, account.OutboundTag)
+		}
+		return s.getWarpAccountRepo().Delete(id)
 	}
+	return common.NewErrorf("warp account %d not found", id)
+}
 
-	url := fmt.Sprintf("https://api.cloudflareclient.com/v0a2158/reg/%s", warpData["device_id"])
+// =============================================================================
+// 出站
+// =============================================================================
 
-	req, err := http.NewRequest("GET", url, nil)
+// validateWarpEndpoint 检查接入点格式，端口必须是 WARP 接受的端口之一
+func validateWarpEndpoint(endpoint string) error {
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" {
+		return common.NewErrorf("invalid warp endpoint: %s", endpoint)
+	}
+	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		return "", err
+		return common.NewErrorf("invalid warp endpoint port: %s", endpoint)
+	}
+	for _, p := range warpEndpointPorts {
+		if p == port {
+			return nil
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+warpData["access_token"])
+	return common.NewErrorf("port %d is not a warp endpoint port", port)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// GetWarpEndpoints 返回可选的接入地址和端口
+func (s *WarpService) GetWarpEndpoints() *WarpEndpoints {
+	return &WarpEndpoints{
+		Default: warpDefaultEndpoint,
+		Hosts:   append([]string(nil), warpEndpointHosts...),
+		Ports:   append([]int(nil), warpEndpointPorts...),
+	}
+}
+
+// nextWarpEndpoint 返回候选列表中当前接入地址之后的下一个地址，端口保持不变
+func nextWarpEndpoint(endpoint string) string {
+	host, port, err := net.SplitHostPort(endpoint)
 	if err != nil {
-		return "", err
+		return warpDefaultEndpoint
 	}
-	defer func() { _ = resp.Body.Close() }()
-	buffer := &bytes.Buffer{}
-	_, err = buffer.ReadFrom(resp.Body)
+	for i, h := range warpEndpointHosts {
+		if h == host {
+			return net.JoinHostPort(warpEndpointHosts[(i+1)%len(warpEndpointHosts)], port)
+		}
+	}
+	return net.JoinHostPort(warpEndpointHosts[0], port)
+}
+
+// warpReserved 将 client_id 解码为 WireGuard reserved 字段
+func warpReserved(clientId string) []int {
+	decoded, err := base64.StdEncoding.DecodeString(clientId)
 	if err != nil {
-		return "", err
+		return nil
+	}
+	reserved := make([]int, len(decoded))
+	for i, b := range decoded {
+		reserved[i] = int(b)
+	}
+	return reserved
+}
+
+// buildWarpOutbound 生成使用账户的 WireGuard 出站；base 非空时保留其中的其他字段（如 mtu、streamSettings）
+func buildWarpOutbound(base json.RawMessage, tag string, account *model.WarpAccount, endpoint string) (json.RawMessage, error) {
+	outbound := map[string]any{}
+	settings := map[string]any{
+		"mtu":            1420,
+		"domainStrategy": "ForceIP",
+		"noKernelTun":    false,
+	}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &outbound); err != nil {
+			return nil, common.NewError("invalid outbound:", err)
+		}
+		if existing, ok := outbound["settings"].(map[string]any); ok {
+			settings = existing
+		}
 	}
 
-	return buffer.String(), nil
+	var addresses []string
+	if account.AddressV4 != "" {
+		addresses = append(addresses, account.AddressV4+"/32")
+	}
+	if account.AddressV6 != "" {
+		addresses = append(addresses, account.AddressV6+"/128")
+	}
+	if account.PrivateKey == "" || account.PeerPublicKey == "" || len(addresses) == 0 {
+		return nil, common.NewErrorf("warp account %s has no wireguard config, refresh it first", account.Name)
+	}
+	settings["secretKey"] = account.PrivateKey
+	settings["address"] = addresses
+	if reserved := warpReserved(account.ClientId); len(reserved) > 0 {
+		settings["reserved"] = reserved
+	} else {
+		delete(settings, "reserved")
+	}
+	settings["peers"] = []map[string]any{{
+		"publicKey":  account.PeerPublicKey,
+		"allowedIPs": []string{"0.0.0.0/0", "::/0"},
+		"endpoint":   endpoint,
+	}}
+
+	outbound["tag"] = tag
+	outbound["protocol"] = "wireguard"
+	outbound["settings"] = settings
+	return json.Marshal(outbound)
 }
 
-func (s *WarpService) RegWarp(secretKey string, publicKey string) (string, error) {
-	tos := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
-	hostName, _ := os.Hostname()
-	data := fmt.Sprintf(`{"key":"%s","tos":"%s","type": "PC","model": "x-ui", "name": "%s"}`, publicKey, tos, hostName)
+// warpOutboundEndpoint 读取出站当前使用的接入点
+func warpOutboundEndpoint(raw json.RawMessage) string {
+	var outbound struct {
+		Settings struct {
+			Peers []struct {
+				Endpoint string `json:"endpoint"`
+			} `json:"peers"`
+		} `json:"settings"`
+	}
+	if json.Unmarshal(raw, &outbound) == nil && len(outbound.Settings.Peers) > 0 && outbound.Settings.Peers[0].Endpoint != "" {
+		return outbound.Settings.Peers[0].Endpoint
+	}
+	return warpDefaultEndpoint
+}
 
-	url := "https://api.cloudflareclient.com/v0a2158/reg"
+// pickSpareWarpAccount 选择一个启用、健康且未被使用的账户，优先 WARP+ 账户
+func (s *WarpService) pickSpareWarpAccount(exclude int) (*model.WarpAccount, error) {
+	accounts, err := s.getWarpAccountRepo().FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var picked *model.WarpAccount
+	for _, account := range accounts {
+		if account.Id == exclude || !account.Enable || !account.Healthy || account.OutboundTag != "" || account.PeerPublicKey == "" {
+			continue
+		}
+		if picked == nil || (picked.AccountType != "limited" && account.AccountType == "limited") {
+			picked = account
+		}
+	}
+	if picked == nil {
+		return nil, common.NewError("no spare warp account available")
+	}
+	return picked, nil
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(data)))
+// CreateWarpOutbound 使用指定或自动选择的账户创建 WireGuard 出站，可同时添加路由规则
+func (s *WarpService) CreateWarpOutbound(req *WarpOutboundRequest, author string) (*model.WarpAccount, error) {
+	if _, err := s.GetWarpAccounts(); err != nil {
+		return nil, err
+	}
+	if !tagPrefixPattern.MatchString(req.Tag) {
+		return nil, common.NewErrorf("invalid outbound tag: %s", req.Tag)
+	}
+	if req.Endpoint == "" {
+		req.Endpoint = warpDefaultEndpoint
+	}
+	if err := validateWarpEndpoint(req.Endpoint); err != nil {
+		return nil, err
+	}
+	for _, d := range req.Domains {
+		if err := validateDomainMatcher(d); err != nil {
+			return nil, err
+		}
+	}
+	for _, ip := range req.IPs {
+		if err := validateIPMatcher(ip); err != nil {
+			return nil, err
+		}
+	}
+
+	var account *model.WarpAccount
+	var err error
+	if req.AccountId > 0 {
+		if account, err = s.getWarpAccountRepo().FindByID(req.AccountId); err != nil {
+			return nil, err
+		}
+		if account.OutboundTag != "" {
+			return nil, common.NewErrorf("warp account %s is used by outbound %s", account.Name, account.OutboundTag)
+		}
+	} else if account, err = s.pickSpareWarpAccount(0); err != nil {
+		return nil, err
+	}
+	if account.PeerPublicKey == "" {
+		if err := s.refreshWarpAccount(account); err != nil {
+			return nil, err
+		}
+	}
+
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if t.outboundTags()[req.Tag] {
+		return nil, common.NewErrorf("outbound tag %s already exists", req.Tag)
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return nil, err
+	}
+	outbound, err := buildWarpOutbound(nil, req.Tag, account, req.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, outbound)
+	if err := validateOutbounds(list); err != nil {
+		return nil, err
+	}
+	if err := t.setOutbounds(list); err != nil {
+		return nil, err
+	}
+	if len(req.Domains) > 0 || len(req.IPs) > 0 {
+		rule, err := json.Marshal(&routingRuleFields{Type: "field", Domain: req.Domains, IP: req.IPs, OutboundTag: req.Tag})
+		if err != nil {
+			return nil, err
+		}
+		t.rules = append(t.rules, rule)
+	}
+	if err := s.saveRoutingTemplate(t, author, "add warp outbound "+req.Tag); err != nil {
+		return nil, err
 	}
 
-	req.Header.Add("CF-Client-Version", "a-7.21-0721")
-	req.Header.Add("Content-Type", "application/json")
+	account.OutboundTag = req.Tag
+	account.Failures = 0
+	account.TunnelFailures = 0
+	return account, s.getWarpAccountRepo().Update(account)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// SetWarpOutboundEndpoint 修改 WARP 出站的接入点
+func (s *WarpService) SetWarpOutboundEndpoint(tag string, endpoint string, author string) error {
+	if err := validateWarpEndpoint(endpoint); err != nil {
+		return err
+	}
+	account, err := s.getWarpAccountRepo().FindByOutboundTag(tag)
 	if err != nil {
-		return "", err
+		return common.NewErrorf("outbound %s is not a warp outbound", tag)
 	}
-	defer func() { _ = resp.Body.Close() }()
-	buffer := &bytes.Buffer{}
-	_, err = buffer.ReadFrom(resp.Body)
+	return s.rewriteWarpOutbound(tag, account, func(string) string { return endpoint }, author, "set warp endpoint "+tag)
+}
+
+// rewriteWarpOutbound 用账户参数重写出站。endpoint 根据当前接入点计算新的接入点，为 nil 时保留当前接入点；
+// endpoint 在持有 templateMu 时调用，保证读取当前接入点和写回之间模板不会被修改
+func (s *WarpService) rewriteWarpOutbound(tag string, account *model.WarpAccount, endpoint func(current string) string, author string, comment string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
 	if err != nil {
-		return "", err
+		return err
+	}
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	index := outboundIndex(list, tag)
+	if index < 0 {
+		return common.NewErrorf("outbound not found: %s", tag)
+	}
+	address := warpOutboundEndpoint(list[index])
+	if endpoint != nil {
+		address = endpoint(address)
 	}
+	if list[index], err = buildWarpOutbound(list[index], tag, account, address); err != nil {
+		return err
+	}
+	if err := t.setOutbounds(list); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, comment)
+}
 
-	var rspData map[string]any
-	err = json.Unmarshal(buffer.Bytes(), &rspData)
+// RotateWarpOutbound 将出站切换到另一个空闲账户；没有可用账户时切换到下一个接入地址
+func (s *WarpService) RotateWarpOutbound(tag string, author string) error {
+	current, err := s.getWarpAccountRepo().FindByOutboundTag(tag)
 	if err != nil {
-		return "", err
+		return common.NewErrorf("outbound %s is not a warp outbound", tag)
+	}
+	next, err := s.pickSpareWarpAccount(current.Id)
+	if err != nil {
+		rotate := func(address string) string {
+			next := nextWarpEndpoint(address)
+			logger.Warningf("No spare warp account for outbound %s, switching endpoint to %s", tag, next)
+			return next
+		}
+		if err := s.rewriteWarpOutbound(tag, current, rotate, author, "rotate warp endpoint "+tag); err != nil {
+			return err
+		}
+		current.Failures = 0
+		current.TunnelFailures = 0
+		return s.getWarpAccountRepo().Update(current)
 	}
 
-	deviceId := rspData["id"].(string)
-	token := rspData["token"].(string)
-	license, ok := rspData["account"].(map[string]any)["license"].(string)
-	if !ok {
-		logger.Debug("Error accessing license value.")
-		return "", err
+	if err := s.rewriteWarpOutbound(tag, next, nil, author, fmt.Sprintf("rotate warp outbound %s to %s", tag, next.Name)); err != nil {
+		return err
 	}
+	current.OutboundTag = ""
+	current.TunnelFailures = 0
+	if err := s.getWarpAccountRepo().Update(current); err != nil {
+		return err
+	}
+	next.OutboundTag = tag
+	next.Failures = 0
+	next.TunnelFailures = 0
+	logger.Infof("Warp outbound %s rotated from account %s to %s", tag, current.Name, next.Name)
+	return s.getWarpAccountRepo().Update(next)
+}
 
-	warpData := fmt.Sprintf("{\n  \"access_token\": \"%s\",\n  \"device_id\": \"%s\",", token, deviceId)
-	warpData += fmt.Sprintf("\n  \"license_key\": \"%s\",\n  \"private_key\": \"%s\"\n}", license, secretKey)
+// =============================================================================
+// 健康检查
+// =============================================================================
 
-	_ = s.SetWarp(warpData)
+// refreshWarpAccount 通过 API 检查账户并更新注册信息
+func (s *WarpService) refreshWarpAccount(account *model.WarpAccount) error {
+	reg, _, err := fetchWarpRegistration(account)
+	account.LastCheck = time.Now().Unix()
+	if err == nil {
+		applyWarpRegistration(account, reg)
+		account.Healthy = true
+		account.Failures = 0
+		account.LastError = ""
+	} else {
+		account.Healthy = false
+		account.Failures++
+		account.LastError = err.Error()
+	}
+	if updateErr := s.getWarpAccountRepo().Update(account); updateErr != nil {
+		logger.Warning("Failed to update warp account:", updateErr)
+	}
+	return err
+}
 
-	result := fmt.Sprintf("{\n  \"data\": %s,\n  \"config\": %s\n}", warpData, buffer.String())
+// RefreshWarpAccount 立即检查指定账户
+func (s *WarpService) RefreshWarpAccount(id int) error {
+	account, err := s.getWarpAccountRepo().FindByID(id)
+	if err != nil {
+		return err
+	}
+	before := *account
+	if err := s.refreshWarpAccount(account); err != nil {
+		return err
+	}
+	if account.OutboundTag != "" && warpConfigChanged(&before, account) {
+		return s.rewriteWarpOutbound(account.OutboundTag, account, nil, "system", "refresh warp outbound "+account.OutboundTag)
+	}
+	return nil
+}
 
-	return result, nil
+func warpConfigChanged(before, after *model.WarpAccount) bool {
+	return before.ClientId != after.ClientId || before.AddressV4 != after.AddressV4 ||
+		before.AddressV6 != after.AddressV6 || before.PeerPublicKey != after.PeerPublicKey
 }
 
-func (s *WarpService) SetWarpLicense(license string) (string, error) {
-	var warpData map[string]string
-	warp, err := s.GetWarp()
+// CheckWarpAccounts 检查全部已启用账户。alive 为观测服务给出的出站存活状态（未被观测的出站不在其中）；
+// 出站的账户连续失败达到阈值或出站持续不可用时轮换账户。返回模板是否发生变化
+func (s *WarpService) CheckWarpAccounts(alive map[string]bool) bool {
+	accounts, err := s.GetWarpAccounts()
 	if err != nil {
-		return "", err
+		logger.Warning("Failed to load warp accounts:", err)
+		return false
+	}
+	changed := false
+	var rotate []string
+	for _, account := range accounts {
+		if !account.Enable && account.OutboundTag == "" {
+			continue
+		}
+		before := *account
+		if err := s.refreshWarpAccount(account); err != nil {
+			logger.Warningf("Warp account %s check failed: %v", account.Name, err)
+		}
+		if account.OutboundTag == "" {
+			continue
+		}
+		// 隧道存活状态单独计数，账户 API 检查成功不会清零
+		if ok, observed := alive[account.OutboundTag]; observed {
+			tunnelFailures := 0
+			if !ok {
+				tunnelFailures = account.TunnelFailures + 1
+			}
+			if tunnelFailures != account.TunnelFailures {
+				account.TunnelFailures = tunnelFailures
+				if !ok {
+					account.LastError = "outbound is not alive"
+				}
+				if err := s.getWarpAccountRepo().Update(account); err != nil {
+					logger.Warning("Failed to update warp account:", err)
+				}
+			}
+		}
+		if account.Failures >= warpMaxFailures || account.TunnelFailures >= warpMaxFailures {
+			rotate = append(rotate, account.OutboundTag)
+			continue
+		}
+		if warpConfigChanged(&before, account) {
+			if err := s.rewriteWarpOutbound(account.OutboundTag, account, nil, "system", "refresh warp outbound "+account.OutboundTag); err != nil {
+				logger.Warningf("Update warp outbound %s failed: %v", account.OutboundTag, err)
+				continue
+			}
+			changed = true
+		}
+	}
+	for _, tag := range rotate {
+		if err := s.RotateWarpOutbound(tag, "system"); err != nil {
+			logger.Warningf("Rotate warp outbound %s failed: %v", tag, err)
+			continue
+		}
+		changed = true
+	}
+	return changed
+}
+
+// =============================================================================
+// 旧版单账户接口，作用于名为 warp 的账户
+// =============================================================================
+
+func (s *WarpService) getLegacyWarpAccount() (*model.WarpAccount, error) {
+	if err := s.migrateLegacyWarp(); err != nil {
+		return nil, err
 	}
-	err = json.Unmarshal([]byte(warp), &warpData)
+	return s.getWarpAccountRepo().FindByName(legacyWarpAccountName)
+}
+
+func legacyWarpData(account *model.WarpAccount) string {
+	data, _ := json.MarshalIndent(map[string]string{
+		"access_token": account.AccessToken,
+		"device_id":    account.DeviceId,
+		"license_key":  account.LicenseKey,
+		"private_key":  account.PrivateKey,
+	}, "", "  ")
+	return string(data)
+}
+
+func (s *WarpService) GetWarpData() (string, error) {
+	account, err := s.getLegacyWarpAccount()
 	if err != nil {
-		return "", err
+		return "", nil
 	}
+	return legacyWarpData(account), nil
+}
 
-	url := fmt.Sprintf("https://api.cloudflareclient.com/v0a2158/reg/%s/account", warpData["device_id"])
-	data := fmt.Sprintf(`{"license": "%s"}`, license)
+func (s *WarpService) DelWarpData() error {
+	account, err := s.getLegacyWarpAccount()
+	if err != nil {
+		return nil
+	}
+	return s.DelWarpAccount(account.Id)
+}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer([]byte(data)))
+func (s *WarpService) GetWarpConfig() (string, error) {
+	account, err := s.getLegacyWarpAccount()
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+warpData["access_token"])
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	reg, raw, err := fetchWarpRegistration(account)
 	if err != nil {
 		return "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
-	buffer := &bytes.Buffer{}
-	_, err = buffer.ReadFrom(resp.Body)
+	applyWarpRegistration(account, reg)
+	if err := s.getWarpAccountRepo().Update(account); err != nil {
+		logger.Warning("Failed to update warp account:", err)
+	}
+	return string(raw), nil
+}
+
+func (s *WarpService) RegWarp(secretKey string, publicKey string) (string, error) {
+	if account, err := s.getLegacyWarpAccount(); err == nil {
+		if account.OutboundTag != "" {
+			return "", common.NewErrorf("warp account %s is used by outbound %s", account.Name, account.OutboundTag)
+		}
+		if err := s.getWarpAccountRepo().Delete(account.Id); err != nil {
+			return "", err
+		}
+	}
+	account, raw, err := s.registerWarpAccount(legacyWarpAccountName, secretKey, publicKey)
 	if err != nil {
 		return "", err
 	}
+	result := fmt.Sprintf("{\n  \"data\": %s,\n  \"config\": %s\n}", legacyWarpData(account), string(raw))
+	return result, nil
+}
 
-	var response map[string]any
-	err = json.Unmarshal(buffer.Bytes(), &response)
+func (s *WarpService) SetWarpLicense(license string) (string, error) {
+	account, err := s.getLegacyWarpAccount()
 	if err != nil {
 		return "", err
 	}
-	if response["success"] == false {
-		errorArr, _ := response["errors"].([]any)
-		errorObj := errorArr[0].(map[string]any)
-		return "", common.NewError(errorObj["code"], errorObj["message"])
+	if err := s.SetWarpAccountLicense(account.Id, license); err != nil {
+		return "", err
 	}
-
-	warpData["license_key"] = license
-	newWarpData, err := json.MarshalIndent(warpData, "", "  ")
+	account, err = s.getWarpAccountRepo().FindByID(account.Id)
 	if err != nil {
 		return "", err
 	}
-	_ = s.SetWarp(string(newWarpData))
-
-	return string(newWarpData), nil
+	return legacyWarpData(account), nil
 }
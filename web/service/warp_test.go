@@ -0,0 +1,307 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"x-ui/util/wireguard"
+)
+
+// fakeWarpAPI 模拟 Cloudflare WARP 注册接口
+type fakeWarpAPI struct {
+	mu       sync.Mutex
+	next     int
+	peerKey  string
+	licenses map[string]string
+	broken   map[string]bool
+}
+
+func newFakeWarpAPI(t *testing.T) *fakeWarpAPI {
+	t.Helper()
+	_, peerKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &fakeWarpAPI{peerKey: peerKey, licenses: map[string]string{}, broken: map[string]bool{}}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	old := warpAPIBase
+	warpAPIBase = srv.URL
+	t.Cleanup(func() { warpAPIBase = old })
+	return api
+}
+
+func (f *fakeWarpAPI) registration(id string) map[string]any {
+	accountType := "free"
+	if f.licenses[id] != "" {
+		accountType = "limited"
+	}
+	n := strings.TrimPrefix(id, "dev-")
+	return map[string]any{
+		"id":    id,
+		"token": "tok-" + n,
+		"account": map[string]any{
+			"account_type": accountType,
+			"license":      f.licenses[id],
+			"quota":        0,
+		},
+		"config": map[string]any{
+			"client_id": base64.StdEncoding.EncodeToString([]byte{1, 2, 3}),
+			"interface": map[string]any{"addresses": map[string]string{"v4": "172.16.0." + n, "v6": "2606:4700::" + n}},
+			"peers":     []map[string]any{{"public_key": f.peerKey}},
+		},
+	}
+}
+
+func (f *fakeWarpAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	fail := func(code int, msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []map[string]any{{"code": code, "message": msg}}})
+	}
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "reg":
+		f.next++
+		id := fmt.Sprintf("dev-%d", f.next)
+		_ = json.NewEncoder(w).Encode(f.registration(id))
+	case len(parts) >= 2 && parts[0] == "reg":
+		id := parts[1]
+		if r.Header.Get("Authorization") != "Bearer tok-"+strings.TrimPrefix(id, "dev-") || f.broken[id] {
+			fail(1000, "unauthorized")
+			return
+		}
+		if r.Method == http.MethodPut && len(parts) == 3 {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["license"] == "invalid" {
+				fail(1001, "invalid license")
+				return
+			}
+			f.licenses[id] = body["license"]
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "acc", "license": body["license"]})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.registration(id))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWarpAPI) setBroken(id string, broken bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.broken[id] = broken
+}
+
+func setupWarpTest(t *testing.T) (*WarpService, *fakeWarpAPI) {
+	t.Helper()
+	xs := setupRoutingTemplate(t)
+	return &WarpService{XraySettingService: *xs}, newFakeWarpAPI(t)
+}
+
+func TestWarpService_AccountsAndLicense(t *testing.T) {
+	s, _ := setupWarpTest(t)
+
+	account, err := s.CreateWarpAccount("primary", "")
+	if err != nil {
+		t.Fatalf("create account failed: %v", err)
+	}
+	if account.DeviceId != "dev-1" || account.PrivateKey == "" || account.AccountType != "free" || account.AddressV4 != "172.16.0.1" {
+		t.Errorf("unexpected account: %+v", account)
+	}
+	if _, err := s.CreateWarpAccount("primary", ""); err == nil {
+		t.Error("duplicate account name accepted")
+	}
+
+	if err := s.SetWarpAccountLicense(account.Id, "invalid"); err == nil {
+		t.Error("invalid license accepted")
+	}
+	if err := s.SetWarpAccountLicense(account.Id, "plus-key"); err != nil {
+		t.Fatalf("set license failed: %v", err)
+	}
+	plus, err := s.CreateWarpAccount("plus", "plus-key-2")
+	if err != nil {
+		t.Fatalf("create plus account failed: %v", err)
+	}
+	if plus.AccountType != "limited" || plus.LicenseKey != "plus-key-2" {
+		t.Errorf("license not applied: %+v", plus)
+	}
+
+	accounts, err := s.GetWarpAccounts()
+	if err != nil || len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d (%v)", len(accounts), err)
+	}
+	if accounts[0].AccountType != "limited" {
+		t.Errorf("account type not refreshed after license: %s", accounts[0].AccountType)
+	}
+}
+
+func TestWarpService_MigrateLegacy(t *testing.T) {
+	s, _ := setupWarpTest(t)
+	legacy := `{"access_token":"tok-9","device_id":"dev-9","license_key":"k","private_key":"p"}`
+	if err := s.SetWarp(legacy); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.GetWarpData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(data), &got); err != nil || got["device_id"] != "dev-9" || got["private_key"] != "p" {
+		t.Errorf("legacy data not migrated: %s", data)
+	}
+	if warp, _ := s.GetWarp(); warp != "" {
+		t.Error("legacy setting not cleared after migration")
+	}
+	if err := s.DelWarpData(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.GetWarpData(); data != "" {
+		t.Errorf("legacy account not deleted: %s", data)
+	}
+}
+
+func TestWarpService_OutboundAndRotation(t *testing.T) {
+	s, api := setupWarpTest(t)
+
+	first, err := s.CreateWarpAccount("a1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWarpOutbound(&WarpOutboundRequest{Tag: "warp", Endpoint: "162.159.192.1:3000"}, "admin"); err == nil {
+		t.Error("invalid endpoint port accepted")
+	}
+	account, err := s.CreateWarpOutbound(&WarpOutboundRequest{Tag: "warp", Domains: []string{"domain:openai.com"}}, "admin")
+	if err != nil {
+		t.Fatalf("create warp outbound failed: %v", err)
+	}
+	if account.Id != first.Id || account.OutboundTag != "warp" {
+		t.Errorf("unexpected account assignment: %+v", account)
+	}
+	if _, err := s.CreateWarpOutbound(&WarpOutboundRequest{Tag: "warp2"}, "admin"); err == nil {
+		t.Error("outbound created without a spare account")
+	}
+
+	outbounds, _ := s.GetOutbounds()
+	var config map[string]any
+	for _, o := range outbounds {
+		if o.Tag == "warp" {
+			_ = json.Unmarshal(o.Config, &config)
+		}
+	}
+	settings, _ := config["settings"].(map[string]any)
+	if config["protocol"] != "wireguard" || settings["secretKey"] != first.PrivateKey {
+		t.Fatalf("unexpected warp outbound: %v", config)
+	}
+	rules, _ := s.GetRoutingRules()
+	if last := rules[len(rules)-1]; last["outboundTag"] != "warp" || len(last["domain"].([]any)) != 1 {
+		t.Errorf("routing rule not added: %+v", last)
+	}
+	if err := s.DelWarpAccount(first.Id); err == nil {
+		t.Error("account in use deleted")
+	}
+
+	// 没有空闲账户时失效后切换接入地址
+	api.setBroken(first.DeviceId, true)
+	for i := 0; i < warpMaxFailures; i++ {
+		s.CheckWarpAccounts(nil)
+	}
+	outbounds, _ = s.GetOutbounds()
+	for _, o := range outbounds {
+		if o.Tag == "warp" && warpOutboundEndpoint(o.Config) != "162.159.192.1:2408" {
+			t.Errorf("endpoint not rotated: %s", warpOutboundEndpoint(o.Config))
+		}
+	}
+
+	// 有空闲账户时轮换账户
+	second, err := s.CreateWarpAccount("a2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < warpMaxFailures; i++ {
+		s.CheckWarpAccounts(nil)
+	}
+	current, err := s.getWarpAccountRepo().FindByOutboundTag("warp")
+	if err != nil || current.Id != second.Id {
+		t.Fatalf("outbound not rotated to spare account: %+v %v", current, err)
+	}
+	outbounds, _ = s.GetOutbounds()
+	for _, o := range outbounds {
+		if o.Tag == "warp" {
+			if !strings.Contains(string(o.Config), second.PrivateKey) || warpOutboundEndpoint(o.Config) != "162.159.192.1:2408" {
+				t.Errorf("outbound not rewritten for new account: %s", o.Config)
+			}
+		}
+	}
+
+	// 出站被删除后账户重新变为空闲
+	if err := s.DelRoutingRule(len(rules)-1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelOutbound("warp", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelWarpAccount(second.Id); err != nil {
+		t.Errorf("account not released after outbound deletion: %v", err)
+	}
+}
+
+func TestNextWarpEndpoint(t *testing.T) {
+	if got := nextWarpEndpoint("engage.cloudflareclient.com:2408"); got != "162.159.192.1:2408" {
+		t.Errorf("got %s", got)
+	}
+	if got := nextWarpEndpoint("188.114.97.1:500"); got != "engage.cloudflareclient.com:500" {
+		t.Errorf("got %s", got)
+	}
+	if got := nextWarpEndpoint("[2606:4700:d0::a29f:c001]:2408"); got != "engage.cloudflareclient.com:2408" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestWarpService_RotateOnDeadTunnel(t *testing.T) {
+	s, _ := setupWarpTest(t)
+
+	first, err := s.CreateWarpAccount("t1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWarpOutbound(&WarpOutboundRequest{Tag: "warp"}, "admin"); err != nil {
+		t.Fatalf("create warp outbound failed: %v", err)
+	}
+	second, err := s.CreateWarpAccount("t2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 账户 API 正常但隧道持续不可用，达到阈值后轮换账户
+	for i := 0; i < warpMaxFailures-1; i++ {
+		s.CheckWarpAccounts(map[string]bool{"warp": false})
+	}
+	current, _ := s.getWarpAccountRepo().FindByOutboundTag("warp")
+	if current.Id != first.Id || current.TunnelFailures != warpMaxFailures-1 || current.Failures != 0 {
+		t.Fatalf("unexpected counters before threshold: %+v", current)
+	}
+	// 隧道恢复后重新计数
+	s.CheckWarpAccounts(map[string]bool{"warp": true})
+	current, _ = s.getWarpAccountRepo().FindByOutboundTag("warp")
+	if current.TunnelFailures != 0 {
+		t.Errorf("tunnel failures not cleared after recovery: %d", current.TunnelFailures)
+	}
+
+	for i := 0; i < warpMaxFailures; i++ {
+		s.CheckWarpAccounts(map[string]bool{"warp": false})
+	}
+	current, err = s.getWarpAccountRepo().FindByOutboundTag("warp")
+	if err != nil || current.Id != second.Id || current.TunnelFailures != 0 {
+		t.Fatalf("outbound not rotated after dead tunnel: %+v %v", current, err)
+	}
+}
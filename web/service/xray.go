@@ -1,13 +1,18 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"x-ui/config"
+	"x-ui/database/model"
 	"x-ui/logger"
 	"x-ui/util/common"
 	json_util "x-ui/util/json_util"
@@ -19,6 +24,7 @@ import (
 type XrayService struct {
 	inboundService *InboundService
 	settingService *SettingService
+	tgService      TelegramService
 	xrayAPI        xray.XrayAPI
 
 	// 封装原全局变量到结构体中
@@ -27,6 +33,23 @@ type XrayService struct {
 	isNeedXrayRestart atomic.Bool
 	isManuallyStopped atomic.Bool
 	result            string
+
+	// 最近一次配置应用的结果
+	reconcileMu   sync.RWMutex
+	lastReconcile *ReconcileResult
+
+	// 出站观测结果
+	healthMu       sync.RWMutex
+	healthTime     int64
+	outboundHealth map[string]*OutboundHealth
+	balancerHealth map[string]*BalancerHealth
+
+	// 进程监控状态
+	supervisor xraySupervisor
+
+	// 与 Xray 同时运行的 sing-box 后端，首次使用时创建
+	backendOnce sync.Once
+	singBox     *singBoxBackend
 }
 
 // NewXrayService 创建 XrayService 实例
@@ -42,6 +65,11 @@ func (s *XrayService) SetInboundService(inbound *InboundService) {
 	s.inboundService = inbound
 }
 
+// SetTelegramService 用于从外部注入 TelegramService 实例，配置回滚时发送通知
+func (s *XrayService) SetTelegramService(tgService TelegramService) {
+	s.tgService = tgService
+}
+
 // SetXrayAPI 用于从外部注入 XrayAPI 实例
 func (s *XrayService) SetXrayAPI(api xray.XrayAPI) {
 	s.xrayAPI = api
@@ -103,11 +131,9 @@ func (s *XrayService) GetXrayResult() string {
 	return s.result
 }
 
+// GetXrayVersion 返回当前代理核心的版本
 func (s *XrayService) GetXrayVersion() string {
-	if s.process == nil {
-		return "Unknown"
-	}
-	return s.process.GetVersion()
+	return s.GetCoreBackend().GetVersion()
 }
 
 func RemoveIndex(s []any, index int) []any {
@@ -131,6 +157,10 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 	// Sanitize Outbounds: Remove allowInsecure to adapt to Xray-core v25+
 	// Sanitize Outbounds: Remove allowInsecure to adapt to Xray-core v25+
 	_ = xrayConfig.AdaptToXrayCoreV25()
+	// 配置了观测时开启 ObservatoryService，面板才能读取出站健康状态
+	xrayConfig.EnableObservatoryService()
+	// 开启 RoutingService，面板生成的路由规则变化时可以热更新
+	xrayConfig.EnableRoutingService()
 
 	inbounds, err := s.inboundService.GetAllInbounds()
 	if err != nil {
@@ -205,6 +235,13 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 	// 将完整配置好的 level 0 写回 policyLevels，确保最终生成的 config.json 是正确的。
 	policyLevels["0"] = level0
 
+	// 设备超限策略为限速时，超限用户会被切换到该速率对应的 level
+	if policy, _ := s.settingService.GetDeviceLimitPolicy(); policy == DeviceLimitPolicyThrottle {
+		if speed, _ := s.settingService.GetDeviceLimitThrottleSpeed(); speed > 0 {
+			uniqueSpeeds[speed] = true
+		}
+	}
+
 	// 4. 遍历所有收集到的限速值，为每个独立的限速值创建对应的 level
 	for speed := range uniqueSpeeds {
 		// 为每个速率创建一个 level，level 的名字就是速率的字符串形式
@@ -242,10 +279,17 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 	// 触发一次空调用以处理可能的残留任务
 	_, _ = s.inboundService.AddTraffic(nil, nil)
 
+	var forwards []*PortForward
+	var wireguardRoutes []wireguardPeerRoute
 	for _, inbound := range inbounds {
+		var peerRoutes []wireguardPeerRoute
 		if !inbound.Enable {
 			continue
 		}
+		// Hysteria2、TUIC 等协议由与 Xray 同时运行的 sing-box 承载，不写入 Xray 配置
+		if !xrayProtocols[inbound.Protocol] {
+			continue
+		}
 
 		// 先生成一个 inboundConfig（后面会覆盖 Settings/StreamSettings）
 		inboundConfig := inbound.GenXrayInboundConfig()
@@ -274,8 +318,15 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 		originalClients, ok := settings["clients"].([]interface{})
 		if ok {
 			clientStats := inbound.ClientStats
+			// WireGuard 入站的客户端即 peer，启用的 peer 写入 settings.peers，
+			// 并按隧道地址分流到各自的 loopback 出站以统计 peer 的流量
+			isWireguard := inbound.Protocol == model.WireGuard
+			// SOCKS/HTTP 入站的客户端即账户，启用的客户端写入 settings.accounts
+			isAccount := accountProtocols[inbound.Protocol]
 
 			var xrayClients []interface{}
+			var wireguardPeers []interface{}
+			var accounts []interface{}
 			for _, clientRaw := range originalClients {
 				c, ok := clientRaw.(map[string]interface{})
 				if !ok {
@@ -308,6 +359,17 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 					logger.Infof("已从Xray配置中移除被禁用的用户: %s", email)
 					continue
 				}
+				if isWireguard {
+					wireguardPeers = append(wireguardPeers, wireguardPeer(c))
+					if route, ok := wireguardPeerRouteOf(inbound.Tag, c); ok {
+						peerRoutes = append(peerRoutes, route)
+					}
+					continue
+				}
+				if isAccount {
+					accounts = append(accounts, accountOf(c))
+					continue
+				}
 
 				// -----------------------------------------------------------------
 				// 构建干净的 xrayClient（只保留白名单字段）
@@ -387,7 +449,17 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 			}
 
 			// 把纯净的 clients 应用到 settings，并写入 inboundConfig.Settings
-			settings["clients"] = xrayClients
+			switch {
+			case isWireguard:
+				applyWireguardPeers(settings, wireguardPeers)
+			case isAccount:
+				if !applyAccounts(inbound.Protocol, settings, accounts) {
+					logger.Infof("入站 %s 没有可用账户，已从Xray配置中跳过", inbound.Tag)
+					continue
+				}
+			default:
+				settings["clients"] = xrayClients
+			}
 			finalSettingsForXray, err := json.Marshal(settings)
 			if err != nil {
 				logger.Warningf("无法序列化用于Xray的入站设置 in GetXrayConfig for inbound %d: %v，跳过该入站", inbound.Id, err)
@@ -396,6 +468,17 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 			inboundConfig.Settings = json_util.RawMessage(finalSettingsForXray)
 		}
 
+		// 端口转发：移除面板字段、写入端口范围，稍后生成对应的路由规则
+		if isPortForward(inbound) {
+			if err := applyPortForwardSettings(inboundConfig, settings); err != nil {
+				logger.Warningf("无法生成端口转发 %s 的入站设置: %v，跳过该入站", inbound.Tag, err)
+				continue
+			}
+			if forward, err := portForwardOf(inbound); err == nil {
+				forwards = append(forwards, forward)
+			}
+		}
+
 		// -----------------------------------------------------------------
 		// 处理 StreamSettings（清理敏感字段）
 		// -----------------------------------------------------------------
@@ -422,12 +505,109 @@ func (s *XrayService) GetXrayConfig() (*xray.Config, error) {
 		}
 
 		xrayConfig.InboundConfigs = append(xrayConfig.InboundConfigs, *inboundConfig)
+		wireguardRoutes = append(wireguardRoutes, peerRoutes...)
+	}
+
+	if err := applyWireguardPeerStats(xrayConfig, wireguardRoutes); err != nil {
+		return nil, err
+	}
+
+	// 端口转发和设备限制的路由规则置于模板规则之前
+	rejected := GetDeviceLimitRejectedIPs()
+	err = prependRoutingRules(xrayConfig, func(outbound func(string) string) []map[string]any {
+		return append(portForwardRules(forwards, outbound), deviceLimitRules(rejected, outbound)...)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return xrayConfig, nil
 }
 
+// generatedOutboundTags 模板中没有对应协议的出站时，为面板生成的路由规则补充的出站标签
+var generatedOutboundTags = map[string]string{
+	"freedom":   "panel-direct",
+	"blackhole": "panel-blocked",
+}
+
+// prependRoutingRules 将面板生成的路由规则置于模板规则之前。build 通过 outbound 函数按协议引用出站，
+// 模板中没有该协议的出站时补充一个
+func prependRoutingRules(config *xray.Config, build func(outbound func(protocol string) string) []map[string]any) error {
+	var outbounds []map[string]any
+	if len(config.OutboundConfigs) > 0 && string(config.OutboundConfigs) != "null" {
+		if err := json.Unmarshal(config.OutboundConfigs, &outbounds); err != nil {
+			return common.NewError("invalid outbounds in template:", err)
+		}
+	}
+	added := false
+	outbound := func(protocol string) string {
+		for _, o := range outbounds {
+			if p, _ := o["protocol"].(string); p == protocol {
+				if tag, _ := o["tag"].(string); tag != "" {
+					return tag
+				}
+			}
+		}
+		tag := generatedOutboundTags[protocol]
+		outbounds = append(outbounds, map[string]any{"tag": tag, "protocol": protocol})
+		added = true
+		return tag
+	}
+
+	generated := build(outbound)
+	if len(generated) == 0 {
+		return nil
+	}
+	if added {
+		data, err := json.Marshal(outbounds)
+		if err != nil {
+			return err
+		}
+		config.OutboundConfigs = json_util.RawMessage(data)
+	}
+
+	routing := make(map[string]any)
+	if len(config.RouterConfig) > 0 && string(config.RouterConfig) != "null" {
+		if err := json.Unmarshal(config.RouterConfig, &routing); err != nil {
+			return common.NewError("invalid routing in template:", err)
+		}
+	}
+	rules := make([]any, 0, len(generated))
+	for _, rule := range generated {
+		rules = append(rules, rule)
+	}
+	existing, _ := routing["rules"].([]any)
+	routing["rules"] = append(rules, existing...)
+	data, err := json.Marshal(routing)
+	if err != nil {
+		return err
+	}
+	config.RouterConfig = json_util.RawMessage(data)
+	return nil
+}
+
+// GetXrayTraffic 读取 Xray 的流量统计，sing-box 运行时合并其承载入站的流量
 func (s *XrayService) GetXrayTraffic() ([]*xray.Traffic, []*xray.ClientTraffic, error) {
+	traffic, clientTraffic, err := s.GetCoreBackend().GetTraffic()
+	if err != nil {
+		logger.Debug("Failed to fetch core traffic:", err)
+	}
+	if sb := s.GetSingBoxBackend(); sb != nil && sb.IsRunning() {
+		sbTraffic, sbClientTraffic, sbErr := sb.GetTraffic()
+		if sbErr != nil {
+			logger.Debug("Failed to fetch sing-box traffic:", sbErr)
+		} else {
+			// Xray 读取失败时仍返回 sing-box 的流量
+			return append(traffic, sbTraffic...), append(clientTraffic, sbClientTraffic...), nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return traffic, clientTraffic, nil
+}
+
+func (s *XrayService) getXrayCoreTraffic() ([]*xray.Traffic, []*xray.ClientTraffic, error) {
 	if !s.IsXrayRunning() {
 		err := common.ErrXrayNotRunning
 		logger.Debug("Attempted to fetch Xray traffic, but Xray is not running:", err)
@@ -442,15 +622,29 @@ func (s *XrayService) GetXrayTraffic() ([]*xray.Traffic, []*xray.ClientTraffic,
 		logger.Debug("Failed to fetch Xray traffic:", err)
 		return nil, nil, err
 	}
-	return traffic, clientTraffic, nil
+	traffic, peerTraffic := splitWireguardPeerTraffic(traffic)
+	return traffic, append(clientTraffic, peerTraffic...), nil
 }
 
+// RestartXray 应用 Xray 配置，并按设置启动、重新加载或停止与 Xray 同时运行的 sing-box
 func (s *XrayService) RestartXray(isForce bool) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	logger.Debug("restart Xray, force:", isForce)
+	logger.Debug("restart core, force:", isForce)
 	s.isManuallyStopped.Store(false)
 
+	err := s.restartXrayCore(isForce)
+	if sbErr := s.restartSingBox(isForce); sbErr != nil {
+		logger.Warning("Failed to apply sing-box config:", sbErr)
+		if err == nil {
+			err = sbErr
+		}
+	}
+	return err
+}
+
+// restartXrayCore 生成 Xray 配置并以热更新或重启的方式应用，调用方需持有 s.lock
+func (s *XrayService) restartXrayCore(isForce bool) error {
 	xrayConfig, err := s.GetXrayConfig()
 	if err != nil {
 		return err
@@ -464,11 +658,57 @@ func (s *XrayService) RestartXray(isForce bool) error {
 		logger.Warning("无法将 Xray 配置编组以进行日志记录：", jsonErr)
 	}
 
-	if s.IsXrayRunning() {
-		if !isForce && s.process.GetConfig().Equals(xrayConfig) && !s.isNeedXrayRestart.Load() {
+	// 面板升级后接管旧面板进程交接下来的 Xray，按差异应用配置而不是重新启动
+	if s.process == nil && !s.isEmbeddedMode() {
+		if adopted := xray.AdoptProcess(); adopted != nil {
+			s.process = adopted
+		}
+	}
+
+	running := s.IsXrayRunning()
+	var diff *xray.ConfigDiff
+	if running && !isForce {
+		diff = xray.DiffConfig(s.process.GetConfig(), xrayConfig)
+		if s.process.IsEmbedded() != s.isEmbeddedMode() {
+			diff.RestartReasons = append(diff.RestartReasons, "core mode")
+		}
+		if diff.IsEmpty() {
 			logger.Debug("It does not need to restart Xray")
 			return nil
 		}
+	}
+
+	// 预检：配置无法构建时保持当前进程不变；Xray 未运行时回滚到最近一次可用配置
+	if err := xray.ValidateConfig(xrayConfig); err != nil {
+		if running {
+			s.reportConfigFailure(err, false)
+			return err
+		}
+		return s.rollback(xrayConfig, err)
+	}
+
+	var hotErr error
+	if diff != nil {
+		// 入站与用户的变更通过 gRPC 热更新，只有路由、DNS、策略等无法热更新的部分变更时才重启
+		if !diff.NeedRestart() {
+			var applied bool
+			if applied, hotErr = s.tryHotApply(diff, xrayConfig); applied {
+				return nil
+			}
+		} else {
+			logger.Info("Restarting Xray:", strings.Join(diff.RestartReasons, ", "))
+		}
+	}
+
+	if err := xray.TestConfig(xrayConfig); err != nil {
+		if running {
+			s.reportConfigFailure(err, false)
+			return err
+		}
+		return s.rollback(xrayConfig, err)
+	}
+
+	if running {
 		err := s.process.Stop()
 		if err != nil {
 			logger.Warning("Error stopping Xray:", err)
@@ -477,25 +717,125 @@ func (s *XrayService) RestartXray(isForce bool) error {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	s.process = xray.NewProcess(xrayConfig)
+	if err := s.startProcess(xrayConfig); err != nil {
+		return s.rollback(xrayConfig, err)
+	}
+	if err := xray.SaveLastGoodConfig(xrayConfig); err != nil {
+		logger.Warning("Failed to save last known good Xray config:", err)
+	}
+	result := newReconcileResult(ReconcileRestart, diff)
+	if hotErr != nil {
+		result.Error = hotErr.Error()
+	}
+	s.setLastReconcile(result)
+
+	return nil
+}
+
+// startProcess 启动 Xray 并在启动窗口内观察进程，进程立即退出时视为启动失败
+func (s *XrayService) startProcess(xrayConfig *xray.Config) error {
+	if s.isEmbeddedMode() {
+		s.process = xray.NewEmbeddedProcess(xrayConfig)
+	} else {
+		s.process = xray.NewProcess(xrayConfig)
+	}
 	s.result = ""
-	err = s.process.Start()
-	if err != nil {
+	if err := s.process.Start(); err != nil {
 		return err
 	}
 
+	deadline := time.Now().Add(config.XrayStartupCheckPeriod)
+	for time.Now().Before(deadline) {
+		if s.process.HasExited() {
+			return common.NewErrorf("xray exited right after start: %s", s.process.GetResult())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 	return nil
 }
 
+// isEmbeddedMode 判断是否配置为在面板进程内运行 xray-core
+func (s *XrayService) isEmbeddedMode() bool {
+	if s.settingService == nil {
+		return false
+	}
+	mode, err := s.settingService.GetXrayCoreMode()
+	return err == nil && mode == xray.CoreModeEmbedded
+}
+
+// rollback 新配置无法启动时恢复最近一次成功启动的配置，并通过 GetXrayResult 和 Telegram 报告原因
+func (s *XrayService) rollback(failed *xray.Config, cause error) error {
+	lastGood, err := xray.LoadLastGoodConfig()
+	if err != nil || sameXrayConfig(lastGood, failed) {
+		s.reportConfigFailure(cause, false)
+		return cause
+	}
+
+	logger.Warning("Rolling back Xray to last known good config:", cause)
+	if s.IsXrayRunning() {
+		_ = s.process.Stop()
+		time.Sleep(500 * time.Millisecond)
+	}
+	if err := s.startProcess(lastGood); err != nil {
+		logger.Error("Failed to start Xray with last known good config:", err)
+		s.reportConfigFailure(cause, false)
+		return cause
+	}
+	s.reportConfigFailure(cause, true)
+	return cause
+}
+
+// sameXrayConfig 按序列化结果比较配置，忽略原始 JSON 的格式差异
+func sameXrayConfig(a, b *xray.Config) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
+
+// reportConfigFailure 记录配置应用失败的原因，供状态接口和通知使用
+func (s *XrayService) reportConfigFailure(cause error, rolledBack bool) {
+	msg := "Xray config rejected: " + cause.Error()
+	if rolledBack {
+		msg = "Xray failed to start with new config, rolled back to last known good config: " + cause.Error()
+	}
+	logger.Error(msg)
+	s.result = msg
+	if s.tgService != nil && s.tgService.IsRunning() {
+		_ = s.tgService.SendMessage(msg)
+	}
+}
+
 func (s *XrayService) StopXray() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.isManuallyStopped.Store(true)
-	logger.Debug("Attempting to stop Xray...")
-	if s.IsXrayRunning() {
-		return s.process.Stop()
+	logger.Debug("Attempting to stop core...")
+	s.stopSingBox()
+	return s.GetCoreBackend().Stop()
+}
+
+// HandoffXray 面板升级替换进程前调用，返回把正在运行的 Xray 交给新进程所需的环境变量和文件。
+// sing-box 和内嵌模式的 Xray 无法跨 exec 保留，由新进程重新启动，此时停止核心并返回空值
+func (s *XrayService) HandoffXray() (string, *os.File) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopSingBox()
+	if s.process != nil {
+		env, f, err := s.process.Handoff()
+		if err == nil {
+			return env, f
+		}
+		logger.Warning("Xray cannot be kept running across upgrade:", err)
 	}
-	return common.ErrXrayNotRunning
+	s.isManuallyStopped.Store(true)
+	_ = s.GetCoreBackend().Stop()
+	return "", nil
+}
+
+// CancelHandoff 撤销 HandoffXray 的效果，在替换进程失败时调用：清除手动停止标记，
+// 重新启动被停止的 sing-box 和内嵌模式的 Xray；仍在运行的外部进程按配置差异处理
+func (s *XrayService) CancelHandoff() error {
+	return s.RestartXray(false)
 }
 
 func (s *XrayService) SetToNeedRestart() {
@@ -506,9 +846,13 @@ func (s *XrayService) IsNeedRestartAndSetFalse() bool {
 	return s.isNeedXrayRestart.CompareAndSwap(true, false)
 }
 
-// Check if Xray is not running and wasn't stopped manually, i.e. crashed
+// Check if Xray is not running and wasn't stopped manually, i.e. crashed.
+// sing-box 与 Xray 同时运行时，它未经面板停止而退出也视为崩溃
 func (s *XrayService) DidXrayCrash() bool {
-	return !s.IsXrayRunning() && !s.isManuallyStopped.Load()
+	if s.isManuallyStopped.Load() {
+		return false
+	}
+	return !s.IsCoreRunning() || s.getSingBoxBackend().crashedProcess() != nil
 }
 
 // GetOnlineClients 返回在线客户端列表
@@ -526,10 +870,7 @@ func (s *XrayService) SetOnlineClients(clients []string) {
 	}
 }
 
-// GetProcessUptime 返回进程运行时间
+// GetProcessUptime 返回当前代理核心的运行时间
 func (s *XrayService) GetProcessUptime() uint64 {
-	if s.process == nil || !s.process.IsRunning() {
-		return 0
-	}
-	return s.process.GetUptime()
+	return s.GetCoreBackend().GetUptime()
 }
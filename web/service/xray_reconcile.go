@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"x-ui/logger"
+	"x-ui/util/common"
+	"x-ui/xray"
+)
+
+// 配置应用方式
+const (
+	ReconcileHot     = "hot"
+	ReconcileRestart = "restart"
+)
+
+// ReconcileResult 记录最近一次配置应用的方式及具体执行的操作
+type ReconcileResult struct {
+	Time           int64             `json:"time"`
+	Mode           string            `json:"mode"`
+	AddInbounds    []string          `json:"addInbounds,omitempty"`
+	RemoveInbounds []string          `json:"removeInbounds,omitempty"`
+	AddUsers       []xray.UserChange `json:"addUsers,omitempty"`
+	RemoveUsers    []xray.UserChange `json:"removeUsers,omitempty"`
+	RoutingReload  bool              `json:"routingReload,omitempty"`
+	RestartReasons []string          `json:"restartReasons,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+func newReconcileResult(mode string, diff *xray.ConfigDiff) *ReconcileResult {
+	result := &ReconcileResult{
+		Time: time.Now().Unix(),
+		Mode: mode,
+	}
+	if diff != nil {
+		result.AddInbounds = diff.AddInboundTags()
+		result.RemoveInbounds = diff.RemoveInbounds
+		result.AddUsers = diff.AddUsers
+		result.RemoveUsers = diff.RemoveUsers
+		result.RoutingReload = len(diff.Routing) > 0
+		result.RestartReasons = diff.RestartReasons
+	}
+	return result
+}
+
+// GetLastReconcile 返回最近一次配置应用的结果，尚未应用过配置时返回 nil
+func (s *XrayService) GetLastReconcile() *ReconcileResult {
+	s.reconcileMu.RLock()
+	defer s.reconcileMu.RUnlock()
+	return s.lastReconcile
+}
+
+func (s *XrayService) setLastReconcile(result *ReconcileResult) {
+	s.reconcileMu.Lock()
+	s.lastReconcile = result
+	s.reconcileMu.Unlock()
+}
+
+// tryHotApply 尝试通过 gRPC Handler API 将配置差异应用到运行中的 Xray，
+// 应用失败时返回 false，由调用方重启 Xray
+func (s *XrayService) tryHotApply(diff *xray.ConfigDiff, xrayConfig *xray.Config) (bool, error) {
+	if err := s.applyConfigDiff(diff); err != nil {
+		logger.Warning("Failed to hot apply Xray config, falling back to restart:", err)
+		return false, err
+	}
+	if err := s.process.SetConfig(xrayConfig); err != nil {
+		logger.Warning("Failed to persist hot applied Xray config:", err)
+	}
+	if err := xray.SaveLastGoodConfig(xrayConfig); err != nil {
+		logger.Warning("Failed to save last known good Xray config:", err)
+	}
+
+	s.result = ""
+	s.setLastReconcile(newReconcileResult(ReconcileHot, diff))
+	logger.Infof("Xray config hot applied: +%d/-%d inbounds, +%d/-%d users, routing reloaded: %v",
+		len(diff.AddInbounds), len(diff.RemoveInbounds), len(diff.AddUsers), len(diff.RemoveUsers), len(diff.Routing) > 0)
+	return true, nil
+}
+
+// applyConfigDiff 依次执行删除用户、删除入站、新增入站、新增用户
+func (s *XrayService) applyConfigDiff(diff *xray.ConfigDiff) error {
+	// 使用独立的 API 客户端，避免与流量统计任务共享连接状态
+	api := xray.XrayAPI{}
+	if err := api.Init(s.process.GetAPIPort()); err != nil {
+		return err
+	}
+	defer api.Close()
+
+	for _, user := range diff.RemoveUsers {
+		if err := api.RemoveUser(user.Tag, user.Email); err != nil && !isMissingErr(err) {
+			return err
+		}
+	}
+	for _, tag := range diff.RemoveInbounds {
+		if err := api.DelInbound(tag); err != nil && !isMissingErr(err) {
+			return common.NewErrorf("failed to remove inbound %s: %v", tag, err)
+		}
+	}
+	for _, inbound := range diff.AddInbounds {
+		data, err := json.Marshal(inbound)
+		if err != nil {
+			return err
+		}
+		if err := api.AddInbound(data); err != nil {
+			return common.NewErrorf("failed to add inbound %s: %v", inbound.Tag, err)
+		}
+	}
+	for _, user := range diff.AddUsers {
+		if err := api.AddUser(user.Protocol, user.Tag, user.User); err != nil {
+			return err
+		}
+	}
+	if len(diff.Routing) > 0 {
+		if err := api.ReloadRouting(diff.Routing); err != nil {
+			return common.NewErrorf("failed to reload routing: %v", err)
+		}
+	}
+	return nil
+}
+
+// isMissingErr 要删除的对象已不存在时视为成功
+func isMissingErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "not exist")
+}
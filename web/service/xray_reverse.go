@@ -0,0 +1,645 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"x-ui/database"
+	"x-ui/database/repository"
+	"x-ui/util/common"
+	"x-ui/util/json_util"
+	"x-ui/xray"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/curve25519"
+)
+
+// 反向代理在 portal 一侧生成的入站标签后缀：bridge 通过 interconn 入站连入，外部访问从 external 入站进入
+const (
+	reverseInterconnSuffix = "-interconn"
+	reverseExternalSuffix  = "-external"
+	reverseBridgeSuffix    = "-bridge"
+	// reverseDefaultSNI 未指定时 interconn 入站 REALITY 伪装的目标网站
+	reverseDefaultSNI = "www.microsoft.com"
+)
+
+// ReverseTunnel 一对 portal/bridge 反向代理。portal 运行在本面板，bridge 运行在 NAT 之后的机器上，
+// 外部访问 ExternalPort 的流量经 bridge 主动建立的连接转发到 bridge 一侧的 Target
+type ReverseTunnel struct {
+	Tag           string `json:"tag"`
+	Domain        string `json:"domain"`
+	Listen        string `json:"listen,omitempty"`
+	InterconnPort int    `json:"interconnPort"`
+	ExternalPort  int    `json:"externalPort"`
+	Network       string `json:"network"`
+	Target        string `json:"target"`
+	BridgeID      string `json:"bridgeId"`
+	// SNI bridge 与 portal 之间的连接使用 VLESS + REALITY，SNI 为 REALITY 伪装的目标网站
+	SNI string `json:"sni"`
+	// Missing 模板中缺失的组成部分，通常由手动编辑模板造成
+	Missing []string `json:"missing,omitempty"`
+}
+
+// ReverseTunnelStatus 反向代理的连接状态，bridge 在线与否通过其客户端的在线统计判断
+type ReverseTunnelStatus struct {
+	*ReverseTunnel
+	Connected bool     `json:"connected"`
+	BridgeIPs []string `json:"bridgeIps,omitempty"`
+	LastSeen  int64    `json:"lastSeen,omitempty"`
+}
+
+// reversePortal reverse.portals / reverse.bridges 中的一项
+type reversePortal struct {
+	Tag    string `json:"tag"`
+	Domain string `json:"domain"`
+}
+
+// reverseSection 模板中的 reverse 部分
+type reverseSection struct {
+	Bridges []reversePortal `json:"bridges,omitempty"`
+	Portals []reversePortal `json:"portals,omitempty"`
+}
+
+func (t *routingTemplate) reverse() (*reverseSection, error) {
+	section := &reverseSection{}
+	if raw := t.config.Reverse; len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, section); err != nil {
+			return nil, common.NewError("invalid reverse section:", err)
+		}
+	}
+	return section, nil
+}
+
+func (t *routingTemplate) setReverse(section *reverseSection) error {
+	if len(section.Bridges) == 0 && len(section.Portals) == 0 {
+		t.config.Reverse = nil
+		return nil
+	}
+	data, err := json.Marshal(section)
+	if err != nil {
+		return err
+	}
+	t.config.Reverse = data
+	return nil
+}
+
+// reverseTags 返回 reverse 中的 portal 和 bridge 标签，路由规则可以把它们作为出站引用
+func (t *routingTemplate) reverseTags() map[string]bool {
+	tags := make(map[string]bool)
+	section, err := t.reverse()
+	if err != nil {
+		return tags
+	}
+	for _, p := range section.Portals {
+		tags[p.Tag] = true
+	}
+	for _, b := range section.Bridges {
+		tags[b.Tag] = true
+	}
+	return tags
+}
+
+func (t *routingTemplate) templateInbound(tag string) *xray.InboundConfig {
+	for i := range t.config.InboundConfigs {
+		if t.config.InboundConfigs[i].Tag == tag {
+			return &t.config.InboundConfigs[i]
+		}
+	}
+	return nil
+}
+
+// GetReverseTunnels 从模板的 reverse.portals 及其对应的入站还原反向代理列表
+func (s *XraySettingService) GetReverseTunnels() ([]*ReverseTunnel, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	section, err := t.reverse()
+	if err != nil {
+		return nil, err
+	}
+	tunnels := make([]*ReverseTunnel, 0, len(section.Portals))
+	for _, portal := range section.Portals {
+		tunnels = append(tunnels, t.reverseTunnel(portal))
+	}
+	return tunnels, nil
+}
+
+// GetReverseTunnel 返回指定标签的反向代理
+func (s *XraySettingService) GetReverseTunnel(tag string) (*ReverseTunnel, error) {
+	tunnels, err := s.GetReverseTunnels()
+	if err != nil {
+		return nil, err
+	}
+	for _, tunnel := range tunnels {
+		if tunnel.Tag == tag {
+			return tunnel, nil
+		}
+	}
+	return nil, common.NewErrorf("reverse tunnel %s not found", tag)
+}
+
+func (t *routingTemplate) reverseTunnel(portal reversePortal) *ReverseTunnel {
+	tunnel := &ReverseTunnel{Tag: portal.Tag, Domain: portal.Domain}
+
+	if inbound := t.templateInbound(portal.Tag + reverseInterconnSuffix); inbound != nil {
+		tunnel.InterconnPort = inbound.Port
+		_ = json.Unmarshal(inbound.Listen, &tunnel.Listen)
+		var settings struct {
+			Clients []struct {
+				ID string `json:"id"`
+			} `json:"clients"`
+		}
+		if json.Unmarshal(inbound.Settings, &settings) == nil && len(settings.Clients) > 0 {
+			tunnel.BridgeID = settings.Clients[0].ID
+		}
+		if reality, ok := reverseReality(inbound); ok {
+			tunnel.SNI = reality.ServerNames[0]
+		} else {
+			// 早期版本创建的 interconn 入站未加密，需要删除后重新创建
+			tunnel.Missing = append(tunnel.Missing, "reality settings of inbound "+portal.Tag+reverseInterconnSuffix)
+		}
+	} else {
+		tunnel.Missing = append(tunnel.Missing, "inbound "+portal.Tag+reverseInterconnSuffix)
+	}
+
+	if inbound := t.templateInbound(portal.Tag + reverseExternalSuffix); inbound != nil {
+		tunnel.ExternalPort = inbound.Port
+		var settings struct {
+			Address string `json:"address"`
+			Port    int    `json:"port"`
+			Network string `json:"network"`
+		}
+		if json.Unmarshal(inbound.Settings, &settings) == nil {
+			tunnel.Network = settings.Network
+			if settings.Address != "" && settings.Port > 0 {
+				tunnel.Target = net.JoinHostPort(settings.Address, strconv.Itoa(settings.Port))
+			}
+		}
+	} else {
+		tunnel.Missing = append(tunnel.Missing, "inbound "+portal.Tag+reverseExternalSuffix)
+	}
+
+	for _, suffix := range []string{reverseExternalSuffix, reverseInterconnSuffix} {
+		if t.reverseRuleIndex(portal.Tag, portal.Tag+suffix) < 0 {
+			tunnel.Missing = append(tunnel.Missing, "routing rule "+portal.Tag+suffix)
+		}
+	}
+	return tunnel
+}
+
+// reverseRealitySettings interconn 入站的 REALITY 设置
+type reverseRealitySettings struct {
+	Target      string   `json:"target"`
+	ServerNames []string `json:"serverNames"`
+	PrivateKey  string   `json:"privateKey"`
+	ShortIds    []string `json:"shortIds"`
+}
+
+// reverseReality 读取 interconn 入站的 REALITY 设置，未启用 REALITY 时返回 false
+func reverseReality(inbound *xray.InboundConfig) (*reverseRealitySettings, bool) {
+	var stream struct {
+		Security        string                  `json:"security"`
+		RealitySettings *reverseRealitySettings `json:"realitySettings"`
+	}
+	if json.Unmarshal(inbound.StreamSettings, &stream) != nil || stream.Security != "reality" {
+		return nil, false
+	}
+	reality := stream.RealitySettings
+	if reality == nil || reality.PrivateKey == "" || len(reality.ServerNames) == 0 || len(reality.ShortIds) == 0 {
+		return nil, false
+	}
+	return reality, true
+}
+
+// newRealityKeyPair 生成 REALITY 使用的 X25519 密钥对（base64 URL 编码，无填充）
+func newRealityKeyPair() (string, string, error) {
+	private := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(private); err != nil {
+		return "", "", err
+	}
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(private), base64.RawURLEncoding.EncodeToString(public), nil
+}
+
+// realityPublicKey 由 REALITY 私钥计算公钥
+func realityPublicKey(privateKey string) (string, error) {
+	private, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil || len(private) != curve25519.ScalarSize {
+		return "", common.NewError("invalid reality private key")
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(public), nil
+}
+
+// reverseRuleIndex 查找把 inboundTag 的流量交给 portal 的路由规则
+func (t *routingTemplate) reverseRuleIndex(portalTag string, inboundTag string) int {
+	for i, raw := range t.rules {
+		rule, err := decodeRoutingRule(raw)
+		if err != nil || rule.OutboundTag != portalTag {
+			continue
+		}
+		if len(rule.InboundTag) == 1 && rule.InboundTag[0] == inboundTag {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddReverseTunnel 创建一对 portal/bridge：在模板中加入 reverse.portals、interconn 与 external 入站
+// 以及对应的路由规则，BridgeID 和 Domain 未填写时自动生成
+func (s *XraySettingService) AddReverseTunnel(tunnel *ReverseTunnel, author string) error {
+	if err := normalizeReverseTunnel(tunnel); err != nil {
+		return err
+	}
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	section, err := t.reverse()
+	if err != nil {
+		return err
+	}
+
+	interconnTag := tunnel.Tag + reverseInterconnSuffix
+	externalTag := tunnel.Tag + reverseExternalSuffix
+	if t.outboundTags()[tunnel.Tag] || t.balancerTags()[tunnel.Tag] {
+		return common.NewErrorf("tag %s is already in use", tunnel.Tag)
+	}
+	inboundTags := s.inboundTags(t)
+	for _, tag := range []string{interconnTag, externalTag} {
+		if inboundTags[tag] {
+			return common.NewErrorf("inbound tag %s is already in use", tag)
+		}
+	}
+	for _, p := range section.Portals {
+		if p.Domain == tunnel.Domain {
+			return common.NewErrorf("reverse domain %s is already used by %s", tunnel.Domain, p.Tag)
+		}
+	}
+	if err := s.checkReversePorts(t, tunnel); err != nil {
+		return err
+	}
+	if err := s.checkReverseEmail(tunnel.Tag + reverseBridgeSuffix); err != nil {
+		return err
+	}
+
+	section.Portals = append(section.Portals, reversePortal{Tag: tunnel.Tag, Domain: tunnel.Domain})
+	if err := t.setReverse(section); err != nil {
+		return err
+	}
+
+	interconn, external, err := buildReverseInbounds(tunnel)
+	if err != nil {
+		return err
+	}
+	if err := t.addInbounds(interconn, external); err != nil {
+		return err
+	}
+
+	// 规则插在最前面，避免外部流量被后面的 geoip:private 等规则拦截
+	var rules []json.RawMessage
+	for _, inboundTag := range []string{externalTag, interconnTag} {
+		raw, err := json.Marshal(&routingRuleFields{Type: "field", RuleTag: inboundTag, InboundTag: []string{inboundTag}, OutboundTag: tunnel.Tag})
+		if err != nil {
+			return err
+		}
+		rules = append(rules, raw)
+	}
+	t.rules = append(rules, t.rules...)
+
+	return s.saveRoutingTemplate(t, author, "add reverse tunnel "+tunnel.Tag)
+}
+
+// DelReverseTunnel 删除反向代理在模板中的全部组成部分
+func (s *XraySettingService) DelReverseTunnel(tag string, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	section, err := t.reverse()
+	if err != nil {
+		return err
+	}
+	found := false
+	portals := section.Portals[:0]
+	for _, p := range section.Portals {
+		if p.Tag == tag {
+			found = true
+			continue
+		}
+		portals = append(portals, p)
+	}
+	if !found {
+		return common.NewErrorf("reverse tunnel %s not found", tag)
+	}
+	section.Portals = portals
+	if err := t.setReverse(section); err != nil {
+		return err
+	}
+
+	t.removeInbounds(tag+reverseInterconnSuffix, tag+reverseExternalSuffix)
+
+	// 只删除 AddReverseTunnel 生成的规则，其余引用 portal 的规则由用户自行处理
+	generated := map[string]bool{tag + reverseInterconnSuffix: true, tag + reverseExternalSuffix: true}
+	rules := t.rules[:0]
+	for _, raw := range t.rules {
+		if rule, err := decodeRoutingRule(raw); err == nil && rule.OutboundTag == tag && generated[rule.RuleTag] &&
+			len(rule.InboundTag) == 1 && rule.InboundTag[0] == rule.RuleTag {
+			continue
+		}
+		rules = append(rules, raw)
+	}
+	t.rules = rules
+	list, err := t.outbounds()
+	if err != nil {
+		return err
+	}
+	if refs := t.outboundReferences(list, tag); len(refs) > 0 {
+		return common.NewErrorf("reverse tunnel %s is referenced by %s", tag, strings.Join(refs, ", "))
+	}
+
+	return s.saveRoutingTemplate(t, author, "delete reverse tunnel "+tag)
+}
+
+// GetReverseBridgeConfig 生成 bridge 一侧可直接运行的完整 Xray 配置，address 为 bridge 连接本面板使用的地址
+func (s *XraySettingService) GetReverseBridgeConfig(tag string, address string) (json.RawMessage, error) {
+	tunnel, err := s.GetReverseTunnel(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(tunnel.Missing) > 0 {
+		return nil, common.NewErrorf("reverse tunnel %s is incomplete: %s", tag, strings.Join(tunnel.Missing, ", "))
+	}
+	if address == "" {
+		return nil, common.NewError("portal address is required")
+	}
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	inbound := t.templateInbound(tag + reverseInterconnSuffix)
+	if inbound == nil {
+		return nil, common.NewErrorf("reverse tunnel %s is incomplete", tag)
+	}
+	reality, _ := reverseReality(inbound)
+	return buildBridgeConfig(tunnel, reality, address)
+}
+
+func normalizeReverseTunnel(tunnel *ReverseTunnel) error {
+	tunnel.Missing = nil
+	if !tagPrefixPattern.MatchString(tunnel.Tag) {
+		return common.NewErrorf("invalid tag: %q", tunnel.Tag)
+	}
+	if tunnel.Domain == "" {
+		tunnel.Domain = tunnel.Tag + ".reverse.internal"
+	} else if err := validateDomainMatcher("full:" + tunnel.Domain); err != nil {
+		return err
+	}
+	if tunnel.SNI == "" {
+		tunnel.SNI = reverseDefaultSNI
+	} else if err := validateDomainMatcher("full:" + tunnel.SNI); err != nil {
+		return err
+	}
+	if tunnel.BridgeID == "" {
+		tunnel.BridgeID = uuid.NewString()
+	} else if _, err := uuid.Parse(tunnel.BridgeID); err != nil {
+		return common.NewErrorf("invalid bridge id: %s", tunnel.BridgeID)
+	}
+	if tunnel.Listen != "" && net.ParseIP(tunnel.Listen) == nil {
+		return common.NewErrorf("invalid listen address: %s", tunnel.Listen)
+	}
+	for _, port := range []int{tunnel.InterconnPort, tunnel.ExternalPort} {
+		if port < 1 || port > 65535 {
+			return common.NewErrorf("invalid port: %d", port)
+		}
+	}
+	if tunnel.InterconnPort == tunnel.ExternalPort {
+		return common.NewError("interconn port and external port must differ")
+	}
+	switch tunnel.Network {
+	case "":
+		tunnel.Network = "tcp"
+	case "tcp", "udp", "tcp,udp":
+	default:
+		return common.NewErrorf("invalid network: %s", tunnel.Network)
+	}
+	host, port, err := net.SplitHostPort(tunnel.Target)
+	if err != nil || host == "" {
+		return common.NewErrorf("invalid target: %q", tunnel.Target)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return common.NewErrorf("invalid target: %q", tunnel.Target)
+	}
+	return nil
+}
+
+// checkReversePorts 检查 interconn 与 external 端口没有被模板或数据库中的入站占用
+func (s *XraySettingService) checkReversePorts(t *routingTemplate, tunnel *ReverseTunnel) error {
+	repo := repository.NewInboundRepository(database.GetDB())
+	for _, port := range []int{tunnel.InterconnPort, tunnel.ExternalPort} {
+		for _, inbound := range t.config.InboundConfigs {
+			if inbound.Port == port {
+				return common.NewErrorf("port %d is already used by inbound %s", port, inbound.Tag)
+			}
+		}
+		exist, err := repo.CheckPortExist(tunnel.Listen, port, 0)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return common.NewErrorf("port %d is already used by an inbound", port)
+		}
+	}
+	return nil
+}
+
+// checkReverseEmail bridge 客户端的 email 用于在线统计，不能与已有客户端重复
+func (s *XraySettingService) checkReverseEmail(email string) error {
+	emails, err := repository.NewInboundRepository(database.GetDB()).GetAllEmails()
+	if err != nil {
+		return err
+	}
+	for _, e := range emails {
+		if strings.EqualFold(e, email) {
+			return common.NewErrorf("client email %s is already in use", email)
+		}
+	}
+	return nil
+}
+
+func buildReverseInbounds(tunnel *ReverseTunnel) (*xray.InboundConfig, *xray.InboundConfig, error) {
+	var listen json_util.RawMessage
+	if tunnel.Listen != "" {
+		data, err := json.Marshal(tunnel.Listen)
+		if err != nil {
+			return nil, nil, err
+		}
+		listen = data
+	}
+
+	interconnSettings, err := json.Marshal(map[string]any{
+		"clients":    []map[string]any{{"id": tunnel.BridgeID, "email": tunnel.Tag + reverseBridgeSuffix, "level": 0}},
+		"decryption": "none",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	host, port, _ := net.SplitHostPort(tunnel.Target)
+	targetPort, _ := strconv.Atoi(port)
+	// external 入站的目标地址不会在 portal 上连接，而是作为请求目标随流量交给 bridge，由 bridge 直连
+	externalSettings, err := json.Marshal(map[string]any{
+		"address": host,
+		"port":    targetPort,
+		"network": tunnel.Network,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// bridge 与 portal 之间的流量经过公网，使用 REALITY 加密并验证 portal 身份
+	privateKey, _, err := newRealityKeyPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	shortID := make([]byte, 8)
+	if _, err := rand.Read(shortID); err != nil {
+		return nil, nil, err
+	}
+	interconnStream, err := json.Marshal(map[string]any{
+		"network":  "tcp",
+		"security": "reality",
+		"realitySettings": map[string]any{
+			"show":        false,
+			"target":      net.JoinHostPort(tunnel.SNI, "443"),
+			"xver":        0,
+			"serverNames": []string{tunnel.SNI},
+			"privateKey":  privateKey,
+			"shortIds":    []string{hex.EncodeToString(shortID)},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interconn := &xray.InboundConfig{
+		Listen:         listen,
+		Port:           tunnel.InterconnPort,
+		Protocol:       "vless",
+		Settings:       interconnSettings,
+		StreamSettings: interconnStream,
+		Tag:            tunnel.Tag + reverseInterconnSuffix,
+	}
+	external := &xray.InboundConfig{
+		Listen:   listen,
+		Port:     tunnel.ExternalPort,
+		Protocol: "dokodemo-door",
+		Settings: externalSettings,
+		Tag:      tunnel.Tag + reverseExternalSuffix,
+	}
+	return interconn, external, nil
+}
+
+// buildBridgeConfig bridge 一侧的配置：发往 portal 域名的流量走 interconn 出站以 VLESS + REALITY 连接本面板，
+// portal 转发回来的请求由 direct 出站连接 Target
+func buildBridgeConfig(tunnel *ReverseTunnel, reality *reverseRealitySettings, address string) (json.RawMessage, error) {
+	publicKey, err := realityPublicKey(reality.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	config := map[string]any{
+		"log": map[string]any{"loglevel": "warning"},
+		"reverse": map[string]any{
+			"bridges": []map[string]any{{"tag": "bridge", "domain": tunnel.Domain}},
+		},
+		"outbounds": []map[string]any{
+			{
+				"tag":      "interconn",
+				"protocol": "vless",
+				"settings": map[string]any{
+					"vnext": []map[string]any{{
+						"address": address,
+						"port":    tunnel.InterconnPort,
+						"users":   []map[string]any{{"id": tunnel.BridgeID, "encryption": "none"}},
+					}},
+				},
+				"streamSettings": map[string]any{
+					"network":  "tcp",
+					"security": "reality",
+					"realitySettings": map[string]any{
+						"serverName":  reality.ServerNames[0],
+						"fingerprint": "chrome",
+						"publicKey":   publicKey,
+						"shortId":     reality.ShortIds[0],
+						"spiderX":     "/",
+					},
+				},
+			},
+			{"tag": "direct", "protocol": "freedom"},
+		},
+		"routing": map[string]any{
+			"rules": []map[string]any{
+				{"type": "field", "inboundTag": []string{"bridge"}, "domain": []string{"full:" + tunnel.Domain}, "outboundTag": "interconn"},
+				{"type": "field", "inboundTag": []string{"bridge"}, "outboundTag": "direct"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetReverseTunnelStatus 根据 bridge 客户端的在线 IP 判断各反向代理是否已连接
+func (s *XrayService) GetReverseTunnelStatus(tunnels []*ReverseTunnel) []*ReverseTunnelStatus {
+	online, err := s.GetOnlineClientIPs()
+	if err != nil {
+		online = nil
+	}
+	statuses := make([]*ReverseTunnelStatus, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		status := &ReverseTunnelStatus{ReverseTunnel: tunnel}
+		for ip, seen := range online[tunnel.Tag+reverseBridgeSuffix] {
+			status.Connected = true
+			status.BridgeIPs = append(status.BridgeIPs, ip)
+			status.LastSeen = max(status.LastSeen, seen.Unix())
+		}
+		sort.Strings(status.BridgeIPs)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// GetOnlineClientIPs 通过 Xray 的在线统计获取各客户端当前在线的 IP
+func (s *XrayService) GetOnlineClientIPs() (map[string]map[string]time.Time, error) {
+	if !s.IsXrayRunning() {
+		return nil, common.ErrXrayNotRunning
+	}
+	api := xray.XrayAPI{}
+	if err := api.Init(s.process.GetAPIPort()); err != nil {
+		return nil, fmt.Errorf("failed to connect to xray api: %w", err)
+	}
+	defer api.Close()
+	return api.GetOnlineClientIPs()
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestXraySettingService_ReverseTunnel(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	tunnel := &ReverseTunnel{Tag: "home", InterconnPort: 20001, ExternalPort: 20002, Target: "127.0.0.1:8080"}
+	if err := s.AddReverseTunnel(tunnel, "admin"); err != nil {
+		t.Fatalf("add reverse tunnel failed: %v", err)
+	}
+	if tunnel.BridgeID == "" || tunnel.Domain != "home.reverse.internal" || tunnel.Network != "tcp" || tunnel.SNI != reverseDefaultSNI {
+		t.Errorf("defaults not filled: %+v", tunnel)
+	}
+
+	invalid := []*ReverseTunnel{
+		{Tag: "home", InterconnPort: 20011, ExternalPort: 20012, Target: "127.0.0.1:80"},
+		{Tag: "office", InterconnPort: 20001, ExternalPort: 20012, Target: "127.0.0.1:80"},
+		{Tag: "office", InterconnPort: 20011, ExternalPort: 20012, Target: "127.0.0.1"},
+		{Tag: "office", InterconnPort: 20011, ExternalPort: 20011, Target: "127.0.0.1:80"},
+		{Tag: "direct", InterconnPort: 20011, ExternalPort: 20012, Target: "127.0.0.1:80"},
+		{Tag: "office", InterconnPort: 20011, ExternalPort: 20012, Target: "127.0.0.1:80", Domain: "home.reverse.internal"},
+	}
+	for _, tun := range invalid {
+		if err := s.AddReverseTunnel(tun, "admin"); err == nil {
+			t.Errorf("invalid tunnel accepted: %+v", tun)
+		}
+	}
+
+	tunnels, err := s.GetReverseTunnels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tunnels) != 1 {
+		t.Fatalf("expected 1 tunnel, got %d", len(tunnels))
+	}
+	got := tunnels[0]
+	if len(got.Missing) > 0 || got.BridgeID != tunnel.BridgeID || got.Target != "127.0.0.1:8080" ||
+		got.InterconnPort != 20001 || got.ExternalPort != 20002 {
+		t.Errorf("unexpected tunnel %+v", got)
+	}
+
+	rules, err := s.GetRoutingRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules[0]["outboundTag"] != "home" || rules[0]["inboundTag"].([]any)[0] != "home-external" || rules[1]["inboundTag"].([]any)[0] != "home-interconn" {
+		t.Errorf("reverse rules not at the front: %+v %+v", rules[0], rules[1])
+	}
+	// portal 标签可以被其他路由规则引用
+	if err := s.AddRoutingRule(RoutingRule{"domain": []string{"full:nas.example.com"}, "outboundTag": "home"}, -1, "admin"); err != nil {
+		t.Errorf("rule referencing portal rejected: %v", err)
+	}
+
+	raw, err := s.GetReverseBridgeConfig("home", "portal.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bridge struct {
+		Reverse struct {
+			Bridges []reversePortal `json:"bridges"`
+		} `json:"reverse"`
+		Outbounds []struct {
+			Tag            string `json:"tag"`
+			StreamSettings struct {
+				Security        string `json:"security"`
+				RealitySettings struct {
+					ServerName string `json:"serverName"`
+					PublicKey  string `json:"publicKey"`
+					ShortID    string `json:"shortId"`
+				} `json:"realitySettings"`
+			} `json:"streamSettings"`
+			Settings struct {
+				Vnext []struct {
+					Address string `json:"address"`
+					Port    int    `json:"port"`
+					Users   []struct {
+						ID string `json:"id"`
+					} `json:"users"`
+				} `json:"vnext"`
+			} `json:"settings"`
+		} `json:"outbounds"`
+	}
+	if err := json.Unmarshal(raw, &bridge); err != nil {
+		t.Fatal(err)
+	}
+	if len(bridge.Reverse.Bridges) != 1 || bridge.Reverse.Bridges[0].Domain != "home.reverse.internal" {
+		t.Errorf("unexpected bridge reverse section: %s", raw)
+	}
+	vnext := bridge.Outbounds[0].Settings.Vnext[0]
+	if vnext.Address != "portal.example.com" || vnext.Port != 20001 || vnext.Users[0].ID != tunnel.BridgeID {
+		t.Errorf("unexpected interconn outbound: %+v", vnext)
+	}
+	// bridge 使用的公钥和 shortId 应与 portal 的 interconn 入站匹配
+	template, err := s.loadRoutingTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	portal, ok := reverseReality(template.templateInbound("home-interconn"))
+	if !ok {
+		t.Fatal("interconn inbound should use reality")
+	}
+	publicKey, _ := realityPublicKey(portal.PrivateKey)
+	stream := bridge.Outbounds[0].StreamSettings
+	if stream.Security != "reality" || stream.RealitySettings.PublicKey != publicKey ||
+		stream.RealitySettings.ShortID != portal.ShortIds[0] || stream.RealitySettings.ServerName != reverseDefaultSNI {
+		t.Errorf("unexpected interconn security: %+v", stream)
+	}
+
+	statuses := (&XrayService{}).GetReverseTunnelStatus(tunnels)
+	if len(statuses) != 1 || statuses[0].Connected {
+		t.Errorf("tunnel should not be connected without xray: %+v", statuses)
+	}
+
+	// 用户添加的规则仍引用 portal 时拒绝删除，且不会被当作生成的规则删除
+	if err := s.DelReverseTunnel("home", "admin"); err == nil {
+		t.Fatal("tunnel referenced by a user rule should not be deleted")
+	}
+	rules, _ = s.GetRoutingRules()
+	if err := s.DelRoutingRule(len(rules)-1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelReverseTunnel("home", "admin"); err != nil {
+		t.Fatalf("delete reverse tunnel failed: %v", err)
+	}
+	if tunnels, _ := s.GetReverseTunnels(); len(tunnels) != 0 {
+		t.Errorf("tunnel not deleted: %+v", tunnels)
+	}
+	rules, _ = s.GetRoutingRules()
+	for _, rule := range rules {
+		if rule["outboundTag"] == "home" {
+			t.Errorf("rule not deleted: %+v", rule)
+		}
+	}
+	if err := s.DelReverseTunnel("home", "admin"); err == nil {
+		t.Error("expected error deleting missing tunnel")
+	}
+}
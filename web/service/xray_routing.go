@@ -0,0 +1,931 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"x-ui/database"
+	"x-ui/database/repository"
+	"x-ui/util/common"
+	"x-ui/util/json_util"
+	"x-ui/xray"
+)
+
+// RoutingRule 路由规则，对应 Xray routing.rules 中的一项。规则以原始 JSON 对象保存，
+// 面板只校验和修改 routingRuleFields 中的字段，localPort、process、webhook 等其余字段原样保留
+type RoutingRule map[string]any
+
+// routingRuleFields 路由规则中由面板校验和修改的字段，也用于生成 WARP、反向代理等内置规则
+type routingRuleFields struct {
+	Type        string            `json:"type,omitempty"`
+	RuleTag     string            `json:"ruleTag,omitempty"`
+	Domain      []string          `json:"domain,omitempty"`
+	IP          []string          `json:"ip,omitempty"`
+	Port        rulePort          `json:"port,omitempty"`
+	SourcePort  rulePort          `json:"sourcePort,omitempty"`
+	Network     string            `json:"network,omitempty"`
+	Source      []string          `json:"source,omitempty"`
+	User        []string          `json:"user,omitempty"`
+	InboundTag  []string          `json:"inboundTag,omitempty"`
+	Protocol    []string          `json:"protocol,omitempty"`
+	Attrs       map[string]string `json:"attrs,omitempty"`
+	OutboundTag string            `json:"outboundTag,omitempty"`
+	BalancerTag string            `json:"balancerTag,omitempty"`
+}
+
+// routingRuleKeys routingRuleFields 对应的 JSON 字段名
+var routingRuleKeys = []string{
+	"type", "ruleTag", "domain", "ip", "port", "sourcePort", "network", "source",
+	"user", "inboundTag", "protocol", "attrs", "outboundTag", "balancerTag",
+}
+
+// rulePort 端口列表，Xray 同时接受数字（443）和字符串（"53,443,1000-2000"）
+type rulePort string
+
+func (p *rulePort) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*p = rulePort(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return common.NewErrorf("invalid port: %s", data)
+	}
+	*p = rulePort(s)
+	return nil
+}
+
+// decodeRoutingRule 解析规则中由面板管理的字段
+func decodeRoutingRule(raw []byte) (*routingRuleFields, error) {
+	fields := &routingRuleFields{}
+	if err := json.Unmarshal(raw, fields); err != nil {
+		return nil, common.NewError("invalid routing rule:", err)
+	}
+	return fields, nil
+}
+
+// BalancerStrategy 负载均衡策略
+type BalancerStrategy struct {
+	Type     string          `json:"type,omitempty"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// Balancer 负载均衡器，对应 Xray routing.balancers 中的一项
+type Balancer struct {
+	Tag         string            `json:"tag"`
+	Selector    []string          `json:"selector"`
+	FallbackTag string            `json:"fallbackTag,omitempty"`
+	Strategy    *BalancerStrategy `json:"strategy,omitempty"`
+}
+
+// DNSServer DNS 服务器，对应 Xray dns.servers 中的一项；只填写地址时以字符串形式保存
+type DNSServer struct {
+	Address       string   `json:"address"`
+	Port          int      `json:"port,omitempty"`
+	Domains       []string `json:"domains,omitempty"`
+	ExpectIPs     []string `json:"expectIPs,omitempty"`
+	SkipFallback  bool     `json:"skipFallback,omitempty"`
+	ClientIP      string   `json:"clientIP,omitempty"`
+	QueryStrategy string   `json:"queryStrategy,omitempty"`
+	Tag           string   `json:"tag,omitempty"`
+}
+
+// FakeDNSPool FakeDNS 地址池，对应 Xray fakedns 中的一项
+type FakeDNSPool struct {
+	IPPool   string `json:"ipPool"`
+	PoolSize int    `json:"poolSize"`
+}
+
+var (
+	ruleNetworks  = map[string]bool{"tcp": true, "udp": true}
+	ruleProtocols = map[string]bool{"http": true, "tls": true, "quic": true, "bittorrent": true}
+	dnsStrategies = map[string]bool{"": true, "UseIP": true, "UseIPv4": true, "UseIPv6": true}
+)
+
+// routingTemplate 模板中与路由相关的可编辑部分。保存时只写回这些部分，
+// 模板的其他顶层字段和入站中面板不认识的字段原样保留
+type routingTemplate struct {
+	config   *xray.Config
+	raw      map[string]json.RawMessage
+	inbounds []json.RawMessage
+	routing  map[string]json.RawMessage
+	rules    []json.RawMessage
+	bals     []json.RawMessage
+	dns      map[string]json.RawMessage
+	servers  []json.RawMessage
+	fakedns  []json.RawMessage
+}
+
+func (s *XraySettingService) loadRoutingTemplate() (*routingTemplate, error) {
+	template, err := s.GetXrayConfigTemplate()
+	if err != nil {
+		return nil, err
+	}
+	t := &routingTemplate{config: &xray.Config{}}
+	if err := json.Unmarshal([]byte(template), t.config); err != nil {
+		return nil, common.NewError("xray template config invalid:", err)
+	}
+	if err := json.Unmarshal([]byte(template), &t.raw); err != nil {
+		return nil, common.NewError("xray template config invalid:", err)
+	}
+	if err := decodeList(t.raw["inbounds"], &t.inbounds); err != nil {
+		return nil, common.NewError("invalid inbounds:", err)
+	}
+
+	if t.routing, err = decodeSection(t.config.RouterConfig); err != nil {
+		return nil, common.NewError("invalid routing section:", err)
+	}
+	if err := decodeList(t.routing["rules"], &t.rules); err != nil {
+		return nil, common.NewError("invalid routing rules:", err)
+	}
+	if err := decodeList(t.routing["balancers"], &t.bals); err != nil {
+		return nil, common.NewError("invalid routing balancers:", err)
+	}
+	if t.dns, err = decodeSection(t.config.DNSConfig); err != nil {
+		return nil, common.NewError("invalid dns section:", err)
+	}
+	if err := decodeList(t.dns["servers"], &t.servers); err != nil {
+		return nil, common.NewError("invalid dns servers:", err)
+	}
+	// fakedns 既可以是单个对象也可以是数组
+	if raw := t.config.FakeDNS; len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &t.fakedns); err != nil {
+			t.fakedns = []json.RawMessage{json.RawMessage(raw)}
+		}
+	}
+	return t, nil
+}
+
+// saveRoutingTemplate 写回各部分并通过 SaveXraySetting 保存，保证完整校验并记录历史版本。
+// 调用方需在 loadRoutingTemplate 之前取得 templateMu，并持有到保存完成
+func (s *XraySettingService) saveRoutingTemplate(t *routingTemplate, author string, comment string) error {
+	var err error
+	if t.routing["rules"], err = json.Marshal(t.rules); err != nil {
+		return err
+	}
+	if len(t.bals) > 0 {
+		if t.routing["balancers"], err = json.Marshal(t.bals); err != nil {
+			return err
+		}
+	} else {
+		delete(t.routing, "balancers")
+	}
+	if t.config.RouterConfig, err = json.Marshal(t.routing); err != nil {
+		return err
+	}
+
+	if len(t.servers) > 0 || len(t.dns) > 0 {
+		if len(t.servers) > 0 {
+			if t.dns["servers"], err = json.Marshal(t.servers); err != nil {
+				return err
+			}
+		} else {
+			delete(t.dns, "servers")
+		}
+		if len(t.dns) > 0 {
+			if t.config.DNSConfig, err = json.Marshal(t.dns); err != nil {
+				return err
+			}
+		} else {
+			t.config.DNSConfig = nil
+		}
+	}
+
+	if len(t.fakedns) > 0 {
+		if t.config.FakeDNS, err = json.Marshal(t.fakedns); err != nil {
+			return err
+		}
+	} else {
+		t.config.FakeDNS = nil
+	}
+
+	inbounds, err := json.Marshal(t.inbounds)
+	if err != nil {
+		return err
+	}
+	for key, section := range map[string][]byte{
+		"routing":   t.config.RouterConfig,
+		"dns":       t.config.DNSConfig,
+		"fakedns":   t.config.FakeDNS,
+		"outbounds": t.config.OutboundConfigs,
+		"reverse":   t.config.Reverse,
+		"inbounds":  inbounds,
+	} {
+		if len(section) == 0 || string(section) == "null" {
+			delete(t.raw, key)
+		} else {
+			t.raw[key] = section
+		}
+	}
+
+	data, err := json.MarshalIndent(t.raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.saveXraySetting(string(data), author, comment)
+}
+
+// addInbounds 向模板追加入站
+func (t *routingTemplate) addInbounds(inbounds ...*xray.InboundConfig) error {
+	for _, inbound := range inbounds {
+		raw, err := json.Marshal(inbound)
+		if err != nil {
+			return err
+		}
+		t.inbounds = append(t.inbounds, raw)
+		t.config.InboundConfigs = append(t.config.InboundConfigs, *inbound)
+	}
+	return nil
+}
+
+// removeInbounds 从模板删除指定标签的入站
+func (t *routingTemplate) removeInbounds(tags ...string) {
+	remove := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		remove[tag] = true
+	}
+	inbounds := t.inbounds[:0]
+	for _, raw := range t.inbounds {
+		var inbound struct {
+			Tag string `json:"tag"`
+		}
+		if json.Unmarshal(raw, &inbound) == nil && remove[inbound.Tag] {
+			continue
+		}
+		inbounds = append(inbounds, raw)
+	}
+	t.inbounds = inbounds
+
+	configs := t.config.InboundConfigs[:0]
+	for _, inbound := range t.config.InboundConfigs {
+		if !remove[inbound.Tag] {
+			configs = append(configs, inbound)
+		}
+	}
+	t.config.InboundConfigs = configs
+}
+
+func decodeSection(raw json_util.RawMessage) (map[string]json.RawMessage, error) {
+	section := make(map[string]json.RawMessage)
+	if len(raw) == 0 || string(raw) == "null" {
+		return section, nil
+	}
+	if err := json.Unmarshal(raw, &section); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+func decodeList(raw json.RawMessage, out *[]json.RawMessage) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// outboundTags 返回路由规则可以引用的出站标签（包括 api、metrics 等内置标签及反向代理标签）
+func (t *routingTemplate) outboundTags() map[string]bool {
+	tags := make(map[string]bool)
+	var outbounds []struct {
+		Tag string `json:"tag"`
+	}
+	_ = json.Unmarshal(t.config.OutboundConfigs, &outbounds)
+	for _, o := range outbounds {
+		if o.Tag != "" {
+			tags[o.Tag] = true
+		}
+	}
+	for _, raw := range []json_util.RawMessage{t.config.API, t.config.Metrics} {
+		var section struct {
+			Tag string `json:"tag"`
+		}
+		if json.Unmarshal(raw, &section) == nil && section.Tag != "" {
+			tags[section.Tag] = true
+		}
+	}
+	// reverse 中的 portal 与 bridge 也可以作为出站被路由规则引用
+	for tag := range t.reverseTags() {
+		tags[tag] = true
+	}
+	return tags
+}
+
+func (t *routingTemplate) balancerTags() map[string]bool {
+	tags := make(map[string]bool)
+	for _, raw := range t.bals {
+		var b Balancer
+		if json.Unmarshal(raw, &b) == nil {
+			tags[b.Tag] = true
+		}
+	}
+	return tags
+}
+
+// inboundTags 返回模板与数据库中的全部入站标签
+func (s *XraySettingService) inboundTags(t *routingTemplate) map[string]bool {
+	tags := make(map[string]bool)
+	for _, inbound := range t.config.InboundConfigs {
+		if inbound.Tag != "" {
+			tags[inbound.Tag] = true
+		}
+	}
+	if dbTags, err := repository.NewInboundRepository(database.GetDB()).GetAllTags(); err == nil {
+		for _, tag := range dbTags {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
+
+// =============================================================================
+// 路由规则
+// =============================================================================
+
+// GetRoutingRules 返回模板中的全部路由规则
+func (s *XraySettingService) GetRoutingRules() ([]RoutingRule, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]RoutingRule, 0, len(t.rules))
+	for _, raw := range t.rules {
+		var rule RoutingRule
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			return nil, common.NewError("invalid routing rule:", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// AddRoutingRule 在 index 处插入路由规则，index 小于 0 或越界时追加到末尾
+func (s *XraySettingService) AddRoutingRule(rule RoutingRule, index int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	raw, err := s.encodeRoutingRule(t, rule)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index > len(t.rules) {
+		index = len(t.rules)
+	}
+	t.rules = append(t.rules[:index], append([]json.RawMessage{raw}, t.rules[index:]...)...)
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("add routing rule #%d", index))
+}
+
+// UpdateRoutingRule 修改指定位置的路由规则。由面板管理的字段以提交的内容为准，
+// 其余字段未提交时保留原值，提交 null 时删除
+func (s *XraySettingService) UpdateRoutingRule(index int, rule RoutingRule, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.rules) {
+		return common.NewErrorf("routing rule #%d not found", index)
+	}
+	var merged RoutingRule
+	if err := json.Unmarshal(t.rules[index], &merged); err != nil || merged == nil {
+		merged = RoutingRule{}
+	}
+	for _, key := range routingRuleKeys {
+		delete(merged, key)
+	}
+	for key, value := range rule {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if t.rules[index], err = s.encodeRoutingRule(t, merged); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("update routing rule #%d", index))
+}
+
+// encodeRoutingRule 校验规则并序列化，未指定 type 时补充为 field
+func (s *XraySettingService) encodeRoutingRule(t *routingTemplate, rule RoutingRule) (json.RawMessage, error) {
+	if rule == nil {
+		return nil, common.NewError("routing rule is required")
+	}
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := decodeRoutingRule(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRoutingRule(t, fields); err != nil {
+		return nil, err
+	}
+	if _, ok := rule["type"]; !ok {
+		rule["type"] = fields.Type
+		return json.Marshal(rule)
+	}
+	return raw, nil
+}
+
+// DelRoutingRule 删除指定位置的路由规则
+func (s *XraySettingService) DelRoutingRule(index int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.rules) {
+		return common.NewErrorf("routing rule #%d not found", index)
+	}
+	t.rules = append(t.rules[:index], t.rules[index+1:]...)
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("delete routing rule #%d", index))
+}
+
+// MoveRoutingRule 调整路由规则顺序，规则按顺序匹配
+func (s *XraySettingService) MoveRoutingRule(from int, to int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if from < 0 || from >= len(t.rules) || to < 0 || to >= len(t.rules) {
+		return common.NewErrorf("invalid move from #%d to #%d", from, to)
+	}
+	if from == to {
+		return nil
+	}
+	rule := t.rules[from]
+	t.rules = append(t.rules[:from], t.rules[from+1:]...)
+	t.rules = append(t.rules[:to], append([]json.RawMessage{rule}, t.rules[to:]...)...)
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("move routing rule #%d to #%d", from, to))
+}
+
+func (s *XraySettingService) validateRoutingRule(t *routingTemplate, rule *routingRuleFields) error {
+	if rule.Type == "" {
+		rule.Type = "field"
+	}
+	if rule.Type != "field" {
+		return common.NewErrorf("unsupported rule type: %s", rule.Type)
+	}
+	if (rule.OutboundTag == "") == (rule.BalancerTag == "") {
+		return common.NewError("rule must specify exactly one of outboundTag and balancerTag")
+	}
+	if rule.OutboundTag != "" && !t.outboundTags()[rule.OutboundTag] {
+		return common.NewErrorf("outbound tag %q does not exist", rule.OutboundTag)
+	}
+	if rule.BalancerTag != "" && !t.balancerTags()[rule.BalancerTag] {
+		return common.NewErrorf("balancer tag %q does not exist", rule.BalancerTag)
+	}
+
+	if len(rule.Domain) == 0 && len(rule.IP) == 0 && rule.Port == "" && rule.SourcePort == "" &&
+		rule.Network == "" && len(rule.Source) == 0 && len(rule.User) == 0 &&
+		len(rule.InboundTag) == 0 && len(rule.Protocol) == 0 && len(rule.Attrs) == 0 {
+		return common.NewError("rule must have at least one matcher")
+	}
+
+	for _, d := range rule.Domain {
+		if err := validateDomainMatcher(d); err != nil {
+			return err
+		}
+	}
+	for _, ip := range rule.IP {
+		if err := validateIPMatcher(ip); err != nil {
+			return err
+		}
+	}
+	for _, ip := range rule.Source {
+		if err := validateIPMatcher(ip); err != nil {
+			return err
+		}
+	}
+	if err := validatePortList(string(rule.Port)); err != nil {
+		return err
+	}
+	if err := validatePortList(string(rule.SourcePort)); err != nil {
+		return err
+	}
+	if rule.Network != "" {
+		for _, n := range strings.Split(rule.Network, ",") {
+			if !ruleNetworks[strings.TrimSpace(n)] {
+				return common.NewErrorf("invalid network: %s", n)
+			}
+		}
+	}
+	for _, p := range rule.Protocol {
+		if !ruleProtocols[p] {
+			return common.NewErrorf("invalid protocol: %s", p)
+		}
+	}
+	if len(rule.InboundTag) > 0 {
+		inboundTags := s.inboundTags(t)
+		for _, tag := range rule.InboundTag {
+			if !inboundTags[tag] {
+				return common.NewErrorf("inbound tag %q does not exist", tag)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDomainMatcher 校验域名匹配规则（domain:/full:/keyword:/regexp:/geosite:/ext: 或纯字符串）
+func validateDomainMatcher(d string) error {
+	if strings.TrimSpace(d) == "" || strings.ContainsAny(d, " \t") {
+		return common.NewErrorf("invalid domain matcher: %q", d)
+	}
+	prefix, value, found := strings.Cut(d, ":")
+	if !found {
+		return nil
+	}
+	switch prefix {
+	case "regexp":
+		if _, err := regexp.Compile(value); err != nil {
+			return common.NewErrorf("invalid domain regexp %q: %v", value, err)
+		}
+	case "domain", "full", "keyword", "geosite", "ext", "dotless":
+		if value == "" && prefix != "dotless" {
+			return common.NewErrorf("invalid domain matcher: %q", d)
+		}
+	}
+	return nil
+}
+
+// validateIPMatcher 校验 IP 匹配规则（IP、CIDR、geoip: 或 ext:）
+func validateIPMatcher(ip string) error {
+	if strings.HasPrefix(ip, "geoip:") || strings.HasPrefix(ip, "ext:") {
+		if len(strings.SplitN(ip, ":", 2)[1]) == 0 {
+			return common.NewErrorf("invalid ip matcher: %q", ip)
+		}
+		return nil
+	}
+	if net.ParseIP(ip) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(ip); err == nil {
+		return nil
+	}
+	return common.NewErrorf("invalid ip matcher: %q", ip)
+}
+
+// validatePortList 校验端口列表，例如 "53,443,1000-2000"
+func validatePortList(ports string) error {
+	if ports == "" {
+		return nil
+	}
+	parsePort := func(p string) (int, error) {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 || n > 65535 {
+			return 0, common.NewErrorf("invalid port: %q", p)
+		}
+		return n, nil
+	}
+	for _, part := range strings.Split(ports, ",") {
+		if lo, hi, isRange := strings.Cut(part, "-"); isRange {
+			a, err := parsePort(lo)
+			if err != nil {
+				return err
+			}
+			b, err := parsePort(hi)
+			if err != nil {
+				return err
+			}
+			if a > b {
+				return common.NewErrorf("invalid port range: %q", part)
+			}
+			continue
+		}
+		if _, err := parsePort(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// 负载均衡器
+// =============================================================================
+
+// GetBalancers 返回模板中的全部负载均衡器
+func (s *XraySettingService) GetBalancers() ([]*Balancer, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	balancers := make([]*Balancer, 0, len(t.bals))
+	for _, raw := range t.bals {
+		b := &Balancer{}
+		if err := json.Unmarshal(raw, b); err != nil {
+			return nil, err
+		}
+		balancers = append(balancers, b)
+	}
+	return balancers, nil
+}
+
+func (t *routingTemplate) balancerIndex(tag string) int {
+	for i, raw := range t.bals {
+		var b Balancer
+		if json.Unmarshal(raw, &b) == nil && b.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// SaveBalancer 新增或更新（按 tag）负载均衡器
+func (s *XraySettingService) SaveBalancer(balancer *Balancer, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if balancer.Tag == "" {
+		return common.NewError("balancer tag is required")
+	}
+	if len(balancer.Selector) == 0 {
+		return common.NewError("balancer selector is required")
+	}
+	for _, sel := range balancer.Selector {
+		if strings.TrimSpace(sel) == "" {
+			return common.NewError("balancer selector must not be empty")
+		}
+	}
+	if balancer.FallbackTag != "" && !t.outboundTags()[balancer.FallbackTag] {
+		return common.NewErrorf("fallback outbound tag %q does not exist", balancer.FallbackTag)
+	}
+	if balancer.Strategy != nil {
+		switch balancer.Strategy.Type {
+		case "", "random", "roundRobin", "leastPing", "leastLoad":
+		default:
+			return common.NewErrorf("invalid balancer strategy: %s", balancer.Strategy.Type)
+		}
+	}
+
+	raw, err := json.Marshal(balancer)
+	if err != nil {
+		return err
+	}
+	if i := t.balancerIndex(balancer.Tag); i >= 0 {
+		t.bals[i] = raw
+	} else {
+		t.bals = append(t.bals, raw)
+	}
+	return s.saveRoutingTemplate(t, author, "save balancer "+balancer.Tag)
+}
+
+// DelBalancer 删除负载均衡器，仍被路由规则引用时拒绝删除
+func (s *XraySettingService) DelBalancer(tag string, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	i := t.balancerIndex(tag)
+	if i < 0 {
+		return common.NewErrorf("balancer %q not found", tag)
+	}
+	for n, raw := range t.rules {
+		if rule, err := decodeRoutingRule(raw); err == nil && rule.BalancerTag == tag {
+			return common.NewErrorf("balancer %q is used by routing rule #%d", tag, n)
+		}
+	}
+	t.bals = append(t.bals[:i], t.bals[i+1:]...)
+	return s.saveRoutingTemplate(t, author, "delete balancer "+tag)
+}
+
+// =============================================================================
+// DNS 服务器
+// =============================================================================
+
+// GetDNSServers 返回模板中的 DNS 服务器列表
+func (s *XraySettingService) GetDNSServers() ([]*DNSServer, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]*DNSServer, 0, len(t.servers))
+	for _, raw := range t.servers {
+		server, err := decodeDNSServer(raw)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+func decodeDNSServer(raw json.RawMessage) (*DNSServer, error) {
+	var address string
+	if json.Unmarshal(raw, &address) == nil {
+		return &DNSServer{Address: address}, nil
+	}
+	server := &DNSServer{}
+	if err := json.Unmarshal(raw, server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func encodeDNSServer(server *DNSServer) (json.RawMessage, error) {
+	if server.Port == 0 && len(server.Domains) == 0 && len(server.ExpectIPs) == 0 && !server.SkipFallback &&
+		server.ClientIP == "" && server.QueryStrategy == "" && server.Tag == "" {
+		return json.Marshal(server.Address)
+	}
+	return json.Marshal(server)
+}
+
+func validateDNSServer(server *DNSServer) error {
+	if strings.TrimSpace(server.Address) == "" {
+		return common.NewError("dns server address is required")
+	}
+	if server.Port < 0 || server.Port > 65535 {
+		return common.NewErrorf("invalid dns server port: %d", server.Port)
+	}
+	if !dnsStrategies[server.QueryStrategy] {
+		return common.NewErrorf("invalid query strategy: %s", server.QueryStrategy)
+	}
+	if server.ClientIP != "" && net.ParseIP(server.ClientIP) == nil {
+		return common.NewErrorf("invalid client ip: %s", server.ClientIP)
+	}
+	for _, d := range server.Domains {
+		if err := validateDomainMatcher(d); err != nil {
+			return err
+		}
+	}
+	for _, ip := range server.ExpectIPs {
+		if err := validateIPMatcher(strings.TrimPrefix(ip, "!")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDNSServer 在 index 处插入 DNS 服务器，index 小于 0 或越界时追加到末尾
+func (s *XraySettingService) AddDNSServer(server *DNSServer, index int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if err := validateDNSServer(server); err != nil {
+		return err
+	}
+	raw, err := encodeDNSServer(server)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index > len(t.servers) {
+		index = len(t.servers)
+	}
+	t.servers = append(t.servers[:index], append([]json.RawMessage{raw}, t.servers[index:]...)...)
+	return s.saveRoutingTemplate(t, author, "add dns server "+server.Address)
+}
+
+// UpdateDNSServer 替换指定位置的 DNS 服务器
+func (s *XraySettingService) UpdateDNSServer(index int, server *DNSServer, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.servers) {
+		return common.NewErrorf("dns server #%d not found", index)
+	}
+	if err := validateDNSServer(server); err != nil {
+		return err
+	}
+	if t.servers[index], err = encodeDNSServer(server); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, "update dns server "+server.Address)
+}
+
+// DelDNSServer 删除指定位置的 DNS 服务器
+func (s *XraySettingService) DelDNSServer(index int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.servers) {
+		return common.NewErrorf("dns server #%d not found", index)
+	}
+	t.servers = append(t.servers[:index], t.servers[index+1:]...)
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("delete dns server #%d", index))
+}
+
+// =============================================================================
+// FakeDNS 地址池
+// =============================================================================
+
+// GetFakeDNSPools 返回模板中的 FakeDNS 地址池
+func (s *XraySettingService) GetFakeDNSPools() ([]*FakeDNSPool, error) {
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return nil, err
+	}
+	pools := make([]*FakeDNSPool, 0, len(t.fakedns))
+	for _, raw := range t.fakedns {
+		pool := &FakeDNSPool{}
+		if err := json.Unmarshal(raw, pool); err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func validateFakeDNSPool(t *routingTemplate, pool *FakeDNSPool, skip int) error {
+	_, network, err := net.ParseCIDR(pool.IPPool)
+	if err != nil {
+		return common.NewErrorf("invalid fakedns ip pool: %s", pool.IPPool)
+	}
+	ones, bits := network.Mask.Size()
+	if pool.PoolSize <= 0 {
+		return common.NewError("fakedns pool size must be positive")
+	}
+	if hostBits := bits - ones; hostBits < 63 && int64(pool.PoolSize) > int64(1)<<hostBits {
+		return common.NewErrorf("fakedns pool size %d exceeds the capacity of %s", pool.PoolSize, pool.IPPool)
+	}
+	for i, raw := range t.fakedns {
+		var other FakeDNSPool
+		if i == skip || json.Unmarshal(raw, &other) != nil {
+			continue
+		}
+		if _, otherNet, err := net.ParseCIDR(other.IPPool); err == nil &&
+			(otherNet.Contains(network.IP) || network.Contains(otherNet.IP)) {
+			return common.NewErrorf("fakedns ip pool %s overlaps with %s", pool.IPPool, other.IPPool)
+		}
+	}
+	return nil
+}
+
+// AddFakeDNSPool 新增 FakeDNS 地址池
+func (s *XraySettingService) AddFakeDNSPool(pool *FakeDNSPool, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if err := validateFakeDNSPool(t, pool, -1); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(pool)
+	if err != nil {
+		return err
+	}
+	t.fakedns = append(t.fakedns, raw)
+	return s.saveRoutingTemplate(t, author, "add fakedns pool "+pool.IPPool)
+}
+
+// UpdateFakeDNSPool 替换指定位置的 FakeDNS 地址池
+func (s *XraySettingService) UpdateFakeDNSPool(index int, pool *FakeDNSPool, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.fakedns) {
+		return common.NewErrorf("fakedns pool #%d not found", index)
+	}
+	if err := validateFakeDNSPool(t, pool, index); err != nil {
+		return err
+	}
+	if t.fakedns[index], err = json.Marshal(pool); err != nil {
+		return err
+	}
+	return s.saveRoutingTemplate(t, author, "update fakedns pool "+pool.IPPool)
+}
+
+// DelFakeDNSPool 删除指定位置的 FakeDNS 地址池
+func (s *XraySettingService) DelFakeDNSPool(index int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	t, err := s.loadRoutingTemplate()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(t.fakedns) {
+		return common.NewErrorf("fakedns pool #%d not found", index)
+	}
+	t.fakedns = append(t.fakedns[:index], t.fakedns[index+1:]...)
+	return s.saveRoutingTemplate(t, author, fmt.Sprintf("delete fakedns pool #%d", index))
+}
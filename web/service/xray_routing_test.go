@@ -0,0 +1,211 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func setupRoutingTemplate(t *testing.T) *XraySettingService {
+	t.Helper()
+	setupTestDB(t)
+	s := &XraySettingService{}
+	if err := s.SaveXraySetting(testTemplateB, "admin", ""); err != nil {
+		t.Fatalf("failed to seed template: %v", err)
+	}
+	return s
+}
+
+func TestXraySettingService_RoutingRules(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	if err := s.AddRoutingRule(RoutingRule{"domain": []string{"full:example.com"}, "outboundTag": "direct"}, -1, "admin"); err != nil {
+		t.Fatalf("add rule failed: %v", err)
+	}
+	if err := s.AddRoutingRule(RoutingRule{"inboundTag": []string{"api"}, "outboundTag": "blocked"}, 0, "admin"); err != nil {
+		t.Fatalf("insert rule failed: %v", err)
+	}
+
+	invalid := []RoutingRule{
+		{"domain": []string{"example.com"}, "outboundTag": "missing"},
+		{"domain": []string{"example.com"}, "balancerTag": "missing"},
+		{"domain": []string{"example.com"}, "outboundTag": "direct", "balancerTag": "b"},
+		{"outboundTag": "direct"},
+		{"domain": []string{"regexp:("}, "outboundTag": "direct"},
+		{"ip": []string{"300.1.1.1"}, "outboundTag": "direct"},
+		{"port": "0-80", "outboundTag": "direct"},
+		{"network": "icmp", "outboundTag": "direct"},
+		{"inboundTag": []string{"no-such-inbound"}, "outboundTag": "direct"},
+	}
+	for i, rule := range invalid {
+		if err := s.AddRoutingRule(rule, -1, "admin"); err == nil {
+			t.Errorf("invalid rule %d accepted", i)
+		}
+	}
+
+	rules, err := s.GetRoutingRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0]["outboundTag"] != "blocked" || rules[0]["type"] != "field" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	if err := s.MoveRoutingRule(0, 1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	rules, _ = s.GetRoutingRules()
+	if rules[0]["outboundTag"] != "direct" {
+		t.Errorf("move did not reorder rules: %+v", rules)
+	}
+
+	if err := s.DelRoutingRule(1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	rules, _ = s.GetRoutingRules()
+	if len(rules) != 1 {
+		t.Errorf("expected 1 rule after delete, got %d", len(rules))
+	}
+}
+
+// 并发修改模板时每次修改都必须保留，不能被其他修改覆盖
+func TestXraySettingService_ConcurrentTemplateEdits(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rule := RoutingRule{"domain": []string{fmt.Sprintf("full:host%d.example.com", i)}, "outboundTag": "direct"}
+			if err := s.AddRoutingRule(rule, -1, "admin"); err != nil {
+				t.Errorf("add rule %d failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	rules, err := s.GetRoutingRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != n {
+		t.Errorf("expected %d rules after concurrent edits, got %d", n, len(rules))
+	}
+}
+
+func TestXraySettingService_RoutingRuleKeepsUnknownFields(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	// 数字端口和面板不认识的字段都应原样保留
+	rule := RoutingRule{"port": 443, "process": []string{"curl"}, "localPort": "1000-2000", "outboundTag": "direct"}
+	if err := s.AddRoutingRule(rule, -1, "admin"); err != nil {
+		t.Fatalf("add rule failed: %v", err)
+	}
+	rules, err := s.GetRoutingRules()
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("unexpected rules %+v, %v", rules, err)
+	}
+	if rules[0]["port"] != float64(443) || rules[0]["localPort"] != "1000-2000" {
+		t.Errorf("rule fields not preserved: %+v", rules[0])
+	}
+	if err := s.AddRoutingRule(RoutingRule{"port": 0, "outboundTag": "direct"}, -1, "admin"); err == nil {
+		t.Error("invalid numeric port accepted")
+	}
+
+	// 修改时替换面板管理的字段，未提交的其他字段保留，提交 null 的字段删除
+	if err := s.UpdateRoutingRule(0, RoutingRule{"domain": []string{"full:example.com"}, "outboundTag": "blocked", "localPort": nil}, "admin"); err != nil {
+		t.Fatalf("update rule failed: %v", err)
+	}
+	rules, _ = s.GetRoutingRules()
+	got := rules[0]
+	if _, ok := got["port"]; ok || got["outboundTag"] != "blocked" || got["process"] == nil || got["localPort"] != nil {
+		t.Errorf("unexpected rule after update: %+v", got)
+	}
+
+	// 模板中面板不认识的顶层字段和入站字段同样保留
+	template, _ := s.GetXrayConfigTemplate()
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(template), &doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["custom"] = map[string]any{"enabled": true}
+	doc["inbounds"].([]any)[0].(map[string]any)["allocate"] = map[string]any{"strategy": "always"}
+	data, _ := json.Marshal(doc)
+	if err := s.SaveXraySetting(string(data), "admin", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelRoutingRule(0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	template, _ = s.GetXrayConfigTemplate()
+	if !strings.Contains(template, `"custom"`) || !strings.Contains(template, `"allocate"`) {
+		t.Errorf("unknown template keys dropped:\n%s", template)
+	}
+}
+
+func TestXraySettingService_Balancers(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	if err := s.SaveBalancer(&Balancer{Tag: "lb", Selector: []string{"dir"}, FallbackTag: "direct"}, "admin"); err != nil {
+		t.Fatalf("save balancer failed: %v", err)
+	}
+	if err := s.SaveBalancer(&Balancer{Tag: "bad", Selector: []string{"dir"}, FallbackTag: "missing"}, "admin"); err == nil {
+		t.Error("expected unknown fallback tag to be rejected")
+	}
+	if err := s.AddRoutingRule(RoutingRule{"network": "tcp,udp", "balancerTag": "lb"}, -1, "admin"); err != nil {
+		t.Fatalf("add balancer rule failed: %v", err)
+	}
+	if err := s.DelBalancer("lb", "admin"); err == nil || !strings.Contains(err.Error(), "used by routing rule") {
+		t.Errorf("expected referenced balancer deletion to fail, got %v", err)
+	}
+	if err := s.DelRoutingRule(0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DelBalancer("lb", "admin"); err != nil {
+		t.Errorf("delete balancer failed: %v", err)
+	}
+}
+
+func TestXraySettingService_DNS(t *testing.T) {
+	s := setupRoutingTemplate(t)
+
+	if err := s.AddDNSServer(&DNSServer{Address: "1.1.1.1"}, -1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddDNSServer(&DNSServer{Address: "8.8.8.8", Port: 53, Domains: []string{"domain:google.com"}}, -1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddDNSServer(&DNSServer{Address: "9.9.9.9", QueryStrategy: "Bad"}, -1, "admin"); err == nil {
+		t.Error("expected invalid query strategy to be rejected")
+	}
+
+	template, _ := s.GetXrayConfigTemplate()
+	if !strings.Contains(template, `"1.1.1.1"`) {
+		t.Error("plain dns server should be stored as string")
+	}
+	servers, err := s.GetDNSServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[1].Port != 53 {
+		t.Fatalf("unexpected servers %+v", servers)
+	}
+
+	if err := s.AddFakeDNSPool(&FakeDNSPool{IPPool: "198.18.0.0/15", PoolSize: 65535}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFakeDNSPool(&FakeDNSPool{IPPool: "198.18.1.0/24", PoolSize: 10}, "admin"); err == nil {
+		t.Error("expected overlapping pool to be rejected")
+	}
+	if err := s.AddFakeDNSPool(&FakeDNSPool{IPPool: "fc00::/126", PoolSize: 100}, "admin"); err == nil {
+		t.Error("expected oversized pool to be rejected")
+	}
+	pools, _ := s.GetFakeDNSPools()
+	if len(pools) != 1 {
+		t.Errorf("expected 1 fakedns pool, got %d", len(pools))
+	}
+}
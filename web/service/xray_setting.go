@@ -3,13 +3,32 @@ package service
 import (
 	_ "embed"
 	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
+	"x-ui/database"
+	"x-ui/database/model"
+	"x-ui/database/repository"
+	"x-ui/logger"
 	"x-ui/util/common"
+	"x-ui/util/json_util"
 	"x-ui/xray"
 )
 
+// maxXrayRevisions Xray 配置模板最多保留的历史版本数
+const maxXrayRevisions = 50
+
+// templateMu 串行化 Xray 配置模板的“读取→修改→保存”过程。面板保存、出站订阅刷新、
+// WARP 检查与轮换、反向代理和 geo 文件编辑都会改写同一份模板，
+// 并发执行时后保存的一方会覆盖先保存的修改，因此所有修改模板的入口都必须持有该锁
+var templateMu sync.Mutex
+
 type XraySettingService struct {
 	SettingService
+
+	revisionRepo    repository.XrayRevisionRepository
+	outboundSubRepo repository.OutboundSubscriptionRepository
 }
 
 // NewXraySettingService 创建 XraySettingService 实例
@@ -17,18 +36,121 @@ func NewXraySettingService(settingService *SettingService) *XraySettingService {
 	return &XraySettingService{SettingService: *settingService}
 }
 
-func (s *XraySettingService) SaveXraySetting(newXraySettings string) error {
+// getRevisionRepo 返回 XrayRevisionRepository，支持延迟初始化
+func (s *XraySettingService) getRevisionRepo() repository.XrayRevisionRepository {
+	if s.revisionRepo == nil {
+		s.revisionRepo = repository.NewXrayRevisionRepository(database.GetDB())
+	}
+	return s.revisionRepo
+}
+
+// SaveXraySetting 校验并保存 Xray 配置模板，同时记录一个历史版本
+func (s *XraySettingService) SaveXraySetting(newXraySettings string, author string, comment string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	return s.saveXraySetting(newXraySettings, author, comment)
+}
+
+// saveXraySetting 与 SaveXraySetting 相同，调用方需已持有 templateMu
+func (s *XraySettingService) saveXraySetting(newXraySettings string, author string, comment string) error {
 	if err := s.CheckXrayConfig(newXraySettings); err != nil {
 		return err
 	}
-	return s.saveSetting("xrayTemplateConfig", newXraySettings)
+	return s.saveTemplate(newXraySettings, author, comment, 0)
 }
 
+func (s *XraySettingService) saveTemplate(newXraySettings string, author string, comment string, revertOf int) error {
+	// 首次记录历史时先保存修改前的模板作为基线，保证第一次修改也可以撤销
+	if count, err := s.getRevisionRepo().Count(); err == nil && count == 0 {
+		if current, err := s.GetXrayConfigTemplate(); err == nil && current != newXraySettings {
+			s.addRevision(current, "system", "initial", 0)
+		}
+	}
+
+	if err := s.saveSetting("xrayTemplateConfig", newXraySettings); err != nil {
+		return err
+	}
+	s.addRevision(newXraySettings, author, comment, revertOf)
+	return nil
+}
+
+// addRevision 记录历史版本，失败时只记录日志，不影响模板保存
+func (s *XraySettingService) addRevision(config string, author string, comment string, revertOf int) {
+	revision := &model.XrayConfigRevision{
+		Config:    config,
+		Author:    author,
+		Comment:   comment,
+		RevertOf:  revertOf,
+		Size:      len(config),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err := s.getRevisionRepo().Create(revision); err != nil {
+		logger.Warning("Failed to save xray config revision:", err)
+		return
+	}
+	if err := s.getRevisionRepo().Prune(maxXrayRevisions); err != nil {
+		logger.Warning("Failed to prune xray config revisions:", err)
+	}
+}
+
+// GetRevisions 按时间倒序返回历史版本列表（不含配置内容）
+func (s *XraySettingService) GetRevisions() ([]*model.XrayConfigRevision, error) {
+	return s.getRevisionRepo().List(maxXrayRevisions)
+}
+
+// GetRevision 返回指定历史版本的完整内容
+func (s *XraySettingService) GetRevision(id int) (*model.XrayConfigRevision, error) {
+	return s.getRevisionRepo().FindByID(id)
+}
+
+// DiffRevisions 对两个历史版本做结构化 JSON 比较，toId 为 0 时与当前模板比较
+func (s *XraySettingService) DiffRevisions(fromId int, toId int) ([]json_util.DiffEntry, error) {
+	from, err := s.GetRevision(fromId)
+	if err != nil {
+		return nil, err
+	}
+	var to string
+	if toId == 0 {
+		to, err = s.GetXrayConfigTemplate()
+	} else {
+		var revision *model.XrayConfigRevision
+		revision, err = s.GetRevision(toId)
+		if revision != nil {
+			to = revision.Config
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json_util.DiffJSON([]byte(from.Config), []byte(to))
+}
+
+// RevertToRevision 将模板恢复为指定历史版本，恢复同样经过完整校验并记录为新的历史版本
+func (s *XraySettingService) RevertToRevision(id int, author string) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	revision, err := s.GetRevision(id)
+	if err != nil {
+		return err
+	}
+	if err := s.CheckXrayConfig(revision.Config); err != nil {
+		return err
+	}
+	return s.saveTemplate(revision.Config, author, fmt.Sprintf("revert to #%d", id), id)
+}
+
+// CheckXrayConfig 在保存前完整校验模板：先用 xray-core 构建器构建每个配置段，
+// 再使用 Xray 二进制的测试模式检查，任一失败都拒绝保存
 func (s *XraySettingService) CheckXrayConfig(XrayTemplateConfig string) error {
 	xrayConfig := &xray.Config{}
 	err := json.Unmarshal([]byte(XrayTemplateConfig), xrayConfig)
 	if err != nil {
 		return common.NewError("xray template config invalid:", err)
 	}
-	return nil
+	// 与生成运行配置时保持一致，先移除新版 xray-core 不再支持的字段
+	_ = xrayConfig.AdaptToXrayCoreV25()
+	if err := xray.ValidateConfig(xrayConfig); err != nil {
+		return err
+	}
+	return xray.TestConfig(xrayConfig)
 }
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+)
+
+const (
+	testTemplateA = `{"log":{"loglevel":"warning"},"inbounds":[{"tag":"api","listen":"127.0.0.1","port":62789,"protocol":"tunnel","settings":{"address":"127.0.0.1"}}],"outbounds":[{"protocol":"freedom","tag":"direct"}]}`
+	testTemplateB = `{"log":{"loglevel":"info"},"inbounds":[{"tag":"api","listen":"127.0.0.1","port":62789,"protocol":"tunnel","settings":{"address":"127.0.0.1"}}],"outbounds":[{"protocol":"freedom","tag":"direct"},{"protocol":"blackhole","tag":"blocked"}]}`
+)
+
+func TestXraySettingService_Revisions(t *testing.T) {
+	setupTestDB(t)
+	s := &XraySettingService{}
+
+	if err := s.SaveXraySetting(testTemplateA, "admin", "first"); err != nil {
+		t.Fatalf("save A failed: %v", err)
+	}
+	if err := s.SaveXraySetting(testTemplateB, "admin", "second"); err != nil {
+		t.Fatalf("save B failed: %v", err)
+	}
+	if err := s.SaveXraySetting(`{"inbounds":[{"protocol":"no-such-protocol"}]}`, "admin", "broken"); err == nil {
+		t.Fatal("expected invalid template to be rejected")
+	}
+
+	revisions, err := s.GetRevisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 基线 + A + B，无效模板不产生历史版本
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Comment != "second" || revisions[2].Comment != "initial" {
+		t.Errorf("unexpected revision order: %q, %q", revisions[0].Comment, revisions[2].Comment)
+	}
+	idA := revisions[1].Id
+
+	diff, err := s.DiffRevisions(idA, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 2 {
+		t.Errorf("expected 2 differences between A and current, got %+v", diff)
+	}
+
+	if err := s.RevertToRevision(idA, "admin"); err != nil {
+		t.Fatalf("revert failed: %v", err)
+	}
+	current, _ := s.GetXrayConfigTemplate()
+	if current != testTemplateA {
+		t.Errorf("template not reverted, got %s", current)
+	}
+	revisions, _ = s.GetRevisions()
+	if len(revisions) != 4 || revisions[0].RevertOf != idA {
+		t.Errorf("expected revert revision referencing #%d, got %+v", idA, revisions[0])
+	}
+}
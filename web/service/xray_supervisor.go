@@ -0,0 +1,229 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"x-ui/config"
+	"x-ui/logger"
+	"x-ui/singbox"
+	"x-ui/xray"
+)
+
+// Xray 进程监控状态
+const (
+	SupervisorRunning   = "running"
+	SupervisorStopped   = "stopped"
+	SupervisorBackoff   = "backoff"
+	SupervisorCrashLoop = "crashloop"
+)
+
+// SupervisorStatus Xray 进程监控状态及重启计数
+type SupervisorStatus struct {
+	State         string `json:"state"`
+	Restarts      int    `json:"restarts"`
+	Crashes       int    `json:"crashes"`
+	RecentCrashes int    `json:"recentCrashes"`
+	LastExitCode  int    `json:"lastExitCode"`
+	LastCrash     int64  `json:"lastCrash,omitempty"`
+	LastReport    string `json:"lastReport,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	NextRetry     int64  `json:"nextRetry,omitempty"`
+}
+
+// xraySupervisor 记录崩溃历史和退避状态
+type xraySupervisor struct {
+	mu         sync.Mutex
+	handled    *xray.Process    // 已记录过崩溃的进程，同一次退出只计数一次
+	handledSB  *singbox.Process // 已记录过崩溃的 sing-box 进程
+	downChecks int
+	restarting bool // 正在重启，此时不持有锁，其他检查跳过
+	failures   int
+	nextRetry  time.Time
+	crashTimes []time.Time
+	status     SupervisorStatus
+}
+
+// SuperviseXray 检查 Xray 以及与其同时运行的 sing-box 的进程状态，异常退出时记录崩溃报告并按指数退避自动重启；
+// 在 XrayCrashLoopWindow 内崩溃达到 XrayCrashLoopThreshold 次后进入崩溃循环状态，
+// 停止自动重启并发送通知，直到手动重启。
+// 重启在锁外进行，避免重启期间阻塞 GetSupervisorStatus 和 ResetSupervisor
+func (s *XrayService) SuperviseXray(now time.Time) {
+	if !s.checkSupervisor(now) {
+		return
+	}
+	err := s.RestartXray(false)
+
+	sv := &s.supervisor
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.restarting = false
+	if err != nil {
+		logger.Error("Restart xray failed:", err)
+		sv.status.LastError = err.Error()
+		sv.scheduleRetry(now)
+		return
+	}
+	sv.downChecks = 0
+	sv.status.State = SupervisorRunning
+}
+
+// checkSupervisor 在持有 supervisor 锁时检查进程状态并记录崩溃，返回是否需要重启
+func (s *XrayService) checkSupervisor(now time.Time) bool {
+	sv := &s.supervisor
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.restarting {
+		return false
+	}
+
+	if !s.DidXrayCrash() {
+		sv.downChecks = 0
+		if s.IsCoreRunning() {
+			sv.status.State = SupervisorRunning
+			// 稳定运行一段时间后重置退避
+			if sv.failures > 0 && time.Duration(s.GetProcessUptime())*time.Second >= config.XrayStablePeriod {
+				sv.failures = 0
+			}
+		} else {
+			sv.status.State = SupervisorStopped
+		}
+		return false
+	}
+	if sv.status.State == SupervisorCrashLoop {
+		return false
+	}
+	// 重启过程中进程会短暂处于未运行状态，连续两次检查都未运行才认为已崩溃
+	sv.downChecks++
+	if sv.downChecks < 2 {
+		return false
+	}
+
+	if s.process != nil && !s.IsCoreRunning() && sv.handled != s.process {
+		sv.handled = s.process
+		info := &xray.CrashInfo{
+			Core:     CoreBackendXray,
+			Time:     now,
+			ExitCode: s.process.ExitCode(),
+			Tail:     s.process.GetLogTail(),
+		}
+		if err := s.process.GetErr(); err != nil {
+			info.Reason = err.Error()
+		}
+		s.recordCrash(info)
+		if sv.status.State == SupervisorCrashLoop {
+			return false
+		}
+	}
+	if p := s.getSingBoxBackend().crashedProcess(); p != nil && sv.handledSB != p {
+		sv.handledSB = p
+		info := &xray.CrashInfo{
+			Core:     CoreBackendSingBox,
+			Time:     now,
+			ExitCode: p.ExitCode(),
+		}
+		if line := p.GetResult(); line != "" {
+			info.Tail = []string{line}
+		}
+		if err := p.GetErr(); err != nil {
+			info.Reason = err.Error()
+		}
+		s.recordCrash(info)
+		if sv.status.State == SupervisorCrashLoop {
+			return false
+		}
+	}
+
+	if now.Before(sv.nextRetry) {
+		sv.status.State = SupervisorBackoff
+		return false
+	}
+
+	sv.status.Restarts++
+	sv.restarting = true
+	return true
+}
+
+// recordCrash 写入崩溃报告并更新计数，Xray 和 sing-box 的崩溃共用崩溃循环计数，调用方需持有 supervisor 锁
+func (s *XrayService) recordCrash(info *xray.CrashInfo) {
+	sv := &s.supervisor
+	now := info.Time
+	name, err := xray.WriteCrashReport(info)
+	if err != nil {
+		logger.Error("Unable to write crash report:", err)
+	}
+
+	sv.status.Crashes++
+	sv.status.LastExitCode = info.ExitCode
+	sv.status.LastCrash = now.Unix()
+	if name != "" {
+		sv.status.LastReport = name
+	}
+	sv.status.LastError = info.Reason
+
+	sv.crashTimes = append(sv.crashTimes, now)
+	kept := sv.crashTimes[:0]
+	for _, t := range sv.crashTimes {
+		if now.Sub(t) < config.XrayCrashLoopWindow {
+			kept = append(kept, t)
+		}
+	}
+	sv.crashTimes = kept
+	sv.status.RecentCrashes = len(kept)
+	logger.Warningf("%s exited unexpectedly (exit code %d), crash %d in the last %s", info.Core, info.ExitCode, len(kept), config.XrayCrashLoopWindow)
+
+	if len(kept) >= config.XrayCrashLoopThreshold {
+		sv.status.State = SupervisorCrashLoop
+		sv.status.NextRetry = 0
+		msg := fmt.Sprintf("%s crashed %d times within %s and will not be restarted automatically. Last exit code: %d, report: %s",
+			info.Core, len(kept), config.XrayCrashLoopWindow, info.ExitCode, name)
+		logger.Error(msg)
+		s.result = msg
+		if s.tgService != nil && s.tgService.IsRunning() {
+			_ = s.tgService.SendMessage(msg)
+		}
+		return
+	}
+	sv.scheduleRetry(now)
+}
+
+// scheduleRetry 按连续失败次数计算下一次重启时间
+func (sv *xraySupervisor) scheduleRetry(now time.Time) {
+	sv.failures++
+	sv.nextRetry = now.Add(restartBackoff(sv.failures))
+	sv.status.State = SupervisorBackoff
+	sv.status.NextRetry = sv.nextRetry.Unix()
+}
+
+// restartBackoff 第 n 次连续失败后的等待时间：base * 2^(n-1)，不超过上限
+func restartBackoff(failures int) time.Duration {
+	backoff := config.XrayRestartBackoffBase
+	for i := 1; i < failures && backoff < config.XrayRestartBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, config.XrayRestartBackoffMax)
+}
+
+// ResetSupervisor 清除崩溃循环和退避状态，手动重启 Xray 时调用
+func (s *XrayService) ResetSupervisor() {
+	sv := &s.supervisor
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.downChecks = 0
+	sv.failures = 0
+	sv.nextRetry = time.Time{}
+	sv.crashTimes = nil
+	sv.status.RecentCrashes = 0
+	sv.status.NextRetry = 0
+	if sv.status.State == SupervisorCrashLoop || sv.status.State == SupervisorBackoff {
+		sv.status.State = SupervisorStopped
+	}
+}
+
+// GetSupervisorStatus 返回进程监控状态
+func (s *XrayService) GetSupervisorStatus() SupervisorStatus {
+	s.supervisor.mu.Lock()
+	defer s.supervisor.mu.Unlock()
+	return s.supervisor.status
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"x-ui/config"
+	"x-ui/singbox"
+	"x-ui/xray"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	if got := restartBackoff(1); got != config.XrayRestartBackoffBase {
+		t.Errorf("first backoff = %s", got)
+	}
+	if got := restartBackoff(3); got != 4*config.XrayRestartBackoffBase {
+		t.Errorf("third backoff = %s", got)
+	}
+	if got := restartBackoff(100); got != config.XrayRestartBackoffMax {
+		t.Errorf("backoff not capped: %s", got)
+	}
+}
+
+// startCrashingProcess 使用一个立即崩溃的脚本代替 Xray 二进制启动进程，并等待其退出
+func startCrashingProcess(t *testing.T) *xray.Process {
+	t.Helper()
+	p := xray.NewProcess(&xray.Config{})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !p.HasExited() || p.ExitCode() < 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("fake xray did not exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return p
+}
+
+func TestXrayService_SuperviseCrashLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake xray binary is a shell script")
+	}
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	t.Setenv("XUI_LOG_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+	script := "#!/bin/sh\n[ \"$1\" = \"-version\" ] && echo 'Xray 1.0.0' && exit 0\necho 'panic: boom'\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(dir, xray.GetBinaryName()), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未注入 InboundService 时自动重启会失败，便于观察退避与崩溃循环
+	s := &XrayService{}
+	now := time.Now()
+	for i := 1; i <= config.XrayCrashLoopThreshold; i++ {
+		s.process = startCrashingProcess(t)
+		s.SuperviseXray(now)
+		s.SuperviseXray(now)
+
+		status := s.GetSupervisorStatus()
+		if status.Crashes != i || status.LastExitCode != 3 {
+			t.Fatalf("crash %d: unexpected status %+v", i, status)
+		}
+		if i < config.XrayCrashLoopThreshold && status.State != SupervisorBackoff {
+			t.Fatalf("crash %d: expected backoff, got %s", i, status.State)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	status := s.GetSupervisorStatus()
+	if status.State != SupervisorCrashLoop {
+		t.Fatalf("expected crash loop, got %+v", status)
+	}
+	restarts := status.Restarts
+	s.SuperviseXray(now.Add(time.Hour))
+	if s.GetSupervisorStatus().Restarts != restarts {
+		t.Error("supervisor should not restart while in crash loop")
+	}
+
+	reports, err := xray.ListCrashReports()
+	if err != nil || len(reports) == 0 {
+		t.Fatalf("expected crash reports, got %v, %v", reports, err)
+	}
+	data, _ := xray.ReadCrashReport(status.LastReport)
+	if len(data) == 0 {
+		t.Error("last crash report is empty")
+	}
+
+	s.ResetSupervisor()
+	if state := s.GetSupervisorStatus().State; state == SupervisorCrashLoop {
+		t.Error("reset did not clear crash loop")
+	}
+
+	// 另一次检查正在锁外重启时跳过，不重复记录崩溃
+	s.supervisor.restarting = true
+	s.process = startCrashingProcess(t)
+	s.SuperviseXray(now.Add(2 * time.Hour))
+	s.SuperviseXray(now.Add(2 * time.Hour))
+	if crashes := s.GetSupervisorStatus().Crashes; crashes != status.Crashes {
+		t.Errorf("supervisor should skip checks while restarting, crashes %d -> %d", status.Crashes, crashes)
+	}
+}
+
+func TestXrayService_SuperviseSingBoxCrash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake sing-box binary is a shell script")
+	}
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	t.Setenv("XUI_LOG_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+	script := "#!/bin/sh\n[ \"$1\" = \"version\" ] && echo 'sing-box version 1.0.0' && exit 0\necho 'FATAL boom'\nexit 2\n"
+	if err := os.WriteFile(filepath.Join(dir, singbox.GetBinaryName()), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := singbox.NewProcess(&singbox.Config{}, 0)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("fake sing-box did not exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s := &XrayService{}
+	s.getSingBoxBackend().process = p
+	if !s.DidXrayCrash() {
+		t.Fatal("sing-box exit should be detected as a crash")
+	}
+	now := time.Now()
+	s.SuperviseXray(now)
+	s.SuperviseXray(now)
+
+	status := s.GetSupervisorStatus()
+	if status.Crashes != 1 || status.LastExitCode != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	data, _ := xray.ReadCrashReport(status.LastReport)
+	if !strings.Contains(string(data), "core: sing-box") {
+		t.Errorf("crash report should name sing-box: %s", data)
+	}
+
+	// 面板主动停止后不再视为崩溃
+	s.stopSingBox()
+	if s.getSingBoxBackend().crashedProcess() != nil {
+		t.Error("stopped sing-box should not be reported as crashed")
+	}
+}
@@ -51,17 +51,21 @@ type wrapAssetsFS struct {
 
 // Keep-Alive 监听器包装器：用于拦截新连接并设置 Keep-Alive 选项
 type keepAliveListener struct {
-	*net.TCPListener
+	net.Listener
 	KeepAlivePeriod time.Duration
 }
 
 // Accept 方法：拦截连接并设置 Keep-Alive
 func (l keepAliveListener) Accept() (net.Conn, error) {
-	// 1. 接受底层 TCP 连接
-	tc, err := l.AcceptTCP()
+	// 1. 接受底层连接
+	c, err := l.Listener.Accept()
 	if err != nil {
 		return nil, err
 	}
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return c, nil
+	}
 
 	// 2. 在 *net.TCPConn 上设置 Keep-Alive 属性 (这里的方法是正确的)
 	if err := tc.SetKeepAlive(true); err != nil {
@@ -111,10 +115,15 @@ type Server struct {
 	httpServer *http.Server
 	listener   net.Listener
 
+	// 独立的健康检查监听（可选）
+	healthServer   *http.Server
+	healthListener net.Listener
+
 	index  *controller.IndexController
 	server *controller.ServerController
 	panel  *controller.XUIController
 	api    *controller.APIController
+	health *controller.HealthController
 
 	xrayService     *service.XrayService
 	inboundService  *service.InboundService
@@ -124,6 +133,8 @@ type Server struct {
 	// 添加这个字段，用来“持有”从 main.go 传递过来的 serverService 实例。
 	serverService *service.ServerService
 	userService   *service.UserService
+	eventHub      *service.EventHub
+	healthService *service.HealthService
 
 	cron *cron.Cron
 
@@ -136,6 +147,16 @@ func (s *Server) SetTelegramService(tgService service.TelegramService) {
 	s.tgbotService = tgService
 }
 
+// SetEventHub 注入事件中心，用于实时推送
+func (s *Server) SetEventHub(eventHub *service.EventHub) {
+	s.eventHub = eventHub
+}
+
+// SetHealthService 注入健康检查服务
+func (s *Server) SetHealthService(healthService *service.HealthService) {
+	s.healthService = healthService
+}
+
 // NewServer 创建 Web 服务器实例，接收所有必要的服务依赖
 func NewServer(
 	serverService *service.ServerService,
@@ -304,13 +325,17 @@ func (s *Server) initRouter() (*gin.Engine, error) {
 	// 调用我们刚刚改造过的 NewServerController，并将 s.serverService 作为参数传进去。
 	s.server = controller.NewServerController(g, s.serverService)
 	s.panel = controller.NewXUIController(g, s.serverService)
-	s.api = controller.NewAPIController(g, s.serverService)
+	s.api = controller.NewAPIController(g, s.serverService, s.eventHub)
+	if s.healthService != nil {
+		s.health = controller.NewHealthController(g, s.healthService)
+	}
 
 	return engine, nil
 }
 
 func (s *Server) startTask() {
-	err := s.xrayService.RestartXray(true)
+	// 非强制重启：平滑重启面板时，只有生成的配置发生变化才会重启 Xray
+	err := s.xrayService.RestartXray(false)
 	if err != nil {
 		logger.Warning("start xray failed:", err)
 	}
@@ -398,29 +423,16 @@ func (s *Server) Start() (err error) {
 		listenAddr = net.JoinHostPort(listen, strconv.Itoa(port))
 	}
 
-	// 1. 使用 baseListener 临时变量接收 net.Listen 的结果，这是底层的 TCP 监听器
-	baseListener, err := net.Listen("tcp", listenAddr)
+	// 1. 从监听池获取底层 TCP 监听器，平滑重启时会直接接管上一个实例保留的套接字
+	baseListener, err := network.Listen("tcp", listenAddr)
 	if err != nil {
 		return err
 	}
 
-	// 声明最终要使用的 listener 变量
-	var listener net.Listener
-
-	// 2. 尝试将 net.Listener 断言为 *net.TCPListener，以便进行更底层的设置
-	tcpListener, ok := baseListener.(*net.TCPListener)
-	if !ok {
-		// 如果断言失败 (例如在某些特殊环境或测试中)，则直接使用原始的 listener，不设置 Keep-Alive
-		logger.Warning("监听器不是 TCPListener 类型, 无法设置 Keep-Alive。")
-		listener = baseListener
-	} else {
-		// 3. 【核心功能】: 使用自定义的包装器为每一个新的连接设置 Keep-Alive 属性
-		kaListener := &keepAliveListener{
-			TCPListener:     tcpListener,
-			KeepAlivePeriod: 5 * time.Second, // 将 Keep-Alive 探测周期设置为 5 秒
-		}
-		// 将包装后的监听器赋值给最终的 listener 变量，后续流程将使用这个新的 listener
-		listener = net.Listener(kaListener)
+	// 2. 【核心功能】: 使用自定义的包装器为每一个新的连接设置 Keep-Alive 属性
+	var listener net.Listener = &keepAliveListener{
+		Listener:        baseListener,
+		KeepAlivePeriod: 5 * time.Second, // 将 Keep-Alive 探测周期设置为 5 秒
 	}
 
 	// 再次检查证书，配置 TLS Listener
@@ -471,6 +483,10 @@ func (s *Server) Start() (err error) {
 		_ = s.httpServer.Serve(listener)
 	}()
 
+	if err = s.startHealthServer(); err != nil {
+		return err
+	}
+
 	s.startTask()
 
 	// 启动 TG Bot
@@ -488,9 +504,56 @@ func (s *Server) Start() (err error) {
 	return nil
 }
 
+// startHealthServer 在配置的独立地址上提供 /healthz 和 /readyz，便于负载均衡器和容器探针访问
+func (s *Server) startHealthServer() error {
+	listen := config.GetHealthListen()
+	if listen == "" || s.healthService == nil {
+		return nil
+	}
+
+	secret, err := s.settingService.GetSecret()
+	if err != nil {
+		return err
+	}
+
+	engine := gin.New()
+	engine.Use(middleware.RecoveryMiddleware())
+	// 与面板共用会话密钥，已登录的调用方可以获得详细检查结果
+	engine.Use(sessions.Sessions("3x-ui", cookie.NewStore(secret)))
+	controller.NewHealthController(&engine.RouterGroup, s.healthService)
+
+	listener, err := network.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	s.healthListener = listener
+	s.healthServer = &http.Server{
+		Handler:           engine,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		_ = s.healthServer.Serve(listener)
+	}()
+	logger.Info("Health server running on", listener.Addr())
+	return nil
+}
+
+// Stop 停止 Web 服务器及 Xray 进程
 func (s *Server) Stop() error {
+	return s.shutdown(true)
+}
+
+// StopForRestart 平滑停止：排空在途请求，保留 Xray 进程和监听套接字供新实例接管
+func (s *Server) StopForRestart() error {
+	return s.shutdown(false)
+}
+
+func (s *Server) shutdown(stopXray bool) error {
 	s.cancel()
-	_ = s.xrayService.StopXray()
+	if stopXray {
+		_ = s.xrayService.StopXray()
+	}
 	if s.cron != nil {
 		s.cron.Stop()
 	}
@@ -502,13 +565,17 @@ func (s *Server) Stop() error {
 	}
 	var err1 error
 	var err2 error
+	var err3 error
 	if s.httpServer != nil {
-		err1 = s.httpServer.Shutdown(s.ctx)
+		err1 = network.DrainServer(s.httpServer, config.PanelDrainTimeout)
 	}
 	if s.listener != nil {
 		err2 = s.listener.Close()
 	}
-	return common.Combine(err1, err2)
+	if s.healthServer != nil {
+		err3 = network.DrainServer(s.healthServer, config.PanelDrainTimeout)
+	}
+	return common.Combine(err1, err2, err3)
 }
 
 func (s *Server) GetCtx() context.Context {
@@ -11,7 +11,9 @@ import (
 	"x-ui/logger"
 	"x-ui/util/common"
 
+	observatoryService "github.com/xtls/xray-core/app/observatory/command"
 	"github.com/xtls/xray-core/app/proxyman/command"
+	routerService "github.com/xtls/xray-core/app/router/command"
 	statsService "github.com/xtls/xray-core/app/stats/command"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
@@ -28,11 +30,17 @@ import (
 type XrayAPI struct {
 	HandlerServiceClient *command.HandlerServiceClient
 	StatsServiceClient   *statsService.StatsServiceClient
+	ObservatoryClient    *observatoryService.ObservatoryServiceClient
+	RoutingServiceClient *routerService.RoutingServiceClient
 	grpcClient           *grpc.ClientConn
 	isConnected          bool
 }
 
 func (x *XrayAPI) Init(apiPort int) error {
+	// 内嵌模式下直接访问进程内的 xray-core，不经过 gRPC 端口
+	if instance := getEmbeddedInstance(); instance != nil {
+		return x.initEmbedded(instance)
+	}
 	if apiPort <= 0 || apiPort > math.MaxUint16 {
 		return fmt.Errorf("invalid Xray API port: %d", apiPort)
 	}
@@ -48,9 +56,13 @@ func (x *XrayAPI) Init(apiPort int) error {
 
 	hsClient := command.NewHandlerServiceClient(conn)
 	ssClient := statsService.NewStatsServiceClient(conn)
+	osClient := observatoryService.NewObservatoryServiceClient(conn)
+	rsClient := routerService.NewRoutingServiceClient(conn)
 
 	x.HandlerServiceClient = &hsClient
 	x.StatsServiceClient = &ssClient
+	x.ObservatoryClient = &osClient
+	x.RoutingServiceClient = &rsClient
 
 	return nil
 }
@@ -60,7 +72,10 @@ func (x *XrayAPI) Close() {
 		_ = x.grpcClient.Close()
 	}
 	x.HandlerServiceClient = nil
+	x.grpcClient = nil
 	x.StatsServiceClient = nil
+	x.ObservatoryClient = nil
+	x.RoutingServiceClient = nil
 	x.isConnected = false
 }
 
@@ -130,11 +145,15 @@ func (x *XrayAPI) AddUser(Protocol string, inboundTag string, user map[string]an
 				CipherType: ssCipherType,
 			})
 		} else {
-			account = serial.ToTypedMessage(&shadowsocks_2022.ServerConfig{
-				Key:   user["password"].(string),
-				Email: user["email"].(string),
+			// SS2022 多用户入站的用户只携带自己的密钥，email 由 User 设置
+			account = serial.ToTypedMessage(&shadowsocks_2022.Account{
+				Key: user["password"].(string),
 			})
 		}
+	case "wireguard", "socks", "http":
+		// WireGuard peer 和 SOCKS/HTTP 账户不是 Xray 用户管理器中的用户，只能通过替换整个入站生效。
+		// 返回错误使调用方标记需要重新应用配置，由热更新替换该入站
+		return fmt.Errorf("adding users to %s inbound '%s' is not supported by the api", Protocol, inboundTag)
 	default:
 		return nil
 	}
@@ -153,6 +172,7 @@ func (x *XrayAPI) AddUser(Protocol string, inboundTag string, user map[string]an
 		Operation: serial.ToTypedMessage(&command.AddUserOperation{
 			User: &protocol.User{
 				Email:   user["email"].(string),
+				Level:   userLevel(user),
 				Account: account,
 			},
 		}),
@@ -166,6 +186,14 @@ func (x *XrayAPI) AddUser(Protocol string, inboundTag string, user map[string]an
 	return nil
 }
 
+// userLevel 读取可选的 level 字段，对应按速率生成的 policy level
+func userLevel(user map[string]any) uint32 {
+	if level, ok := user["level"].(int); ok && level > 0 {
+		return uint32(level)
+	}
+	return 0
+}
+
 func (x *XrayAPI) RemoveUser(inboundTag, email string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -184,14 +212,20 @@ func (x *XrayAPI) RemoveUser(inboundTag, email string) error {
 	return nil
 }
 
+// Ping 调用 StatsService.GetSysStats 检查 gRPC API 是否可达
+func (x *XrayAPI) Ping(ctx context.Context) error {
+	if !x.isConnected || x.StatsServiceClient == nil {
+		return common.NewError("xray api is not initialized")
+	}
+	_, err := (*x.StatsServiceClient).GetSysStats(ctx, &statsService.SysStatsRequest{})
+	return err
+}
+
 func (x *XrayAPI) GetTraffic(reset bool) ([]*Traffic, []*ClientTraffic, error) {
-	if x.grpcClient == nil {
+	if !x.isConnected {
 		return nil, nil, common.NewError("xray api is not initialized")
 	}
 
-	trafficRegex := regexp.MustCompile(`(inbound|outbound)>>>([^>]+)>>>traffic>>>(downlink|uplink)`)
-	clientTrafficRegex := regexp.MustCompile(`user>>>([^>]+)>>>traffic>>>(downlink|uplink)`)
-
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
@@ -205,17 +239,29 @@ func (x *XrayAPI) GetTraffic(reset bool) ([]*Traffic, []*ClientTraffic, error) {
 		return nil, nil, err
 	}
 
+	traffic, clientTraffic := ParseTrafficStats(resp.GetStat())
+	return traffic, clientTraffic, nil
+}
+
+var (
+	trafficRegex       = regexp.MustCompile(`(inbound|outbound)>>>([^>]+)>>>traffic>>>(downlink|uplink)`)
+	clientTrafficRegex = regexp.MustCompile(`user>>>([^>]+)>>>traffic>>>(downlink|uplink)`)
+)
+
+// ParseTrafficStats 将 StatsService 的计数器按入站/出站标签和客户端 email 汇总为流量，
+// sing-box 的 V2Ray API 使用相同的计数器命名，也可复用
+func ParseTrafficStats(stats []*statsService.Stat) ([]*Traffic, []*ClientTraffic) {
 	tagTrafficMap := make(map[string]*Traffic)
 	emailTrafficMap := make(map[string]*ClientTraffic)
 
-	for _, stat := range resp.GetStat() {
+	for _, stat := range stats {
 		if matches := trafficRegex.FindStringSubmatch(stat.Name); len(matches) == 4 {
 			processTraffic(matches, stat.Value, tagTrafficMap)
 		} else if matches := clientTrafficRegex.FindStringSubmatch(stat.Name); len(matches) == 3 {
 			processClientTraffic(matches, stat.Value, emailTrafficMap)
 		}
 	}
-	return mapToSlice(tagTrafficMap), mapToSlice(emailTrafficMap), nil
+	return mapToSlice(tagTrafficMap), mapToSlice(emailTrafficMap)
 }
 
 func processTraffic(matches []string, value int64, trafficMap map[string]*Traffic) {
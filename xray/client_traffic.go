@@ -12,4 +12,5 @@ type ClientTraffic struct {
 	Total      int64  `json:"total" form:"total"`
 	Reset      int    `json:"reset" form:"reset" gorm:"default:0"`
 	LastOnline int64  `json:"lastOnline" form:"lastOnline" gorm:"default:0"`
+	LastReset  int64  `json:"lastReset" form:"lastReset" gorm:"default:0"` // 按周期重置配额时上次重置的毫秒时间戳
 }
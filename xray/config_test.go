@@ -1,6 +1,8 @@
 package xray
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"x-ui/util/json_util"
@@ -94,6 +96,36 @@ func TestInboundConfig_Equals(t *testing.T) {
 	}
 }
 
+func TestInboundConfig_PortRangeJSON(t *testing.T) {
+	ranged := InboundConfig{Port: 10000, PortRange: "10000-10010", Protocol: "tunnel", Tag: "forward-10000"}
+	data, err := json.Marshal(ranged)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"port":"10000-10010"`) {
+		t.Errorf("port range not written: %s", data)
+	}
+	var decoded InboundConfig
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Port != 10000 || decoded.PortRange != "10000-10010" || decoded.Tag != ranged.Tag {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+
+	var single InboundConfig
+	if err := json.Unmarshal([]byte(`{"port":"8080","protocol":"vless"}`), &single); err != nil {
+		t.Fatalf("Unmarshal string port failed: %v", err)
+	}
+	if single.Port != 8080 || single.PortRange != "" {
+		t.Errorf("string port parsed wrongly: %+v", single)
+	}
+	data, _ = json.Marshal(single)
+	if !strings.Contains(string(data), `"port":8080`) {
+		t.Errorf("single port should stay numeric: %s", data)
+	}
+}
+
 func TestConfig_Equals(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,120 @@
+package xray
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"x-ui/config"
+	"x-ui/util/common"
+)
+
+const crashReportPrefix = "core_crash_"
+
+var crashReportName = regexp.MustCompile(`^core_crash_\d{8}_\d{6}(_\d+)?\.log$`)
+
+// CrashReport 崩溃报告文件信息
+type CrashReport struct {
+	Name string `json:"name"`
+	Time int64  `json:"time"`
+	Size int64  `json:"size"`
+}
+
+// CrashInfo 一次 Xray 异常退出的信息
+type CrashInfo struct {
+	Core     string
+	Time     time.Time
+	ExitCode int
+	Reason   string
+	Tail     []string
+}
+
+// WriteCrashReport 将崩溃信息写入 bin 目录下的 core_crash_*.log，并按保留数量清理旧报告
+func WriteCrashReport(info *CrashInfo) (string, error) {
+	var b strings.Builder
+	if info.Core != "" {
+		fmt.Fprintf(&b, "core: %s\n", info.Core)
+	}
+	fmt.Fprintf(&b, "time: %s\n", info.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "exit code: %d\n", info.ExitCode)
+	if info.Reason != "" {
+		fmt.Fprintf(&b, "reason: %s\n", info.Reason)
+	}
+	b.WriteString("\n--- last output ---\n")
+	for _, line := range info.Tail {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	dir := config.GetBinFolderPath()
+	base := crashReportPrefix + info.Time.Format("20060102_150405")
+	name := base + ".log"
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s_%d.log", base, i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+	if err := PruneCrashReports(config.XrayCrashReportRetention); err != nil {
+		return name, err
+	}
+	return name, nil
+}
+
+// ListCrashReports 按时间倒序列出崩溃报告
+func ListCrashReports() ([]*CrashReport, error) {
+	entries, err := os.ReadDir(config.GetBinFolderPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	reports := make([]*CrashReport, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !crashReportName.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		reports = append(reports, &CrashReport{
+			Name: entry.Name(),
+			Time: info.ModTime().Unix(),
+			Size: info.Size(),
+		})
+	}
+	// 文件名中包含崩溃时间，按文件名倒序即为按时间倒序
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name > reports[j].Name })
+	return reports, nil
+}
+
+// ReadCrashReport 读取指定崩溃报告的内容，只允许读取 core_crash_*.log 文件
+func ReadCrashReport(name string) ([]byte, error) {
+	if !crashReportName.MatchString(name) {
+		return nil, common.NewErrorf("invalid crash report name: %s", name)
+	}
+	return os.ReadFile(filepath.Join(config.GetBinFolderPath(), name))
+}
+
+// PruneCrashReports 仅保留最新的 keep 个崩溃报告
+func PruneCrashReports(keep int) error {
+	reports, err := ListCrashReports()
+	if err != nil || len(reports) <= keep {
+		return err
+	}
+	for _, report := range reports[keep:] {
+		if err := os.Remove(filepath.Join(config.GetBinFolderPath(), report.Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
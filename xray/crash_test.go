@@ -0,0 +1,57 @@
+package xray
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"x-ui/config"
+)
+
+func TestCrashReports(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	info := &CrashInfo{Time: base, ExitCode: 2, Reason: "exit status 2", Tail: []string{"panic: boom", "goroutine 1 [running]:"}}
+	name, err := WriteCrashReport(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "core_crash_20260102_030405.log" {
+		t.Errorf("unexpected report name %s", name)
+	}
+	// 同一秒内的多次崩溃不会相互覆盖
+	second, err := WriteCrashReport(info)
+	if err != nil || second == name {
+		t.Fatalf("expected a distinct report name, got %s, %v", second, err)
+	}
+
+	data, err := ReadCrashReport(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "exit code: 2") || !strings.Contains(string(data), "panic: boom") {
+		t.Errorf("unexpected report content:\n%s", data)
+	}
+	for _, bad := range []string{"../config.json", "config.json", "core_crash_x.log"} {
+		if _, err := ReadCrashReport(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	for i := 1; i <= config.XrayCrashReportRetention+3; i++ {
+		if _, err := WriteCrashReport(&CrashInfo{Time: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports, err := ListCrashReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != config.XrayCrashReportRetention {
+		t.Errorf("expected %d reports after pruning, got %d", config.XrayCrashReportRetention, len(reports))
+	}
+}
@@ -0,0 +1,291 @@
+package xray
+
+import (
+	"context"
+	"sync"
+
+	"x-ui/util/common"
+
+	"github.com/xtls/xray-core/app/observatory"
+	observatoryService "github.com/xtls/xray-core/app/observatory/command"
+	"github.com/xtls/xray-core/app/proxyman/command"
+	routerService "github.com/xtls/xray-core/app/router/command"
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	// 内嵌模式需要注册 xray-core 的全部功能，与官方发行版 main/distro/all 保持一致（不含命令行和配置加载器）
+	_ "github.com/xtls/xray-core/app/commander"
+	_ "github.com/xtls/xray-core/app/dispatcher"
+	_ "github.com/xtls/xray-core/app/dns"
+	_ "github.com/xtls/xray-core/app/dns/fakedns"
+	_ "github.com/xtls/xray-core/app/log"
+	_ "github.com/xtls/xray-core/app/log/command"
+	_ "github.com/xtls/xray-core/app/metrics"
+	_ "github.com/xtls/xray-core/app/observatory"
+	_ "github.com/xtls/xray-core/app/policy"
+	_ "github.com/xtls/xray-core/app/proxyman/inbound"
+	_ "github.com/xtls/xray-core/app/proxyman/outbound"
+	_ "github.com/xtls/xray-core/app/reverse"
+	_ "github.com/xtls/xray-core/app/router"
+	_ "github.com/xtls/xray-core/app/stats"
+	_ "github.com/xtls/xray-core/proxy/blackhole"
+	_ "github.com/xtls/xray-core/proxy/dns"
+	_ "github.com/xtls/xray-core/proxy/dokodemo"
+	_ "github.com/xtls/xray-core/proxy/freedom"
+	_ "github.com/xtls/xray-core/proxy/http"
+	_ "github.com/xtls/xray-core/proxy/loopback"
+	_ "github.com/xtls/xray-core/proxy/shadowsocks"
+	_ "github.com/xtls/xray-core/proxy/socks"
+	_ "github.com/xtls/xray-core/proxy/trojan"
+	_ "github.com/xtls/xray-core/proxy/vless/inbound"
+	_ "github.com/xtls/xray-core/proxy/vless/outbound"
+	_ "github.com/xtls/xray-core/proxy/vmess/inbound"
+	_ "github.com/xtls/xray-core/proxy/vmess/outbound"
+	_ "github.com/xtls/xray-core/proxy/wireguard"
+	_ "github.com/xtls/xray-core/transport/internet/grpc"
+	_ "github.com/xtls/xray-core/transport/internet/headers/http"
+	_ "github.com/xtls/xray-core/transport/internet/headers/noop"
+	_ "github.com/xtls/xray-core/transport/internet/httpupgrade"
+	_ "github.com/xtls/xray-core/transport/internet/kcp"
+	_ "github.com/xtls/xray-core/transport/internet/reality"
+	_ "github.com/xtls/xray-core/transport/internet/splithttp"
+	_ "github.com/xtls/xray-core/transport/internet/tagged/taggedimpl"
+	_ "github.com/xtls/xray-core/transport/internet/tcp"
+	_ "github.com/xtls/xray-core/transport/internet/tls"
+	_ "github.com/xtls/xray-core/transport/internet/udp"
+	_ "github.com/xtls/xray-core/transport/internet/websocket"
+)
+
+// Xray 核心运行模式
+const (
+	// CoreModeExternal 启动独立的 Xray 二进制进程，通过本地 gRPC 端口管理
+	CoreModeExternal = "external"
+	// CoreModeEmbedded 在面板进程内以库的方式运行 xray-core，API 调用直接访问内部功能
+	CoreModeEmbedded = "embedded"
+)
+
+var (
+	embeddedMu       sync.RWMutex
+	embeddedInstance *core.Instance
+)
+
+func setEmbeddedInstance(instance *core.Instance) {
+	embeddedMu.Lock()
+	embeddedInstance = instance
+	embeddedMu.Unlock()
+}
+
+// clearEmbeddedInstance 仅在当前实例仍是 instance 时清除，避免新实例被旧实例的停止操作覆盖
+func clearEmbeddedInstance(instance *core.Instance) {
+	embeddedMu.Lock()
+	if embeddedInstance == instance {
+		embeddedInstance = nil
+	}
+	embeddedMu.Unlock()
+}
+
+func getEmbeddedInstance() *core.Instance {
+	embeddedMu.RLock()
+	defer embeddedMu.RUnlock()
+	return embeddedInstance
+}
+
+// startEmbeddedCore 构建配置并在当前进程内启动 xray-core
+func startEmbeddedCore(c *Config) (*core.Instance, error) {
+	coreConfig, err := buildCoreConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := core.New(coreConfig)
+	if err != nil {
+		return nil, common.NewErrorf("failed to create embedded xray core: %v", err)
+	}
+	if err := instance.Start(); err != nil {
+		_ = instance.Close()
+		return nil, common.NewErrorf("failed to start embedded xray core: %v", err)
+	}
+	return instance, nil
+}
+
+// initEmbedded 使用进程内实现代替 gRPC 客户端，XrayAPI 的其余方法无需区分运行模式
+func (x *XrayAPI) initEmbedded(instance *core.Instance) error {
+	im, ok := instance.GetFeature(inbound.ManagerType()).(inbound.Manager)
+	if !ok {
+		return common.NewError("embedded xray core has no inbound manager")
+	}
+	var hsClient command.HandlerServiceClient = &embeddedHandlerClient{instance: instance, inbounds: im}
+	x.HandlerServiceClient = &hsClient
+
+	if sm, ok := instance.GetFeature(stats.ManagerType()).(stats.Manager); ok {
+		var ssClient statsService.StatsServiceClient = &embeddedStatsClient{server: statsService.NewStatsServer(sm)}
+		x.StatsServiceClient = &ssClient
+	}
+	if obs, ok := instance.GetFeature(extension.ObservatoryType()).(extension.Observatory); ok {
+		var osClient observatoryService.ObservatoryServiceClient = &embeddedObservatoryClient{observatory: obs}
+		x.ObservatoryClient = &osClient
+	}
+	if router, ok := instance.GetFeature(routing.RouterType()).(routing.Router); ok {
+		var rsClient routerService.RoutingServiceClient = &embeddedRoutingClient{server: routerService.NewRoutingServer(router, nil)}
+		x.RoutingServiceClient = &rsClient
+	}
+	x.isConnected = true
+	return nil
+}
+
+func unimplemented(method string) error {
+	return status.Error(codes.Unimplemented, method+" is not available in embedded mode")
+}
+
+// embeddedHandlerClient 进程内的 HandlerService 实现，逻辑与 xray-core 的 gRPC 服务端一致
+type embeddedHandlerClient struct {
+	instance *core.Instance
+	inbounds inbound.Manager
+}
+
+func (c *embeddedHandlerClient) AddInbound(ctx context.Context, in *command.AddInboundRequest, _ ...grpc.CallOption) (*command.AddInboundResponse, error) {
+	if err := core.AddInboundHandler(c.instance, in.Inbound); err != nil {
+		return nil, err
+	}
+	return &command.AddInboundResponse{}, nil
+}
+
+func (c *embeddedHandlerClient) RemoveInbound(ctx context.Context, in *command.RemoveInboundRequest, _ ...grpc.CallOption) (*command.RemoveInboundResponse, error) {
+	return &command.RemoveInboundResponse{}, c.inbounds.RemoveHandler(ctx, in.Tag)
+}
+
+func (c *embeddedHandlerClient) AlterInbound(ctx context.Context, in *command.AlterInboundRequest, _ ...grpc.CallOption) (*command.AlterInboundResponse, error) {
+	rawOperation, err := in.Operation.GetInstance()
+	if err != nil {
+		return nil, common.NewError("unknown operation:", err)
+	}
+	operation, ok := rawOperation.(command.InboundOperation)
+	if !ok {
+		return nil, common.NewError("not an inbound operation")
+	}
+	handler, err := c.inbounds.GetHandler(ctx, in.Tag)
+	if err != nil {
+		return nil, common.NewError("failed to get handler:", in.Tag, err)
+	}
+	return &command.AlterInboundResponse{}, operation.ApplyInbound(ctx, handler)
+}
+
+func (c *embeddedHandlerClient) ListInbounds(ctx context.Context, in *command.ListInboundsRequest, _ ...grpc.CallOption) (*command.ListInboundsResponse, error) {
+	response := &command.ListInboundsResponse{}
+	for _, handler := range c.inbounds.ListHandlers(ctx) {
+		response.Inbounds = append(response.Inbounds, &core.InboundHandlerConfig{Tag: handler.Tag()})
+	}
+	return response, nil
+}
+
+func (c *embeddedHandlerClient) GetInboundUsers(context.Context, *command.GetInboundUserRequest, ...grpc.CallOption) (*command.GetInboundUserResponse, error) {
+	return nil, unimplemented("GetInboundUsers")
+}
+
+func (c *embeddedHandlerClient) GetInboundUsersCount(context.Context, *command.GetInboundUserRequest, ...grpc.CallOption) (*command.GetInboundUsersCountResponse, error) {
+	return nil, unimplemented("GetInboundUsersCount")
+}
+
+func (c *embeddedHandlerClient) AddOutbound(ctx context.Context, in *command.AddOutboundRequest, _ ...grpc.CallOption) (*command.AddOutboundResponse, error) {
+	if err := core.AddOutboundHandler(c.instance, in.Outbound); err != nil {
+		return nil, err
+	}
+	return &command.AddOutboundResponse{}, nil
+}
+
+func (c *embeddedHandlerClient) RemoveOutbound(context.Context, *command.RemoveOutboundRequest, ...grpc.CallOption) (*command.RemoveOutboundResponse, error) {
+	return nil, unimplemented("RemoveOutbound")
+}
+
+func (c *embeddedHandlerClient) AlterOutbound(context.Context, *command.AlterOutboundRequest, ...grpc.CallOption) (*command.AlterOutboundResponse, error) {
+	return nil, unimplemented("AlterOutbound")
+}
+
+func (c *embeddedHandlerClient) ListOutbounds(context.Context, *command.ListOutboundsRequest, ...grpc.CallOption) (*command.ListOutboundsResponse, error) {
+	return nil, unimplemented("ListOutbounds")
+}
+
+// embeddedStatsClient 将 xray-core 的 StatsService 服务端直接作为客户端使用
+type embeddedStatsClient struct {
+	server statsService.StatsServiceServer
+}
+
+func (c *embeddedStatsClient) GetStats(ctx context.Context, in *statsService.GetStatsRequest, _ ...grpc.CallOption) (*statsService.GetStatsResponse, error) {
+	return c.server.GetStats(ctx, in)
+}
+
+func (c *embeddedStatsClient) GetStatsOnline(ctx context.Context, in *statsService.GetStatsRequest, _ ...grpc.CallOption) (*statsService.GetStatsResponse, error) {
+	return c.server.GetStatsOnline(ctx, in)
+}
+
+func (c *embeddedStatsClient) QueryStats(ctx context.Context, in *statsService.QueryStatsRequest, _ ...grpc.CallOption) (*statsService.QueryStatsResponse, error) {
+	return c.server.QueryStats(ctx, in)
+}
+
+func (c *embeddedStatsClient) GetSysStats(ctx context.Context, in *statsService.SysStatsRequest, _ ...grpc.CallOption) (*statsService.SysStatsResponse, error) {
+	return c.server.GetSysStats(ctx, in)
+}
+
+func (c *embeddedStatsClient) GetStatsOnlineIpList(ctx context.Context, in *statsService.GetStatsRequest, _ ...grpc.CallOption) (*statsService.GetStatsOnlineIpListResponse, error) {
+	return c.server.GetStatsOnlineIpList(ctx, in)
+}
+
+func (c *embeddedStatsClient) GetAllOnlineUsers(ctx context.Context, in *statsService.GetAllOnlineUsersRequest, _ ...grpc.CallOption) (*statsService.GetAllOnlineUsersResponse, error) {
+	return c.server.GetAllOnlineUsers(ctx, in)
+}
+
+// embeddedObservatoryClient 直接读取 observatory 功能的观测结果
+type embeddedObservatoryClient struct {
+	observatory extension.Observatory
+}
+
+func (c *embeddedObservatoryClient) GetOutboundStatus(ctx context.Context, _ *observatoryService.GetOutboundStatusRequest, _ ...grpc.CallOption) (*observatoryService.GetOutboundStatusResponse, error) {
+	result, err := c.observatory.GetObservation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	observation, ok := result.(*observatory.ObservationResult)
+	if !ok {
+		return nil, common.NewError("unexpected observation result")
+	}
+	return &observatoryService.GetOutboundStatusResponse{Status: observation}, nil
+}
+
+// embeddedRoutingClient 将 xray-core 的 RoutingService 服务端直接作为客户端使用，不支持流式订阅
+type embeddedRoutingClient struct {
+	server routerService.RoutingServiceServer
+}
+
+func (c *embeddedRoutingClient) SubscribeRoutingStats(context.Context, *routerService.SubscribeRoutingStatsRequest, ...grpc.CallOption) (grpc.ServerStreamingClient[routerService.RoutingContext], error) {
+	return nil, unimplemented("SubscribeRoutingStats")
+}
+
+func (c *embeddedRoutingClient) TestRoute(ctx context.Context, in *routerService.TestRouteRequest, _ ...grpc.CallOption) (*routerService.RoutingContext, error) {
+	return c.server.TestRoute(ctx, in)
+}
+
+func (c *embeddedRoutingClient) GetBalancerInfo(ctx context.Context, in *routerService.GetBalancerInfoRequest, _ ...grpc.CallOption) (*routerService.GetBalancerInfoResponse, error) {
+	return c.server.GetBalancerInfo(ctx, in)
+}
+
+func (c *embeddedRoutingClient) OverrideBalancerTarget(ctx context.Context, in *routerService.OverrideBalancerTargetRequest, _ ...grpc.CallOption) (*routerService.OverrideBalancerTargetResponse, error) {
+	return c.server.OverrideBalancerTarget(ctx, in)
+}
+
+func (c *embeddedRoutingClient) AddRule(ctx context.Context, in *routerService.AddRuleRequest, _ ...grpc.CallOption) (*routerService.AddRuleResponse, error) {
+	return c.server.AddRule(ctx, in)
+}
+
+func (c *embeddedRoutingClient) RemoveRule(ctx context.Context, in *routerService.RemoveRuleRequest, _ ...grpc.CallOption) (*routerService.RemoveRuleResponse, error) {
+	return c.server.RemoveRule(ctx, in)
+}
+
+func (c *embeddedRoutingClient) ListRule(ctx context.Context, in *routerService.ListRuleRequest, _ ...grpc.CallOption) (*routerService.ListRuleResponse, error) {
+	return c.server.ListRule(ctx, in)
+}
@@ -0,0 +1,105 @@
+package xray
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"x-ui/config"
+	"x-ui/util/json_util"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestEmbeddedProcess(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	t.Setenv("XUI_LOG_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+
+	api := InboundConfig{Port: freePort(t), Protocol: "tunnel", Tag: "api", Listen: json_util.RawMessage(`"127.0.0.1"`), Settings: json_util.RawMessage(`{"address":"127.0.0.1"}`)}
+	in := vlessInbound("in-1", freePort(t), `{"decryption":"none","clients":[{"email":"a@x","id":"b831381d-6324-4d53-ad4f-8cda48b30811"}]}`)
+	in.Listen = json_util.RawMessage(`"127.0.0.1"`)
+	c := &Config{
+		LogConfig:       json_util.RawMessage(`{"loglevel":"none"}`),
+		API:             json_util.RawMessage(`{"tag":"api","services":["HandlerService","StatsService"]}`),
+		Stats:           json_util.RawMessage(`{}`),
+		Policy:          json_util.RawMessage(`{"system":{"statsInboundUplink":true,"statsInboundDownlink":true}}`),
+		OutboundConfigs: json_util.RawMessage(`[{"protocol":"freedom","tag":"direct"}]`),
+		RouterConfig:    json_util.RawMessage(`{"rules":[{"type":"field","inboundTag":["api"],"outboundTag":"api"}]}`),
+		InboundConfigs:  []InboundConfig{api, in},
+	}
+
+	p := NewEmbeddedProcess(c)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Stop() }()
+	if !p.IsRunning() || !p.IsEmbedded() || p.GetAPIPort() != api.Port {
+		t.Fatalf("unexpected process state running=%v port=%d", p.IsRunning(), p.GetAPIPort())
+	}
+
+	x := &XrayAPI{}
+	if err := x.Init(p.GetAPIPort()); err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	if x.grpcClient != nil {
+		t.Error("embedded mode should not dial the gRPC port")
+	}
+
+	if err := x.AddUser("vless", "in-1", map[string]any{"email": "b@x", "id": "3c9d4f3e-9b5a-4c35-9a0a-1d5e2f7b8c91", "flow": ""}); err != nil {
+		t.Errorf("add user failed: %v", err)
+	}
+	if err := x.RemoveUser("in-1", "a@x"); err != nil {
+		t.Errorf("remove user failed: %v", err)
+	}
+
+	extra := vlessInbound("in-2", freePort(t), `{"decryption":"none","clients":[]}`)
+	extra.Listen = json_util.RawMessage(`"127.0.0.1"`)
+	data, _ := json.Marshal(extra)
+	if err := x.AddInbound(data); err != nil {
+		t.Errorf("add inbound failed: %v", err)
+	}
+	if err := x.DelInbound("in-2"); err != nil {
+		t.Errorf("delete inbound failed: %v", err)
+	}
+	if _, _, err := x.GetTraffic(true); err != nil {
+		t.Errorf("query traffic failed: %v", err)
+	}
+
+	// 内嵌模式下 API 端口仍可供外部工具通过 gRPC 访问
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", api.Port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := statsService.NewStatsServiceClient(conn).QueryStats(ctx, &statsService.QueryStatsRequest{}, grpc.WaitForReady(true)); err != nil {
+		t.Errorf("query stats over grpc failed: %v", err)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if p.IsRunning() || getEmbeddedInstance() != nil {
+		t.Error("embedded core still registered after stop")
+	}
+}
@@ -0,0 +1,153 @@
+package xray
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+
+	"x-ui/util/common"
+
+	"github.com/xtls/xray-core/app/router"
+	"google.golang.org/protobuf/proto"
+)
+
+// 地理数据文件类型
+const (
+	GeoTypeIP   = "geoip"
+	GeoTypeSite = "geosite"
+)
+
+// ParseGeoData 解析 geoip/geosite .dat 文件并返回其中的条目名，用于校验下载或上传的文件是否可被 Xray 加载
+func ParseGeoData(geoType string, data []byte) ([]string, error) {
+	var codes []string
+	switch geoType {
+	case GeoTypeIP:
+		list := &router.GeoIPList{}
+		if err := proto.Unmarshal(data, list); err != nil {
+			return nil, common.NewError("invalid geoip data:", err)
+		}
+		for _, entry := range list.GetEntry() {
+			codes = append(codes, entry.GetCountryCode())
+		}
+	case GeoTypeSite:
+		list := &router.GeoSiteList{}
+		if err := proto.Unmarshal(data, list); err != nil {
+			return nil, common.NewError("invalid geosite data:", err)
+		}
+		for _, entry := range list.GetEntry() {
+			codes = append(codes, entry.GetCountryCode())
+		}
+	default:
+		return nil, common.NewErrorf("unknown geo data type: %s", geoType)
+	}
+	if len(codes) == 0 {
+		return nil, common.NewErrorf("%s data contains no entries", geoType)
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// CompileGeoList 将纯文本列表编译为只包含一个条目 code 的 .dat 文件。
+// 每行一项，# 之后为注释；geoip 每行为 IP 或 CIDR，
+// geosite 每行为域名，可带 domain:/full:/keyword:/regexp: 前缀，无前缀时匹配域名及其子域名
+func CompileGeoList(geoType string, code string, content []byte) ([]byte, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, common.NewError("geo list code is required")
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, common.NewError("geo list is empty")
+	}
+
+	switch geoType {
+	case GeoTypeIP:
+		entry := &router.GeoIP{CountryCode: code}
+		for _, line := range lines {
+			cidr, err := parseGeoCIDR(line)
+			if err != nil {
+				return nil, err
+			}
+			entry.Cidr = append(entry.Cidr, cidr)
+		}
+		return proto.Marshal(&router.GeoIPList{Entry: []*router.GeoIP{entry}})
+	case GeoTypeSite:
+		entry := &router.GeoSite{CountryCode: code}
+		for _, line := range lines {
+			domain, err := parseGeoDomain(line)
+			if err != nil {
+				return nil, err
+			}
+			entry.Domain = append(entry.Domain, domain)
+		}
+		return proto.Marshal(&router.GeoSiteList{Entry: []*router.GeoSite{entry}})
+	default:
+		return nil, common.NewErrorf("unknown geo data type: %s", geoType)
+	}
+}
+
+func parseGeoCIDR(line string) (*router.CIDR, error) {
+	if !strings.Contains(line, "/") {
+		ip := net.ParseIP(line)
+		if ip == nil {
+			return nil, common.NewErrorf("invalid IP: %s", line)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &router.CIDR{Ip: ip4, Prefix: 32}, nil
+		}
+		return &router.CIDR{Ip: ip, Prefix: 128}, nil
+	}
+	_, network, err := net.ParseCIDR(line)
+	if err != nil {
+		return nil, common.NewErrorf("invalid CIDR: %s", line)
+	}
+	ones, _ := network.Mask.Size()
+	ip := network.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return &router.CIDR{Ip: ip, Prefix: uint32(ones)}, nil
+}
+
+func parseGeoDomain(line string) (*router.Domain, error) {
+	domainType := router.Domain_Domain
+	value := line
+	if prefix, rest, found := strings.Cut(line, ":"); found {
+		switch prefix {
+		case "domain":
+			domainType = router.Domain_Domain
+		case "full":
+			domainType = router.Domain_Full
+		case "keyword":
+			domainType = router.Domain_Plain
+		case "regexp":
+			domainType = router.Domain_Regex
+		default:
+			return nil, common.NewErrorf("invalid domain rule: %s", line)
+		}
+		value = rest
+	}
+	if value == "" || strings.ContainsAny(value, " \t") {
+		return nil, common.NewErrorf("invalid domain rule: %s", line)
+	}
+	if domainType != router.Domain_Regex {
+		value = strings.ToLower(value)
+	}
+	return &router.Domain{Type: domainType, Value: value}, nil
+}
@@ -0,0 +1,99 @@
+package xray
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/app/router"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCompileGeoListIP(t *testing.T) {
+	data, err := CompileGeoList(GeoTypeIP, "office", []byte("# office ranges\n10.0.0.0/8\n192.168.1.1 # gateway\n\n2001:db8::/32\n"))
+	if err != nil {
+		t.Fatalf("CompileGeoList: %v", err)
+	}
+	list := &router.GeoIPList{}
+	if err := proto.Unmarshal(data, list); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(list.Entry) != 1 || list.Entry[0].CountryCode != "OFFICE" {
+		t.Fatalf("unexpected entries: %+v", list.Entry)
+	}
+	cidrs := list.Entry[0].Cidr
+	if len(cidrs) != 3 {
+		t.Fatalf("got %d cidrs, want 3", len(cidrs))
+	}
+	if cidrs[0].Prefix != 8 || len(cidrs[0].Ip) != 4 {
+		t.Errorf("cidr[0] = %v/%d", cidrs[0].Ip, cidrs[0].Prefix)
+	}
+	if cidrs[1].Prefix != 32 {
+		t.Errorf("single IP prefix = %d, want 32", cidrs[1].Prefix)
+	}
+	if cidrs[2].Prefix != 32 || len(cidrs[2].Ip) != 16 {
+		t.Errorf("cidr[2] = %v/%d", cidrs[2].Ip, cidrs[2].Prefix)
+	}
+
+	codes, err := ParseGeoData(GeoTypeIP, data)
+	if err != nil || len(codes) != 1 || codes[0] != "OFFICE" {
+		t.Errorf("ParseGeoData = %v, %v", codes, err)
+	}
+}
+
+func TestCompileGeoListSite(t *testing.T) {
+	data, err := CompileGeoList(GeoTypeSite, "ads", []byte("Example.com\nfull:www.test.org\nkeyword:track\nregexp:^ad[0-9]+\\.\n"))
+	if err != nil {
+		t.Fatalf("CompileGeoList: %v", err)
+	}
+	list := &router.GeoSiteList{}
+	if err := proto.Unmarshal(data, list); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := []struct {
+		typ   router.Domain_Type
+		value string
+	}{
+		{router.Domain_Domain, "example.com"},
+		{router.Domain_Full, "www.test.org"},
+		{router.Domain_Plain, "track"},
+		{router.Domain_Regex, `^ad[0-9]+\.`},
+	}
+	domains := list.Entry[0].Domain
+	if len(domains) != len(want) {
+		t.Fatalf("got %d domains, want %d", len(domains), len(want))
+	}
+	for i, w := range want {
+		if domains[i].Type != w.typ || domains[i].Value != w.value {
+			t.Errorf("domain[%d] = %v %q, want %v %q", i, domains[i].Type, domains[i].Value, w.typ, w.value)
+		}
+	}
+}
+
+func TestCompileGeoListInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		geoType string
+		code    string
+		content string
+	}{
+		{"empty", GeoTypeIP, "X", "# only comments\n"},
+		{"no code", GeoTypeIP, " ", "10.0.0.0/8"},
+		{"bad ip", GeoTypeIP, "X", "10.0.0.300"},
+		{"bad prefix", GeoTypeSite, "X", "suffix:example.com"},
+		{"unknown type", "geofoo", "X", "example.com"},
+	}
+	for _, tc := range cases {
+		if _, err := CompileGeoList(tc.geoType, tc.code, []byte(tc.content)); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestParseGeoDataRejectsInvalid(t *testing.T) {
+	if _, err := ParseGeoData(GeoTypeSite, []byte("<html>not found</html>")); err == nil {
+		t.Error("expected error for non-protobuf data")
+	}
+	empty, _ := proto.Marshal(&router.GeoIPList{})
+	if _, err := ParseGeoData(GeoTypeIP, empty); err == nil {
+		t.Error("expected error for empty list")
+	}
+}
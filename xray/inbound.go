@@ -2,6 +2,9 @@ package xray
 
 import (
 	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
 
 	"x-ui/util/json_util"
 )
@@ -14,13 +17,60 @@ type InboundConfig struct {
 	StreamSettings json_util.RawMessage `json:"streamSettings"`
 	Tag            string               `json:"tag"`
 	Sniffing       json_util.RawMessage `json:"sniffing"`
+	// PortRange 监听端口范围，如 "10000-10010"。非空时替代 Port 写入配置，Port 保存范围起点
+	PortRange string `json:"-"`
+}
+
+// MarshalJSON 设置了 PortRange 时 port 以字符串形式输出端口范围
+func (c InboundConfig) MarshalJSON() ([]byte, error) {
+	type alias InboundConfig
+	if c.PortRange == "" {
+		return json.Marshal(alias(c))
+	}
+	return json.Marshal(struct {
+		alias
+		Port string `json:"port"`
+	}{alias(c), c.PortRange})
+}
+
+// UnmarshalJSON 兼容数字端口和字符串形式的端口或端口范围
+func (c *InboundConfig) UnmarshalJSON(data []byte) error {
+	type alias InboundConfig
+	aux := struct {
+		*alias
+		Port json.RawMessage `json:"port"`
+	}{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.Port, c.PortRange = 0, ""
+	if len(aux.Port) == 0 || string(aux.Port) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(aux.Port, &c.Port); err == nil {
+		return nil
+	}
+	var port string
+	if err := json.Unmarshal(aux.Port, &port); err != nil {
+		return err
+	}
+	start, _, isRange := strings.Cut(port, "-")
+	n, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil {
+		return err
+	}
+	c.Port = n
+	if isRange {
+		c.PortRange = port
+	}
+	return nil
 }
 
 func (c *InboundConfig) Equals(other *InboundConfig) bool {
 	if !bytes.Equal(c.Listen, other.Listen) {
 		return false
 	}
-	if c.Port != other.Port {
+	if c.Port != other.Port || c.PortRange != other.PortRange {
 		return false
 	}
 	if c.Protocol != other.Protocol {
@@ -3,7 +3,9 @@ package xray
 import (
 	"regexp"
 	"strings"
+	"sync"
 
+	"x-ui/config"
 	"x-ui/logger"
 )
 
@@ -13,6 +15,30 @@ func NewLogWriter() *LogWriter {
 
 type LogWriter struct {
 	lastLine string
+
+	// 最近的输出行，进程异常退出时写入崩溃报告
+	tailMu sync.Mutex
+	tail   []string
+}
+
+// Tail 返回最近的 Xray 输出行
+func (lw *LogWriter) Tail() []string {
+	lw.tailMu.Lock()
+	defer lw.tailMu.Unlock()
+	return append([]string(nil), lw.tail...)
+}
+
+func (lw *LogWriter) appendTail(message string) {
+	lw.tailMu.Lock()
+	defer lw.tailMu.Unlock()
+	for line := range strings.SplitSeq(message, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lw.tail = append(lw.tail, line)
+		}
+	}
+	if over := len(lw.tail) - config.XrayCrashLogTailLines; over > 0 {
+		lw.tail = append(lw.tail[:0:0], lw.tail[over:]...)
+	}
 }
 
 func (lw *LogWriter) Write(m []byte) (n int, err error) {
@@ -20,15 +46,12 @@ func (lw *LogWriter) Write(m []byte) (n int, err error) {
 
 	// Convert the data to a string
 	message := strings.TrimSpace(string(m))
+	lw.appendTail(message)
 
-	// Check if the message contains a crash
+	// Check if the message contains a crash; the report is written by the supervisor once the process exits
 	if crashRegex.MatchString(message) {
 		logger.Debug("Core crash detected:\n", message)
 		lw.lastLine = message
-		err1 := writeCrashReport(m)
-		if err1 != nil {
-			logger.Error("Unable to write crash report:", err1)
-		}
 		return len(m), nil
 	}
 
@@ -0,0 +1,73 @@
+package xray
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"x-ui/util/common"
+
+	observatoryService "github.com/xtls/xray-core/app/observatory/command"
+)
+
+// observatoryServiceName Xray API 中观测服务的名称
+const observatoryServiceName = "ObservatoryService"
+
+// OutboundStatus Xray 观测服务返回的单个出站探测结果
+type OutboundStatus struct {
+	Tag       string `json:"tag"`
+	Alive     bool   `json:"alive"`
+	Delay     int64  `json:"delay"`
+	LastError string `json:"lastError,omitempty"`
+	LastSeen  int64  `json:"lastSeen"`
+	LastTry   int64  `json:"lastTry"`
+}
+
+// HasObservatory 判断配置中是否启用了 observatory 或 burstObservatory
+func (c *Config) HasObservatory() bool {
+	for _, raw := range []json.RawMessage{json.RawMessage(c.Observatory), json.RawMessage(c.BurstObservatory)} {
+		if len(raw) > 0 && string(raw) != "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// EnableObservatoryService 配置了观测时确保 API 开启 ObservatoryService，以便面板读取探测结果。
+// 返回配置是否被修改
+func (c *Config) EnableObservatoryService() bool {
+	if !c.HasObservatory() {
+		return false
+	}
+	return c.enableAPIService(observatoryServiceName)
+}
+
+// GetOutboundStatus 通过观测服务获取各出站的存活状态和延迟
+func (x *XrayAPI) GetOutboundStatus() ([]*OutboundStatus, error) {
+	if x.ObservatoryClient == nil {
+		return nil, common.NewError("xray api not initialized")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := (*x.ObservatoryClient).GetOutboundStatus(ctx, &observatoryService.GetOutboundStatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+	result := resp.GetStatus()
+	if result == nil {
+		return nil, nil
+	}
+	statuses := make([]*OutboundStatus, 0, len(result.GetStatus()))
+	for _, st := range result.GetStatus() {
+		statuses = append(statuses, &OutboundStatus{
+			Tag:       st.GetOutboundTag(),
+			Alive:     st.GetAlive(),
+			Delay:     st.GetDelay(),
+			LastError: st.GetLastErrorReason(),
+			LastSeen:  st.GetLastSeenTime(),
+			LastTry:   st.GetLastTryTime(),
+		})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,24 @@
+package xray
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnableObservatoryService(t *testing.T) {
+	c := &Config{API: []byte(`{"tag":"api","services":["HandlerService","StatsService"]}`)}
+	if c.EnableObservatoryService() {
+		t.Fatal("service should not be enabled without observatory")
+	}
+
+	c.BurstObservatory = []byte(`{"subjectSelector":["proxy"]}`)
+	if !c.EnableObservatoryService() {
+		t.Fatal("expected service to be enabled")
+	}
+	if !strings.Contains(string(c.API), `"ObservatoryService"`) || !strings.Contains(string(c.API), `"tag":"api"`) {
+		t.Errorf("unexpected api section %s", c.API)
+	}
+	if c.EnableObservatoryService() {
+		t.Error("service should only be added once")
+	}
+}
@@ -0,0 +1,55 @@
+package xray
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"x-ui/util/common"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+)
+
+// GetOnlineClientIPs 通过 StatsService 的在线统计获取每个客户端当前在线的 IP 及其最后活跃时间。
+// 需要在策略中开启 statsUserOnline，不依赖访问日志
+func (x *XrayAPI) GetOnlineClientIPs() (map[string]map[string]time.Time, error) {
+	if !x.isConnected || x.StatsServiceClient == nil {
+		return nil, common.NewError("xray api is not initialized")
+	}
+	client := *x.StatsServiceClient
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users, err := client.GetAllOnlineUsers(ctx, &statsService.GetAllOnlineUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]time.Time, len(users.GetUsers()))
+	for _, name := range users.GetUsers() {
+		// 在线统计名称格式为 user>>>email>>>online
+		email, ok := strings.CutPrefix(name, "user>>>")
+		if !ok {
+			continue
+		}
+		email, ok = strings.CutSuffix(email, ">>>online")
+		if !ok || email == "" {
+			continue
+		}
+		resp, err := client.GetStatsOnlineIpList(ctx, &statsService.GetStatsRequest{Name: name})
+		if err != nil {
+			// 两次调用之间用户可能已下线
+			continue
+		}
+		ips := make(map[string]time.Time, len(resp.GetIps()))
+		for ip, seen := range resp.GetIps() {
+			if ip == "127.0.0.1" || ip == "::1" {
+				continue
+			}
+			ips[ip] = time.Unix(seen, 0)
+		}
+		if len(ips) > 0 {
+			result[email] = ips
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package xray
+
+import (
+	"context"
+	"testing"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"google.golang.org/grpc"
+)
+
+type fakeOnlineStatsClient struct {
+	statsService.StatsServiceClient
+	users []string
+	ips   map[string]map[string]int64
+}
+
+func (c *fakeOnlineStatsClient) GetAllOnlineUsers(context.Context, *statsService.GetAllOnlineUsersRequest, ...grpc.CallOption) (*statsService.GetAllOnlineUsersResponse, error) {
+	return &statsService.GetAllOnlineUsersResponse{Users: c.users}, nil
+}
+
+func (c *fakeOnlineStatsClient) GetStatsOnlineIpList(_ context.Context, in *statsService.GetStatsRequest, _ ...grpc.CallOption) (*statsService.GetStatsOnlineIpListResponse, error) {
+	return &statsService.GetStatsOnlineIpListResponse{Name: in.Name, Ips: c.ips[in.Name]}, nil
+}
+
+func TestGetOnlineClientIPs(t *testing.T) {
+	var client statsService.StatsServiceClient = &fakeOnlineStatsClient{
+		users: []string{"user>>>alice>>>online", "user>>>bob>>>online", "inbound>>>x>>>online"},
+		ips: map[string]map[string]int64{
+			"user>>>alice>>>online": {"1.2.3.4": 100, "5.6.7.8": 200},
+			"user>>>bob>>>online":   {"::1": 300},
+		},
+	}
+	x := &XrayAPI{StatsServiceClient: &client, isConnected: true}
+
+	online, err := x.GetOnlineClientIPs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(online) != 1 || len(online["alice"]) != 2 || online["alice"]["5.6.7.8"].Unix() != 200 {
+		t.Errorf("unexpected online clients: %v", online)
+	}
+
+	if _, err := (&XrayAPI{}).GetOnlineClientIPs(); err == nil {
+		t.Error("expected error without connection")
+	}
+}
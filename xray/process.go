@@ -5,17 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"x-ui/config"
 	"x-ui/logger"
 	"x-ui/util/common"
+
+	"github.com/xtls/xray-core/core"
 )
 
 func GetBinaryName() string {
@@ -96,8 +100,23 @@ func NewProcess(xrayConfig *Config) *Process {
 	return p
 }
 
+// NewEmbeddedProcess 创建在面板进程内运行 xray-core 的 Process
+func NewEmbeddedProcess(xrayConfig *Config) *Process {
+	p := &Process{newProcess(xrayConfig)}
+	p.embedded = true
+	runtime.SetFinalizer(p, stopProcess)
+	return p
+}
+
 type process struct {
-	cmd *exec.Cmd
+	// 外部进程模式下的 Xray 进程、退出通知及其输出管道的读端
+	proc    *os.Process
+	done    chan struct{}
+	logPipe *os.File
+
+	// 内嵌模式下运行的 xray-core 实例
+	embedded bool
+	instance atomic.Pointer[core.Instance]
 
 	version string
 	apiPort int
@@ -108,32 +127,64 @@ type process struct {
 	config    *Config
 	logWriter *LogWriter
 	exitErr   error
+	exitCode  atomic.Int32
 	startTime time.Time
 }
 
 func newProcess(config *Config) *process {
-	return &process{
+	p := &process{
 		version:   "Unknown",
 		config:    config,
 		logWriter: NewLogWriter(),
 		startTime: time.Now(),
 	}
+	p.exitCode.Store(-1)
+	return p
 }
 
 func (p *process) IsRunning() bool {
-	if p.cmd == nil || p.cmd.Process == nil {
+	if p.embedded {
+		return p.instance.Load() != nil
+	}
+	if p.proc == nil {
 		return false
 	}
-	if p.cmd.ProcessState == nil {
+	select {
+	case <-p.done:
+		return false
+	default:
 		return true
 	}
-	return false
+}
+
+// HasExited 判断进程是否已启动并退出（或启动失败）
+func (p *process) HasExited() bool {
+	// 外部进程的 exitErr 在退出通知之前写入，先判断退出通知
+	if p.proc != nil {
+		return !p.IsRunning()
+	}
+	return p.exitErr != nil
 }
 
 func (p *process) GetErr() error {
 	return p.exitErr
 }
 
+// ExitCode 返回进程的退出码，进程尚未退出或无法获取时返回 -1
+func (p *process) ExitCode() int {
+	return int(p.exitCode.Load())
+}
+
+// IsEmbedded 判断是否为内嵌模式
+func (p *process) IsEmbedded() bool {
+	return p.embedded
+}
+
+// GetLogTail 返回进程最近的输出行
+func (p *process) GetLogTail() []string {
+	return p.logWriter.Tail()
+}
+
 func (p *process) GetResult() string {
 	if len(p.logWriter.lastLine) == 0 && p.exitErr != nil {
 		return p.exitErr.Error()
@@ -150,9 +201,31 @@ func (p *Process) GetAPIPort() int {
 }
 
 func (p *Process) GetConfig() *Config {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 	return p.config
 }
 
+// SetConfig 在配置已通过 API 热更新后同步进程持有的配置，并重写 config.json，
+// 保证后续差异计算与崩溃重启都基于当前生效的配置
+func (p *Process) SetConfig(xrayConfig *Config) error {
+	p.mutex.Lock()
+	p.config = xrayConfig
+	p.mutex.Unlock()
+	return writeConfigFile(GetConfigPath(), xrayConfig)
+}
+
+func writeConfigFile(path string, xrayConfig *Config) error {
+	data, err := json.MarshalIndent(xrayConfig, "", "  ")
+	if err != nil {
+		return common.NewErrorf("Failed to generate XRAY configuration files: %v", err)
+	}
+	if err := os.WriteFile(path, data, fs.ModePerm); err != nil {
+		return common.NewErrorf("Failed to write configuration file: %v", err)
+	}
+	return nil
+}
+
 func (p *Process) GetOnlineClients() []string {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
@@ -208,41 +281,79 @@ func (p *process) Start() (err error) {
 		}
 	}()
 
-	data, err := json.MarshalIndent(p.config, "", "  ")
-	if err != nil {
-		return common.NewErrorf("Failed to generate XRAY configuration files: %v", err)
-	}
-
 	err = os.MkdirAll(config.GetLogFolder(), 0o750)
 	if err != nil {
 		logger.Warningf("Failed to create log folder: %s", err)
 	}
 
 	configPath := GetConfigPath()
-	err = os.WriteFile(configPath, data, fs.ModePerm)
-	if err != nil {
-		return common.NewErrorf("Failed to write configuration file: %v", err)
+	if err = writeConfigFile(configPath, p.config); err != nil {
+		return err
 	}
 
+	if p.embedded {
+		instance, err := startEmbeddedCore(p.config)
+		if err != nil {
+			return err
+		}
+		p.instance.Store(instance)
+		setEmbeddedInstance(instance)
+		p.version = core.Version()
+		p.refreshAPIPort()
+		return nil
+	}
+
+	// 输出管道由面板自行创建，升级替换面板进程时可以连同 Xray 进程一起交给新进程
+	logPipe, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
 	//nolint:gosec
 	cmd := exec.Command(GetBinaryPath(), "-c", configPath)
-	p.cmd = cmd
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		_ = logPipe.Close()
+		return err
+	}
+	p.track(cmd.Process, logPipe)
+
+	p.refreshVersion()
+	p.refreshAPIPort()
+
+	return nil
+}
 
-	cmd.Stdout = p.logWriter
-	cmd.Stderr = p.logWriter
+// track 转发进程输出到 logWriter 并在后台等待进程退出
+func (p *process) track(proc *os.Process, logPipe *os.File) {
+	p.proc = proc
+	p.logPipe = logPipe
+	p.done = make(chan struct{})
 
+	copied := make(chan struct{})
 	go func() {
-		err := cmd.Run()
+		defer close(copied)
+		_, _ = io.Copy(p.logWriter, logPipe)
+		_ = logPipe.Close()
+	}()
+	go func() {
+		defer close(p.done)
+		state, err := proc.Wait()
+		// 等待剩余输出写入 logWriter，崩溃报告需要完整的日志尾部
+		<-copied
+		if state != nil {
+			p.exitCode.Store(int32(state.ExitCode()))
+			if err == nil && !state.Success() {
+				err = &exec.ExitError{ProcessState: state}
+			}
+		}
 		if err != nil {
 			logger.Error("Failure in running xray-core:", err)
 			p.exitErr = err
 		}
 	}()
-
-	p.refreshVersion()
-	p.refreshAPIPort()
-
-	return nil
 }
 
 func (p *process) Stop() error {
@@ -250,11 +361,20 @@ func (p *process) Stop() error {
 		return errors.New("xray is not running")
 	}
 
+	if p.embedded {
+		instance := p.instance.Swap(nil)
+		if instance == nil {
+			return errors.New("xray is not running")
+		}
+		clearEmbeddedInstance(instance)
+		return instance.Close()
+	}
+
 	var err error
 	if runtime.GOOS == "windows" {
-		err = p.cmd.Process.Kill()
+		err = p.proc.Kill()
 	} else {
-		err = p.cmd.Process.Signal(syscall.SIGTERM)
+		err = p.proc.Signal(syscall.SIGTERM)
 	}
 
 	if err != nil {
@@ -262,24 +382,14 @@ func (p *process) Stop() error {
 	}
 
 	// Wait for the process to exit with a timeout
-	done := make(chan error, 1)
-	go func() {
-		done <- p.cmd.Wait()
-	}()
-
 	select {
-	case <-done:
+	case <-p.done:
 		return nil
 	case <-time.After(2 * time.Second):
 		if p.IsRunning() {
 			logger.Warning("Xray process did not stop in time, killing it...")
-			return p.cmd.Process.Kill()
+			return p.proc.Kill()
 		}
 		return nil
 	}
 }
-
-func writeCrashReport(m []byte) error {
-	crashReportPath := config.GetBinFolderPath() + "/core_crash_" + time.Now().Format("20060102_150405") + ".log"
-	return os.WriteFile(crashReportPath, m, 0o600)
-}
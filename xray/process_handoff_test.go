@@ -0,0 +1,114 @@
+//go:build !windows
+
+package xray
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"x-ui/config"
+)
+
+// fakeXray 在临时 bin 目录下写入模拟 Xray 的脚本
+func fakeXray(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"-version\" ]; then echo 'Xray 1.2.3 (test)'; exit 0; fi\n" +
+		"while true; do echo tick; sleep 0.1; done\n"
+	if err := os.WriteFile(GetBinaryPath(), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return GetBinaryPath()
+}
+
+func TestProcess_Handoff(t *testing.T) {
+	fakeXray(t)
+	p := NewProcess(&Config{})
+	if err := p.Start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	defer func() { _ = p.Stop() }()
+
+	env, f, err := p.Handoff()
+	if err != nil {
+		t.Fatalf("handoff failed: %v", err)
+	}
+	defer f.Close()
+	want := fmt.Sprintf("%s=%d:%d", envInheritedProcess, p.proc.Pid, f.Fd())
+	if env != want {
+		t.Errorf("expected %q, got %q", want, env)
+	}
+
+	embedded := NewEmbeddedProcess(&Config{})
+	if _, _, err := embedded.Handoff(); err == nil {
+		t.Error("embedded core should not be handed off")
+	}
+}
+
+func TestAdoptProcess(t *testing.T) {
+	binary := fakeXray(t)
+	if err := writeConfigFile(GetConfigPath(), &Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(envInheritedProcess, "")
+	if AdoptProcess() != nil {
+		t.Error("nothing should be adopted without an inherited process")
+	}
+
+	// 模拟升级前的面板进程启动的 Xray
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary)
+	cmd.Stdout = w
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+
+	t.Setenv(envInheritedProcess, fmt.Sprintf("%d:%d", cmd.Process.Pid, fd))
+	p := AdoptProcess()
+	if p == nil {
+		_ = cmd.Process.Kill()
+		t.Fatal("running xray should be adopted")
+	}
+	if os.Getenv(envInheritedProcess) != "" {
+		t.Error("inherited spec should be consumed")
+	}
+	if !p.IsRunning() || p.GetVersion() != "1.2.3" {
+		t.Errorf("unexpected adopted process state: running=%v version=%s", p.IsRunning(), p.GetVersion())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.ContainsFunc(p.GetLogTail(), func(line string) bool { return strings.Contains(line, "tick") }) {
+		if time.Now().After(deadline) {
+			t.Fatal("output of adopted process is not forwarded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if p.IsRunning() || !p.HasExited() {
+		t.Error("adopted process should be stopped")
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+
+package xray
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"x-ui/logger"
+)
+
+// envInheritedProcess 面板升级替换进程时用于传递外部 Xray 进程的环境变量
+// 格式: "pid:fd"，fd 为 Xray 输出管道的读端
+const envInheritedProcess = "XUI_INHERITED_XRAY"
+
+// Handoff 复制 Xray 输出管道的读端，返回需要传递给 exec 后新面板进程的环境变量和文件。
+// 复制出的描述符带有 CLOEXEC 标记，由调用方在 exec 前清除；exec 失败时调用方负责关闭文件。
+// 内嵌模式的 xray-core 运行在面板进程内，无法交接
+func (p *Process) Handoff() (string, *os.File, error) {
+	if p.embedded || !p.IsRunning() || p.logPipe == nil {
+		return "", nil, errors.New("xray process cannot be handed off")
+	}
+	raw, err := p.logPipe.SyscallConn()
+	if err != nil {
+		return "", nil, err
+	}
+	var fd int
+	var dupErr error
+	if err := raw.Control(func(f uintptr) {
+		fd, dupErr = syscall.Dup(int(f))
+	}); err != nil {
+		return "", nil, err
+	}
+	if dupErr != nil {
+		return "", nil, dupErr
+	}
+	syscall.CloseOnExec(fd)
+	env := fmt.Sprintf("%s=%d:%d", envInheritedProcess, p.proc.Pid, fd)
+	return env, os.NewFile(uintptr(fd), "xray-output"), nil
+}
+
+// AdoptProcess 接管升级前的面板进程交接下来的 Xray 进程，没有可接管的进程时返回 nil。
+// exec 后面板的 PID 不变，Xray 仍是面板的子进程；接管后以磁盘上的 config.json 作为当前配置，
+// 后续按差异热更新或重启
+func AdoptProcess() *Process {
+	spec := os.Getenv(envInheritedProcess)
+	if spec == "" {
+		return nil
+	}
+	_ = os.Unsetenv(envInheritedProcess)
+
+	pidStr, fdStr, _ := strings.Cut(spec, ":")
+	pid, err1 := strconv.Atoi(pidStr)
+	fd, err2 := strconv.Atoi(fdStr)
+	if err1 != nil || err2 != nil {
+		logger.Warning("Invalid inherited xray process:", spec)
+		return nil
+	}
+	logPipe := os.NewFile(uintptr(fd), "xray-output")
+	if logPipe == nil {
+		return nil
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err == nil {
+		err = proc.Signal(syscall.Signal(0))
+	}
+	if err != nil {
+		logger.Warningf("Inherited xray process %d is gone: %v", pid, err)
+		_ = logPipe.Close()
+		return nil
+	}
+
+	xrayConfig, err := readConfigFile(GetConfigPath())
+	if err != nil {
+		// 无法确认正在运行的配置时结束旧进程，由调用方重新启动
+		logger.Warningf("Failed to load config of inherited xray process %d, stopping it: %v", pid, err)
+		_ = proc.Kill()
+		_, _ = proc.Wait()
+		_ = logPipe.Close()
+		return nil
+	}
+
+	p := NewProcess(xrayConfig)
+	p.track(proc, logPipe)
+	p.refreshVersion()
+	p.refreshAPIPort()
+	logger.Infof("Adopted running xray process %d", pid)
+	return p
+}
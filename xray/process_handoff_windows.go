@@ -0,0 +1,18 @@
+//go:build windows
+
+package xray
+
+import (
+	"errors"
+	"os"
+)
+
+// Handoff Windows 不支持在替换面板进程时交接 Xray 进程
+func (p *Process) Handoff() (string, *os.File, error) {
+	return "", nil, errors.New("xray process handoff is not supported on windows")
+}
+
+// AdoptProcess Windows 上没有可接管的 Xray 进程
+func AdoptProcess() *Process {
+	return nil
+}
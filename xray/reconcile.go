@@ -0,0 +1,253 @@
+package xray
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// hotUserProtocols 支持通过 AlterInbound 动态增删用户的协议
+var hotUserProtocols = map[string]bool{
+	"vmess":       true,
+	"vless":       true,
+	"trojan":      true,
+	"shadowsocks": true,
+}
+
+// UserChange 描述某个入站中需要增删的单个用户
+type UserChange struct {
+	Tag      string         `json:"tag"`
+	Protocol string         `json:"protocol"`
+	Email    string         `json:"email"`
+	User     map[string]any `json:"-"`
+}
+
+// ConfigDiff 运行中配置与期望配置之间的差异
+// 入站与用户的变更可以通过 gRPC Handler API 热更新，其余部分的变更只能重启 Xray 生效。
+type ConfigDiff struct {
+	RemoveInbounds []string        `json:"removeInbounds,omitempty"`
+	AddInbounds    []InboundConfig `json:"-"`
+	RemoveUsers    []UserChange    `json:"removeUsers,omitempty"`
+	AddUsers       []UserChange    `json:"addUsers,omitempty"`
+	// Routing 仅路由规则或负载均衡器变化且运行中的 Xray 开启了 RoutingService 时，需要整体替换的路由配置
+	Routing        json.RawMessage `json:"-"`
+	RestartReasons []string        `json:"restartReasons,omitempty"`
+}
+
+// IsEmpty 判断两份配置是否完全一致
+func (d *ConfigDiff) IsEmpty() bool {
+	return len(d.RemoveInbounds) == 0 && len(d.AddInbounds) == 0 &&
+		len(d.RemoveUsers) == 0 && len(d.AddUsers) == 0 && len(d.Routing) == 0 && len(d.RestartReasons) == 0
+}
+
+// NeedRestart 判断差异中是否包含无法热更新的部分
+func (d *ConfigDiff) NeedRestart() bool {
+	return len(d.RestartReasons) > 0
+}
+
+// AddInboundTags 返回需要新增的入站标签，便于记录和展示
+func (d *ConfigDiff) AddInboundTags() []string {
+	tags := make([]string, 0, len(d.AddInbounds))
+	for _, inbound := range d.AddInbounds {
+		tags = append(tags, inbound.Tag)
+	}
+	return tags
+}
+
+// DiffConfig 比较运行中的配置与期望配置，计算出需要执行的热更新操作
+func DiffConfig(running, desired *Config) *ConfigDiff {
+	diff := &ConfigDiff{}
+
+	sections := []struct {
+		name      string
+		old, want []byte
+	}{
+		{"log", running.LogConfig, desired.LogConfig},
+		{"routing", running.RouterConfig, desired.RouterConfig},
+		{"dns", running.DNSConfig, desired.DNSConfig},
+		{"outbounds", running.OutboundConfigs, desired.OutboundConfigs},
+		{"transport", running.Transport, desired.Transport},
+		{"policy", running.Policy, desired.Policy},
+		{"api", running.API, desired.API},
+		{"stats", running.Stats, desired.Stats},
+		{"reverse", running.Reverse, desired.Reverse},
+		{"fakedns", running.FakeDNS, desired.FakeDNS},
+		{"observatory", running.Observatory, desired.Observatory},
+		{"burstObservatory", running.BurstObservatory, desired.BurstObservatory},
+		{"metrics", running.Metrics, desired.Metrics},
+	}
+	for _, section := range sections {
+		if bytes.Equal(section.old, section.want) {
+			continue
+		}
+		if section.name == "routing" && running.HasAPIService(routingServiceName) &&
+			routingReloadable(section.old, section.want) {
+			diff.Routing = json.RawMessage(section.want)
+			continue
+		}
+		diff.RestartReasons = append(diff.RestartReasons, section.name+" changed")
+	}
+
+	oldInbounds := make(map[string]*InboundConfig, len(running.InboundConfigs))
+	for i := range running.InboundConfigs {
+		inbound := &running.InboundConfigs[i]
+		oldInbounds[inbound.Tag] = inbound
+	}
+	newTags := make(map[string]bool, len(desired.InboundConfigs))
+
+	for i := range desired.InboundConfigs {
+		inbound := &desired.InboundConfigs[i]
+		newTags[inbound.Tag] = true
+		old, ok := oldInbounds[inbound.Tag]
+		switch {
+		case !ok:
+			if inbound.Tag == "api" || inbound.Tag == "" {
+				diff.RestartReasons = append(diff.RestartReasons, fmt.Sprintf("inbound %q added", inbound.Tag))
+				continue
+			}
+			diff.AddInbounds = append(diff.AddInbounds, *inbound)
+		case old.Equals(inbound):
+			continue
+		case inbound.Tag == "api":
+			// api 入站承载 gRPC 连接本身，不能通过 API 自行替换
+			diff.RestartReasons = append(diff.RestartReasons, "api inbound changed")
+		default:
+			if removed, added, ok := diffClients(old, inbound); ok {
+				diff.RemoveUsers = append(diff.RemoveUsers, removed...)
+				diff.AddUsers = append(diff.AddUsers, added...)
+				continue
+			}
+			diff.RemoveInbounds = append(diff.RemoveInbounds, inbound.Tag)
+			diff.AddInbounds = append(diff.AddInbounds, *inbound)
+		}
+	}
+
+	for i := range running.InboundConfigs {
+		tag := running.InboundConfigs[i].Tag
+		if newTags[tag] {
+			continue
+		}
+		if tag == "api" || tag == "" {
+			diff.RestartReasons = append(diff.RestartReasons, fmt.Sprintf("inbound %q removed", tag))
+			continue
+		}
+		diff.RemoveInbounds = append(diff.RemoveInbounds, tag)
+	}
+
+	return diff
+}
+
+// diffClients 当两个入站仅 settings.clients 不同时，按 email 计算用户的增删；
+// 第三个返回值为 false 表示变更无法通过用户操作完成，需要整体替换入站
+func diffClients(old, desired *InboundConfig) ([]UserChange, []UserChange, bool) {
+	if !hotUserProtocols[desired.Protocol] || old.Protocol != desired.Protocol ||
+		old.Port != desired.Port ||
+		!bytes.Equal(old.Listen, desired.Listen) ||
+		!bytes.Equal(old.StreamSettings, desired.StreamSettings) ||
+		!bytes.Equal(old.Sniffing, desired.Sniffing) {
+		return nil, nil, false
+	}
+
+	var oldSettings, newSettings map[string]any
+	if json.Unmarshal(old.Settings, &oldSettings) != nil || json.Unmarshal(desired.Settings, &newSettings) != nil {
+		return nil, nil, false
+	}
+	oldClients, ok1 := clientsByEmail(oldSettings["clients"])
+	newClients, ok2 := clientsByEmail(newSettings["clients"])
+	if !ok1 || !ok2 {
+		return nil, nil, false
+	}
+	delete(oldSettings, "clients")
+	delete(newSettings, "clients")
+	if !reflect.DeepEqual(oldSettings, newSettings) {
+		return nil, nil, false
+	}
+
+	cipher, _ := newSettings["method"].(string)
+	// shadowsocks 2022 的服务端密钥位于 settings 中，多用户模式下用户使用各自的密钥
+	if desired.Protocol == "shadowsocks" && cipher != "" && !legacyShadowsocksCipher(cipher) {
+		return nil, nil, false
+	}
+
+	var removed, added []UserChange
+	for email, client := range oldClients {
+		if newClient, ok := newClients[email]; !ok || !reflect.DeepEqual(client, newClient) {
+			removed = append(removed, UserChange{Tag: desired.Tag, Protocol: desired.Protocol, Email: email})
+		}
+	}
+	for email, client := range newClients {
+		if oldClient, ok := oldClients[email]; !ok || !reflect.DeepEqual(client, oldClient) {
+			added = append(added, UserChange{
+				Tag:      desired.Tag,
+				Protocol: desired.Protocol,
+				Email:    email,
+				User:     apiUser(client, cipher),
+			})
+		}
+	}
+	sortUserChanges(removed)
+	sortUserChanges(added)
+	return removed, added, true
+}
+
+// clientsByEmail 以 email 为键索引客户端，缺少 email 或出现重复时返回 false
+func clientsByEmail(raw any) (map[string]map[string]any, bool) {
+	result := make(map[string]map[string]any)
+	if raw == nil {
+		return result, true
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, false
+	}
+	for _, item := range list {
+		client, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		email, _ := client["email"].(string)
+		if email == "" {
+			return nil, false
+		}
+		if _, dup := result[email]; dup {
+			return nil, false
+		}
+		result[email] = client
+	}
+	return result, true
+}
+
+// apiUser 将配置中的客户端转换为 XrayAPI.AddUser 所需的参数，保证所有键都存在
+func apiUser(client map[string]any, cipher string) map[string]any {
+	str := func(key string) string {
+		v, _ := client[key].(string)
+		return v
+	}
+	level := 0
+	if v, ok := client["level"].(float64); ok {
+		level = int(v)
+	}
+	return map[string]any{
+		"email":    str("email"),
+		"id":       str("id"),
+		"flow":     str("flow"),
+		"password": str("password"),
+		"cipher":   cipher,
+		"level":    level,
+	}
+}
+
+func legacyShadowsocksCipher(cipher string) bool {
+	switch cipher {
+	case "aes-128-gcm", "aes-256-gcm", "chacha20-poly1305", "chacha20-ietf-poly1305",
+		"xchacha20-poly1305", "xchacha20-ietf-poly1305":
+		return true
+	}
+	return false
+}
+
+func sortUserChanges(changes []UserChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Email < changes[j].Email })
+}
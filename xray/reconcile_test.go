@@ -0,0 +1,135 @@
+package xray
+
+import (
+	"testing"
+
+	"x-ui/util/json_util"
+)
+
+func vlessInbound(tag string, port int, settings string) InboundConfig {
+	return InboundConfig{
+		Listen:         json_util.RawMessage(`"0.0.0.0"`),
+		Port:           port,
+		Protocol:       "vless",
+		Settings:       json_util.RawMessage(settings),
+		StreamSettings: json_util.RawMessage(`{"network":"tcp"}`),
+		Tag:            tag,
+	}
+}
+
+func baseConfig(inbounds ...InboundConfig) *Config {
+	api := InboundConfig{Port: 62789, Protocol: "tunnel", Tag: "api", Listen: json_util.RawMessage(`"127.0.0.1"`)}
+	return &Config{
+		RouterConfig:   json_util.RawMessage(`{"rules":[]}`),
+		Policy:         json_util.RawMessage(`{"levels":{}}`),
+		InboundConfigs: append([]InboundConfig{api}, inbounds...),
+	}
+}
+
+func TestDiffConfig_Identical(t *testing.T) {
+	a := baseConfig(vlessInbound("in-1", 443, `{"clients":[{"email":"a","id":"1"}]}`))
+	b := baseConfig(vlessInbound("in-1", 443, `{"clients":[{"email":"a","id":"1"}]}`))
+	if diff := DiffConfig(a, b); !diff.IsEmpty() {
+		t.Errorf("expected empty diff, got %+v", diff)
+	}
+}
+
+func TestDiffConfig_ClientChanges(t *testing.T) {
+	running := baseConfig(vlessInbound("in-1", 443,
+		`{"decryption":"none","clients":[{"email":"a","id":"1"},{"email":"b","id":"2"}]}`))
+	desired := baseConfig(vlessInbound("in-1", 443,
+		`{"decryption":"none","clients":[{"email":"a","id":"1"},{"email":"b","id":"3","flow":"xtls-rprx-vision"},{"email":"c","id":"4","level":1024}]}`))
+
+	diff := DiffConfig(running, desired)
+	if diff.NeedRestart() || len(diff.AddInbounds) != 0 || len(diff.RemoveInbounds) != 0 {
+		t.Fatalf("expected user-only diff, got %+v", diff)
+	}
+	if len(diff.RemoveUsers) != 1 || diff.RemoveUsers[0].Email != "b" {
+		t.Errorf("expected b removed, got %+v", diff.RemoveUsers)
+	}
+	if len(diff.AddUsers) != 2 || diff.AddUsers[0].Email != "b" || diff.AddUsers[1].Email != "c" {
+		t.Fatalf("expected b and c added, got %+v", diff.AddUsers)
+	}
+	user := diff.AddUsers[0].User
+	for _, key := range []string{"email", "id", "flow", "password", "cipher"} {
+		if _, ok := user[key].(string); !ok {
+			t.Errorf("user map missing string key %q", key)
+		}
+	}
+	if user["flow"] != "xtls-rprx-vision" {
+		t.Errorf("unexpected flow %v", user["flow"])
+	}
+	if diff.AddUsers[1].User["level"] != 1024 {
+		t.Errorf("expected level 1024, got %v", diff.AddUsers[1].User["level"])
+	}
+}
+
+func TestDiffConfig_InboundChanges(t *testing.T) {
+	running := baseConfig(
+		vlessInbound("in-1", 443, `{"clients":[]}`),
+		vlessInbound("in-2", 8443, `{"clients":[]}`),
+	)
+	desired := baseConfig(
+		vlessInbound("in-1", 444, `{"clients":[]}`),
+		vlessInbound("in-3", 9443, `{"clients":[]}`),
+	)
+
+	diff := DiffConfig(running, desired)
+	if diff.NeedRestart() {
+		t.Fatalf("unexpected restart reasons %v", diff.RestartReasons)
+	}
+	if got := diff.RemoveInbounds; len(got) != 2 || got[0] != "in-1" || got[1] != "in-2" {
+		t.Errorf("unexpected removed inbounds %v", got)
+	}
+	if got := diff.AddInboundTags(); len(got) != 2 || got[0] != "in-1" || got[1] != "in-3" {
+		t.Errorf("unexpected added inbounds %v", got)
+	}
+}
+
+func TestDiffConfig_NeedsRestart(t *testing.T) {
+	running := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+
+	desired := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	desired.RouterConfig = json_util.RawMessage(`{"rules":[{"outboundTag":"blocked"}]}`)
+	if diff := DiffConfig(running, desired); !diff.NeedRestart() {
+		t.Error("expected routing change to require restart")
+	}
+
+	desired = baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	desired.InboundConfigs[0].Port = 10085
+	if diff := DiffConfig(running, desired); !diff.NeedRestart() {
+		t.Error("expected api inbound change to require restart")
+	}
+}
+
+func TestDiffConfig_Shadowsocks2022ReplacesInbound(t *testing.T) {
+	ss := func(settings string) InboundConfig {
+		return InboundConfig{Port: 8388, Protocol: "shadowsocks", Tag: "ss", Settings: json_util.RawMessage(settings)}
+	}
+	running := baseConfig(ss(`{"method":"2022-blake3-aes-128-gcm","password":"k","clients":[{"email":"a","password":"x"}]}`))
+	desired := baseConfig(ss(`{"method":"2022-blake3-aes-128-gcm","password":"k","clients":[{"email":"a","password":"y"}]}`))
+
+	diff := DiffConfig(running, desired)
+	if len(diff.AddUsers) != 0 || len(diff.RemoveInbounds) != 1 || len(diff.AddInbounds) != 1 {
+		t.Errorf("expected inbound replacement for ss2022, got %+v", diff)
+	}
+}
+
+func TestDiffConfig_RoutingReload(t *testing.T) {
+	running := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	running.API = json_util.RawMessage(`{"tag":"api","services":["HandlerService","RoutingService"]}`)
+	running.RouterConfig = json_util.RawMessage(`{"domainStrategy":"AsIs","rules":[]}`)
+
+	desired := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	desired.API = running.API
+	desired.RouterConfig = json_util.RawMessage(`{"domainStrategy":"AsIs","rules":[{"type":"field","user":["a"],"outboundTag":"blocked"}]}`)
+	diff := DiffConfig(running, desired)
+	if diff.NeedRestart() || len(diff.Routing) == 0 || diff.IsEmpty() {
+		t.Errorf("expected rule change to be hot reloaded, got %+v", diff)
+	}
+
+	desired.RouterConfig = json_util.RawMessage(`{"domainStrategy":"IPIfNonMatch","rules":[]}`)
+	if diff := DiffConfig(running, desired); !diff.NeedRestart() {
+		t.Error("expected domainStrategy change to require restart")
+	}
+}
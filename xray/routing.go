@@ -0,0 +1,98 @@
+package xray
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"x-ui/util/common"
+
+	routerService "github.com/xtls/xray-core/app/router/command"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/infra/conf"
+)
+
+// routingServiceName Xray API 中路由服务的名称
+const routingServiceName = "RoutingService"
+
+// HasAPIService 判断 API 是否开启了指定服务
+func (c *Config) HasAPIService(name string) bool {
+	if len(c.API) == 0 {
+		return false
+	}
+	var api map[string]any
+	if err := json.Unmarshal(c.API, &api); err != nil {
+		return false
+	}
+	services, _ := api["services"].([]any)
+	for _, service := range services {
+		if s, ok := service.(string); ok && s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enableAPIService 确保 API 开启指定服务，返回配置是否被修改。模板没有 api 部分时不做处理
+func (c *Config) enableAPIService(name string) bool {
+	if len(c.API) == 0 || c.HasAPIService(name) {
+		return false
+	}
+	var api map[string]any
+	if err := json.Unmarshal(c.API, &api); err != nil {
+		return false
+	}
+	services, _ := api["services"].([]any)
+	api["services"] = append(services, name)
+	data, err := json.Marshal(api)
+	if err != nil {
+		return false
+	}
+	c.API = data
+	return true
+}
+
+// EnableRoutingService 确保 API 开启 RoutingService，路由规则变更时即可热更新而无需重启 Xray。
+// 返回配置是否被修改
+func (c *Config) EnableRoutingService() bool {
+	return c.enableAPIService(routingServiceName)
+}
+
+// routingReloadable 判断两份路由配置是否只有 rules 和 balancers 不同，
+// 这两部分可以通过 RoutingService 整体替换，其余字段（如 domainStrategy）变更仍需重启
+func routingReloadable(old, desired []byte) bool {
+	var oldRouting, newRouting map[string]any
+	if json.Unmarshal(old, &oldRouting) != nil || json.Unmarshal(desired, &newRouting) != nil {
+		return false
+	}
+	for _, key := range []string{"rules", "balancers"} {
+		delete(oldRouting, key)
+		delete(newRouting, key)
+	}
+	return reflect.DeepEqual(oldRouting, newRouting)
+}
+
+// ReloadRouting 用新的路由配置替换运行中 Xray 的全部路由规则和负载均衡器
+func (x *XrayAPI) ReloadRouting(routing []byte) error {
+	if x.RoutingServiceClient == nil {
+		return common.NewError("xray routing service is not available")
+	}
+	routerConfig := new(conf.RouterConfig)
+	if err := json.Unmarshal(routing, routerConfig); err != nil {
+		return common.NewError("invalid routing config:", err)
+	}
+	config, err := routerConfig.Build()
+	if err != nil {
+		return common.NewError("failed to build routing config:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := *x.RoutingServiceClient
+	_, err = client.AddRule(ctx, &routerService.AddRuleRequest{
+		Config:       serial.ToTypedMessage(config),
+		ShouldAppend: false,
+	})
+	return err
+}
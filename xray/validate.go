@@ -0,0 +1,124 @@
+package xray
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"x-ui/config"
+	"x-ui/util/common"
+
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/infra/conf"
+)
+
+var assetLocationOnce sync.Once
+
+// GetLastGoodConfigPath 返回最近一次成功启动的配置文件路径
+func GetLastGoodConfigPath() string {
+	return config.GetBinFolderPath() + "/config.lastgood.json"
+}
+
+// ValidateConfig 使用 xray-core 的 infra/conf 构建器逐段构建配置，
+// 可以在不启动进程的情况下发现入站、出站、路由、DNS 等配置错误
+func ValidateConfig(c *Config) error {
+	_, err := buildCoreConfig(c)
+	return err
+}
+
+// buildCoreConfig 将面板配置构建为 xray-core 的 protobuf 配置
+func buildCoreConfig(c *Config) (*core.Config, error) {
+	// 路由中的 geosite/geoip 规则需要从面板的 bin 目录加载数据文件
+	assetLocationOnce.Do(func() {
+		if _, ok := os.LookupEnv("XRAY_LOCATION_ASSET"); !ok {
+			_ = os.Setenv("XRAY_LOCATION_ASSET", config.GetBinFolderPath())
+		}
+	})
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, common.NewErrorf("failed to marshal xray config: %v", err)
+	}
+	coreConfig := &conf.Config{}
+	if err := json.Unmarshal(data, coreConfig); err != nil {
+		return nil, common.NewErrorf("invalid xray config: %v", err)
+	}
+	built, err := coreConfig.Build()
+	if err != nil {
+		return nil, common.NewErrorf("invalid xray config: %v", err)
+	}
+	return built, nil
+}
+
+// TestConfig 使用 Xray 二进制的测试模式校验配置，二进制不存在时跳过
+func TestConfig(c *Config) error {
+	binary := GetBinaryPath()
+	if _, err := os.Stat(binary); err != nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return common.NewErrorf("failed to marshal xray config: %v", err)
+	}
+	file, err := os.CreateTemp("", "xray-test-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	_ = file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.XrayConfigTestTimeout)
+	defer cancel()
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, binary, "run", "-test", "-c", file.Name())
+	cmd.Env = append(os.Environ(), "XRAY_LOCATION_ASSET="+config.GetBinFolderPath())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return common.NewErrorf("xray config test failed: %s", lastLines(string(output), 5, err))
+	}
+	return nil
+}
+
+// SaveLastGoodConfig 保存已确认可以正常启动的配置，用于启动失败时回滚
+func SaveLastGoodConfig(c *Config) error {
+	return writeConfigFile(GetLastGoodConfigPath(), c)
+}
+
+// LoadLastGoodConfig 读取最近一次成功启动的配置
+func LoadLastGoodConfig() (*Config, error) {
+	return readConfigFile(GetLastGoodConfigPath())
+}
+
+// readConfigFile 读取并解析 Xray 配置文件
+func readConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// lastLines 截取命令输出的最后几行作为错误信息
+func lastLines(output string, n int, fallback error) string {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return fallback.Error()
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
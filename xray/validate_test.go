@@ -0,0 +1,63 @@
+package xray
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"x-ui/config"
+	"x-ui/util/json_util"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := baseConfig(vlessInbound("in-1", 443, `{"decryption":"none","clients":[{"id":"b831381d-6324-4d53-ad4f-8cda48b30811","email":"a"}]}`))
+	valid.OutboundConfigs = json_util.RawMessage(`[{"protocol":"freedom","tag":"direct"}]`)
+	valid.InboundConfigs[0].Settings = json_util.RawMessage(`{"address":"127.0.0.1"}`)
+	if err := ValidateConfig(valid); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	badProtocol := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	badProtocol.InboundConfigs[0].Settings = json_util.RawMessage(`{"address":"127.0.0.1"}`)
+	badProtocol.InboundConfigs[1].Protocol = "no-such-protocol"
+	if err := ValidateConfig(badProtocol); err == nil {
+		t.Error("expected unknown protocol to be rejected")
+	}
+
+	badRouting := baseConfig()
+	badRouting.InboundConfigs[0].Settings = json_util.RawMessage(`{"address":"127.0.0.1"}`)
+	badRouting.RouterConfig = json_util.RawMessage(`{"domainStrategy":"NoSuchStrategy","rules":[{"type":"field","port":"abc","outboundTag":"direct"}]}`)
+	if err := ValidateConfig(badRouting); err == nil {
+		t.Error("expected invalid routing rule to be rejected")
+	}
+}
+
+func TestLastGoodConfig_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XUI_BIN_FOLDER", dir)
+	config.RefreshEnvConfig()
+	t.Cleanup(config.RefreshEnvConfig)
+
+	if _, err := LoadLastGoodConfig(); err == nil {
+		t.Fatal("expected error when no last good config exists")
+	}
+
+	c := baseConfig(vlessInbound("in-1", 443, `{"clients":[]}`))
+	if err := SaveLastGoodConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.lastgood.json")); err != nil {
+		t.Fatalf("last good config not written: %v", err)
+	}
+	loaded, err := LoadLastGoodConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(c)
+	got, _ := json.Marshal(loaded)
+	if !bytes.Equal(want, got) {
+		t.Error("loaded config differs from saved config")
+	}
+}